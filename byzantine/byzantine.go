@@ -0,0 +1,150 @@
+// Package byzantine implements a library of Byzantine Behaviours for
+// simulated Processes (see sim.Behaviour). Each Behaviour is built on an
+// honest Process: the Process runs the consensus algorithm as usual, and the
+// Behaviour changes the messages that it sends, or the State that it keeps.
+// This means that Byzantine Processes stay in step with honest Processes, and
+// misbehave at the moments that matter most.
+//
+// Most Behaviours are caught by the Catchers of honest Processes:
+//
+//	EquivocatingProposer	EvidenceTypeDoublePropose
+//	DoublePrevoter		EvidenceTypeDoublePrevote
+//	DoublePrecommitter	EvidenceTypeDoublePrecommit
+//	OutOfTurnProposer	EvidenceTypeOutOfTurnPropose
+//	FutureRoundSpammer	EvidenceTypeOutOfWindow (when there is a round window)
+//
+// VoteWithholder and Amnesiac cannot be caught, because their messages are not
+// conflicting on their own. In all cases, honest Processes must stay safe, as
+// long as there are at most f Byzantine Processes.
+package byzantine
+
+import (
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/hyperdrive/process/processutil"
+	"github.com/renproject/hyperdrive/sim"
+)
+
+// EquivocatingProposer returns a Behaviour that proposes two different valid
+// Values whenever the Process is the proposer. Both Proposes are sent to every
+// Process, and the order in which they arrive depends on the network, so
+// honest Processes can prevote for different Values.
+func EquivocatingProposer() sim.Behaviour {
+	return sim.BehaviourFunc(func(node sim.Node, msg interface{}) []sim.Delivery {
+		propose, ok := msg.(process.Propose)
+		if !ok {
+			return node.Broadcast(msg)
+		}
+		conflicting := propose
+		conflicting.Value = processutil.RandomGoodValue(node.Rand)
+		return append(node.Broadcast(propose), node.Broadcast(conflicting)...)
+	})
+}
+
+// DoublePrevoter returns a Behaviour that sends two different Prevotes
+// whenever the Process prevotes. If the Process prevotes for a Value, then the
+// other Prevote is for nil, and vice versa.
+func DoublePrevoter() sim.Behaviour {
+	return sim.BehaviourFunc(func(node sim.Node, msg interface{}) []sim.Delivery {
+		prevote, ok := msg.(process.Prevote)
+		if !ok {
+			return node.Broadcast(msg)
+		}
+		conflicting := prevote
+		conflicting.Value = conflictingValue(node, prevote.Value)
+		return append(node.Broadcast(prevote), node.Broadcast(conflicting)...)
+	})
+}
+
+// DoublePrecommitter returns a Behaviour that sends two different Precommits
+// whenever the Process precommits. If the Process precommits for a Value, then
+// the other Precommit is for nil, and vice versa.
+func DoublePrecommitter() sim.Behaviour {
+	return sim.BehaviourFunc(func(node sim.Node, msg interface{}) []sim.Delivery {
+		precommit, ok := msg.(process.Precommit)
+		if !ok {
+			return node.Broadcast(msg)
+		}
+		conflicting := precommit
+		conflicting.Value = conflictingValue(node, precommit.Value)
+		return append(node.Broadcast(precommit), node.Broadcast(conflicting)...)
+	})
+}
+
+// VoteWithholder returns a Behaviour that never sends Prevotes or Precommits,
+// but still proposes, and still sends Timeouts. Honest Processes must make
+// progress without its votes.
+func VoteWithholder() sim.Behaviour {
+	return sim.BehaviourFunc(func(node sim.Node, msg interface{}) []sim.Delivery {
+		switch msg.(type) {
+		case process.Prevote, process.Precommit:
+			return nil
+		}
+		return node.Broadcast(msg)
+	})
+}
+
+// OutOfTurnProposer returns a Behaviour that proposes a random Value in every
+// Round in which the Process is not the proposer. The Propose is sent when
+// the Process prevotes, because this is when honest Processes are most likely
+// to be waiting for a Propose.
+func OutOfTurnProposer() sim.Behaviour {
+	return sim.BehaviourFunc(func(node sim.Node, msg interface{}) []sim.Delivery {
+		deliveries := node.Broadcast(msg)
+		prevote, ok := msg.(process.Prevote)
+		if !ok {
+			return deliveries
+		}
+		signatory := node.Signatory()
+		if proposer := node.Scheduler.Schedule(prevote.Height, prevote.Round); proposer.Equal(&signatory) {
+			return deliveries
+		}
+		propose := process.Propose{
+			Height:     prevote.Height,
+			Round:      prevote.Round,
+			ValidRound: process.InvalidRound,
+			Value:      processutil.RandomGoodValue(node.Rand),
+			From:       signatory,
+		}
+		return append(deliveries, node.Broadcast(propose)...)
+	})
+}
+
+// FutureRoundSpammer returns a Behaviour that sends a Prevote, a Precommit,
+// and a Timeout for the given number of Rounds after the current Round,
+// whenever the Process sends a message. A single Process cannot make honest
+// Processes skip to a future Round, but Processes with a smaller round window
+// catch the messages as out of window.
+func FutureRoundSpammer(rounds process.Round) sim.Behaviour {
+	return sim.BehaviourFunc(func(node sim.Node, msg interface{}) []sim.Delivery {
+		deliveries := node.Broadcast(msg)
+		// vote for nil, so that the votes for a Round never conflict with
+		// each other
+		height, round, value := node.Process.CurrentHeight, node.Process.CurrentRound+rounds, process.NilValue
+		deliveries = append(deliveries, node.Broadcast(process.Prevote{Height: height, Round: round, Value: value, From: node.Signatory()})...)
+		deliveries = append(deliveries, node.Broadcast(process.Precommit{Height: height, Round: round, Value: value, From: node.Signatory()})...)
+		deliveries = append(deliveries, node.Broadcast(process.Timeout{Height: height, Round: round, From: node.Signatory()})...)
+		return deliveries
+	})
+}
+
+// Amnesiac returns a Behaviour that forgets the Value that the Process is
+// locked on, as soon as it has sent its Precommit. In later Rounds, the
+// Process prevotes for any valid Value that is proposed, even if it conflicts
+// with a Value that it precommitted. This is the amnesia attack, and it cannot
+// be caught without comparing the messages of different Rounds.
+func Amnesiac() sim.Behaviour {
+	return sim.BehaviourFunc(func(node sim.Node, msg interface{}) []sim.Delivery {
+		node.Process.LockedValue = process.NilValue
+		node.Process.LockedRound = process.InvalidRound
+		return node.Broadcast(msg)
+	})
+}
+
+// conflictingValue returns a Value that conflicts with the given Value. If the
+// Value is nil, then a random Value is returned. Otherwise, nil is returned.
+func conflictingValue(node sim.Node, value process.Value) process.Value {
+	if value == process.NilValue {
+		return processutil.RandomGoodValue(node.Rand)
+	}
+	return process.NilValue
+}
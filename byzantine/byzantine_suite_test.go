@@ -0,0 +1,13 @@
+package byzantine_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestByzantine(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Byzantine Suite")
+}
@@ -0,0 +1,211 @@
+package byzantine_test
+
+import (
+	"testing/quick"
+	"time"
+
+	"github.com/renproject/hyperdrive/byzantine"
+	"github.com/renproject/hyperdrive/checker"
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/hyperdrive/sim"
+	"github.com/renproject/id"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Byzantine", func() {
+	// n processes, of which the first f are byzantine
+	n, f := 7, 2
+
+	// run a simulation until every honest process has committed a value at
+	// the given height, and return the simulation. processes are synced,
+	// because byzantine processes can make honest processes commit at
+	// different times, after which some of them miss the precommits
+	run := func(opts sim.Options, behaviour sim.Behaviour, height process.Height) *sim.Simulation {
+		opts = opts.WithSync(true).WithTrace(true)
+		for i := 0; i < f; i++ {
+			opts = opts.WithBehaviour(i, behaviour)
+		}
+		s := sim.New(opts, n)
+		Expect(s.RunUntil(func() bool {
+			for i := f; i < n; i++ {
+				if s.CurrentHeight(i) <= height {
+					return false
+				}
+			}
+			return true
+		}, 24*time.Hour)).To(BeTrue())
+		return s
+	}
+
+	// expectSafety expects honest processes to have committed the same valid
+	// values, without equivocating
+	expectSafety := func(s *sim.Simulation) {
+		honest := make([]int, 0, n-f)
+		for i := f; i < n; i++ {
+			honest = append(honest, i)
+		}
+		Expect(checker.FromSimulation(checker.DefaultOptions(), s, honest).Check()).To(Succeed())
+	}
+
+	// expectCaught expects the evidence of honest processes to contain the
+	// given type of evidence, and to only blame byzantine processes
+	expectCaught := func(s *sim.Simulation, ty process.EvidenceType) {
+		byzantine := map[id.Signatory]bool{}
+		for _, signatory := range s.Signatories()[:f] {
+			byzantine[signatory] = true
+		}
+		caught := 0
+		for i := f; i < n; i++ {
+			for _, evidence := range s.Evidence(i) {
+				for _, msg := range evidence.Messages {
+					Expect(byzantine).To(HaveKey(from(msg)))
+				}
+				if evidence.Type == ty {
+					caught++
+				}
+			}
+		}
+		Expect(caught).To(BeNumerically(">", 0))
+	}
+
+	// expectNotCaught expects the evidence of honest processes to be empty
+	expectNotCaught := func(s *sim.Simulation) {
+		for i := f; i < n; i++ {
+			Expect(s.Evidence(i)).To(BeEmpty())
+		}
+	}
+
+	Context("when there are equivocating proposers", func() {
+		It("should catch them, and stay safe", func() {
+			check := func(seed int64) bool {
+				s := run(sim.DefaultOptions().WithSeed(seed), byzantine.EquivocatingProposer(), 20)
+				expectSafety(s)
+				expectCaught(s, process.EvidenceTypeDoublePropose)
+				return true
+			}
+			Expect(quick.Check(check, &quick.Config{MaxCount: 10})).To(Succeed())
+		})
+	})
+
+	Context("when there are double prevoters", func() {
+		It("should catch them, and stay safe", func() {
+			check := func(seed int64) bool {
+				s := run(sim.DefaultOptions().WithSeed(seed), byzantine.DoublePrevoter(), 20)
+				expectSafety(s)
+				expectCaught(s, process.EvidenceTypeDoublePrevote)
+				return true
+			}
+			Expect(quick.Check(check, &quick.Config{MaxCount: 10})).To(Succeed())
+		})
+	})
+
+	Context("when there are double precommitters", func() {
+		It("should catch them, and stay safe", func() {
+			check := func(seed int64) bool {
+				s := run(sim.DefaultOptions().WithSeed(seed), byzantine.DoublePrecommitter(), 20)
+				expectSafety(s)
+				expectCaught(s, process.EvidenceTypeDoublePrecommit)
+				return true
+			}
+			Expect(quick.Check(check, &quick.Config{MaxCount: 10})).To(Succeed())
+		})
+	})
+
+	Context("when there are vote withholders", func() {
+		It("should make progress, and stay safe", func() {
+			check := func(seed int64) bool {
+				s := run(sim.DefaultOptions().WithSeed(seed), byzantine.VoteWithholder(), 20)
+				expectSafety(s)
+				expectNotCaught(s)
+				return true
+			}
+			Expect(quick.Check(check, &quick.Config{MaxCount: 10})).To(Succeed())
+		})
+	})
+
+	Context("when there are out of turn proposers", func() {
+		It("should catch them, and stay safe", func() {
+			check := func(seed int64) bool {
+				s := run(sim.DefaultOptions().WithSeed(seed), byzantine.OutOfTurnProposer(), 20)
+				expectSafety(s)
+				expectCaught(s, process.EvidenceTypeOutOfTurnPropose)
+				return true
+			}
+			Expect(quick.Check(check, &quick.Config{MaxCount: 10})).To(Succeed())
+		})
+	})
+
+	Context("when there are future round spammers", func() {
+		It("should catch them when there is a round window, and stay safe", func() {
+			check := func(seed int64) bool {
+				opts := sim.DefaultOptions().WithSeed(seed).WithRoundWindow(5)
+				s := run(opts, byzantine.FutureRoundSpammer(10), 20)
+				expectSafety(s)
+				expectCaught(s, process.EvidenceTypeOutOfWindow)
+				return true
+			}
+			Expect(quick.Check(check, &quick.Config{MaxCount: 10})).To(Succeed())
+		})
+
+		It("should not skip to future rounds when there is no round window", func() {
+			check := func(seed int64) bool {
+				s := run(sim.DefaultOptions().WithSeed(seed), byzantine.FutureRoundSpammer(10), 20)
+				expectSafety(s)
+				expectNotCaught(s)
+				return true
+			}
+			Expect(quick.Check(check, &quick.Config{MaxCount: 10})).To(Succeed())
+		})
+	})
+
+	Context("when there are amnesiacs", func() {
+		It("should stay safe", func() {
+			check := func(seed int64) bool {
+				// delays that are close to the timeout make it more likely
+				// that processes lock on values without committing them
+				opts := sim.DefaultOptions().
+					WithSeed(seed).
+					WithDelay(100*time.Millisecond, time.Second)
+				s := run(opts, byzantine.Amnesiac(), 20)
+				expectSafety(s)
+				expectNotCaught(s)
+				return true
+			}
+			Expect(quick.Check(check, &quick.Config{MaxCount: 10})).To(Succeed())
+		})
+	})
+
+	Context("when combining behaviours", func() {
+		It("should stay safe", func() {
+			check := func(seed int64) bool {
+				opts := sim.DefaultOptions().
+					WithSeed(seed).
+					WithSync(true).
+					WithTrace(true).
+					WithBehaviour(0, byzantine.EquivocatingProposer()).
+					WithBehaviour(1, byzantine.Amnesiac())
+				s := sim.New(opts, n)
+				Expect(s.RunUntil(func() bool { return s.CurrentHeight(n-1) > 20 }, 24*time.Hour)).To(BeTrue())
+				expectSafety(s)
+				return true
+			}
+			Expect(quick.Check(check, &quick.Config{MaxCount: 10})).To(Succeed())
+		})
+	})
+})
+
+func from(msg interface{}) id.Signatory {
+	switch msg := msg.(type) {
+	case process.Propose:
+		return msg.From
+	case process.Prevote:
+		return msg.From
+	case process.Precommit:
+		return msg.From
+	case process.Timeout:
+		return msg.From
+	}
+	return id.Signatory{}
+}
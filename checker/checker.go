@@ -0,0 +1,452 @@
+// Package checker checks the safety and liveness of runs of many replicas. A
+// Checker consumes the Values that are committed by replicas, and the
+// messages that are broadcast by them, and reports:
+//
+//   - agreement violations, where honest replicas commit different Values at
+//     the same Height,
+//   - validity violations, where honest replicas commit a Value that was never
+//     proposed at its Height,
+//   - equivocations by honest replicas, where they broadcast conflicting
+//     messages in the same Height and Round, and
+//   - liveness failures, where honest replicas do not commit a Value within a
+//     number of Rounds after the global stabilisation time (GST).
+//
+// Every Violation has a human-readable counterexample, made from the commits
+// and messages that caused it. Checkers can be used with any source of commits
+// and messages, such as the Committers and Broadcasters of replicas, or the
+// trace of a sim.Simulation (see FromSimulation).
+package checker
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/hyperdrive/sim"
+	"github.com/renproject/hyperdrive/timer"
+	"github.com/renproject/id"
+)
+
+// Kind enumerates the different kinds of Violations.
+type Kind uint8
+
+const (
+	// KindAgreement is a Violation where honest replicas commit different
+	// Values at the same Height.
+	KindAgreement Kind = 1
+	// KindValidity is a Violation where an honest replica commits a Value that
+	// was never proposed at its Height.
+	KindValidity Kind = 2
+	// KindEquivocation is a Violation where an honest replica broadcasts
+	// conflicting messages in the same Height and Round.
+	KindEquivocation Kind = 3
+	// KindLiveness is a Violation where an honest replica does not commit a
+	// Value within the liveness Rounds after the GST.
+	KindLiveness Kind = 4
+)
+
+// String implements the Stringer interface.
+func (kind Kind) String() string {
+	switch kind {
+	case KindAgreement:
+		return "Agreement"
+	case KindValidity:
+		return "Validity"
+	case KindEquivocation:
+		return "Equivocation"
+	case KindLiveness:
+		return "Liveness"
+	}
+	return fmt.Sprintf("Kind(%d)", kind)
+}
+
+// A Commit of a Value by a replica.
+type Commit struct {
+	Replica id.Signatory
+	Height  process.Height
+	Value   process.Value
+}
+
+// String implements the Stringer interface.
+func (commit Commit) String() string {
+	return fmt.Sprintf("replica=%v committed value=%v at height=%v", commit.Replica, commit.Value, commit.Height)
+}
+
+// A Message that has been broadcast. The message is a process.Propose,
+// process.Prevote, process.Precommit, or process.Timeout, and is broadcast by
+// the replica in its From field.
+type Message struct {
+	// Time at which the message was broadcast, since the start of the run.
+	Time    time.Duration
+	Message interface{}
+}
+
+// String implements the Stringer interface.
+func (msg Message) String() string {
+	switch m := msg.Message.(type) {
+	case process.Propose:
+		return fmt.Sprintf("time=%v: replica=%v sent Propose(height=%v, round=%v, validRound=%v, value=%v)", msg.Time, m.From, m.Height, m.Round, m.ValidRound, m.Value)
+	case process.Prevote:
+		return fmt.Sprintf("time=%v: replica=%v sent Prevote(height=%v, round=%v, value=%v)", msg.Time, m.From, m.Height, m.Round, m.Value)
+	case process.Precommit:
+		return fmt.Sprintf("time=%v: replica=%v sent Precommit(height=%v, round=%v, value=%v)", msg.Time, m.From, m.Height, m.Round, m.Value)
+	case process.Timeout:
+		return fmt.Sprintf("time=%v: replica=%v sent Timeout(height=%v, round=%v)", msg.Time, m.From, m.Height, m.Round)
+	}
+	return fmt.Sprintf("time=%v: unknown message=%v", msg.Time, msg.Message)
+}
+
+// A Violation of safety or liveness, with the Commits and Messages that make
+// up its counterexample.
+type Violation struct {
+	Kind    Kind
+	Height  process.Height
+	Replica id.Signatory
+	// Round is only set for KindEquivocation and KindLiveness.
+	Round    process.Round
+	Commits  []Commit
+	Messages []Message
+}
+
+// String returns a human-readable counterexample for the Violation.
+func (v Violation) String() string {
+	var summary string
+	switch v.Kind {
+	case KindAgreement:
+		summary = fmt.Sprintf("honest replicas committed different values at height=%v", v.Height)
+	case KindValidity:
+		summary = fmt.Sprintf("replica=%v committed a value that was never proposed at height=%v", v.Replica, v.Height)
+	case KindEquivocation:
+		summary = fmt.Sprintf("honest replica=%v sent conflicting messages at height=%v, round=%v", v.Replica, v.Height, v.Round)
+	case KindLiveness:
+		summary = fmt.Sprintf("honest replica=%v reached round=%v at height=%v without committing", v.Replica, v.Round, v.Height)
+	default:
+		summary = fmt.Sprintf("unknown violation at height=%v", v.Height)
+	}
+	lines := []string{fmt.Sprintf("%v violation: %v", v.Kind, summary)}
+	for _, commit := range v.Commits {
+		lines = append(lines, "\t"+commit.String())
+	}
+	for _, msg := range v.Messages {
+		lines = append(lines, "\t"+msg.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Violations are returned as an error by Check. The error message is the
+// counterexample of every Violation.
+type Violations []Violation
+
+// Error implements the error interface.
+func (violations Violations) Error() string {
+	counterexamples := make([]string, len(violations))
+	for i, v := range violations {
+		counterexamples[i] = v.String()
+	}
+	return fmt.Sprintf("%d violations:\n%v", len(violations), strings.Join(counterexamples, "\n"))
+}
+
+// A Checker checks the Commits and Messages of a run of replicas. It is safe
+// for concurrent use, so that it can be used from the callbacks of replicas
+// that are running in different goroutines.
+type Checker struct {
+	opts   Options
+	honest map[id.Signatory]bool
+
+	mu       *sync.Mutex
+	commits  []Commit
+	messages []Message
+}
+
+// New returns a Checker for the given honest replicas. Commits and Messages
+// from other replicas are only used as context: for example, a Value that is
+// proposed by a Byzantine replica is still a valid Value to commit.
+func New(opts Options, honest []id.Signatory) *Checker {
+	c := &Checker{
+		opts:   opts,
+		honest: make(map[id.Signatory]bool, len(honest)),
+		mu:     new(sync.Mutex),
+	}
+	for _, signatory := range honest {
+		c.honest[signatory] = true
+	}
+	return c
+}
+
+// FromSimulation returns a Checker for the honest Processes of a Simulation,
+// given by their index, with the Values they have committed so far, and the
+// messages in the trace of the Simulation. Messages are taken from their
+// delivery (or from their fault.Injection, if they were dropped), so the
+// Simulation must be tracing.
+func FromSimulation(opts Options, s *sim.Simulation, honest []int) *Checker {
+	signatories := s.Signatories()
+	honestSignatories := make([]id.Signatory, len(honest))
+	for i, index := range honest {
+		honestSignatories[i] = signatories[index]
+	}
+	c := New(opts, honestSignatories)
+	for index, signatory := range signatories {
+		commits := s.Commits(index)
+		heights := make([]process.Height, 0, len(commits))
+		for height := range commits {
+			heights = append(heights, height)
+		}
+		sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+		for _, height := range heights {
+			c.AddCommit(Commit{Replica: signatory, Height: height, Value: commits[height]})
+		}
+	}
+	seen := map[interface{}]bool{}
+	for _, record := range s.Trace() {
+		var msg interface{}
+		if record.Event != nil {
+			msg = record.Event.Message
+		} else if record.Injection != nil {
+			msg = record.Injection.Message
+		}
+		switch msg.(type) {
+		case timer.Timeout, process.CommitCertificate, nil:
+			continue
+		}
+		if seen[msg] {
+			continue
+		}
+		seen[msg] = true
+		c.AddMessage(Message{Time: record.Time, Message: msg})
+	}
+	return c
+}
+
+// AddCommit records a Value that has been committed by a replica.
+func (c *Checker) AddCommit(commit Commit) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.commits = append(c.commits, commit)
+}
+
+// AddMessage records a message that has been broadcast by a replica. Messages
+// must be added in the order in which they were broadcast.
+func (c *Checker) AddMessage(msg Message) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.messages = append(c.messages, msg)
+}
+
+// Violations returns all of the Violations in the Commits and Messages that
+// have been added so far, ordered by their Height, and then by their Kind.
+func (c *Checker) Violations() []Violation {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	violations := []Violation{}
+	violations = append(violations, c.checkAgreement()...)
+	violations = append(violations, c.checkValidity()...)
+	violations = append(violations, c.checkEquivocation()...)
+	violations = append(violations, c.checkLiveness()...)
+	sort.SliceStable(violations, func(i, j int) bool {
+		if violations[i].Height != violations[j].Height {
+			return violations[i].Height < violations[j].Height
+		}
+		return violations[i].Kind < violations[j].Kind
+	})
+	return violations
+}
+
+// Check returns nil if there are no Violations. Otherwise, it returns the
+// Violations as an error.
+func (c *Checker) Check() error {
+	if violations := c.Violations(); len(violations) > 0 {
+		return Violations(violations)
+	}
+	return nil
+}
+
+// checkAgreement returns a Violation for every Height at which honest replicas
+// have committed different Values. The counterexample has every commit at the
+// Height.
+func (c *Checker) checkAgreement() []Violation {
+	byHeight := map[process.Height][]Commit{}
+	heights := []process.Height{}
+	for _, commit := range c.commits {
+		if !c.honest[commit.Replica] {
+			continue
+		}
+		if _, ok := byHeight[commit.Height]; !ok {
+			heights = append(heights, commit.Height)
+		}
+		byHeight[commit.Height] = append(byHeight[commit.Height], commit)
+	}
+	violations := []Violation{}
+	for _, height := range heights {
+		commits := byHeight[height]
+		for _, commit := range commits[1:] {
+			if commit.Value != commits[0].Value {
+				violations = append(violations, Violation{
+					Kind:    KindAgreement,
+					Height:  height,
+					Replica: commit.Replica,
+					Commits: commits,
+				})
+				break
+			}
+		}
+	}
+	return violations
+}
+
+// checkValidity returns a Violation for every commit by an honest replica of a
+// Value that was never proposed at its Height.
+func (c *Checker) checkValidity() []Violation {
+	type proposal struct {
+		height process.Height
+		value  process.Value
+	}
+	proposed := map[proposal]bool{}
+	for _, msg := range c.messages {
+		if propose, ok := msg.Message.(process.Propose); ok {
+			proposed[proposal{propose.Height, propose.Value}] = true
+		}
+	}
+	violations := []Violation{}
+	for _, commit := range c.commits {
+		if !c.honest[commit.Replica] || proposed[proposal{commit.Height, commit.Value}] {
+			continue
+		}
+		violations = append(violations, Violation{
+			Kind:    KindValidity,
+			Height:  commit.Height,
+			Replica: commit.Replica,
+			Commits: []Commit{commit},
+		})
+	}
+	return violations
+}
+
+// checkEquivocation returns a Violation for every honest replica, and every
+// type of message, Height, and Round, in which the replica has broadcast
+// messages with different Values. The counterexample has the first message,
+// and the first message that conflicts with it.
+func (c *Checker) checkEquivocation() []Violation {
+	type slot struct {
+		from   id.Signatory
+		ty     process.MessageType
+		height process.Height
+		round  process.Round
+	}
+	first := map[slot]Message{}
+	reported := map[slot]bool{}
+	violations := []Violation{}
+	for _, msg := range c.messages {
+		var s slot
+		var value process.Value
+		switch m := msg.Message.(type) {
+		case process.Propose:
+			s, value = slot{m.From, process.MessageTypePropose, m.Height, m.Round}, m.Value
+		case process.Prevote:
+			s, value = slot{m.From, process.MessageTypePrevote, m.Height, m.Round}, m.Value
+		case process.Precommit:
+			s, value = slot{m.From, process.MessageTypePrecommit, m.Height, m.Round}, m.Value
+		default:
+			continue
+		}
+		if !c.honest[s.from] || reported[s] {
+			continue
+		}
+		prev, ok := first[s]
+		if !ok {
+			first[s] = msg
+			continue
+		}
+		if valueOf(prev.Message) == value {
+			continue
+		}
+		reported[s] = true
+		violations = append(violations, Violation{
+			Kind:     KindEquivocation,
+			Height:   s.height,
+			Replica:  s.from,
+			Round:    s.round,
+			Messages: []Message{prev, msg},
+		})
+	}
+	return violations
+}
+
+// checkLiveness returns a Violation for every honest replica, and every
+// Height, in which the replica has broadcast a message from the liveness
+// Rounds after the first Round in which it broadcast a message after the GST.
+// A replica that broadcasts messages from that Round has not committed a Value
+// within the liveness Rounds. The counterexample has the first message after
+// the GST, and the first message from that Round. Replicas that stop
+// broadcasting messages (for example, because they cannot see a quorum) are
+// not reported, so runs that must make progress should also check the Height
+// that replicas have reached.
+func (c *Checker) checkLiveness() []Violation {
+	if c.opts.LivenessRounds <= 0 {
+		return []Violation{}
+	}
+	type slot struct {
+		from   id.Signatory
+		height process.Height
+	}
+	first := map[slot]Message{}
+	reported := map[slot]bool{}
+	violations := []Violation{}
+	for _, msg := range c.messages {
+		if msg.Time < c.opts.GST {
+			continue
+		}
+		from, height, round, ok := header(msg.Message)
+		s := slot{from, height}
+		if !ok || !c.honest[from] || reported[s] {
+			continue
+		}
+		prev, ok := first[s]
+		if !ok {
+			first[s] = msg
+			continue
+		}
+		_, _, firstRound, _ := header(prev.Message)
+		if round < firstRound+c.opts.LivenessRounds {
+			continue
+		}
+		reported[s] = true
+		violations = append(violations, Violation{
+			Kind:     KindLiveness,
+			Height:   height,
+			Replica:  from,
+			Round:    round,
+			Messages: []Message{prev, msg},
+		})
+	}
+	return violations
+}
+
+func header(msg interface{}) (id.Signatory, process.Height, process.Round, bool) {
+	switch msg := msg.(type) {
+	case process.Propose:
+		return msg.From, msg.Height, msg.Round, true
+	case process.Prevote:
+		return msg.From, msg.Height, msg.Round, true
+	case process.Precommit:
+		return msg.From, msg.Height, msg.Round, true
+	case process.Timeout:
+		return msg.From, msg.Height, msg.Round, true
+	}
+	return id.Signatory{}, 0, 0, false
+}
+
+func valueOf(msg interface{}) process.Value {
+	switch msg := msg.(type) {
+	case process.Propose:
+		return msg.Value
+	case process.Prevote:
+		return msg.Value
+	case process.Precommit:
+		return msg.Value
+	}
+	return process.NilValue
+}
@@ -0,0 +1,237 @@
+package checker_test
+
+import (
+	"math/rand"
+	"testing/quick"
+	"time"
+
+	"github.com/renproject/hyperdrive/byzantine"
+	"github.com/renproject/hyperdrive/checker"
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/hyperdrive/process/processutil"
+	"github.com/renproject/hyperdrive/sim"
+	"github.com/renproject/id"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Checker", func() {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	// signatories returns n random signatories
+	signatories := func(n int) []id.Signatory {
+		signatories := make([]id.Signatory, n)
+		for i := range signatories {
+			signatories[i] = id.NewPrivKey().Signatory()
+		}
+		return signatories
+	}
+
+	// kinds returns the kinds of the violations
+	kinds := func(violations []checker.Violation) []checker.Kind {
+		kinds := make([]checker.Kind, len(violations))
+		for i, v := range violations {
+			kinds[i] = v.Kind
+		}
+		return kinds
+	}
+
+	Context("when honest replicas commit the same proposed values", func() {
+		It("should not report any violations", func() {
+			replicas := signatories(4)
+			c := checker.New(checker.DefaultOptions(), replicas)
+			for h := process.Height(1); h <= 10; h++ {
+				value := processutil.RandomGoodValue(r)
+				c.AddMessage(checker.Message{Message: process.Propose{Height: h, Round: 0, ValidRound: process.InvalidRound, Value: value, From: replicas[0]}})
+				for _, replica := range replicas {
+					c.AddMessage(checker.Message{Message: process.Prevote{Height: h, Round: 0, Value: value, From: replica}})
+					c.AddCommit(checker.Commit{Replica: replica, Height: h, Value: value})
+				}
+			}
+			Expect(c.Violations()).To(BeEmpty())
+			Expect(c.Check()).To(Succeed())
+		})
+	})
+
+	Context("when honest replicas commit different values", func() {
+		It("should report an agreement violation, with every commit at the height", func() {
+			replicas := signatories(4)
+			c := checker.New(checker.DefaultOptions(), replicas)
+			value1, value2 := processutil.RandomGoodValue(r), processutil.RandomGoodValue(r)
+			c.AddMessage(checker.Message{Message: process.Propose{Height: 1, Value: value1, From: replicas[0]}})
+			c.AddMessage(checker.Message{Message: process.Propose{Height: 1, Round: 1, Value: value2, From: replicas[1]}})
+			c.AddCommit(checker.Commit{Replica: replicas[0], Height: 1, Value: value1})
+			c.AddCommit(checker.Commit{Replica: replicas[1], Height: 1, Value: value1})
+			c.AddCommit(checker.Commit{Replica: replicas[2], Height: 1, Value: value2})
+
+			violations := c.Violations()
+			Expect(kinds(violations)).To(Equal([]checker.Kind{checker.KindAgreement}))
+			Expect(violations[0].Height).To(Equal(process.Height(1)))
+			Expect(violations[0].Replica).To(Equal(replicas[2]))
+			Expect(violations[0].Commits).To(HaveLen(3))
+
+			err := c.Check()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Agreement violation"))
+			Expect(err.Error()).To(ContainSubstring(value1.String()))
+			Expect(err.Error()).To(ContainSubstring(value2.String()))
+		})
+
+		It("should not report byzantine replicas", func() {
+			replicas := signatories(4)
+			c := checker.New(checker.DefaultOptions(), replicas[1:])
+			value1, value2 := processutil.RandomGoodValue(r), processutil.RandomGoodValue(r)
+			c.AddMessage(checker.Message{Message: process.Propose{Height: 1, Value: value1, From: replicas[0]}})
+			c.AddMessage(checker.Message{Message: process.Propose{Height: 1, Value: value2, From: replicas[0]}})
+			c.AddCommit(checker.Commit{Replica: replicas[0], Height: 1, Value: value2})
+			for _, replica := range replicas[1:] {
+				c.AddCommit(checker.Commit{Replica: replica, Height: 1, Value: value1})
+			}
+			Expect(c.Check()).To(Succeed())
+		})
+	})
+
+	Context("when an honest replica commits a value that was never proposed", func() {
+		It("should report a validity violation", func() {
+			replicas := signatories(4)
+			c := checker.New(checker.DefaultOptions(), replicas)
+			value := processutil.RandomGoodValue(r)
+			c.AddMessage(checker.Message{Message: process.Propose{Height: 2, Value: value, From: replicas[0]}})
+			c.AddCommit(checker.Commit{Replica: replicas[1], Height: 1, Value: value})
+
+			violations := c.Violations()
+			Expect(kinds(violations)).To(Equal([]checker.Kind{checker.KindValidity}))
+			Expect(violations[0].Replica).To(Equal(replicas[1]))
+			Expect(violations[0].String()).To(ContainSubstring("never proposed"))
+		})
+	})
+
+	Context("when an honest replica equivocates", func() {
+		It("should report an equivocation, with both messages", func() {
+			check := func(height process.Height, round process.Round) bool {
+				replicas := signatories(4)
+				c := checker.New(checker.DefaultOptions(), replicas)
+				value := processutil.RandomGoodValue(r)
+				prevote := process.Prevote{Height: height, Round: round, Value: value, From: replicas[1]}
+				conflicting := prevote
+				conflicting.Value = process.NilValue
+				c.AddMessage(checker.Message{Time: time.Second, Message: prevote})
+				c.AddMessage(checker.Message{Time: 2 * time.Second, Message: prevote})
+				c.AddMessage(checker.Message{Time: 3 * time.Second, Message: conflicting})
+				c.AddMessage(checker.Message{Time: 4 * time.Second, Message: conflicting})
+
+				violations := c.Violations()
+				Expect(kinds(violations)).To(Equal([]checker.Kind{checker.KindEquivocation}))
+				Expect(violations[0].Height).To(Equal(height))
+				Expect(violations[0].Round).To(Equal(round))
+				Expect(violations[0].Replica).To(Equal(replicas[1]))
+				Expect(violations[0].Messages).To(Equal([]checker.Message{
+					{Time: time.Second, Message: prevote},
+					{Time: 3 * time.Second, Message: conflicting},
+				}))
+				return true
+			}
+			Expect(quick.Check(check, nil)).To(Succeed())
+		})
+
+		It("should not report messages of different types, or from different rounds", func() {
+			replicas := signatories(4)
+			c := checker.New(checker.DefaultOptions(), replicas)
+			value := processutil.RandomGoodValue(r)
+			c.AddMessage(checker.Message{Message: process.Prevote{Height: 1, Round: 0, Value: value, From: replicas[0]}})
+			c.AddMessage(checker.Message{Message: process.Precommit{Height: 1, Round: 0, Value: process.NilValue, From: replicas[0]}})
+			c.AddMessage(checker.Message{Message: process.Prevote{Height: 1, Round: 1, Value: process.NilValue, From: replicas[0]}})
+			Expect(c.Check()).To(Succeed())
+		})
+	})
+
+	Context("when an honest replica does not commit within the liveness rounds", func() {
+		It("should report a liveness failure, but only after the gst", func() {
+			replicas := signatories(4)
+			opts := checker.DefaultOptions().WithGST(10 * time.Second).WithLivenessRounds(3)
+			c := checker.New(opts, replicas)
+
+			// rounds before the gst do not count
+			for round := process.Round(0); round < 10; round++ {
+				c.AddMessage(checker.Message{Time: time.Duration(round) * time.Second, Message: process.Timeout{Height: 1, Round: round, From: replicas[0]}})
+			}
+			Expect(c.Check()).To(Succeed())
+
+			for round := process.Round(10); round < 13; round++ {
+				c.AddMessage(checker.Message{Time: time.Duration(round) * time.Second, Message: process.Timeout{Height: 1, Round: round, From: replicas[0]}})
+			}
+			Expect(c.Check()).To(Succeed())
+
+			c.AddMessage(checker.Message{Time: 13 * time.Second, Message: process.Timeout{Height: 1, Round: 13, From: replicas[0]}})
+			violations := c.Violations()
+			Expect(kinds(violations)).To(Equal([]checker.Kind{checker.KindLiveness}))
+			Expect(violations[0].Round).To(Equal(process.Round(13)))
+			Expect(violations[0].Messages).To(HaveLen(2))
+			Expect(violations[0].String()).To(ContainSubstring("without committing"))
+		})
+
+		It("should not report liveness failures by default", func() {
+			replicas := signatories(4)
+			c := checker.New(checker.DefaultOptions(), replicas)
+			for round := process.Round(0); round < 100; round++ {
+				c.AddMessage(checker.Message{Message: process.Timeout{Height: 1, Round: round, From: replicas[0]}})
+			}
+			Expect(c.Check()).To(Succeed())
+		})
+	})
+
+	Context("when checking a simulation", func() {
+		It("should not report any violations when every process is honest", func() {
+			check := func(seed int64) bool {
+				n := 4
+				s := sim.New(sim.DefaultOptions().WithSeed(seed).WithTrace(true), n)
+				Expect(s.RunUntilHeight(20, time.Hour)).To(Succeed())
+				opts := checker.DefaultOptions().WithLivenessRounds(10)
+				Expect(checker.FromSimulation(opts, s, []int{0, 1, 2, 3}).Check()).To(Succeed())
+				return true
+			}
+			Expect(quick.Check(check, &quick.Config{MaxCount: 10})).To(Succeed())
+		})
+
+		It("should only report equivocations by processes that are assumed to be honest", func() {
+			n := 4
+			opts := sim.DefaultOptions().
+				WithSeed(r.Int63()).
+				WithBehaviour(0, byzantine.DoublePrevoter()).
+				WithTrace(true)
+			s := sim.New(opts, n)
+			Expect(s.RunUntil(func() bool { return s.CurrentHeight(n-1) > 10 }, time.Hour)).To(BeTrue())
+
+			Expect(checker.FromSimulation(checker.DefaultOptions(), s, []int{1, 2, 3}).Check()).To(Succeed())
+
+			violations := checker.FromSimulation(checker.DefaultOptions(), s, []int{0, 1, 2, 3}).Violations()
+			Expect(violations).ToNot(BeEmpty())
+			for _, v := range violations {
+				Expect(v.Kind).To(Equal(checker.KindEquivocation))
+				Expect(v.Replica).To(Equal(s.Signatories()[0]))
+			}
+		})
+
+		It("should report liveness failures when messages are always late", func() {
+			n := 4
+			opts := sim.DefaultOptions().
+				WithSeed(r.Int63()).
+				WithDelay(2*time.Second, 3*time.Second).
+				WithTimeout(time.Second).
+				WithTimeoutScaling(0).
+				WithTrace(true)
+			s := sim.New(opts, n)
+			s.RunUntil(func() bool { return false }, time.Minute)
+			Expect(s.CurrentHeight(0)).To(Equal(process.Height(1)))
+
+			violations := checker.FromSimulation(checker.DefaultOptions().WithLivenessRounds(5), s, []int{0, 1, 2, 3}).Violations()
+			Expect(kinds(violations)).To(Equal([]checker.Kind{
+				checker.KindLiveness,
+				checker.KindLiveness,
+				checker.KindLiveness,
+				checker.KindLiveness,
+			}))
+		})
+	})
+})
@@ -0,0 +1,38 @@
+package checker
+
+import (
+	"time"
+
+	"github.com/renproject/hyperdrive/process"
+)
+
+// Options represent the options for a Checker
+type Options struct {
+	GST            time.Duration
+	LivenessRounds process.Round
+}
+
+// DefaultOptions returns the default options for a Checker. By default,
+// liveness is not checked.
+func DefaultOptions() Options {
+	return Options{
+		GST:            0,
+		LivenessRounds: 0,
+	}
+}
+
+// WithGST updates the global stabilisation time, after which messages between
+// honest replicas are assumed to be delivered within a bounded delay. Liveness
+// is only checked after the GST.
+func (opts Options) WithGST(gst time.Duration) Options {
+	opts.GST = gst
+	return opts
+}
+
+// WithLivenessRounds updates the number of Rounds, after the GST, within which
+// honest replicas must commit a Value at every Height. Zero means that
+// liveness is not checked.
+func (opts Options) WithLivenessRounds(rounds process.Round) Options {
+	opts.LivenessRounds = rounds
+	return opts
+}
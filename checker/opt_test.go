@@ -0,0 +1,31 @@
+package checker_test
+
+import (
+	"time"
+
+	"github.com/renproject/hyperdrive/checker"
+	"github.com/renproject/hyperdrive/process"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Checker Opts", func() {
+	Context("Checker Opts", func() {
+		Specify("with default opts", func() {
+			opts := checker.DefaultOptions()
+			Expect(opts.GST).To(Equal(time.Duration(0)))
+			Expect(opts.LivenessRounds).To(Equal(process.Round(0)))
+		})
+
+		Specify("with gst", func() {
+			opts := checker.DefaultOptions().WithGST(time.Minute)
+			Expect(opts.GST).To(Equal(time.Minute))
+		})
+
+		Specify("with liveness rounds", func() {
+			opts := checker.DefaultOptions().WithLivenessRounds(3)
+			Expect(opts.LivenessRounds).To(Equal(process.Round(3)))
+		})
+	})
+})
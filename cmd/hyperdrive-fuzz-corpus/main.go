@@ -0,0 +1,34 @@
+// Command hyperdrive-fuzz-corpus writes the seed corpora of the fuzz targets
+// in package fuzz, in the layout that go-fuzz expects.
+//
+// Usage:
+//
+//	hyperdrive-fuzz-corpus dir
+//
+// The seeds of every target are written to dir/<target>/corpus, so that
+// dir/<target> can be used as the working directory of go-fuzz.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/renproject/hyperdrive/fuzz"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %v dir\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := fuzz.WriteCorpus(flag.Arg(0)); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,69 @@
+// Command hyperdrive-replay replays recorded scenarios against the current
+// Process code, and reports where its behaviour diverges from the recorded
+// runs.
+//
+// Usage:
+//
+//	hyperdrive-replay [-v] scenario...
+//
+// Scenarios are written by scenario.WriteFile, usually from a traced
+// sim.Simulation. The command exits with status 1 if any scenario diverges,
+// and with status 2 if any scenario cannot be read.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/renproject/hyperdrive/scenario"
+)
+
+func main() {
+	verbose := flag.Bool("v", false, "print every step of the scenarios")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %v [-v] scenario...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	status := 0
+	for _, name := range flag.Args() {
+		s, err := scenario.ReadFile(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v: %v\n", name, err)
+			status = 2
+			continue
+		}
+		fmt.Printf("%v: version=%v, seed=%v, processes=%v, byzantine=%v, faults=%v, steps=%v\n",
+			name, scenario.Version, s.Seed, len(s.Signatories), s.Byzantine, len(s.Faults), len(s.Steps))
+		if *verbose {
+			for index, step := range s.Steps {
+				fmt.Printf("%6d %12v process=%v %v\n", index, step.Time, step.To, scenario.Describe(step.Input))
+				for _, output := range step.Outputs {
+					fmt.Printf("%6s %12s   -> %v\n", "", "", scenario.Describe(output))
+				}
+			}
+		}
+
+		divergence, err := scenario.Replay(s)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v: %v\n", name, err)
+			status = 2
+			continue
+		}
+		if divergence != nil {
+			fmt.Printf("%v: %v\n", name, divergence)
+			if status == 0 {
+				status = 1
+			}
+			continue
+		}
+		fmt.Printf("%v: no divergence\n", name)
+	}
+	os.Exit(status)
+}
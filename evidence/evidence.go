@@ -0,0 +1,427 @@
+// Package evidence implements verifiable evidence of misbehaviour by
+// Processes, and a pool that collects evidence so that it can be included in
+// blocks (and used to punish the misbehaving Processes).
+package evidence
+
+import (
+	"fmt"
+
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/id"
+	"github.com/renproject/surge"
+)
+
+// Type enumerates the various types of evidence.
+type Type uint8
+
+const (
+	// TypeDuplicatePropose is the type of DuplicateProposeEvidence.
+	TypeDuplicatePropose Type = 1
+	// TypeDuplicateVote is the type of DuplicateVoteEvidence.
+	TypeDuplicateVote Type = 2
+	// TypeOutOfTurnPropose is the type of OutOfTurnProposeEvidence.
+	TypeOutOfTurnPropose Type = 3
+)
+
+// String implements the Stringer interface.
+func (ty Type) String() string {
+	switch ty {
+	case TypeDuplicatePropose:
+		return "DuplicatePropose"
+	case TypeDuplicateVote:
+		return "DuplicateVote"
+	case TypeOutOfTurnPropose:
+		return "OutOfTurnPropose"
+	}
+	return fmt.Sprintf("Type(%d)", ty)
+}
+
+// Evidence that a Process has misbehaved at a Height and Round. Evidence is
+// self-verifiable: it contains the signed messages that prove the misbehaviour,
+// so it can be checked by anyone that knows the schedule of proposers.
+type Evidence interface {
+	surge.Marshaler
+
+	// Type of the evidence.
+	Type() Type
+	// Height at which the misbehaviour happened.
+	Height() process.Height
+	// Round at which the misbehaviour happened.
+	Round() process.Round
+	// Offender is the signatory of the Process that misbehaved.
+	Offender() id.Signatory
+	// Verify the evidence. An error is returned if the messages are not
+	// correctly signed by the offender, or if they do not prove misbehaviour.
+	// The Scheduler is only used by evidence that depends on the schedule of
+	// proposers, and is ignored by all other evidence.
+	Verify(process.Scheduler) error
+}
+
+// DuplicateProposeEvidence is the evidence that a Process has signed two
+// different Proposes at the same Height and Round.
+type DuplicateProposeEvidence struct {
+	Proposes [2]process.Propose `json:"proposes"`
+}
+
+// NewDuplicateProposeEvidence returns the evidence that the two Proposes were
+// signed by the same Process. The Proposes are not verified.
+func NewDuplicateProposeEvidence(propose1, propose2 process.Propose) DuplicateProposeEvidence {
+	return DuplicateProposeEvidence{Proposes: [2]process.Propose{propose1, propose2}}
+}
+
+// Type returns TypeDuplicatePropose.
+func (ev DuplicateProposeEvidence) Type() Type { return TypeDuplicatePropose }
+
+// Height of the Proposes.
+func (ev DuplicateProposeEvidence) Height() process.Height { return ev.Proposes[0].Height }
+
+// Round of the Proposes.
+func (ev DuplicateProposeEvidence) Round() process.Round { return ev.Proposes[0].Round }
+
+// Offender is the signatory of the Proposes.
+func (ev DuplicateProposeEvidence) Offender() id.Signatory { return ev.Proposes[0].From }
+
+// Verify that the Proposes have the same Height, Round, and signatory, that
+// they are different, and that they are both correctly signed.
+func (ev DuplicateProposeEvidence) Verify(process.Scheduler) error {
+	propose1, propose2 := ev.Proposes[0], ev.Proposes[1]
+	if propose1.Height != propose2.Height || propose1.Round != propose2.Round || !propose1.From.Equal(&propose2.From) {
+		return fmt.Errorf("unrelated proposes: height=%v/%v, round=%v/%v, from=%v/%v", propose1.Height, propose2.Height, propose1.Round, propose2.Round, propose1.From, propose2.From)
+	}
+	if propose1.Equal(&propose2) {
+		return fmt.Errorf("identical proposes")
+	}
+	if err := propose1.Verify(); err != nil {
+		return fmt.Errorf("verifying first propose: %v", err)
+	}
+	if err := propose2.Verify(); err != nil {
+		return fmt.Errorf("verifying second propose: %v", err)
+	}
+	return nil
+}
+
+// SizeHint returns the number of bytes required to represent this evidence in
+// binary.
+func (ev DuplicateProposeEvidence) SizeHint() int {
+	return ev.Proposes[0].SizeHint() +
+		ev.Proposes[1].SizeHint()
+}
+
+// Marshal this evidence into binary.
+func (ev DuplicateProposeEvidence) Marshal(buf []byte, rem int) ([]byte, int, error) {
+	for i := range ev.Proposes {
+		var err error
+		buf, rem, err = ev.Proposes[i].Marshal(buf, rem)
+		if err != nil {
+			return buf, rem, fmt.Errorf("marshaling propose=%v: %v", ev.Proposes[i], err)
+		}
+	}
+	return buf, rem, nil
+}
+
+// Unmarshal binary into this evidence.
+func (ev *DuplicateProposeEvidence) Unmarshal(buf []byte, rem int) ([]byte, int, error) {
+	for i := range ev.Proposes {
+		var err error
+		buf, rem, err = ev.Proposes[i].Unmarshal(buf, rem)
+		if err != nil {
+			return buf, rem, fmt.Errorf("unmarshaling propose: %v", err)
+		}
+	}
+	return buf, rem, nil
+}
+
+// DuplicateVoteEvidence is the evidence that a Process has signed two
+// different Prevotes, or two different Precommits, at the same Height and
+// Round. Only the fields that differ between the two votes are stored twice.
+type DuplicateVoteEvidence struct {
+	MessageType process.MessageType `json:"messageType"`
+	VoteHeight  process.Height      `json:"height"`
+	VoteRound   process.Round       `json:"round"`
+	From        id.Signatory        `json:"from"`
+	Values      [2]process.Value    `json:"values"`
+	Signatures  [2]id.Signature     `json:"signatures"`
+}
+
+// NewDuplicatePrevoteEvidence returns the evidence that the two Prevotes were
+// signed by the same Process. The Prevotes are not verified.
+func NewDuplicatePrevoteEvidence(prevote1, prevote2 process.Prevote) DuplicateVoteEvidence {
+	return DuplicateVoteEvidence{
+		MessageType: process.MessageTypePrevote,
+		VoteHeight:  prevote1.Height,
+		VoteRound:   prevote1.Round,
+		From:        prevote1.From,
+		Values:      [2]process.Value{prevote1.Value, prevote2.Value},
+		Signatures:  [2]id.Signature{prevote1.Signature, prevote2.Signature},
+	}
+}
+
+// NewDuplicatePrecommitEvidence returns the evidence that the two Precommits
+// were signed by the same Process. The Precommits are not verified.
+func NewDuplicatePrecommitEvidence(precommit1, precommit2 process.Precommit) DuplicateVoteEvidence {
+	return DuplicateVoteEvidence{
+		MessageType: process.MessageTypePrecommit,
+		VoteHeight:  precommit1.Height,
+		VoteRound:   precommit1.Round,
+		From:        precommit1.From,
+		Values:      [2]process.Value{precommit1.Value, precommit2.Value},
+		Signatures:  [2]id.Signature{precommit1.Signature, precommit2.Signature},
+	}
+}
+
+// Type returns TypeDuplicateVote.
+func (ev DuplicateVoteEvidence) Type() Type { return TypeDuplicateVote }
+
+// Height of the votes.
+func (ev DuplicateVoteEvidence) Height() process.Height { return ev.VoteHeight }
+
+// Round of the votes.
+func (ev DuplicateVoteEvidence) Round() process.Round { return ev.VoteRound }
+
+// Offender is the signatory of the votes.
+func (ev DuplicateVoteEvidence) Offender() id.Signatory { return ev.From }
+
+// Verify that the votes are Prevotes or Precommits, that they have different
+// Values, and that they are both correctly signed by the offender.
+func (ev DuplicateVoteEvidence) Verify(process.Scheduler) error {
+	if ev.Values[0].Equal(&ev.Values[1]) {
+		return fmt.Errorf("identical votes")
+	}
+	for i := range ev.Values {
+		var hash id.Hash
+		var err error
+		switch ev.MessageType {
+		case process.MessageTypePrevote:
+			hash, err = process.NewPrevoteHash(ev.VoteHeight, ev.VoteRound, ev.Values[i])
+		case process.MessageTypePrecommit:
+			hash, err = process.NewPrecommitHash(ev.VoteHeight, ev.VoteRound, ev.Values[i])
+		default:
+			return fmt.Errorf("unexpected message type=%v", ev.MessageType)
+		}
+		if err != nil {
+			return fmt.Errorf("hashing vote: %v", err)
+		}
+		signatory, err := ev.Signatures[i].Signatory(&hash)
+		if err != nil {
+			return fmt.Errorf("recovering signatory: %v", err)
+		}
+		if !signatory.Equal(&ev.From) {
+			return fmt.Errorf("bad signatory: expected=%v, got=%v", ev.From, signatory)
+		}
+	}
+	return nil
+}
+
+// SizeHint returns the number of bytes required to represent this evidence in
+// binary.
+func (ev DuplicateVoteEvidence) SizeHint() int {
+	return surge.SizeHint(int8(ev.MessageType)) +
+		surge.SizeHint(ev.VoteHeight) +
+		surge.SizeHint(ev.VoteRound) +
+		surge.SizeHint(ev.From) +
+		2*surge.SizeHint(ev.Values[0]) +
+		2*surge.SizeHint(ev.Signatures[0])
+}
+
+// Marshal this evidence into binary.
+func (ev DuplicateVoteEvidence) Marshal(buf []byte, rem int) ([]byte, int, error) {
+	buf, rem, err := surge.Marshal(int8(ev.MessageType), buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling message type=%v: %v", ev.MessageType, err)
+	}
+	buf, rem, err = surge.Marshal(ev.VoteHeight, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling height=%v: %v", ev.VoteHeight, err)
+	}
+	buf, rem, err = surge.Marshal(ev.VoteRound, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling round=%v: %v", ev.VoteRound, err)
+	}
+	buf, rem, err = surge.Marshal(ev.From, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling from=%v: %v", ev.From, err)
+	}
+	for i := range ev.Values {
+		buf, rem, err = surge.Marshal(ev.Values[i], buf, rem)
+		if err != nil {
+			return buf, rem, fmt.Errorf("marshaling value=%v: %v", ev.Values[i], err)
+		}
+		buf, rem, err = surge.Marshal(ev.Signatures[i], buf, rem)
+		if err != nil {
+			return buf, rem, fmt.Errorf("marshaling signature=%v: %v", ev.Signatures[i], err)
+		}
+	}
+	return buf, rem, nil
+}
+
+// Unmarshal binary into this evidence.
+func (ev *DuplicateVoteEvidence) Unmarshal(buf []byte, rem int) ([]byte, int, error) {
+	buf, rem, err := surge.Unmarshal((*int8)(&ev.MessageType), buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling message type: %v", err)
+	}
+	buf, rem, err = surge.Unmarshal(&ev.VoteHeight, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling height: %v", err)
+	}
+	buf, rem, err = surge.Unmarshal(&ev.VoteRound, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling round: %v", err)
+	}
+	buf, rem, err = surge.Unmarshal(&ev.From, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling from: %v", err)
+	}
+	for i := range ev.Values {
+		buf, rem, err = surge.Unmarshal(&ev.Values[i], buf, rem)
+		if err != nil {
+			return buf, rem, fmt.Errorf("unmarshaling value: %v", err)
+		}
+		buf, rem, err = surge.Unmarshal(&ev.Signatures[i], buf, rem)
+		if err != nil {
+			return buf, rem, fmt.Errorf("unmarshaling signature: %v", err)
+		}
+	}
+	return buf, rem, nil
+}
+
+// OutOfTurnProposeEvidence is the evidence that a Process has signed a Propose
+// at a Height and Round for which it was not the scheduled proposer.
+type OutOfTurnProposeEvidence struct {
+	Propose process.Propose `json:"propose"`
+}
+
+// NewOutOfTurnProposeEvidence returns the evidence that the Propose was signed
+// out of turn. The Propose is not verified.
+func NewOutOfTurnProposeEvidence(propose process.Propose) OutOfTurnProposeEvidence {
+	return OutOfTurnProposeEvidence{Propose: propose}
+}
+
+// Type returns TypeOutOfTurnPropose.
+func (ev OutOfTurnProposeEvidence) Type() Type { return TypeOutOfTurnPropose }
+
+// Height of the Propose.
+func (ev OutOfTurnProposeEvidence) Height() process.Height { return ev.Propose.Height }
+
+// Round of the Propose.
+func (ev OutOfTurnProposeEvidence) Round() process.Round { return ev.Propose.Round }
+
+// Offender is the signatory of the Propose.
+func (ev OutOfTurnProposeEvidence) Offender() id.Signatory { return ev.Propose.From }
+
+// Verify that the Propose is correctly signed, and that its signatory was not
+// scheduled to propose at its Height and Round. An error is returned if the
+// Scheduler is nil.
+func (ev OutOfTurnProposeEvidence) Verify(scheduler process.Scheduler) error {
+	if scheduler == nil {
+		return fmt.Errorf("verifying schedule: nil scheduler")
+	}
+	if err := ev.Propose.Verify(); err != nil {
+		return fmt.Errorf("verifying propose: %v", err)
+	}
+	proposer := scheduler.Schedule(ev.Propose.Height, ev.Propose.Round)
+	if proposer.Equal(&ev.Propose.From) {
+		return fmt.Errorf("scheduled proposer: %v", proposer)
+	}
+	return nil
+}
+
+// SizeHint returns the number of bytes required to represent this evidence in
+// binary.
+func (ev OutOfTurnProposeEvidence) SizeHint() int {
+	return ev.Propose.SizeHint()
+}
+
+// Marshal this evidence into binary.
+func (ev OutOfTurnProposeEvidence) Marshal(buf []byte, rem int) ([]byte, int, error) {
+	buf, rem, err := ev.Propose.Marshal(buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling propose=%v: %v", ev.Propose, err)
+	}
+	return buf, rem, nil
+}
+
+// Unmarshal binary into this evidence.
+func (ev *OutOfTurnProposeEvidence) Unmarshal(buf []byte, rem int) ([]byte, int, error) {
+	buf, rem, err := ev.Propose.Unmarshal(buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling propose: %v", err)
+	}
+	return buf, rem, nil
+}
+
+// A List of evidence of different types. It can be marshaled and unmarshaled,
+// so that it can be included in blocks.
+type List []Evidence
+
+// SizeHint returns the number of bytes required to represent this list in
+// binary.
+func (list List) SizeHint() int {
+	sizeHint := surge.SizeHint(uint32(len(list)))
+	for _, ev := range list {
+		sizeHint += surge.SizeHint(uint8(ev.Type())) + ev.SizeHint()
+	}
+	return sizeHint
+}
+
+// Marshal this list into binary. Every evidence is prefixed with its Type.
+func (list List) Marshal(buf []byte, rem int) ([]byte, int, error) {
+	buf, rem, err := surge.Marshal(uint32(len(list)), buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling len=%v: %v", len(list), err)
+	}
+	for _, ev := range list {
+		buf, rem, err = surge.Marshal(uint8(ev.Type()), buf, rem)
+		if err != nil {
+			return buf, rem, fmt.Errorf("marshaling type=%v: %v", ev.Type(), err)
+		}
+		buf, rem, err = ev.Marshal(buf, rem)
+		if err != nil {
+			return buf, rem, fmt.Errorf("marshaling evidence=%v: %v", ev, err)
+		}
+	}
+	return buf, rem, nil
+}
+
+// Unmarshal binary into this list.
+func (list *List) Unmarshal(buf []byte, rem int) ([]byte, int, error) {
+	var n uint32
+	buf, rem, err := surge.Unmarshal(&n, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling len: %v", err)
+	}
+	// Every evidence takes at least one byte, so the length cannot be larger
+	// than the remaining buffer. This stops malicious lengths from causing
+	// large allocations.
+	if uint64(n) > uint64(len(buf)) {
+		return buf, rem, fmt.Errorf("unmarshaling len=%v: expected at most %v", n, len(buf))
+	}
+	*list = make(List, 0, n)
+	for i := uint32(0); i < n; i++ {
+		var ty Type
+		buf, rem, err = surge.Unmarshal((*uint8)(&ty), buf, rem)
+		if err != nil {
+			return buf, rem, fmt.Errorf("unmarshaling type: %v", err)
+		}
+		switch ty {
+		case TypeDuplicatePropose:
+			ev := DuplicateProposeEvidence{}
+			buf, rem, err = ev.Unmarshal(buf, rem)
+			*list = append(*list, ev)
+		case TypeDuplicateVote:
+			ev := DuplicateVoteEvidence{}
+			buf, rem, err = ev.Unmarshal(buf, rem)
+			*list = append(*list, ev)
+		case TypeOutOfTurnPropose:
+			ev := OutOfTurnProposeEvidence{}
+			buf, rem, err = ev.Unmarshal(buf, rem)
+			*list = append(*list, ev)
+		default:
+			return buf, rem, fmt.Errorf("unmarshaling evidence: unknown type=%v", ty)
+		}
+		if err != nil {
+			return buf, rem, fmt.Errorf("unmarshaling evidence: %v", err)
+		}
+	}
+	return buf, rem, nil
+}
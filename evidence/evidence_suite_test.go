@@ -0,0 +1,13 @@
+package evidence_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestEvidence(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Evidence Suite")
+}
@@ -0,0 +1,256 @@
+package evidence_test
+
+import (
+	"math/rand"
+	"testing/quick"
+	"time"
+
+	"github.com/renproject/hyperdrive/evidence"
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/hyperdrive/process/processutil"
+	"github.com/renproject/hyperdrive/scheduler"
+	"github.com/renproject/id"
+	"github.com/renproject/surge"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// differentValue returns a random good Value that is different from the given
+// Value.
+func differentValue(r *rand.Rand, value process.Value) process.Value {
+	other := processutil.RandomGoodValue(r)
+	for other.Equal(&value) {
+		other = processutil.RandomGoodValue(r)
+	}
+	return other
+}
+
+func duplicatePropose(r *rand.Rand, privKey *id.PrivKey) evidence.DuplicateProposeEvidence {
+	propose1 := process.Propose{
+		Height:     processutil.RandomHeight(r),
+		Round:      processutil.RandomRound(r),
+		ValidRound: processutil.RandomRound(r),
+		Value:      processutil.RandomGoodValue(r),
+	}
+	propose2 := propose1
+	propose2.Value = differentValue(r, propose1.Value)
+	Expect(propose1.Sign(privKey)).To(Succeed())
+	Expect(propose2.Sign(privKey)).To(Succeed())
+	return evidence.NewDuplicateProposeEvidence(propose1, propose2)
+}
+
+func duplicatePrevote(r *rand.Rand, privKey *id.PrivKey) evidence.DuplicateVoteEvidence {
+	prevote1 := process.Prevote{
+		Height: processutil.RandomHeight(r),
+		Round:  processutil.RandomRound(r),
+		Value:  processutil.RandomGoodValue(r),
+	}
+	prevote2 := prevote1
+	prevote2.Value = differentValue(r, prevote1.Value)
+	Expect(prevote1.Sign(privKey)).To(Succeed())
+	Expect(prevote2.Sign(privKey)).To(Succeed())
+	return evidence.NewDuplicatePrevoteEvidence(prevote1, prevote2)
+}
+
+func duplicatePrecommit(r *rand.Rand, privKey *id.PrivKey) evidence.DuplicateVoteEvidence {
+	precommit1 := process.Precommit{
+		Height: processutil.RandomHeight(r),
+		Round:  processutil.RandomRound(r),
+		Value:  processutil.RandomGoodValue(r),
+	}
+	precommit2 := precommit1
+	precommit2.Value = differentValue(r, precommit1.Value)
+	Expect(precommit1.Sign(privKey)).To(Succeed())
+	Expect(precommit2.Sign(privKey)).To(Succeed())
+	return evidence.NewDuplicatePrecommitEvidence(precommit1, precommit2)
+}
+
+func outOfTurnPropose(r *rand.Rand, privKey *id.PrivKey) evidence.OutOfTurnProposeEvidence {
+	propose := process.Propose{
+		Height:     process.Height(1 + r.Intn(100)),
+		Round:      process.Round(r.Intn(100)),
+		ValidRound: process.InvalidRound,
+		Value:      processutil.RandomGoodValue(r),
+	}
+	Expect(propose.Sign(privKey)).To(Succeed())
+	return evidence.NewOutOfTurnProposeEvidence(propose)
+}
+
+func randomEvidence(r *rand.Rand) evidence.Evidence {
+	privKey := id.NewPrivKey()
+	switch r.Intn(4) {
+	case 0:
+		return duplicatePropose(r, privKey)
+	case 1:
+		return duplicatePrevote(r, privKey)
+	case 2:
+		return duplicatePrecommit(r, privKey)
+	default:
+		return outOfTurnPropose(r, privKey)
+	}
+}
+
+var _ = Describe("Evidence", func() {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	Context("when unmarshaling fuzz", func() {
+		It("should not panic", func() {
+			f := func(fuzz []byte) bool {
+				list := evidence.List{}
+				Expect(func() { surge.FromBinary(&list, fuzz) }).ToNot(Panic())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+
+	Context("when marshaling and then unmarshaling a list", func() {
+		It("should equal itself", func() {
+			loop := func() bool {
+				expected := make(evidence.List, r.Intn(10))
+				for i := range expected {
+					expected[i] = randomEvidence(r)
+				}
+				data, err := surge.ToBinary(expected)
+				Expect(err).ToNot(HaveOccurred())
+				got := evidence.List{}
+				Expect(surge.FromBinary(&got, data)).To(Succeed())
+				Expect(got).To(HaveLen(len(expected)))
+				for i := range got {
+					Expect(got[i].Type()).To(Equal(expected[i].Type()))
+					Expect(got[i]).To(Equal(expected[i]))
+				}
+				return true
+			}
+			Expect(quick.Check(loop, nil)).To(Succeed())
+		})
+
+		It("should return an error when not enough bytes", func() {
+			loop := func() bool {
+				list := evidence.List{randomEvidence(r)}
+				buf := make([]byte, list.SizeHint())
+				sizeAvailable := r.Intn(list.SizeHint())
+				_, _, err := list.Marshal(buf, sizeAvailable)
+				Expect(err).To(HaveOccurred())
+				return true
+			}
+			Expect(quick.Check(loop, nil)).To(Succeed())
+		})
+	})
+
+	Context("when verifying duplicate propose evidence", func() {
+		It("should succeed for different proposes from the same signatory", func() {
+			loop := func() bool {
+				privKey := id.NewPrivKey()
+				ev := duplicatePropose(r, privKey)
+				Expect(ev.Verify(nil)).To(Succeed())
+				Expect(ev.Offender()).To(Equal(privKey.Signatory()))
+				return true
+			}
+			Expect(quick.Check(loop, nil)).To(Succeed())
+		})
+
+		It("should fail for identical proposes", func() {
+			loop := func() bool {
+				ev := duplicatePropose(r, id.NewPrivKey())
+				ev.Proposes[1] = ev.Proposes[0]
+				Expect(ev.Verify(nil)).ToNot(Succeed())
+				return true
+			}
+			Expect(quick.Check(loop, nil)).To(Succeed())
+		})
+
+		It("should fail for proposes from different signatories", func() {
+			loop := func() bool {
+				ev := duplicatePropose(r, id.NewPrivKey())
+				Expect(ev.Proposes[1].Sign(id.NewPrivKey())).To(Succeed())
+				Expect(ev.Verify(nil)).ToNot(Succeed())
+				return true
+			}
+			Expect(quick.Check(loop, nil)).To(Succeed())
+		})
+
+		It("should fail for proposes that are not correctly signed", func() {
+			loop := func() bool {
+				ev := duplicatePropose(r, id.NewPrivKey())
+				ev.Proposes[r.Intn(2)].ValidRound++
+				Expect(ev.Verify(nil)).ToNot(Succeed())
+				return true
+			}
+			Expect(quick.Check(loop, nil)).To(Succeed())
+		})
+	})
+
+	Context("when verifying duplicate vote evidence", func() {
+		It("should succeed for different votes from the same signatory", func() {
+			loop := func() bool {
+				privKey := id.NewPrivKey()
+				for _, ev := range []evidence.DuplicateVoteEvidence{duplicatePrevote(r, privKey), duplicatePrecommit(r, privKey)} {
+					Expect(ev.Verify(nil)).To(Succeed())
+					Expect(ev.Offender()).To(Equal(privKey.Signatory()))
+				}
+				return true
+			}
+			Expect(quick.Check(loop, nil)).To(Succeed())
+		})
+
+		It("should fail for identical votes", func() {
+			loop := func() bool {
+				ev := duplicatePrevote(r, id.NewPrivKey())
+				ev.Values[1] = ev.Values[0]
+				Expect(ev.Verify(nil)).ToNot(Succeed())
+				return true
+			}
+			Expect(quick.Check(loop, nil)).To(Succeed())
+		})
+
+		It("should fail for votes of an unexpected message type", func() {
+			loop := func() bool {
+				ev := duplicatePrevote(r, id.NewPrivKey())
+				ev.MessageType = process.MessageTypePropose
+				Expect(ev.Verify(nil)).ToNot(Succeed())
+				return true
+			}
+			Expect(quick.Check(loop, nil)).To(Succeed())
+		})
+
+		It("should fail for votes from a different signatory", func() {
+			loop := func() bool {
+				ev := duplicatePrecommit(r, id.NewPrivKey())
+				ev.From = id.NewPrivKey().Signatory()
+				Expect(ev.Verify(nil)).ToNot(Succeed())
+				return true
+			}
+			Expect(quick.Check(loop, nil)).To(Succeed())
+		})
+	})
+
+	Context("when verifying out of turn propose evidence", func() {
+		It("should succeed for proposes from an unscheduled signatory", func() {
+			loop := func() bool {
+				ev := outOfTurnPropose(r, id.NewPrivKey())
+				sched := scheduler.NewRoundRobin([]id.Signatory{id.NewPrivKey().Signatory()})
+				Expect(ev.Verify(sched)).To(Succeed())
+				return true
+			}
+			Expect(quick.Check(loop, nil)).To(Succeed())
+		})
+
+		It("should fail for proposes from the scheduled signatory", func() {
+			loop := func() bool {
+				privKey := id.NewPrivKey()
+				ev := outOfTurnPropose(r, privKey)
+				sched := scheduler.NewRoundRobin([]id.Signatory{privKey.Signatory()})
+				Expect(ev.Verify(sched)).ToNot(Succeed())
+				return true
+			}
+			Expect(quick.Check(loop, nil)).To(Succeed())
+		})
+
+		It("should fail without a scheduler", func() {
+			ev := outOfTurnPropose(r, id.NewPrivKey())
+			Expect(ev.Verify(nil)).ToNot(Succeed())
+		})
+	})
+})
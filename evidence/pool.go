@@ -0,0 +1,205 @@
+package evidence
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/id"
+)
+
+// key identifies an instance of misbehaviour. Different evidence of the same
+// misbehaviour (for example, a third Prevote from a Process that has already
+// been caught double prevoting) has the same key, so that a Process is only
+// punished once per misbehaviour. Double prevoting and double precommitting in
+// the same Round are different misbehaviours, so the key of
+// DuplicateVoteEvidence also includes the type of the votes.
+type key struct {
+	ty          Type
+	messageType process.MessageType
+	height      process.Height
+	round       process.Round
+	offender    id.Signatory
+}
+
+func keyOf(ev Evidence) key {
+	k := key{
+		ty:       ev.Type(),
+		height:   ev.Height(),
+		round:    ev.Round(),
+		offender: ev.Offender(),
+	}
+	if vote, ok := ev.(DuplicateVoteEvidence); ok {
+		k.messageType = vote.MessageType
+	}
+	return k
+}
+
+// A Pool of pending evidence that has not yet been included in a block.
+// Evidence is deduplicated by its Type, Height, Round, and offender, and it
+// expires once it is too far behind the current Height of the Pool. It is safe
+// for concurrent use.
+//
+// The Pool implements the process.Catcher interface, so it can be given to a
+// Process (or a Replica) to collect evidence of the misbehaviour that the
+// Process catches. Messages from outside of the round window of the Process
+// are not evidence of misbehaviour, and are ignored.
+type Pool struct {
+	mu       *sync.Mutex
+	expiry   process.Height
+	height   process.Height
+	evidence map[key]Evidence
+}
+
+// NewPool returns an empty Pool at the default Height. Evidence expires once it
+// is more than expiry Heights behind the current Height of the Pool.
+func NewPool(expiry process.Height) *Pool {
+	return &Pool{
+		mu:       new(sync.Mutex),
+		expiry:   expiry,
+		height:   process.DefaultHeight,
+		evidence: map[key]Evidence{},
+	}
+}
+
+// Add evidence to the Pool. It returns false if the evidence has expired, or if
+// the Pool already has evidence of the same misbehaviour, otherwise it returns
+// true. The evidence is not verified, so evidence from untrusted sources must
+// be verified before it is added.
+func (pool *Pool) Add(ev Evidence) bool {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	if pool.expired(ev) {
+		return false
+	}
+	k := keyOf(ev)
+	if _, ok := pool.evidence[k]; ok {
+		return false
+	}
+	pool.evidence[k] = ev
+	return true
+}
+
+// Remove evidence of the same misbehaviour as the given evidence from the
+// Pool. It should be called for all evidence in a block once the block has
+// been committed.
+func (pool *Pool) Remove(ev Evidence) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	delete(pool.evidence, keyOf(ev))
+}
+
+// SetHeight sets the current Height of the Pool, and removes all evidence that
+// has expired.
+func (pool *Pool) SetHeight(height process.Height) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	pool.height = height
+	for k, ev := range pool.evidence {
+		if pool.expired(ev) {
+			delete(pool.evidence, k)
+		}
+	}
+}
+
+// Pending returns all evidence in the Pool, ordered by Height, Round, Type, and
+// offender (and by the type of the votes, for DuplicateVoteEvidence), so that
+// the order is the same for all Pools with the same evidence.
+func (pool *Pool) Pending() List {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	list := make(List, 0, len(pool.evidence))
+	for _, ev := range pool.evidence {
+		list = append(list, ev)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		ki, kj := keyOf(list[i]), keyOf(list[j])
+		if ki.height != kj.height {
+			return ki.height < kj.height
+		}
+		if ki.round != kj.round {
+			return ki.round < kj.round
+		}
+		if ki.ty != kj.ty {
+			return ki.ty < kj.ty
+		}
+		if ki.messageType != kj.messageType {
+			return ki.messageType < kj.messageType
+		}
+		return bytes.Compare(ki.offender[:], kj.offender[:]) < 0
+	})
+	return list
+}
+
+// Len returns the number of pending evidence in the Pool.
+func (pool *Pool) Len() int {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	return len(pool.evidence)
+}
+
+// CatchDoublePropose adds DuplicateProposeEvidence to the Pool.
+func (pool *Pool) CatchDoublePropose(propose1, propose2 process.Propose) {
+	pool.Add(NewDuplicateProposeEvidence(propose1, propose2))
+}
+
+// CatchDoublePrevote adds DuplicateVoteEvidence to the Pool.
+func (pool *Pool) CatchDoublePrevote(prevote1, prevote2 process.Prevote) {
+	pool.Add(NewDuplicatePrevoteEvidence(prevote1, prevote2))
+}
+
+// CatchDoublePrecommit adds DuplicateVoteEvidence to the Pool.
+func (pool *Pool) CatchDoublePrecommit(precommit1, precommit2 process.Precommit) {
+	pool.Add(NewDuplicatePrecommitEvidence(precommit1, precommit2))
+}
+
+// CatchOutOfTurnPropose adds OutOfTurnProposeEvidence to the Pool.
+func (pool *Pool) CatchOutOfTurnPropose(propose process.Propose) {
+	pool.Add(NewOutOfTurnProposeEvidence(propose))
+}
+
+// CatchOutOfWindowPropose does nothing.
+func (pool *Pool) CatchOutOfWindowPropose(process.Propose) {}
+
+// CatchOutOfWindowPrevote does nothing.
+func (pool *Pool) CatchOutOfWindowPrevote(process.Prevote) {}
+
+// CatchOutOfWindowPrecommit does nothing.
+func (pool *Pool) CatchOutOfWindowPrecommit(process.Precommit) {}
+
+// CatchOutOfWindowTimeout does nothing.
+func (pool *Pool) CatchOutOfWindowTimeout(process.Timeout) {}
+
+// expired returns true if the evidence is more than expiry Heights behind the
+// current Height of the Pool. It must only be called while holding the mutex.
+func (pool *Pool) expired(ev Evidence) bool {
+	return ev.Height() < pool.height-pool.expiry
+}
+
+// A Proposer proposes Values that can include evidence of misbehaviour.
+type Proposer interface {
+	Propose(process.Height, process.Round, List) process.Value
+}
+
+type proposer struct {
+	pool     *Pool
+	proposer Proposer
+}
+
+// NewProposer returns a process.Proposer that passes all pending evidence in
+// the Pool to the given Proposer, so that the evidence can be included in the
+// proposed Value.
+func NewProposer(pool *Pool, p Proposer) process.Proposer {
+	return proposer{pool: pool, proposer: p}
+}
+
+// Propose implements the process.Proposer interface.
+func (p proposer) Propose(height process.Height, round process.Round) process.Value {
+	return p.proposer.Propose(height, round, p.pool.Pending())
+}
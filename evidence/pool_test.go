@@ -0,0 +1,168 @@
+package evidence_test
+
+import (
+	"math/rand"
+	"testing/quick"
+	"time"
+
+	"github.com/renproject/hyperdrive/evidence"
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/hyperdrive/process/processutil"
+	"github.com/renproject/hyperdrive/scheduler"
+	"github.com/renproject/id"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type proposerFunc func(process.Height, process.Round, evidence.List) process.Value
+
+func (f proposerFunc) Propose(height process.Height, round process.Round, list evidence.List) process.Value {
+	return f(height, round, list)
+}
+
+var _ = Describe("Pool", func() {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	// evidenceAt returns random evidence at the given height.
+	evidenceAt := func(height process.Height) evidence.Evidence {
+		ev := duplicatePrevote(r, id.NewPrivKey())
+		ev.VoteHeight = height
+		return ev
+	}
+
+	Context("when adding evidence", func() {
+		It("should return the evidence as pending", func() {
+			loop := func() bool {
+				pool := evidence.NewPool(process.Height(10))
+				expected := map[evidence.Evidence]bool{}
+				n := r.Intn(10)
+				for i := 0; i < n; i++ {
+					ev := evidenceAt(process.DefaultHeight + process.Height(r.Intn(10)))
+					Expect(pool.Add(ev)).To(BeTrue())
+					expected[ev] = true
+				}
+				pending := pool.Pending()
+				Expect(pending).To(HaveLen(len(expected)))
+				Expect(pool.Len()).To(Equal(len(expected)))
+				for i, ev := range pending {
+					Expect(expected).To(HaveKey(ev))
+					if i > 0 {
+						Expect(ev.Height()).To(BeNumerically(">=", pending[i-1].Height()))
+					}
+				}
+				return true
+			}
+			Expect(quick.Check(loop, nil)).To(Succeed())
+		})
+
+		It("should deduplicate evidence of the same misbehaviour", func() {
+			loop := func() bool {
+				pool := evidence.NewPool(process.Height(10))
+				ev := duplicatePrecommit(r, id.NewPrivKey())
+				ev.VoteHeight = process.DefaultHeight
+				Expect(pool.Add(ev)).To(BeTrue())
+				Expect(pool.Add(ev)).To(BeFalse())
+
+				// different evidence of the same misbehaviour is not added
+				other := ev
+				other.Values[1] = processutil.RandomGoodValue(r)
+				Expect(pool.Add(other)).To(BeFalse())
+				Expect(pool.Len()).To(Equal(1))
+
+				// different misbehaviour at the same height and round is added
+				other.MessageType = process.MessageTypePrevote
+				Expect(pool.Add(other)).To(BeTrue())
+				Expect(pool.Add(evidence.NewOutOfTurnProposeEvidence(process.Propose{Height: ev.VoteHeight, Round: ev.VoteRound, From: ev.From}))).To(BeTrue())
+				Expect(pool.Len()).To(Equal(3))
+
+				pool.Remove(ev)
+				Expect(pool.Len()).To(Equal(2))
+				Expect(pool.Add(ev)).To(BeTrue())
+				return true
+			}
+			Expect(quick.Check(loop, nil)).To(Succeed())
+		})
+	})
+
+	Context("when setting the height", func() {
+		It("should expire old evidence", func() {
+			loop := func() bool {
+				expiry := process.Height(r.Intn(10))
+				height := process.DefaultHeight + expiry + process.Height(r.Intn(10))
+				pool := evidence.NewPool(expiry)
+				for h := process.DefaultHeight; h <= height; h++ {
+					Expect(pool.Add(evidenceAt(h))).To(BeTrue())
+				}
+
+				pool.SetHeight(height)
+				Expect(pool.Len()).To(Equal(int(expiry) + 1))
+				for _, ev := range pool.Pending() {
+					Expect(ev.Height()).To(BeNumerically(">=", height-expiry))
+				}
+
+				// expired evidence cannot be added
+				Expect(pool.Add(evidenceAt(height - expiry - 1))).To(BeFalse())
+				Expect(pool.Add(evidenceAt(height - expiry))).To(BeTrue())
+				return true
+			}
+			Expect(quick.Check(loop, nil)).To(Succeed())
+		})
+	})
+
+	Context("when catching misbehaviour in a process", func() {
+		It("should add evidence to the pool", func() {
+			whoami := id.NewPrivKey().Signatory()
+			scheduled := id.NewPrivKey()
+			offender := id.NewPrivKey()
+			pool := evidence.NewPool(process.Height(10))
+			sched := scheduler.NewRoundRobin([]id.Signatory{scheduled.Signatory()})
+			p := process.New(whoami, 4, nil, sched, nil, nil, nil, nil, nil, pool)
+			p.Start()
+
+			// out of turn propose
+			propose := process.Propose{Height: process.DefaultHeight, Round: 0, ValidRound: process.InvalidRound, Value: processutil.RandomGoodValue(r)}
+			Expect(propose.Sign(offender)).To(Succeed())
+			p.Propose(propose)
+
+			// double propose
+			propose1 := process.Propose{Height: process.DefaultHeight, Round: 0, ValidRound: process.InvalidRound, Value: processutil.RandomGoodValue(r)}
+			propose2 := propose1
+			propose2.Value = differentValue(r, propose1.Value)
+			Expect(propose1.Sign(scheduled)).To(Succeed())
+			Expect(propose2.Sign(scheduled)).To(Succeed())
+			p.Propose(propose1)
+			p.Propose(propose2)
+
+			// double prevote
+			prevote1 := process.Prevote{Height: process.DefaultHeight, Round: 0, Value: processutil.RandomGoodValue(r)}
+			prevote2 := prevote1
+			prevote2.Value = differentValue(r, prevote1.Value)
+			Expect(prevote1.Sign(offender)).To(Succeed())
+			Expect(prevote2.Sign(offender)).To(Succeed())
+			p.Prevote(prevote1)
+			p.Prevote(prevote2)
+
+			pending := pool.Pending()
+			Expect(pending).To(HaveLen(3))
+			for _, ev := range pending {
+				Expect(ev.Verify(sched)).To(Succeed())
+			}
+		})
+	})
+
+	Context("when proposing", func() {
+		It("should pass the pending evidence to the proposer", func() {
+			pool := evidence.NewPool(process.Height(10))
+			ev := evidenceAt(process.DefaultHeight)
+			Expect(pool.Add(ev)).To(BeTrue())
+
+			value := processutil.RandomGoodValue(r)
+			proposer := evidence.NewProposer(pool, proposerFunc(func(height process.Height, round process.Round, list evidence.List) process.Value {
+				Expect(list).To(Equal(evidence.List{ev}))
+				return value
+			}))
+			Expect(proposer.Propose(process.DefaultHeight, 0)).To(Equal(value))
+		})
+	})
+})
@@ -0,0 +1,279 @@
+// Package fault defines a model of network faults that can be injected into
+// the messages that are broadcast by Processes. A Model is a list of Rules,
+// and each Rule injects a fault (dropping, duplicating, or delaying messages)
+// into the messages that are sent over some Links, during some Window. For
+// example, "partition {A,B} from {C,D} between heights 10 and 20" is the Rule
+// that drops all messages from Heights 10 to 19 that are sent between the two
+// groups, in either direction:
+//
+//	model := fault.NewModel().Partition(fault.Heights(10, 20), []id.Signatory{a, b}, []id.Signatory{c, d})
+//
+// Models are used by sim.Simulation, and by transport.Transport, to decide
+// which faults to inject into every message that they send. Every fault that
+// is injected is returned as an Injection, so that it can be recorded.
+package fault
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/id"
+)
+
+// Kind enumerates the different kinds of faults that can be injected.
+type Kind uint8
+
+const (
+	// KindDrop drops the message, so that it is never delivered.
+	KindDrop Kind = 1
+	// KindDuplicate delivers an extra copy of the message.
+	KindDuplicate Kind = 2
+	// KindDelay delays the delivery of the message. Delaying messages by
+	// different amounts reorders them.
+	KindDelay Kind = 3
+)
+
+// String implements the Stringer interface.
+func (kind Kind) String() string {
+	switch kind {
+	case KindDrop:
+		return "Drop"
+	case KindDuplicate:
+		return "Duplicate"
+	case KindDelay:
+		return "Delay"
+	}
+	return fmt.Sprintf("Kind(%d)", kind)
+}
+
+// A Window restricts a Rule to messages from a range of Heights, that are sent
+// during a range of times. Both ranges include their start, and exclude their
+// end. The times are measured from the start of a run: in a Simulation, this
+// is virtual time, and in a Transport, this is the time since the Transport
+// was created.
+type Window struct {
+	StartHeight process.Height
+	EndHeight   process.Height
+	StartTime   time.Duration
+	EndTime     time.Duration
+}
+
+// Always returns a Window that includes all messages, including messages with
+// Heights (or times) that are negative.
+func Always() Window {
+	return Window{
+		StartHeight: math.MinInt64,
+		EndHeight:   math.MaxInt64,
+		StartTime:   math.MinInt64,
+		EndTime:     math.MaxInt64,
+	}
+}
+
+// Heights returns a Window that includes messages from the start Height up to
+// (but excluding) the end Height.
+func Heights(start, end process.Height) Window {
+	window := Always()
+	window.StartHeight = start
+	window.EndHeight = end
+	return window
+}
+
+// Times returns a Window that includes messages that are sent from the start
+// time up to (but excluding) the end time.
+func Times(start, end time.Duration) Window {
+	window := Always()
+	window.StartTime = start
+	window.EndTime = end
+	return window
+}
+
+// Contains returns true if a message from the given Height, that is sent at
+// the given time, is in the Window.
+func (window Window) Contains(height process.Height, now time.Duration) bool {
+	return height >= window.StartHeight && height < window.EndHeight &&
+		now >= window.StartTime && now < window.EndTime
+}
+
+// Links select the links over which a Rule injects faults. A link is directed:
+// it is identified by the signatory that sends messages over it, and the
+// signatory that receives them.
+type Links func(from, to id.Signatory) bool
+
+// AllLinks selects every link.
+func AllLinks() Links {
+	return func(from, to id.Signatory) bool {
+		return true
+	}
+}
+
+// Directed selects the links from any of the senders to any of the receivers,
+// but not the links in the other direction. This is used to inject faults
+// into asymmetric links.
+func Directed(senders, receivers []id.Signatory) Links {
+	isSender, isReceiver := set(senders), set(receivers)
+	return func(from, to id.Signatory) bool {
+		return isSender[from] && isReceiver[to]
+	}
+}
+
+// Between selects the links between the two groups of signatories, in both
+// directions.
+func Between(group1, group2 []id.Signatory) Links {
+	inGroup1, inGroup2 := set(group1), set(group2)
+	return func(from, to id.Signatory) bool {
+		return (inGroup1[from] && inGroup2[to]) || (inGroup2[from] && inGroup1[to])
+	}
+}
+
+// A Rule injects one kind of fault into the messages that are sent over some
+// Links during a Window. Each message is independently affected with the
+// given Probability. Delays are chosen uniformly at random between the
+// minimum and maximum delay.
+type Rule struct {
+	Kind        Kind
+	Window      Window
+	Links       Links
+	Probability float64
+	MinDelay    time.Duration
+	MaxDelay    time.Duration
+}
+
+// An Injection records a fault that has been injected into a message.
+type Injection struct {
+	Kind    Kind
+	From    id.Signatory
+	To      id.Signatory
+	Message interface{}
+	// Delay is the extra delay of a message, if the fault is a KindDelay.
+	Delay time.Duration
+}
+
+// An Effect is the combined effect of all Rules on a message.
+type Effect struct {
+	// Drop is true if the message must not be delivered.
+	Drop bool
+	// Duplicates is the number of extra copies of the message that must be
+	// delivered.
+	Duplicates int
+	// Delay is the extra delay with which the message (and its copies) must
+	// be delivered.
+	Delay time.Duration
+	// Injections are the faults that were injected, in the order of the Rules
+	// that injected them.
+	Injections []Injection
+}
+
+// A Model of network faults is an ordered list of Rules. Models are immutable,
+// and safe for concurrent use.
+type Model struct {
+	rules []Rule
+}
+
+// NewModel returns a Model without any Rules. It does not inject any faults.
+func NewModel() Model {
+	return Model{}
+}
+
+// Rules returns the Rules of the Model.
+func (model Model) Rules() []Rule {
+	rules := make([]Rule, len(model.rules))
+	copy(rules, model.rules)
+	return rules
+}
+
+// With returns a copy of the Model with an extra Rule.
+func (model Model) With(rule Rule) Model {
+	rules := make([]Rule, len(model.rules), len(model.rules)+1)
+	copy(rules, model.rules)
+	model.rules = append(rules, rule)
+	return model
+}
+
+// Partition returns a copy of the Model that drops all messages between the
+// two groups of signatories during the Window.
+func (model Model) Partition(window Window, group1, group2 []id.Signatory) Model {
+	return model.With(Rule{Kind: KindDrop, Window: window, Links: Between(group1, group2), Probability: 1})
+}
+
+// Drop returns a copy of the Model that drops messages over the Links during
+// the Window with the given probability. Processes do not send messages
+// again, so dropping the messages of a quorum can stall consensus until the
+// Processes are synced.
+func (model Model) Drop(window Window, links Links, probability float64) Model {
+	return model.With(Rule{Kind: KindDrop, Window: window, Links: links, Probability: probability})
+}
+
+// Duplicate returns a copy of the Model that duplicates messages over the
+// Links during the Window with the given probability.
+func (model Model) Duplicate(window Window, links Links, probability float64) Model {
+	return model.With(Rule{Kind: KindDuplicate, Window: window, Links: links, Probability: probability})
+}
+
+// Delay returns a copy of the Model that delays all messages over the Links
+// during the Window by a random duration between the minimum and maximum.
+// This is used to model the latency of specific links, and to reorder
+// messages.
+func (model Model) Delay(window Window, links Links, min, max time.Duration) Model {
+	return model.With(Rule{Kind: KindDelay, Window: window, Links: links, Probability: 1, MinDelay: min, MaxDelay: max})
+}
+
+// Apply the Rules of the Model to a message that is sent from one signatory
+// to another at the given time, and return their combined Effect. All random
+// choices are made using the given random number generator, so that the
+// Effect can be reproduced. Once a message has been dropped, no other Rules
+// are applied to it.
+func (model Model) Apply(from, to id.Signatory, msg interface{}, now time.Duration, r *rand.Rand) Effect {
+	effect := Effect{}
+	height := heightOf(msg)
+	for _, rule := range model.rules {
+		if !rule.Window.Contains(height, now) || !rule.Links(from, to) {
+			continue
+		}
+		if rule.Probability < 1 && r.Float64() >= rule.Probability {
+			continue
+		}
+		injection := Injection{Kind: rule.Kind, From: from, To: to, Message: msg}
+		switch rule.Kind {
+		case KindDrop:
+			effect.Drop = true
+		case KindDuplicate:
+			effect.Duplicates++
+		case KindDelay:
+			injection.Delay = rule.MinDelay
+			if rule.MaxDelay > rule.MinDelay {
+				injection.Delay += time.Duration(r.Int63n(int64(rule.MaxDelay-rule.MinDelay) + 1))
+			}
+			effect.Delay += injection.Delay
+		}
+		effect.Injections = append(effect.Injections, injection)
+		if effect.Drop {
+			break
+		}
+	}
+	return effect
+}
+
+func set(signatories []id.Signatory) map[id.Signatory]bool {
+	s := make(map[id.Signatory]bool, len(signatories))
+	for _, signatory := range signatories {
+		s[signatory] = true
+	}
+	return s
+}
+
+func heightOf(msg interface{}) process.Height {
+	switch msg := msg.(type) {
+	case process.Propose:
+		return msg.Height
+	case process.Prevote:
+		return msg.Height
+	case process.Precommit:
+		return msg.Height
+	case process.Timeout:
+		return msg.Height
+	}
+	return 0
+}
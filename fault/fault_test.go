@@ -0,0 +1,169 @@
+package fault_test
+
+import (
+	"math/rand"
+	"testing/quick"
+	"time"
+
+	"github.com/renproject/hyperdrive/fault"
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/hyperdrive/process/processutil"
+	"github.com/renproject/id"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Fault", func() {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	// prevoteAt returns a random Prevote at the given Height
+	prevoteAt := func(height process.Height) process.Prevote {
+		prevote := processutil.RandomPrevote(r)
+		prevote.Height = height
+		return prevote
+	}
+
+	Context("when checking whether a window contains a message", func() {
+		It("should include the start, and exclude the end", func() {
+			f := func(start, end, height process.Height) bool {
+				window := fault.Heights(start, end)
+				Expect(window.Contains(height, 0)).To(Equal(height >= start && height < end))
+				window = fault.Times(time.Duration(start), time.Duration(end))
+				Expect(window.Contains(0, time.Duration(height))).To(Equal(height >= start && height < end))
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should always contain messages in an unbounded window", func() {
+			f := func(height process.Height, now time.Duration) bool {
+				Expect(fault.Always().Contains(height, now)).To(BeTrue())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+
+	Context("when selecting links", func() {
+		a, b, c, d := id.NewPrivKey().Signatory(), id.NewPrivKey().Signatory(), id.NewPrivKey().Signatory(), id.NewPrivKey().Signatory()
+
+		It("should select links between groups in both directions", func() {
+			links := fault.Between([]id.Signatory{a, b}, []id.Signatory{c, d})
+			Expect(links(a, c)).To(BeTrue())
+			Expect(links(d, b)).To(BeTrue())
+			Expect(links(a, b)).To(BeFalse())
+			Expect(links(c, d)).To(BeFalse())
+			Expect(links(a, a)).To(BeFalse())
+		})
+
+		It("should select directed links in one direction", func() {
+			links := fault.Directed([]id.Signatory{a}, []id.Signatory{b, c})
+			Expect(links(a, b)).To(BeTrue())
+			Expect(links(a, c)).To(BeTrue())
+			Expect(links(b, a)).To(BeFalse())
+			Expect(links(a, d)).To(BeFalse())
+		})
+
+		It("should select all links", func() {
+			Expect(fault.AllLinks()(a, b)).To(BeTrue())
+			Expect(fault.AllLinks()(b, a)).To(BeTrue())
+		})
+	})
+
+	Context("when applying a model without rules", func() {
+		It("should not inject any faults", func() {
+			f := func(from, to id.Signatory, height process.Height, now time.Duration) bool {
+				effect := fault.NewModel().Apply(from, to, prevoteAt(height), now, r)
+				Expect(effect).To(Equal(fault.Effect{}))
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+
+	Context("when applying a partition", func() {
+		It("should drop messages between the groups, in the window", func() {
+			a, b, c := id.NewPrivKey().Signatory(), id.NewPrivKey().Signatory(), id.NewPrivKey().Signatory()
+			model := fault.NewModel().Partition(fault.Heights(10, 20), []id.Signatory{a}, []id.Signatory{b})
+			for height := process.Height(1); height < 30; height++ {
+				msg := prevoteAt(height)
+				inWindow := height >= 10 && height < 20
+
+				effect := model.Apply(a, b, msg, 0, r)
+				Expect(effect.Drop).To(Equal(inWindow))
+				if inWindow {
+					Expect(effect.Injections).To(Equal([]fault.Injection{{Kind: fault.KindDrop, From: a, To: b, Message: msg}}))
+				}
+				Expect(model.Apply(b, a, msg, 0, r).Drop).To(Equal(inWindow))
+				Expect(model.Apply(a, c, msg, 0, r).Drop).To(BeFalse())
+				Expect(model.Apply(c, b, msg, 0, r).Drop).To(BeFalse())
+			}
+		})
+	})
+
+	Context("when applying multiple rules", func() {
+		It("should combine their effects", func() {
+			f := func(from, to id.Signatory) bool {
+				model := fault.NewModel().
+					Duplicate(fault.Always(), fault.AllLinks(), 1).
+					Duplicate(fault.Always(), fault.AllLinks(), 1).
+					Delay(fault.Always(), fault.AllLinks(), time.Second, 2*time.Second).
+					Delay(fault.Always(), fault.AllLinks(), time.Second, time.Second)
+				effect := model.Apply(from, to, prevoteAt(1), 0, r)
+				Expect(effect.Drop).To(BeFalse())
+				Expect(effect.Duplicates).To(Equal(2))
+				Expect(effect.Delay).To(BeNumerically(">=", 2*time.Second))
+				Expect(effect.Delay).To(BeNumerically("<=", 3*time.Second))
+				Expect(effect.Injections).To(HaveLen(4))
+				Expect(effect.Injections[2].Delay + effect.Injections[3].Delay).To(Equal(effect.Delay))
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should not apply rules after the message has been dropped", func() {
+			model := fault.NewModel().
+				Drop(fault.Always(), fault.AllLinks(), 1).
+				Duplicate(fault.Always(), fault.AllLinks(), 1)
+			effect := model.Apply(id.Signatory{}, id.Signatory{}, prevoteAt(1), 0, r)
+			Expect(effect.Drop).To(BeTrue())
+			Expect(effect.Duplicates).To(Equal(0))
+			Expect(effect.Injections).To(HaveLen(1))
+		})
+
+		It("should not modify the model that it was built from", func() {
+			model := fault.NewModel().Drop(fault.Always(), fault.AllLinks(), 1)
+			_ = model.Duplicate(fault.Always(), fault.AllLinks(), 1)
+			Expect(model.Rules()).To(HaveLen(1))
+		})
+	})
+
+	Context("when applying rules with a probability", func() {
+		It("should inject faults into the expected fraction of messages", func() {
+			model := fault.NewModel().Drop(fault.Always(), fault.AllLinks(), 0.25)
+			dropped := 0
+			for i := 0; i < 10000; i++ {
+				if model.Apply(id.Signatory{}, id.Signatory{}, prevoteAt(1), 0, r).Drop {
+					dropped++
+				}
+			}
+			Expect(dropped).To(BeNumerically("~", 2500, 300))
+		})
+
+		It("should make the same choices from the same seed", func() {
+			f := func(seed int64) bool {
+				model := fault.NewModel().
+					Drop(fault.Always(), fault.AllLinks(), 0.5).
+					Delay(fault.Always(), fault.AllLinks(), 0, time.Second)
+				r1, r2 := rand.New(rand.NewSource(seed)), rand.New(rand.NewSource(seed))
+				for i := 0; i < 10; i++ {
+					msg := prevoteAt(1)
+					Expect(model.Apply(id.Signatory{}, id.Signatory{}, msg, 0, r1)).To(Equal(model.Apply(id.Signatory{}, id.Signatory{}, msg, 0, r2)))
+				}
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+})
@@ -0,0 +1,245 @@
+package fuzz
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/renproject/hyperdrive/evidence"
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/hyperdrive/process/processutil"
+	"github.com/renproject/hyperdrive/replica"
+	"github.com/renproject/hyperdrive/scenario"
+	"github.com/renproject/hyperdrive/sim"
+	"github.com/renproject/hyperdrive/timer"
+	"github.com/renproject/hyperdrive/wal"
+	"github.com/renproject/surge"
+)
+
+// inputSeeds are the seeds of FuzzInputs.
+var inputSeeds = [][]input{
+	// commit in the first round
+	{
+		{kind: inputPropose, round: 0, value: 1, validRound: -1, from: 1},
+		{kind: inputPrevote, round: 0, value: 1, from: 1},
+		{kind: inputPrevote, round: 0, value: 1, from: 2},
+		{kind: inputPrevote, round: 0, value: 1, from: 3},
+		{kind: inputPrecommit, round: 0, value: 1, from: 1},
+		{kind: inputPrecommit, round: 0, value: 1, from: 2},
+		{kind: inputPrecommit, round: 0, value: 1, from: 3},
+	},
+	// time out in the first round, and commit in the second round
+	{
+		{kind: inputTimeoutPropose, round: 0},
+		{kind: inputPrevote, round: 0, value: 3, from: 1},
+		{kind: inputPrevote, round: 0, value: 3, from: 2},
+		{kind: inputPrevote, round: 0, value: 3, from: 3},
+		{kind: inputPrecommit, round: 0, value: 3, from: 1},
+		{kind: inputPrecommit, round: 0, value: 3, from: 2},
+		{kind: inputPrecommit, round: 0, value: 3, from: 3},
+		{kind: inputTimeoutPrecommit, round: 0},
+		{kind: inputPropose, round: 1, value: 0, validRound: -1, from: 2},
+		{kind: inputPrevote, round: 1, value: 0, from: 1},
+		{kind: inputPrevote, round: 1, value: 0, from: 2},
+		{kind: inputPrevote, round: 1, value: 0, from: 3},
+		{kind: inputPrecommit, round: 1, value: 0, from: 1},
+		{kind: inputPrecommit, round: 1, value: 0, from: 2},
+		{kind: inputPrecommit, round: 1, value: 0, from: 3},
+	},
+	// lock in the first round, and commit the locked value in the second round
+	{
+		{kind: inputPropose, round: 0, value: 1, validRound: -1, from: 1},
+		{kind: inputPrevote, round: 0, value: 1, from: 1},
+		{kind: inputPrevote, round: 0, value: 1, from: 2},
+		{kind: inputPrevote, round: 0, value: 1, from: 3},
+		{kind: inputPrecommit, round: 0, value: 3, from: 1},
+		{kind: inputPrecommit, round: 0, value: 3, from: 2},
+		{kind: inputPrecommit, round: 0, value: 3, from: 3},
+		{kind: inputTimeoutPrecommit, round: 0},
+		{kind: inputPropose, round: 1, value: 1, validRound: 0, from: 2},
+		{kind: inputPrevote, round: 1, value: 1, from: 1},
+		{kind: inputPrevote, round: 1, value: 1, from: 2},
+		{kind: inputPrevote, round: 1, value: 1, from: 3},
+		{kind: inputPrecommit, round: 1, value: 1, from: 1},
+		{kind: inputPrecommit, round: 1, value: 1, from: 2},
+		{kind: inputPrecommit, round: 1, value: 1, from: 3},
+	},
+	// skip to future rounds, and receive messages from other heights
+	{
+		{kind: inputTimeout, round: 2, from: 1},
+		{kind: inputTimeout, round: 2, from: 2},
+		{kind: inputTimeout, round: 2, from: 3},
+		{kind: inputPrevote, round: 5, value: 2, from: 1},
+		{kind: inputPrevote, round: 5, value: 3, from: 2},
+		{kind: inputPropose, height: 1, round: 0, value: 0, validRound: -1, from: 1},
+		{kind: inputPrecommit, height: -1, round: 0, value: 0, from: 1},
+		{kind: inputTimeoutPropose, round: 5},
+		{kind: inputTimeoutPrevote, round: 5},
+	},
+}
+
+// Corpus returns the seed corpus of every target, by name. The seeds include
+// random messages from processutil, so the corpus is different every time
+// that it is generated.
+func Corpus() (map[string][][]byte, error) {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	corpus := map[string][][]byte{}
+	add := func(name string, vs ...interface{}) error {
+		for _, v := range vs {
+			data, err := surge.ToBinary(v)
+			if err != nil {
+				return fmt.Errorf("marshaling %T for %v: %v", v, name, err)
+			}
+			corpus[name] = append(corpus[name], data)
+		}
+		return nil
+	}
+
+	// Run the seeds of FuzzInputs, so that their messages and Processes can be
+	// used as seeds for the decoders.
+	proposes, prevotes, precommits, timeouts := []interface{}{}, []interface{}{}, []interface{}{}, []interface{}{}
+	processes, states := []interface{}{}, []interface{}{}
+	for _, seed := range inputSeeds {
+		data := []byte{}
+		for _, in := range seed {
+			data = append(data, in.bytes()...)
+		}
+		corpus["FuzzInputs"] = append(corpus["FuzzInputs"], data)
+
+		p, _ := runInputs(data)
+		processes = append(processes, p.Process)
+		states = append(states, p.State)
+	}
+	p, _ := runInputs(corpus["FuzzInputs"][0][:6*4])
+	for _, prevote := range p.PrevoteLogs[0] {
+		prevotes = append(prevotes, prevote)
+	}
+	for _, precommit := range p.PrecommitLogs[0] {
+		precommits = append(precommits, precommit)
+	}
+	proposes = append(proposes, p.ProposeLogs[0])
+	for i := 0; i < 4; i++ {
+		proposes = append(proposes, processutil.RandomPropose(r))
+		prevotes = append(prevotes, processutil.RandomPrevote(r))
+		precommits = append(precommits, processutil.RandomPrecommit(r))
+		timeouts = append(timeouts, processutil.RandomTimeout(r))
+		states = append(states, processutil.RandomState(r))
+	}
+	if err := add("FuzzPropose", proposes...); err != nil {
+		return nil, err
+	}
+	if err := add("FuzzPrevote", prevotes...); err != nil {
+		return nil, err
+	}
+	if err := add("FuzzPrecommit", precommits...); err != nil {
+		return nil, err
+	}
+	if err := add("FuzzTimeout", timeouts...); err != nil {
+		return nil, err
+	}
+	if err := add("FuzzState", states...); err != nil {
+		return nil, err
+	}
+	if err := add("FuzzProcess", processes...); err != nil {
+		return nil, err
+	}
+
+	commitCert := process.CommitCertificate{Height: 1, Round: 0, Value: values[1]}
+	for _, precommit := range precommits[:2] {
+		commitCert.Precommits = append(commitCert.Precommits, precommit.(process.Precommit))
+	}
+	timeoutCert := process.TimeoutCertificate{Height: 1, Round: 0}
+	for _, timeout := range timeouts[:2] {
+		timeoutCert.Timeouts = append(timeoutCert.Timeouts, timeout.(process.Timeout))
+	}
+	if err := add("FuzzCommitCertificate", process.CommitCertificate{}, commitCert); err != nil {
+		return nil, err
+	}
+	if err := add("FuzzTimeoutCertificate", process.TimeoutCertificate{}, timeoutCert); err != nil {
+		return nil, err
+	}
+
+	if err := add("FuzzTimer",
+		timer.Timeout{MessageType: process.MessageTypePropose, Height: 1, Round: 0},
+		timer.Timeout{MessageType: process.MessageTypePrevote, Height: 1, Round: 1},
+		timer.Timeout{MessageType: process.MessageTypePrecommit, Height: 2, Round: 0},
+	); err != nil {
+		return nil, err
+	}
+
+	propose1, propose2 := proposes[1].(process.Propose), proposes[2].(process.Propose)
+	prevote1, prevote2 := prevotes[1].(process.Prevote), prevotes[2].(process.Prevote)
+	precommit1, precommit2 := precommits[1].(process.Precommit), precommits[2].(process.Precommit)
+	if err := add("FuzzEvidence",
+		evidence.List{},
+		evidence.List{
+			evidence.NewDuplicateProposeEvidence(propose1, propose2),
+			evidence.NewDuplicatePrevoteEvidence(prevote1, prevote2),
+			evidence.NewDuplicatePrecommitEvidence(precommit1, precommit2),
+			evidence.NewOutOfTurnProposeEvidence(propose1),
+		},
+	); err != nil {
+		return nil, err
+	}
+
+	if err := add("FuzzWAL",
+		wal.Entry{Type: wal.EntryTypePropose, Value: propose1},
+		wal.Entry{Type: wal.EntryTypePrevote, Value: prevote1},
+		wal.Entry{Type: wal.EntryTypePrecommit, Value: precommit1},
+		wal.Entry{Type: wal.EntryTypeTimeoutMessage, Value: timeouts[0].(process.Timeout)},
+		wal.Entry{Type: wal.EntryTypeTimeout, Value: timer.Timeout{MessageType: process.MessageTypePropose, Height: 1, Round: 0}},
+		wal.Entry{Type: wal.EntryTypeResetHeight, Value: wal.ResetHeight{Height: 2, Signatories: signatories}},
+		wal.Entry{Type: wal.EntryTypeBroadcastPropose, Value: propose2},
+		wal.Entry{Type: wal.EntryTypeBroadcastPrevote, Value: prevote2},
+		wal.Entry{Type: wal.EntryTypeBroadcastPrecommit, Value: precommit2},
+		wal.Entry{Type: wal.EntryTypeSync, Value: commitCert},
+	); err != nil {
+		return nil, err
+	}
+
+	if err := add("FuzzSnapshot",
+		replica.Snapshot{Process: processes[0].(process.Process), Signatories: signatories, Messages: []interface{}{}},
+		replica.Snapshot{Process: processes[2].(process.Process), Signatories: signatories, Messages: []interface{}{propose1, prevote1, precommit1, timeouts[0]}},
+	); err != nil {
+		return nil, err
+	}
+
+	s := sim.New(sim.DefaultOptions().WithTrace(true), len(signatories))
+	if err := s.RunUntilHeight(1, time.Minute); err != nil {
+		return nil, fmt.Errorf("running simulation: %v", err)
+	}
+	buf := new(bytes.Buffer)
+	if err := scenario.Write(buf, s.Scenario()); err != nil {
+		return nil, err
+	}
+	corpus["FuzzScenario"] = [][]byte{buf.Bytes()}
+
+	return corpus, nil
+}
+
+// WriteCorpus writes the seed corpus of every target to the directory, in the
+// layout that go-fuzz expects: the seeds of a target are written to
+// <dir>/<target>/corpus.
+func WriteCorpus(dir string) error {
+	corpus, err := Corpus()
+	if err != nil {
+		return err
+	}
+	for name, seeds := range corpus {
+		seedDir := filepath.Join(dir, name, "corpus")
+		if err := os.MkdirAll(seedDir, 0755); err != nil {
+			return fmt.Errorf("creating directory=%v: %v", seedDir, err)
+		}
+		for i, seed := range seeds {
+			file := filepath.Join(seedDir, fmt.Sprintf("seed-%v", i))
+			if err := ioutil.WriteFile(file, seed, 0644); err != nil {
+				return fmt.Errorf("writing file=%v: %v", file, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+// Package fuzz contains fuzz targets for every decoder that takes untrusted
+// bytes, and for sequences of untrusted inputs to a Process. The targets have
+// the signature that go-fuzz (https://github.com/dvyukov/go-fuzz) expects:
+// they return 1 if the input is interesting (for example, because it was
+// decoded), 0 otherwise, and panic when they find a bug.
+//
+// Seed corpora for every target are returned by Corpus, and can be written in
+// the layout that go-fuzz expects by the hyperdrive-fuzz-corpus command:
+//
+//	hyperdrive-fuzz-corpus workdir
+//	go-fuzz-build -func FuzzPropose github.com/renproject/hyperdrive/fuzz
+//	go-fuzz -bin fuzz-fuzz.zip -workdir workdir/FuzzPropose
+package fuzz
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/renproject/hyperdrive/evidence"
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/hyperdrive/replica"
+	"github.com/renproject/hyperdrive/scenario"
+	"github.com/renproject/hyperdrive/timer"
+	"github.com/renproject/hyperdrive/wal"
+	"github.com/renproject/surge"
+)
+
+// Targets are the fuzz targets, by name.
+var Targets = map[string]func([]byte) int{
+	"FuzzPropose":            FuzzPropose,
+	"FuzzPrevote":            FuzzPrevote,
+	"FuzzPrecommit":          FuzzPrecommit,
+	"FuzzTimeout":            FuzzTimeout,
+	"FuzzCommitCertificate":  FuzzCommitCertificate,
+	"FuzzTimeoutCertificate": FuzzTimeoutCertificate,
+	"FuzzState":              FuzzState,
+	"FuzzProcess":            FuzzProcess,
+	"FuzzTimer":              FuzzTimer,
+	"FuzzEvidence":           FuzzEvidence,
+	"FuzzWAL":                FuzzWAL,
+	"FuzzSnapshot":           FuzzSnapshot,
+	"FuzzScenario":           FuzzScenario,
+	"FuzzInputs":             FuzzInputs,
+}
+
+// FuzzPropose decodes a process.Propose.
+func FuzzPropose(data []byte) int {
+	return decode(data, new(process.Propose))
+}
+
+// FuzzPrevote decodes a process.Prevote.
+func FuzzPrevote(data []byte) int {
+	return decode(data, new(process.Prevote))
+}
+
+// FuzzPrecommit decodes a process.Precommit.
+func FuzzPrecommit(data []byte) int {
+	return decode(data, new(process.Precommit))
+}
+
+// FuzzTimeout decodes a process.Timeout.
+func FuzzTimeout(data []byte) int {
+	return decode(data, new(process.Timeout))
+}
+
+// FuzzCommitCertificate decodes a process.CommitCertificate.
+func FuzzCommitCertificate(data []byte) int {
+	return decode(data, new(process.CommitCertificate))
+}
+
+// FuzzTimeoutCertificate decodes a process.TimeoutCertificate.
+func FuzzTimeoutCertificate(data []byte) int {
+	return decode(data, new(process.TimeoutCertificate))
+}
+
+// FuzzState decodes a process.State.
+func FuzzState(data []byte) int {
+	return decode(data, new(process.State))
+}
+
+// FuzzProcess decodes a process.Process.
+func FuzzProcess(data []byte) int {
+	return decode(data, new(process.Process))
+}
+
+// FuzzTimer decodes a timer.Timeout.
+func FuzzTimer(data []byte) int {
+	return decode(data, new(timer.Timeout))
+}
+
+// FuzzEvidence decodes an evidence.List.
+func FuzzEvidence(data []byte) int {
+	return decode(data, new(evidence.List))
+}
+
+// FuzzWAL decodes a wal.Entry.
+func FuzzWAL(data []byte) int {
+	return decode(data, new(wal.Entry))
+}
+
+// FuzzSnapshot decodes a replica.Snapshot.
+func FuzzSnapshot(data []byte) int {
+	return decode(data, new(replica.Snapshot))
+}
+
+// FuzzScenario reads a scenario.Scenario, and checks that a Scenario that has
+// been read can be written again.
+func FuzzScenario(data []byte) int {
+	s, err := scenario.Read(bytes.NewReader(data))
+	if err != nil {
+		return 0
+	}
+	if err := scenario.Write(ioutil.Discard, s); err != nil {
+		panic(fmt.Sprintf("writing scenario: %v", err))
+	}
+	return 1
+}
+
+// decode the data into the value, and check that a value that has been
+// decoded can be encoded again.
+func decode(data []byte, v surge.Unmarshaler) int {
+	if _, _, err := v.Unmarshal(data, surge.MaxBytes); err != nil {
+		return 0
+	}
+	if _, err := surge.ToBinary(v); err != nil {
+		panic(fmt.Sprintf("marshaling %T: %v", v, err))
+	}
+	return 1
+}
@@ -0,0 +1,13 @@
+package fuzz_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestFuzz(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Fuzz Suite")
+}
@@ -0,0 +1,115 @@
+package fuzz_test
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing/quick"
+	"time"
+
+	"github.com/renproject/hyperdrive/fuzz"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Fuzz targets", func() {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	// mutate returns a copy of the data with a few random bytes changed,
+	// inserted, or removed
+	mutate := func(data []byte) []byte {
+		mutated := append([]byte{}, data...)
+		for n := r.Intn(4) + 1; n > 0; n-- {
+			switch r.Intn(4) {
+			case 0:
+				if len(mutated) > 0 {
+					mutated[r.Intn(len(mutated))] ^= byte(r.Intn(255) + 1)
+				}
+			case 1:
+				if len(mutated) > 0 {
+					mutated[r.Intn(len(mutated))] = 0xFF
+				}
+			case 2:
+				mutated = mutated[:r.Intn(len(mutated)+1)]
+			case 3:
+				i := r.Intn(len(mutated) + 1)
+				mutated = append(mutated[:i], append([]byte{byte(r.Int())}, mutated[i:]...)...)
+			}
+		}
+		return mutated
+	}
+
+	Context("when given their seeds", func() {
+		It("should decode every seed", func() {
+			corpus, err := fuzz.Corpus()
+			Expect(err).ToNot(HaveOccurred())
+			for name, target := range fuzz.Targets {
+				Expect(corpus[name]).ToNot(BeEmpty(), name)
+				if name == "FuzzInputs" {
+					continue
+				}
+				for _, seed := range corpus[name] {
+					Expect(target(seed)).To(Equal(1), name)
+				}
+			}
+		})
+
+		It("should commit with every seed of inputs, except the last one", func() {
+			corpus, err := fuzz.Corpus()
+			Expect(err).ToNot(HaveOccurred())
+			results := []int{}
+			for _, seed := range corpus["FuzzInputs"] {
+				results = append(results, fuzz.FuzzInputs(seed))
+			}
+			Expect(results).To(Equal([]int{1, 1, 1, 0}))
+		})
+	})
+
+	Context("when given mutated seeds", func() {
+		It("should not panic", func() {
+			corpus, err := fuzz.Corpus()
+			Expect(err).ToNot(HaveOccurred())
+			for name, target := range fuzz.Targets {
+				seeds := corpus[name]
+				for i := 0; i < 200; i++ {
+					data := mutate(seeds[r.Intn(len(seeds))])
+					Expect(func() { target(data) }).ToNot(Panic(), name)
+				}
+			}
+		})
+	})
+
+	Context("when given random bytes", func() {
+		It("should not panic", func() {
+			f := func(data []byte) bool {
+				for name, target := range fuzz.Targets {
+					Expect(func() { target(data) }).ToNot(Panic(), name)
+				}
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+
+	Context("when writing the corpus", func() {
+		It("should write the seeds of every target to its own directory", func() {
+			dir, err := ioutil.TempDir("", "fuzz")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(dir)
+
+			Expect(fuzz.WriteCorpus(dir)).To(Succeed())
+			for name, target := range fuzz.Targets {
+				files, err := ioutil.ReadDir(filepath.Join(dir, name, "corpus"))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(files).ToNot(BeEmpty(), name)
+				for _, file := range files {
+					data, err := ioutil.ReadFile(filepath.Join(dir, name, "corpus", file.Name()))
+					Expect(err).ToNot(HaveOccurred())
+					Expect(func() { target(data) }).ToNot(Panic(), name)
+				}
+			}
+		})
+	})
+})
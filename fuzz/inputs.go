@@ -0,0 +1,153 @@
+package fuzz
+
+import (
+	"fmt"
+
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/hyperdrive/scheduler"
+	"github.com/renproject/hyperdrive/sim"
+)
+
+const (
+	inputPropose = iota
+	inputPrevote
+	inputPrecommit
+	inputTimeout
+	inputTimeoutPropose
+	inputTimeoutPrevote
+	inputTimeoutPrecommit
+	numInputs
+)
+
+var (
+	// signatories of the committee in FuzzInputs. The Process under test is
+	// the first one.
+	signatories = sim.GenerateSignatories(0, 4)
+	// values that can be proposed and voted for in FuzzInputs. The third one
+	// is not valid, and the last one is nil.
+	values = [4]process.Value{{1}, {2}, {3}, process.NilValue}
+)
+
+type proposer struct{}
+
+func (proposer) Propose(height process.Height, round process.Round) process.Value {
+	return values[(int64(height)+int64(round))%3]
+}
+
+type validator struct{}
+
+func (validator) Valid(height process.Height, round process.Round, value process.Value) bool {
+	return value != values[2]
+}
+
+// An input to the Process in FuzzInputs. It is encoded in 4 bytes.
+type input struct {
+	kind       int
+	height     process.Height // relative to the current Height, from -1 to 1
+	round      process.Round  // from -1 to 6
+	value      int            // index into values
+	validRound process.Round  // from -1 to 6
+	from       int            // index into signatories
+}
+
+func decodeInput(b []byte) input {
+	return input{
+		kind:       int(b[0]) % numInputs,
+		height:     process.Height(b[1]>>3)%3 - 1,
+		round:      process.Round(b[1]&7) - 1,
+		value:      int(b[2] & 3),
+		validRound: process.Round(b[2]>>2&7) - 1,
+		from:       int(b[3] & 3),
+	}
+}
+
+func (in input) bytes() []byte {
+	return []byte{
+		byte(in.kind),
+		byte(in.height+1)<<3 | byte(in.round+1),
+		byte(in.validRound+1)<<2 | byte(in.value),
+		byte(in.from),
+	}
+}
+
+// FuzzInputs decodes a sequence of inputs, gives them to a Process in a
+// committee of 4, and panics if the Process violates one of its invariants:
+// it must never broadcast more than one non-nil Precommit in a Round, and its
+// LockedRound must never be greater than its CurrentRound. It returns 1 if the
+// Process commits.
+//
+// Every input is encoded in 4 bytes, and trailing bytes are ignored. The
+// first byte is the type of the input: a Propose, Prevote, Precommit, or
+// Timeout message, or a propose, prevote, or precommit timeout. The second
+// byte is the Height (relative to the current Height of the Process) and
+// Round, the third byte is the Value and ValidRound, and the fourth byte is
+// the sender. Heights, Rounds, Values, and senders are taken from small sets,
+// so that inputs are likely to trigger the rules of the Process.
+func FuzzInputs(data []byte) int {
+	if _, committed := runInputs(data); committed {
+		return 1
+	}
+	return 0
+}
+
+// runInputs gives the inputs to a Process, and returns the Process, and
+// whether or not it committed.
+func runInputs(data []byte) (*process.ActionProcess, bool) {
+	p := process.NewActionProcess(process.New(signatories[0], len(signatories), nil, scheduler.NewRoundRobin(signatories), proposer{}, validator{}, nil, nil, nil, nil))
+	precommitted := map[[2]int64]bool{}
+	committed := false
+
+	execute := func(actions []process.Action) {
+		for len(actions) > 0 {
+			commit := false
+			for _, action := range actions {
+				switch action := action.(type) {
+				case process.Broadcast:
+					precommit, ok := action.Message.(process.Precommit)
+					if !ok || precommit.Value == process.NilValue {
+						continue
+					}
+					key := [2]int64{int64(precommit.Height), int64(precommit.Round)}
+					if precommitted[key] {
+						panic(fmt.Sprintf("precommitted twice at height=%v, round=%v", precommit.Height, precommit.Round))
+					}
+					precommitted[key] = true
+				case process.Commit:
+					commit = true
+				}
+			}
+			if p.LockedRound > p.CurrentRound {
+				panic(fmt.Sprintf("locked round=%v after current round=%v", p.LockedRound, p.CurrentRound))
+			}
+			actions = nil
+			if commit {
+				committed = true
+				actions = p.Committed(nil, nil)
+			}
+		}
+	}
+
+	execute(p.Start())
+	for ; len(data) >= 4; data = data[4:] {
+		in := decodeInput(data)
+		height := p.CurrentHeight + in.height
+		from := signatories[in.from]
+		switch in.kind {
+		case inputPropose:
+			execute(p.Propose(process.Propose{Height: height, Round: in.round, ValidRound: in.validRound, Value: values[in.value], From: from}))
+		case inputPrevote:
+			execute(p.Prevote(process.Prevote{Height: height, Round: in.round, Value: values[in.value], From: from}))
+		case inputPrecommit:
+			execute(p.Precommit(process.Precommit{Height: height, Round: in.round, Value: values[in.value], From: from}))
+		case inputTimeout:
+			execute(p.Timeout(process.Timeout{Height: height, Round: in.round, From: from}))
+		case inputTimeoutPropose:
+			execute(p.OnTimeoutPropose(height, in.round))
+		case inputTimeoutPrevote:
+			execute(p.OnTimeoutPrevote(height, in.round))
+		case inputTimeoutPrecommit:
+			execute(p.OnTimeoutPrecommit(height, in.round))
+		}
+	}
+	return p, committed
+}
@@ -0,0 +1,158 @@
+package modelcheck
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/hyperdrive/timer"
+	"github.com/renproject/id"
+)
+
+// encoder appends a canonical encoding of messages, timeouts, and States to a
+// buffer. It is used instead of surge, because the model checker encodes
+// every state that it explores, and surge uses reflection to encode maps.
+// Signatures are not encoded, because messages are not signed in a model
+// check.
+type encoder []byte
+
+func (e encoder) int(v int64) encoder {
+	buf := [8]byte{}
+	binary.BigEndian.PutUint64(buf[:], uint64(v))
+	return append(e, buf[:]...)
+}
+
+func (e encoder) hash(h [32]byte) encoder {
+	return append(e, h[:]...)
+}
+
+func (e encoder) message(msg interface{}) encoder {
+	switch msg := msg.(type) {
+	case process.Propose:
+		return append(e, 1).int(int64(msg.Height)).int(int64(msg.Round)).int(int64(msg.ValidRound)).hash(msg.Value).hash(msg.From)
+	case process.Prevote:
+		return append(e, 2).int(int64(msg.Height)).int(int64(msg.Round)).hash(msg.Value).hash(msg.From)
+	case process.Precommit:
+		return append(e, 3).int(int64(msg.Height)).int(int64(msg.Round)).hash(msg.Value).hash(msg.From)
+	case process.Timeout:
+		return append(e, 4).int(int64(msg.Height)).int(int64(msg.Round)).hash(msg.From)
+	case timer.Timeout:
+		return append(e, 5, byte(msg.MessageType)).int(int64(msg.Height)).int(int64(msg.Round))
+	}
+	panic("unknown message type")
+}
+
+// state encodes the State, including its message logs and once-flags. Maps
+// are encoded in the order of their keys.
+func (e encoder) state(s process.State) encoder {
+	e = e.int(int64(s.CurrentHeight)).
+		int(int64(s.CurrentRound)).
+		int(int64(s.CurrentStep)).
+		hash(s.LockedValue).
+		int(int64(s.LockedRound)).
+		hash(s.ValidValue).
+		int(int64(s.ValidRound))
+
+	rounds := make([]process.Round, 0, len(s.ProposeLogs))
+	for round := range s.ProposeLogs {
+		rounds = append(rounds, round)
+	}
+	e = e.int(int64(len(rounds)))
+	for _, round := range sortRounds(rounds) {
+		valid := byte(0)
+		if s.ProposeIsValid[round] {
+			valid = 1
+		}
+		e = append(e.message(s.ProposeLogs[round]), valid)
+	}
+
+	rounds = rounds[:0]
+	for round := range s.PrevoteLogs {
+		rounds = append(rounds, round)
+	}
+	e = e.int(int64(len(rounds)))
+	for _, round := range sortRounds(rounds) {
+		prevotes := s.PrevoteLogs[round]
+		froms := make([]id.Signatory, 0, len(prevotes))
+		for from := range prevotes {
+			froms = append(froms, from)
+		}
+		e = e.int(int64(len(froms)))
+		for _, from := range sortSignatories(froms) {
+			e = e.message(prevotes[from])
+		}
+	}
+
+	rounds = rounds[:0]
+	for round := range s.PrecommitLogs {
+		rounds = append(rounds, round)
+	}
+	e = e.int(int64(len(rounds)))
+	for _, round := range sortRounds(rounds) {
+		precommits := s.PrecommitLogs[round]
+		froms := make([]id.Signatory, 0, len(precommits))
+		for from := range precommits {
+			froms = append(froms, from)
+		}
+		e = e.int(int64(len(froms)))
+		for _, from := range sortSignatories(froms) {
+			e = e.message(precommits[from])
+		}
+	}
+
+	rounds = rounds[:0]
+	for round := range s.TimeoutLogs {
+		rounds = append(rounds, round)
+	}
+	e = e.int(int64(len(rounds)))
+	for _, round := range sortRounds(rounds) {
+		timeouts := s.TimeoutLogs[round]
+		froms := make([]id.Signatory, 0, len(timeouts))
+		for from := range timeouts {
+			froms = append(froms, from)
+		}
+		e = e.int(int64(len(froms)))
+		for _, from := range sortSignatories(froms) {
+			e = e.message(timeouts[from])
+		}
+	}
+
+	rounds = rounds[:0]
+	for round := range s.TraceLogs {
+		rounds = append(rounds, round)
+	}
+	e = e.int(int64(len(rounds)))
+	for _, round := range sortRounds(rounds) {
+		froms := make([]id.Signatory, 0, len(s.TraceLogs[round]))
+		for from, ok := range s.TraceLogs[round] {
+			if ok {
+				froms = append(froms, from)
+			}
+		}
+		e = e.int(int64(round)).int(int64(len(froms)))
+		for _, from := range sortSignatories(froms) {
+			e = e.hash(from)
+		}
+	}
+
+	rounds = rounds[:0]
+	for round := range s.OnceFlags {
+		rounds = append(rounds, round)
+	}
+	e = e.int(int64(len(rounds)))
+	for _, round := range sortRounds(rounds) {
+		e = e.int(int64(round)).int(int64(s.OnceFlags[round]))
+	}
+	return e
+}
+
+func sortRounds(rounds []process.Round) []process.Round {
+	sort.Slice(rounds, func(i, j int) bool { return rounds[i] < rounds[j] })
+	return rounds
+}
+
+func sortSignatories(signatories []id.Signatory) []id.Signatory {
+	sort.Slice(signatories, func(i, j int) bool { return bytes.Compare(signatories[i][:], signatories[j][:]) < 0 })
+	return signatories
+}
@@ -0,0 +1,138 @@
+package modelcheck
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/id"
+)
+
+// A World is a global state of the committee that is being explored. Worlds
+// share memory with the model checker, and must not be modified.
+type World struct {
+	Signatories []id.Signatory
+	// States of the Processes, in order of their index.
+	States []process.State
+	// Commits of the Processes, in order of their index.
+	Commits []map[process.Height]process.Value
+	// Sent are the messages that have been broadcast by the Processes, in a
+	// canonical order.
+	Sent []interface{}
+}
+
+// An Invariant is a property that must hold in every World. It returns an
+// error that describes why the property does not hold, or nil if it does.
+type Invariant func(World) error
+
+// Agreement is the Invariant that no two Processes commit different Values at
+// the same Height.
+func Agreement(w World) error {
+	committed := map[process.Height]int{}
+	for i, commits := range w.Commits {
+		for _, height := range heights(commits) {
+			j, ok := committed[height]
+			if !ok {
+				committed[height] = i
+				continue
+			}
+			if value, other := w.Commits[j][height], commits[height]; !value.Equal(&other) {
+				return fmt.Errorf("process=%v committed value=%v, but process=%v committed value=%v at height=%v", j, value, i, other, height)
+			}
+		}
+	}
+	return nil
+}
+
+// Locks is the Invariant that the locks of every Process are consistent, and
+// that a quorum of Precommits for a Value protects it: once a quorum of
+// Processes has precommitted a Value in a Round, no Process can lock on a
+// different Value in that Round or a later one, and no quorum of Prevotes for
+// a different Value can be formed in a later Round.
+func Locks(w World) error {
+	for i, state := range w.States {
+		if (state.LockedRound == process.InvalidRound) != (state.LockedValue == process.NilValue) {
+			return fmt.Errorf("process=%v has locked round=%v with locked value=%v", i, state.LockedRound, state.LockedValue)
+		}
+		if state.LockedRound > state.ValidRound {
+			return fmt.Errorf("process=%v has locked round=%v after valid round=%v", i, state.LockedRound, state.ValidRound)
+		}
+		if state.LockedRound > state.CurrentRound {
+			return fmt.Errorf("process=%v has locked round=%v after current round=%v", i, state.LockedRound, state.CurrentRound)
+		}
+	}
+
+	n := len(w.Signatories)
+	quorum := n - (n-1)/3
+	prevotes, precommits := quorums(w.Sent, quorum)
+	for _, precommit := range precommits {
+		for i, state := range w.States {
+			if state.CurrentHeight != precommit.Height || state.LockedRound < precommit.Round {
+				continue
+			}
+			if !state.LockedValue.Equal(&precommit.Value) {
+				return fmt.Errorf("process=%v locked value=%v in round=%v, but a quorum precommitted value=%v in round=%v at height=%v", i, state.LockedValue, state.LockedRound, precommit.Value, precommit.Round, precommit.Height)
+			}
+		}
+		for _, prevote := range prevotes {
+			if prevote.Height != precommit.Height || prevote.Round <= precommit.Round {
+				continue
+			}
+			if !prevote.Value.Equal(&precommit.Value) {
+				return fmt.Errorf("a quorum prevoted value=%v in round=%v, but a quorum precommitted value=%v in round=%v at height=%v", prevote.Value, prevote.Round, precommit.Value, precommit.Round, precommit.Height)
+			}
+		}
+	}
+	return nil
+}
+
+// vote is a Height, Round, and non-nil Value that has been voted for.
+type vote struct {
+	Height process.Height
+	Round  process.Round
+	Value  process.Value
+}
+
+// quorums returns the votes for which a quorum of Prevotes, and a quorum of
+// Precommits, have been sent. Votes for process.NilValue are ignored.
+func quorums(sent []interface{}, quorum int) ([]vote, []vote) {
+	prevotes, precommits := map[vote]int{}, map[vote]int{}
+	for _, msg := range sent {
+		switch msg := msg.(type) {
+		case process.Prevote:
+			if msg.Value != process.NilValue {
+				prevotes[vote{msg.Height, msg.Round, msg.Value}]++
+			}
+		case process.Precommit:
+			if msg.Value != process.NilValue {
+				precommits[vote{msg.Height, msg.Round, msg.Value}]++
+			}
+		}
+	}
+	return atLeast(prevotes, quorum), atLeast(precommits, quorum)
+}
+
+func atLeast(counts map[vote]int, quorum int) []vote {
+	votes := []vote{}
+	for v, count := range counts {
+		if count >= quorum {
+			votes = append(votes, v)
+		}
+	}
+	sort.Slice(votes, func(i, j int) bool {
+		if votes[i].Height != votes[j].Height {
+			return votes[i].Height < votes[j].Height
+		}
+		return votes[i].Round < votes[j].Round
+	})
+	return votes
+}
+
+func heights(commits map[process.Height]process.Value) []process.Height {
+	heights := make([]process.Height, 0, len(commits))
+	for height := range commits {
+		heights = append(heights, height)
+	}
+	sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+	return heights
+}
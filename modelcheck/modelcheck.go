@@ -0,0 +1,468 @@
+// Package modelcheck is a bounded model checker for small committees of
+// Processes. Random tests, and simulations, sample the interleavings of
+// messages and timeouts, but consensus bugs can hide in rare orderings. The
+// model checker explores every one of them, up to a given depth.
+//
+// The committee is modelled as a set of Processes, an asynchronous network of
+// in-flight messages, and a set of pending timeouts. A transition either
+// delivers an in-flight message to its receiver, or fires a pending timeout.
+// Messages can be delivered in any order, and timeouts can fire at any time,
+// but messages are never lost. Messages that a Process broadcasts are
+// delivered to itself immediately, and messages from future Heights are only
+// delivered once their receiver has reached that Height (in the same way that
+// a Replica buffers them). Messages and timeouts that can no longer have an
+// effect on their receiver are pruned.
+//
+// Starting from the state in which every Process has started, paths of
+// transitions are explored depth-first. Every state is hashed, and states that
+// have already been explored (with at least as many transitions remaining)
+// are not explored again. The Invariants of the Options are checked in every
+// state, and the first Violation is returned with the path of transitions that
+// leads to it.
+package modelcheck
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/hyperdrive/scenario"
+	"github.com/renproject/hyperdrive/scheduler"
+	"github.com/renproject/hyperdrive/sim"
+	"github.com/renproject/hyperdrive/timer"
+	"github.com/renproject/id"
+)
+
+// A Transition delivers a message to a Process, or fires a timeout of a
+// Process. The Input is a process.Propose, process.Prevote,
+// process.Precommit, or process.Timeout message, or a timer.Timeout.
+type Transition struct {
+	To    int
+	Input interface{}
+}
+
+// String implements the Stringer interface.
+func (t Transition) String() string {
+	return fmt.Sprintf("process=%v %v", t.To, scenario.Describe(t.Input))
+}
+
+// A Violation of an Invariant, and the path of Transitions that leads to it
+// from the initial state.
+type Violation struct {
+	Err  error
+	Path []Transition
+}
+
+// Error implements the error interface, and returns the Violation with its
+// path.
+func (v Violation) Error() string {
+	lines := []string{fmt.Sprintf("invariant violated after %v transitions: %v", len(v.Path), v.Err)}
+	for i, t := range v.Path {
+		lines = append(lines, fmt.Sprintf("%4d %v", i, t))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// A Result of a model check.
+type Result struct {
+	// States is the number of distinct states that were explored.
+	States int
+	// Transitions is the number of Transitions that were taken.
+	Transitions int
+	// Complete is true if every path, up to the depth, was explored. It is
+	// false if the model check stopped because it found a Violation, or
+	// reached the maximum number of states.
+	Complete bool
+	// Violation is the first Violation that was found, or nil if none were.
+	Violation *Violation
+}
+
+// Check explores the states of a committee of n Processes, and returns the
+// Result. The Processes propose a different Value in every Height and Round,
+// and every Value is valid.
+func Check(opts Options, n int) Result {
+	mc := &modelChecker{
+		opts:        opts,
+		signatories: sim.GenerateSignatories(0, n),
+		visited:     map[[32]byte]int{},
+		path:        []Transition{},
+		result:      Result{Complete: true},
+	}
+	mc.explore(mc.initial(), opts.Depth)
+	mc.result.States = len(mc.visited)
+	return mc.result
+}
+
+type modelChecker struct {
+	opts        Options
+	signatories []id.Signatory
+
+	// visited maps the hash of every explored state to the largest number of
+	// transitions that were remaining when it was explored.
+	visited map[[32]byte]int
+	path    []Transition
+	result  Result
+}
+
+// state is a global state of the committee. States are copied on write, and
+// Processes are only cloned when they receive an input.
+type state struct {
+	nodes   []*node
+	network []envelope
+	timers  []envelope
+	sent    []envelope
+}
+
+// node is a Process, the Values that it has committed, and the hash of both.
+type node struct {
+	proc    *process.ActionProcess
+	commits map[process.Height]process.Value
+	hash    [32]byte
+}
+
+// envelope is a message, or timeout, with its receiver. The key of an
+// envelope is a canonical encoding of both, so that envelopes can be sorted
+// and hashed.
+type envelope struct {
+	to  int
+	msg interface{}
+	key string
+}
+
+// explore the state, and the states that can be reached from it in at most
+// depth transitions. It returns false if the model check must stop.
+func (mc *modelChecker) explore(s *state, depth int) bool {
+	hash := s.hash()
+	remaining, ok := mc.visited[hash]
+	if ok && remaining >= depth {
+		return true
+	}
+	if !ok && mc.opts.MaxStates > 0 && len(mc.visited) >= mc.opts.MaxStates {
+		mc.result.Complete = false
+		return false
+	}
+	mc.visited[hash] = depth
+
+	world := s.world(mc.signatories)
+	for _, invariant := range mc.opts.Invariants {
+		if err := invariant(world); err != nil {
+			path := make([]Transition, len(mc.path))
+			copy(path, mc.path)
+			mc.result.Violation = &Violation{Err: err, Path: path}
+			mc.result.Complete = false
+			return false
+		}
+	}
+	if depth == 0 {
+		return true
+	}
+
+	for _, t := range mc.enabled(s) {
+		mc.result.Transitions++
+		mc.path = append(mc.path, t)
+		ok := mc.explore(mc.apply(s, t), depth-1)
+		mc.path = mc.path[:len(mc.path)-1]
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// initial returns the state in which every Process has started.
+func (mc *modelChecker) initial() *state {
+	n := len(mc.signatories)
+	s := &state{
+		nodes:   make([]*node, n),
+		network: []envelope{},
+		timers:  []envelope{},
+		sent:    []envelope{},
+	}
+	for i := range s.nodes {
+		proc := process.New(
+			mc.signatories[i],
+			n,
+			nil,
+			scheduler.NewRoundRobin(mc.signatories),
+			proposer{whoami: mc.signatories[i]},
+			validator{},
+			nil,
+			nil,
+			nil,
+			nil,
+		)
+		s.nodes[i] = &node{
+			proc:    process.NewActionProcess(proc),
+			commits: map[process.Height]process.Value{},
+		}
+	}
+	for i := range s.nodes {
+		mc.execute(s, i, s.nodes[i].proc.Start())
+	}
+	for i := range s.nodes {
+		s.nodes[i].rehash()
+	}
+	s.prune(mc.opts)
+	return s
+}
+
+// enabled returns the Transitions that can be taken from the state, in a
+// canonical order. Identical in-flight messages result in one Transition.
+func (mc *modelChecker) enabled(s *state) []Transition {
+	transitions := []Transition{}
+	for k, e := range s.network {
+		if k > 0 && s.network[k-1].key == e.key {
+			continue
+		}
+		if heightOf(e.msg) == s.nodes[e.to].proc.CurrentHeight {
+			transitions = append(transitions, Transition{To: e.to, Input: e.msg})
+		}
+	}
+	for _, e := range s.timers {
+		transitions = append(transitions, Transition{To: e.to, Input: e.msg})
+	}
+	return transitions
+}
+
+// apply the Transition to a copy of the state, and return the copy.
+func (mc *modelChecker) apply(s *state, t Transition) *state {
+	next := &state{
+		nodes:   make([]*node, len(s.nodes)),
+		network: s.network,
+		timers:  s.timers,
+		sent:    s.sent,
+	}
+	copy(next.nodes, s.nodes)
+	next.nodes[t.To] = s.nodes[t.To].clone()
+
+	k := encode(t.To, t.Input)
+	if _, ok := t.Input.(timer.Timeout); ok {
+		next.timers = remove(s.timers, k)
+	} else {
+		next.network = remove(s.network, k)
+	}
+
+	mc.execute(next, t.To, receive(next.nodes[t.To].proc, t.Input))
+	next.nodes[t.To].rehash()
+	next.prune(mc.opts)
+	return next
+}
+
+// execute the Actions of a Process. Messages that are broadcast are sent to
+// the other Processes, and delivered to the Process itself, once all of the
+// Actions have been executed.
+func (mc *modelChecker) execute(s *state, i int, actions []process.Action) {
+	n := s.nodes[i]
+	self := []interface{}{}
+	for _, action := range actions {
+		switch action := action.(type) {
+		case process.Broadcast:
+			if key := encode(-1, action.Message); !contains(s.sent, key) {
+				s.sent = insert(s.sent, envelope{to: -1, msg: action.Message, key: key})
+			}
+			for j := range s.nodes {
+				if j == i {
+					continue
+				}
+				s.network = insert(s.network, envelope{to: j, msg: action.Message, key: encode(j, action.Message)})
+			}
+			self = append(self, action.Message)
+		case process.ScheduleTimeout:
+			timeout := timer.Timeout{MessageType: action.MessageType, Height: action.Height, Round: action.Round}
+			s.timers = insert(s.timers, envelope{to: i, msg: timeout, key: encode(i, timeout)})
+		case process.Commit:
+			n.commits[action.Certificate.Height] = action.Certificate.Value
+			// processes stop once they have committed enough heights, so
+			// that the next height is not explored
+			if action.Certificate.Height < mc.opts.Heights {
+				mc.execute(s, i, n.proc.Committed(nil, nil))
+			}
+		}
+	}
+	for _, msg := range self {
+		mc.execute(s, i, receive(n.proc, msg))
+	}
+}
+
+// receive an input, and return the resulting Actions.
+func receive(proc *process.ActionProcess, input interface{}) []process.Action {
+	switch input := input.(type) {
+	case process.Propose:
+		return proc.Propose(input)
+	case process.Prevote:
+		return proc.Prevote(input)
+	case process.Precommit:
+		return proc.Precommit(input)
+	case process.Timeout:
+		return proc.Timeout(input)
+	case timer.Timeout:
+		switch input.MessageType {
+		case process.MessageTypePropose:
+			return proc.OnTimeoutPropose(input.Height, input.Round)
+		case process.MessageTypePrevote:
+			return proc.OnTimeoutPrevote(input.Height, input.Round)
+		case process.MessageTypePrecommit:
+			return proc.OnTimeoutPrecommit(input.Height, input.Round)
+		}
+	}
+	return nil
+}
+
+// prune the messages and timeouts that can no longer have an effect on their
+// receivers. Messages from previous Heights are ignored by Processes, as are
+// timeouts from previous Heights and Rounds. Processes that have stopped
+// ignore everything. Timeouts for Rounds that are not explored are pruned too.
+func (s *state) prune(opts Options) {
+	stopped := func(i int) bool {
+		return s.nodes[i].proc.CurrentHeight > opts.Heights
+	}
+	network := make([]envelope, 0, len(s.network))
+	for _, e := range s.network {
+		if !stopped(e.to) && heightOf(e.msg) >= s.nodes[e.to].proc.CurrentHeight {
+			network = append(network, e)
+		}
+	}
+	timers := make([]envelope, 0, len(s.timers))
+	for _, e := range s.timers {
+		timeout := e.msg.(timer.Timeout)
+		proc := s.nodes[e.to].proc
+		if !stopped(e.to) && timeout.Height == proc.CurrentHeight && timeout.Round == proc.CurrentRound && timeout.Round < opts.Rounds {
+			timers = append(timers, e)
+		}
+	}
+	s.network, s.timers = network, timers
+}
+
+// hash returns a hash that is the same for two states if, and only if, they
+// are the same (with overwhelming probability).
+func (s *state) hash() [32]byte {
+	h := sha256.New()
+	for _, n := range s.nodes {
+		h.Write(n.hash[:])
+	}
+	for _, envelopes := range [][]envelope{s.network, s.timers, s.sent} {
+		length := [8]byte{}
+		binary.BigEndian.PutUint64(length[:], uint64(len(envelopes)))
+		h.Write(length[:])
+		for _, e := range envelopes {
+			h.Write([]byte(e.key))
+		}
+	}
+	hash := [32]byte{}
+	copy(hash[:], h.Sum(nil))
+	return hash
+}
+
+// world returns the World of the state, for checking Invariants.
+func (s *state) world(signatories []id.Signatory) World {
+	w := World{
+		Signatories: signatories,
+		States:      make([]process.State, len(s.nodes)),
+		Commits:     make([]map[process.Height]process.Value, len(s.nodes)),
+		Sent:        make([]interface{}, len(s.sent)),
+	}
+	for i, n := range s.nodes {
+		w.States[i] = n.proc.State
+		w.Commits[i] = n.commits
+	}
+	for i, e := range s.sent {
+		w.Sent[i] = e.msg
+	}
+	return w
+}
+
+// clone the node, so that it can receive an input without affecting the
+// states that share it.
+func (n *node) clone() *node {
+	proc := n.proc.Process
+	proc.State = proc.State.Clone()
+	commits := make(map[process.Height]process.Value, len(n.commits))
+	for height, value := range n.commits {
+		commits[height] = value
+	}
+	return &node{
+		proc:    process.NewActionProcess(proc),
+		commits: commits,
+		hash:    n.hash,
+	}
+}
+
+// rehash the node after it has received an input.
+func (n *node) rehash() {
+	e := encoder{}.state(n.proc.State)
+	for _, height := range heights(n.commits) {
+		e = e.int(int64(height)).hash(n.commits[height])
+	}
+	n.hash = sha256.Sum256(e)
+}
+
+// proposer proposes a different Value in every Height and Round.
+type proposer struct {
+	whoami id.Signatory
+}
+
+func (p proposer) Propose(height process.Height, round process.Round) process.Value {
+	data := [16]byte{}
+	binary.BigEndian.PutUint64(data[:8], uint64(height))
+	binary.BigEndian.PutUint64(data[8:], uint64(round))
+	return process.Value(sha256.Sum256(append(p.whoami[:], data[:]...)))
+}
+
+// validator accepts every Value except process.NilValue.
+type validator struct{}
+
+func (validator) Valid(height process.Height, round process.Round, value process.Value) bool {
+	return value != process.NilValue
+}
+
+// encode the receiver and message of an envelope into its key. Receivers of
+// -1 are used for messages that are not addressed to any Process.
+func encode(to int, msg interface{}) string {
+	return string(encoder{}.int(int64(to)).message(msg))
+}
+
+// insert the envelope into the sorted envelopes, and return the result without
+// modifying them.
+func insert(envelopes []envelope, e envelope) []envelope {
+	k := sort.Search(len(envelopes), func(k int) bool { return envelopes[k].key >= e.key })
+	inserted := make([]envelope, 0, len(envelopes)+1)
+	inserted = append(inserted, envelopes[:k]...)
+	inserted = append(inserted, e)
+	return append(inserted, envelopes[k:]...)
+}
+
+// contains returns true if the sorted envelopes contain an envelope with the
+// key.
+func contains(envelopes []envelope, key string) bool {
+	k := sort.Search(len(envelopes), func(k int) bool { return envelopes[k].key >= key })
+	return k < len(envelopes) && envelopes[k].key == key
+}
+
+// remove one envelope with the key from the sorted envelopes, and return the
+// result without modifying them.
+func remove(envelopes []envelope, key string) []envelope {
+	if !contains(envelopes, key) {
+		return envelopes
+	}
+	k := sort.Search(len(envelopes), func(k int) bool { return envelopes[k].key >= key })
+	removed := make([]envelope, 0, len(envelopes)-1)
+	removed = append(removed, envelopes[:k]...)
+	return append(removed, envelopes[k+1:]...)
+}
+
+func heightOf(msg interface{}) process.Height {
+	switch msg := msg.(type) {
+	case process.Propose:
+		return msg.Height
+	case process.Prevote:
+		return msg.Height
+	case process.Precommit:
+		return msg.Height
+	case process.Timeout:
+		return msg.Height
+	}
+	return 0
+}
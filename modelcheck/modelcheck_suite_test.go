@@ -0,0 +1,13 @@
+package modelcheck_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestModelCheck(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "ModelCheck Suite")
+}
@@ -0,0 +1,207 @@
+package modelcheck_test
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/renproject/hyperdrive/modelcheck"
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/hyperdrive/process/processutil"
+	"github.com/renproject/hyperdrive/sim"
+	"github.com/renproject/hyperdrive/timer"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Model checker", func() {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	// world returns a world of 4 processes in their default states
+	world := func() modelcheck.World {
+		w := modelcheck.World{
+			Signatories: sim.GenerateSignatories(r.Int63(), 4),
+			States:      make([]process.State, 4),
+			Commits:     make([]map[process.Height]process.Value, 4),
+			Sent:        []interface{}{},
+		}
+		for i := range w.States {
+			w.States[i] = process.DefaultState()
+			w.Commits[i] = map[process.Height]process.Value{}
+		}
+		return w
+	}
+
+	Context("when exploring a committee of 4 processes", func() {
+		It("should explore every state up to the depth without violations", func() {
+			result := modelcheck.Check(modelcheck.DefaultOptions().WithDepth(6), 4)
+			Expect(result.Violation).To(BeNil())
+			Expect(result.Complete).To(BeTrue())
+			Expect(result.States).To(BeNumerically(">", 1000))
+			Expect(result.Transitions).To(BeNumerically(">", result.States))
+		})
+
+		It("should explore the same states every time", func() {
+			opts := modelcheck.DefaultOptions().WithDepth(4)
+			Expect(modelcheck.Check(opts, 4)).To(Equal(modelcheck.Check(opts, 4)))
+		})
+
+		It("should explore more states at a greater depth", func() {
+			states := 0
+			for depth := 0; depth <= 4; depth++ {
+				result := modelcheck.Check(modelcheck.DefaultOptions().WithDepth(depth), 4)
+				Expect(result.States).To(BeNumerically(">", states))
+				states = result.States
+			}
+		})
+
+		It("should stop when it reaches the maximum number of states", func() {
+			result := modelcheck.Check(modelcheck.DefaultOptions().WithDepth(6).WithMaxStates(100), 4)
+			Expect(result.Violation).To(BeNil())
+			Expect(result.Complete).To(BeFalse())
+			Expect(result.States).To(Equal(100))
+		})
+
+		It("should explore deep enough to check states in which processes have committed", func() {
+			// at a depth of 6, no process can commit, so the invariants are
+			// only checked against commits when exploring deeper
+			observed := 0
+			countCommits := func(w modelcheck.World) error {
+				for _, commits := range w.Commits {
+					if _, ok := commits[1]; ok {
+						observed++
+					}
+				}
+				return nil
+			}
+			opts := modelcheck.DefaultOptions().
+				WithDepth(12).
+				WithMaxStates(20000).
+				WithInvariants(modelcheck.Agreement, modelcheck.Locks, countCommits)
+			result := modelcheck.Check(opts, 4)
+			Expect(result.Violation).To(BeNil())
+			Expect(result.States).To(Equal(20000))
+			Expect(observed).To(BeNumerically(">", 0))
+		})
+	})
+
+	Context("when exploring a committee of 7 processes", func() {
+		It("should explore every state up to the depth without violations", func() {
+			result := modelcheck.Check(modelcheck.DefaultOptions().WithDepth(3), 7)
+			Expect(result.Violation).To(BeNil())
+			Expect(result.Complete).To(BeTrue())
+		})
+	})
+
+	Context("when an invariant does not hold", func() {
+		It("should return the violation, with the path that leads to it", func() {
+			// processes commit, so an invariant that they do not must be
+			// violated
+			noCommits := func(w modelcheck.World) error {
+				for i, commits := range w.Commits {
+					if len(commits) > 0 {
+						return fmt.Errorf("process=%v committed", i)
+					}
+				}
+				return nil
+			}
+			opts := modelcheck.DefaultOptions().
+				WithDepth(12).
+				WithInvariants(modelcheck.Agreement, modelcheck.Locks, noCommits)
+			result := modelcheck.Check(opts, 4)
+			Expect(result.Complete).To(BeFalse())
+			Expect(result.Violation).ToNot(BeNil())
+			Expect(len(result.Violation.Path)).To(BeNumerically(">=", 10))
+			Expect(len(result.Violation.Path)).To(BeNumerically("<=", 12))
+
+			// the last transition delivers the precommit that completes the
+			// quorum of the process that committed
+			last := result.Violation.Path[len(result.Violation.Path)-1]
+			Expect(last.Input).To(BeAssignableToTypeOf(process.Precommit{}))
+			Expect(result.Violation.Err.Error()).To(Equal(fmt.Sprintf("process=%v committed", last.To)))
+			Expect(result.Violation.Error()).To(ContainSubstring("invariant violated"))
+			Expect(result.Violation.Error()).To(ContainSubstring(last.String()))
+		})
+
+		It("should fire timeouts", func() {
+			noTimeouts := func(w modelcheck.World) error {
+				for _, msg := range w.Sent {
+					if prevote, ok := msg.(process.Prevote); ok && prevote.Value == process.NilValue {
+						return fmt.Errorf("prevoted nil")
+					}
+				}
+				return nil
+			}
+			result := modelcheck.Check(modelcheck.DefaultOptions().WithDepth(2).WithInvariants(noTimeouts), 4)
+			Expect(result.Violation).ToNot(BeNil())
+			Expect(result.Violation.Path[len(result.Violation.Path)-1].Input).To(Equal(timer.Timeout{
+				MessageType: process.MessageTypePropose,
+				Height:      1,
+				Round:       0,
+			}))
+		})
+	})
+
+	Context("when checking agreement", func() {
+		It("should hold when processes commit the same values", func() {
+			w := world()
+			value := processutil.RandomGoodValue(r)
+			for i := range w.Commits {
+				w.Commits[i][1] = value
+			}
+			w.Commits[0][2] = processutil.RandomGoodValue(r)
+			Expect(modelcheck.Agreement(w)).To(Succeed())
+		})
+
+		It("should not hold when processes commit different values", func() {
+			w := world()
+			w.Commits[0][1] = processutil.RandomGoodValue(r)
+			w.Commits[2][1] = processutil.RandomGoodValue(r)
+			Expect(modelcheck.Agreement(w)).ToNot(Succeed())
+		})
+	})
+
+	Context("when checking locks", func() {
+		It("should not hold when a process is locked on nil", func() {
+			w := world()
+			w.States[0].LockedRound = 0
+			w.States[0].ValidRound = 0
+			Expect(modelcheck.Locks(w)).ToNot(Succeed())
+		})
+
+		It("should not hold when a process is locked after its valid round", func() {
+			w := world()
+			w.States[0].LockedRound = 0
+			w.States[0].LockedValue = processutil.RandomGoodValue(r)
+			Expect(modelcheck.Locks(w)).ToNot(Succeed())
+		})
+
+		It("should only allow locks on the value that was precommitted by a quorum", func() {
+			w := world()
+			value, other := processutil.RandomGoodValue(r), processutil.RandomGoodValue(r)
+			for _, signatory := range w.Signatories[:3] {
+				w.Sent = append(w.Sent, process.Precommit{Height: 1, Round: 0, Value: value, From: signatory})
+			}
+			w.States[3].CurrentRound = 1
+			w.States[3].LockedRound, w.States[3].LockedValue = 1, value
+			w.States[3].ValidRound, w.States[3].ValidValue = 1, value
+			Expect(modelcheck.Locks(w)).To(Succeed())
+
+			w.States[3].LockedValue = other
+			Expect(modelcheck.Locks(w)).ToNot(Succeed())
+		})
+
+		It("should not hold when a quorum prevotes a different value in a later round", func() {
+			w := world()
+			value, other := processutil.RandomGoodValue(r), processutil.RandomGoodValue(r)
+			for _, signatory := range w.Signatories[:3] {
+				w.Sent = append(w.Sent, process.Precommit{Height: 1, Round: 0, Value: value, From: signatory})
+			}
+			for _, signatory := range w.Signatories[1:] {
+				w.Sent = append(w.Sent, process.Prevote{Height: 1, Round: 1, Value: other, From: signatory})
+			}
+			Expect(modelcheck.Locks(w)).ToNot(Succeed())
+		})
+	})
+})
@@ -0,0 +1,67 @@
+package modelcheck
+
+import (
+	"github.com/renproject/hyperdrive/process"
+)
+
+// Options represent the options for a model check.
+type Options struct {
+	Depth      int
+	Rounds     process.Round
+	Heights    process.Height
+	MaxStates  int
+	Invariants []Invariant
+}
+
+// DefaultOptions returns the default options for a model check. By default,
+// every path of up to 8 transitions is explored, timeouts only fire in the
+// first Round, Processes stop after committing the first Height, and the
+// Agreement and Locks invariants are checked.
+func DefaultOptions() Options {
+	return Options{
+		Depth:      8,
+		Rounds:     1,
+		Heights:    1,
+		MaxStates:  0,
+		Invariants: []Invariant{Agreement, Locks},
+	}
+}
+
+// WithDepth updates the maximum number of transitions in an explored path. The
+// number of states grows exponentially with the depth, so it should be kept
+// small: for 4 Processes, at least 10 transitions are needed for a Process to
+// commit.
+func (opts Options) WithDepth(depth int) Options {
+	opts.Depth = depth
+	return opts
+}
+
+// WithRounds updates the number of Rounds in which timeouts can fire. Timeouts
+// for later Rounds are never fired, but Processes can still reach later Rounds
+// by receiving messages from them.
+func (opts Options) WithRounds(rounds process.Round) Options {
+	opts.Rounds = rounds
+	return opts
+}
+
+// WithHeights updates the number of Heights that each Process commits before
+// it stops.
+func (opts Options) WithHeights(heights process.Height) Options {
+	opts.Heights = heights
+	return opts
+}
+
+// WithMaxStates updates the maximum number of distinct states that are
+// explored. When the maximum is reached, the model check stops, and its Result
+// is not complete. Zero means that there is no maximum.
+func (opts Options) WithMaxStates(max int) Options {
+	opts.MaxStates = max
+	return opts
+}
+
+// WithInvariants updates the Invariants that are checked in every explored
+// state, replacing the existing ones.
+func (opts Options) WithInvariants(invariants ...Invariant) Options {
+	opts.Invariants = invariants
+	return opts
+}
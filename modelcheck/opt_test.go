@@ -0,0 +1,47 @@
+package modelcheck_test
+
+import (
+	"github.com/renproject/hyperdrive/modelcheck"
+	"github.com/renproject/hyperdrive/process"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ModelCheck Opts", func() {
+	Context("ModelCheck Opts", func() {
+		Specify("with default opts", func() {
+			opts := modelcheck.DefaultOptions()
+			Expect(opts.Depth).To(Equal(8))
+			Expect(opts.Rounds).To(Equal(process.Round(1)))
+			Expect(opts.Heights).To(Equal(process.Height(1)))
+			Expect(opts.MaxStates).To(Equal(0))
+			Expect(opts.Invariants).To(HaveLen(2))
+		})
+
+		Specify("with depth", func() {
+			opts := modelcheck.DefaultOptions().WithDepth(4)
+			Expect(opts.Depth).To(Equal(4))
+		})
+
+		Specify("with rounds", func() {
+			opts := modelcheck.DefaultOptions().WithRounds(3)
+			Expect(opts.Rounds).To(Equal(process.Round(3)))
+		})
+
+		Specify("with heights", func() {
+			opts := modelcheck.DefaultOptions().WithHeights(2)
+			Expect(opts.Heights).To(Equal(process.Height(2)))
+		})
+
+		Specify("with max states", func() {
+			opts := modelcheck.DefaultOptions().WithMaxStates(100)
+			Expect(opts.MaxStates).To(Equal(100))
+		})
+
+		Specify("with invariants", func() {
+			opts := modelcheck.DefaultOptions().WithInvariants(modelcheck.Agreement)
+			Expect(opts.Invariants).To(HaveLen(1))
+		})
+	})
+})
@@ -1,6 +1,7 @@
 package mq
 
 import (
+	"bytes"
 	"fmt"
 	"sort"
 
@@ -29,12 +30,16 @@ func New(opts Options) MessageQueue {
 	}
 }
 
-// Consume Propose, Prevote, and Precommit messages from the MessageQueue that
-// have heights up to (and including) the given height. The appropriate callback
-// will be called for every message that is consumed. All consumed messages will
-// be dropped from the MessageQueue.
-func (mq *MessageQueue) Consume(h process.Height, propose func(process.Propose), prevote func(process.Prevote), precommit func(process.Precommit), procsAllowed map[id.Signatory]bool) (n int) {
-	for from, q := range mq.queuesByPid {
+// Consume Propose, Prevote, Precommit, and Timeout messages from the
+// MessageQueue that have heights up to (and including) the given height. The
+// appropriate callback will be called for every message that is consumed. All
+// consumed messages will be dropped from the MessageQueue. Messages are
+// consumed one sender at a time, in the order of the senders' signatories, so
+// that the order in which messages are consumed does not depend on the order
+// in which maps are iterated.
+func (mq *MessageQueue) Consume(h process.Height, propose func(process.Propose), prevote func(process.Prevote), precommit func(process.Precommit), timeout func(process.Timeout), procsAllowed map[id.Signatory]bool) (n int) {
+	for _, from := range mq.senders() {
+		q := mq.queuesByPid[from]
 		for len(q) > 0 {
 			if q[0] == nil || height(q[0]) > h {
 				break
@@ -57,6 +62,8 @@ func (mq *MessageQueue) Consume(h process.Height, propose func(process.Propose),
 					prevote(msg)
 				case process.Precommit:
 					precommit(msg)
+				case process.Timeout:
+					timeout(msg)
 				}
 			}()
 		}
@@ -82,6 +89,50 @@ func (mq *MessageQueue) DropMessagesBelowHeight(h process.Height) {
 	}
 }
 
+// Len returns the number of messages in the MessageQueue, across all senders.
+func (mq *MessageQueue) Len() int {
+	n := 0
+	for _, q := range mq.queuesByPid {
+		for _, msg := range q {
+			if msg == nil {
+				break
+			}
+			n++
+		}
+	}
+	return n
+}
+
+// Messages returns all messages in the MessageQueue, in the order in which
+// they would be consumed: the messages from each sender are ordered by height
+// and round, and senders are ordered by their signatories. The MessageQueue is
+// not modified.
+func (mq *MessageQueue) Messages() []interface{} {
+	msgs := make([]interface{}, 0, mq.Len())
+	for _, from := range mq.senders() {
+		q := mq.queuesByPid[from]
+		for _, msg := range q {
+			if msg == nil {
+				break
+			}
+			msgs = append(msgs, msg)
+		}
+	}
+	return msgs
+}
+
+// senders returns the signatories of the senders that have queues, in order.
+func (mq *MessageQueue) senders() []id.Signatory {
+	senders := make([]id.Signatory, 0, len(mq.queuesByPid))
+	for from := range mq.queuesByPid {
+		senders = append(senders, from)
+	}
+	sort.Slice(senders, func(i, j int) bool {
+		return bytes.Compare(senders[i][:], senders[j][:]) < 0
+	})
+	return senders
+}
+
 // InsertPropose message into the MessageQueue. This method assumes that the
 // sender has already been authenticated and filtered.
 func (mq *MessageQueue) InsertPropose(propose process.Propose) {
@@ -100,6 +151,12 @@ func (mq *MessageQueue) InsertPrecommit(precommit process.Precommit) {
 	mq.insert(precommit)
 }
 
+// InsertTimeout message into the MessageQueue. This method assumes that the
+// sender has already been authenticated and filtered.
+func (mq *MessageQueue) InsertTimeout(timeout process.Timeout) {
+	mq.insert(timeout)
+}
+
 func (mq *MessageQueue) insert(msg interface{}) {
 	// Initialise the queue for the sender of the message, to avoid nil-pointer
 	// errors. This makes the assumption that messages that have not already
@@ -150,6 +207,8 @@ func height(msg interface{}) process.Height {
 		return msg.Height
 	case process.Precommit:
 		return msg.Height
+	case process.Timeout:
+		return msg.Height
 	default:
 		panic(fmt.Errorf("non-exhaustive pattern: %T", msg))
 	}
@@ -163,6 +222,8 @@ func round(msg interface{}) process.Round {
 		return msg.Round
 	case process.Precommit:
 		return msg.Round
+	case process.Timeout:
+		return msg.Round
 	default:
 		panic(fmt.Errorf("non-exhaustive pattern: %T", msg))
 	}
@@ -176,6 +237,8 @@ func from(msg interface{}) id.Signatory {
 		return msg.From
 	case process.Precommit:
 		return msg.From
+	case process.Timeout:
+		return msg.From
 	default:
 		panic(fmt.Errorf("non-exhaustive pattern: %T", msg))
 	}
@@ -1,6 +1,8 @@
 package mq_test
 
 import (
+	"bytes"
+	"math"
 	"math/rand"
 	"testing/quick"
 	"time"
@@ -102,12 +104,16 @@ var _ = Describe("MQ", func() {
 			precommitCallback := func(precommit process.Precommit) {
 				Expect(true).ToNot(BeTrue())
 			}
+			timeoutCallback := func(timeout process.Timeout) {
+				Expect(true).ToNot(BeTrue())
+			}
 
 			n := queue.Consume(
 				process.Height(9223372036854775807),
 				proposeCallback,
 				prevoteCallback,
 				precommitCallback,
+				timeoutCallback,
 				map[id.Signatory]bool{},
 			)
 
@@ -151,7 +157,7 @@ var _ = Describe("MQ", func() {
 						}
 
 						// cannot consume msgs of height less than lowerHeight
-						n := queue.Consume(height, proposeCallback, prevoteCallback, precommitCallback, procsAllowed)
+						n := queue.Consume(height, proposeCallback, prevoteCallback, precommitCallback, nil, procsAllowed)
 						Expect(n).To(Equal(1))
 						Expect(processed).Should(BeTrue())
 
@@ -194,7 +200,7 @@ var _ = Describe("MQ", func() {
 						}
 
 						// It should filter out the message
-						n := queue.Consume(height, proposeCallback, prevoteCallback, precommitCallback, procsAllowed)
+						n := queue.Consume(height, proposeCallback, prevoteCallback, precommitCallback, nil, procsAllowed)
 						Expect(n).To(Equal(1))
 						Expect(processed).Should(BeFalse())
 
@@ -249,7 +255,7 @@ var _ = Describe("MQ", func() {
 						}
 
 						// It should process the message when consuming current height
-						n := queue.Consume(height, proposeCallback, prevoteCallback, precommitCallback, procsAllowed)
+						n := queue.Consume(height, proposeCallback, prevoteCallback, precommitCallback, nil, procsAllowed)
 						Expect(n).To(Equal(1))
 						Expect(processed).Should(BeTrue())
 
@@ -258,7 +264,7 @@ var _ = Describe("MQ", func() {
 						processed = false
 
 						// It should not process the message when consuming future height
-						n = queue.Consume(higherHeight, proposeCallback, prevoteCallback, precommitCallback, procsAllowed)
+						n = queue.Consume(higherHeight, proposeCallback, prevoteCallback, precommitCallback, nil, procsAllowed)
 						Expect(n).To(Equal(1))
 						Expect(processed).Should(BeFalse())
 
@@ -312,7 +318,7 @@ var _ = Describe("MQ", func() {
 						}
 
 						// It should not process the message when consuming current height
-						n := queue.Consume(height, proposeCallback, prevoteCallback, precommitCallback, procsAllowed)
+						n := queue.Consume(height, proposeCallback, prevoteCallback, precommitCallback, nil, procsAllowed)
 						Expect(n).To(Equal(1))
 						Expect(processed).Should(BeFalse())
 
@@ -320,7 +326,7 @@ var _ = Describe("MQ", func() {
 						procsAllowed[sender] = true
 
 						// It should process the message when consuming future height
-						n = queue.Consume(higherHeight, proposeCallback, prevoteCallback, precommitCallback, procsAllowed)
+						n = queue.Consume(higherHeight, proposeCallback, prevoteCallback, precommitCallback, nil, procsAllowed)
 						Expect(n).To(Equal(1))
 						Expect(processed).Should(BeTrue())
 
@@ -417,12 +423,12 @@ var _ = Describe("MQ", func() {
 
 					// cannot consume msgs of height less than lowerHeight
 					evenLowerHeight := lowerHeight - 1 - process.Height(r.Intn(100))
-					n := queue.Consume(evenLowerHeight, proposeCallback, prevoteCallback, precommitCallback, procsAllowed)
+					n := queue.Consume(evenLowerHeight, proposeCallback, prevoteCallback, precommitCallback, nil, procsAllowed)
 					Expect(n).To(Equal(0))
 					Expect(i).To(Equal(0))
 
 					// consume all messages
-					n = queue.Consume(higherHeight, proposeCallback, prevoteCallback, precommitCallback, procsAllowed)
+					n = queue.Consume(higherHeight, proposeCallback, prevoteCallback, precommitCallback, nil, procsAllowed)
 					Expect(n).To(Equal(2))
 					Expect(i).To(Equal(2))
 
@@ -501,12 +507,12 @@ var _ = Describe("MQ", func() {
 
 					// cannot consume msgs of height less than lowerHeight
 					lowerHeight := height - 1 - process.Height(r.Intn(100))
-					n := queue.Consume(lowerHeight, proposeCallback, prevoteCallback, precommitCallback, procsAllowed)
+					n := queue.Consume(lowerHeight, proposeCallback, prevoteCallback, precommitCallback, nil, procsAllowed)
 					Expect(n).To(Equal(0))
 					Expect(t).To(Equal(0))
 
 					// consume all messages
-					n = queue.Consume(height, proposeCallback, prevoteCallback, precommitCallback, procsAllowed)
+					n = queue.Consume(height, proposeCallback, prevoteCallback, precommitCallback, nil, procsAllowed)
 					Expect(n).To(Equal(cap(rounds)))
 					Expect(t).To(Equal(cap(rounds)))
 
@@ -592,12 +598,12 @@ var _ = Describe("MQ", func() {
 					}
 
 					// cannot consume msgs of height less than the min height
-					n := queue.Consume(minHeight-1, proposeCallback, prevoteCallback, precommitCallback, procsAllowed)
+					n := queue.Consume(minHeight-1, proposeCallback, prevoteCallback, precommitCallback, nil, procsAllowed)
 					Expect(n).To(Equal(0))
 					Expect(i).To(Equal(0))
 
 					// consume all messages
-					n = queue.Consume(maxHeight, proposeCallback, prevoteCallback, precommitCallback, procsAllowed)
+					n = queue.Consume(maxHeight, proposeCallback, prevoteCallback, precommitCallback, nil, procsAllowed)
 					Expect(n).To(Equal(msgsCount))
 					Expect(i).To(Equal(msgsCount))
 
@@ -631,7 +637,7 @@ var _ = Describe("MQ", func() {
 					Expect(precommit.Height >= thresholdHeight).To(BeTrue())
 				}
 
-				_ = queue.Consume(maxHeight, proposeCallback, prevoteCallback, precommitCallback, procsAllowed)
+				_ = queue.Consume(maxHeight, proposeCallback, prevoteCallback, precommitCallback, nil, procsAllowed)
 				return true
 			}
 			Expect(quick.Check(loop, nil)).To(Succeed())
@@ -666,7 +672,7 @@ var _ = Describe("MQ", func() {
 
 				// so consuming will only return the first msg
 				proposeCallback := func(propose process.Propose) {}
-				n := queue.Consume(process.Height(1), proposeCallback, nil, nil, procsAllowed)
+				n := queue.Consume(process.Height(1), proposeCallback, nil, nil, nil, procsAllowed)
 				Expect(n).To(Equal(2))
 
 				// re-insert the original msg
@@ -686,7 +692,7 @@ var _ = Describe("MQ", func() {
 					Expect(propose.Round).To(Equal(originalMsg.Round))
 					Expect(propose.From).To(Equal(originalSender))
 				}
-				n = queue.Consume(process.Height(1), proposeCallback, nil, nil, procsAllowed)
+				n = queue.Consume(process.Height(1), proposeCallback, nil, nil, nil, procsAllowed)
 				Expect(n).To(Equal(1))
 
 				// re-insert the original msg
@@ -705,7 +711,7 @@ var _ = Describe("MQ", func() {
 					Expect(propose.Round).To(Equal(msg.Round))
 					Expect(propose.From).To(Equal(originalSender))
 				}
-				n = queue.Consume(process.Height(1), proposeCallback, nil, nil, procsAllowed)
+				n = queue.Consume(process.Height(1), proposeCallback, nil, nil, nil, procsAllowed)
 				Expect(n).To(Equal(1))
 
 				return true
@@ -783,7 +789,7 @@ var _ = Describe("MQ", func() {
 					i++
 				}
 
-				n := queue.Consume(height, proposeCallback, prevoteCallback, precommitCallback, procsAllowed)
+				n := queue.Consume(height, proposeCallback, prevoteCallback, precommitCallback, nil, procsAllowed)
 				Expect(n).To(Equal(c))
 				Expect(i).To(Equal(c))
 
@@ -792,4 +798,140 @@ var _ = Describe("MQ", func() {
 			Expect(quick.Check(loop, nil)).To(Succeed())
 		})
 	})
+
+	Context("when inserting timeouts", func() {
+		It("should consume them in order of height and round with other messages", func() {
+			loop := func() bool {
+				queue := mq.New(mq.DefaultOptions())
+				sender := id.NewPrivKey().Signatory()
+				procsAllowed := map[id.Signatory]bool{sender: true}
+				height := process.Height(1 + r.Intn(100))
+				msgsCount := 1 + r.Intn(20)
+
+				// insert timeouts and prevotes for every round, in a random
+				// order
+				for _, i := range r.Perm(msgsCount) {
+					timeout := processutil.RandomTimeout(r)
+					timeout.From = sender
+					timeout.Height = height
+					timeout.Round = process.Round(i)
+					queue.InsertTimeout(timeout)
+
+					prevote := processutil.RandomPrevote(r)
+					prevote.From = sender
+					prevote.Height = height
+					prevote.Round = process.Round(i)
+					queue.InsertPrevote(prevote)
+				}
+
+				lastRound := process.Round(0)
+				timeouts := 0
+				prevoteCallback := func(prevote process.Prevote) {
+					Expect(prevote.Round >= lastRound).To(BeTrue())
+					lastRound = prevote.Round
+				}
+				timeoutCallback := func(timeout process.Timeout) {
+					Expect(timeout.Round >= lastRound).To(BeTrue())
+					lastRound = timeout.Round
+					timeouts++
+				}
+
+				// timeouts from future heights are not consumed
+				n := queue.Consume(height-1, nil, prevoteCallback, nil, timeoutCallback, procsAllowed)
+				Expect(n).To(Equal(0))
+				n = queue.Consume(height, nil, prevoteCallback, nil, timeoutCallback, procsAllowed)
+				Expect(n).To(Equal(2 * msgsCount))
+				Expect(timeouts).To(Equal(msgsCount))
+				return true
+			}
+			Expect(quick.Check(loop, nil)).To(Succeed())
+		})
+	})
+
+	Context("when consuming messages from different senders", func() {
+		It("should consume them in the order of the senders' signatories", func() {
+			loop := func() bool {
+				queue := mq.New(mq.DefaultOptions())
+				procsAllowed := map[id.Signatory]bool{}
+				height := process.Height(1 + r.Intn(100))
+				for i := 0; i < 2+r.Intn(10); i++ {
+					sender := id.NewPrivKey().Signatory()
+					procsAllowed[sender] = true
+					prevote := processutil.RandomPrevote(r)
+					prevote.From = sender
+					prevote.Height = height
+					queue.InsertPrevote(prevote)
+				}
+
+				senders := []id.Signatory{}
+				queue.Consume(height, nil, func(prevote process.Prevote) {
+					senders = append(senders, prevote.From)
+				}, nil, nil, procsAllowed)
+				Expect(senders).To(HaveLen(len(procsAllowed)))
+				for i := 1; i < len(senders); i++ {
+					Expect(bytes.Compare(senders[i-1][:], senders[i][:])).To(Equal(-1))
+				}
+				return true
+			}
+			Expect(quick.Check(loop, nil)).To(Succeed())
+		})
+	})
+
+	Context("when counting messages", func() {
+		It("should return the number of messages that have not been consumed", func() {
+			loop := func() bool {
+				opts := mq.DefaultOptions()
+				queue := mq.New(opts)
+				Expect(queue.Len()).To(Equal(0))
+
+				procsAllowed := map[id.Signatory]bool{}
+				expected := 0
+				for i := 0; i < 1+r.Intn(5); i++ {
+					sender := id.NewPrivKey().Signatory()
+					procsAllowed[sender] = true
+					_, _, msgsCount := insertRandomMessages(&queue, sender)
+					if msgsCount > opts.MaxCapacity {
+						msgsCount = opts.MaxCapacity
+					}
+					expected += msgsCount
+				}
+				Expect(queue.Len()).To(Equal(expected))
+
+				n := queue.Consume(process.Height(1+r.Intn(100)), func(process.Propose) {}, func(process.Prevote) {}, func(process.Precommit) {}, nil, procsAllowed)
+				Expect(queue.Len()).To(Equal(expected - n))
+				return true
+			}
+			Expect(quick.Check(loop, nil)).To(Succeed())
+		})
+
+		It("should return the messages that have not been consumed", func() {
+			loop := func() bool {
+				queue := mq.New(mq.DefaultOptions())
+				sender := id.NewPrivKey().Signatory()
+				insertRandomMessages(&queue, sender)
+				msgs := queue.Messages()
+				Expect(msgs).To(HaveLen(queue.Len()))
+
+				// the messages are ordered, and consuming them yields the
+				// same messages
+				i := 0
+				consume := func(msg interface{}) {
+					Expect(msg).To(Equal(msgs[i]))
+					i++
+				}
+				queue.Consume(
+					process.Height(math.MaxInt64),
+					func(propose process.Propose) { consume(propose) },
+					func(prevote process.Prevote) { consume(prevote) },
+					func(precommit process.Precommit) { consume(precommit) },
+					func(timeout process.Timeout) { consume(timeout) },
+					map[id.Signatory]bool{sender: true},
+				)
+				Expect(i).To(Equal(len(msgs)))
+				Expect(queue.Messages()).To(BeEmpty())
+				return true
+			}
+			Expect(quick.Check(loop, nil)).To(Succeed())
+		})
+	})
 })
@@ -0,0 +1,418 @@
+package process
+
+import (
+	"fmt"
+)
+
+// An Action is an effect of a Process on the outside world. When a Process is
+// wrapped by an ActionProcess, it does not call its Timer, Broadcaster,
+// Committer, or Catcher. Instead, every call is returned as an Action, in the
+// order in which it would have been made, and it is the responsibility of the
+// caller to execute the Actions. This makes it possible to batch Actions, to
+// persist them before they are executed, and to test their order.
+//
+// An Action is one of Broadcast, ScheduleTimeout, Commit, or ReportEvidence.
+type Action interface {
+	isAction()
+}
+
+// Broadcast is the Action of broadcasting a message. The message is a Propose,
+// Prevote, Precommit, or Timeout, and it has already been signed by the Signer
+// of the Process (if there is one).
+type Broadcast struct {
+	Message interface{}
+}
+
+// ScheduleTimeout is the Action of scheduling a timeout. The MessageType is
+// MessageTypePropose, MessageTypePrevote, or MessageTypePrecommit, and
+// identifies whether OnTimeoutPropose, OnTimeoutPrevote, or OnTimeoutPrecommit
+// must be called once the timeout has elapsed.
+type ScheduleTimeout struct {
+	Height      Height
+	Round       Round
+	MessageType MessageType
+}
+
+// Commit is the Action of committing a Value. Once it has been executed,
+// ActionProcess.Committed must be called to start the next Height.
+type Commit struct {
+	Certificate CommitCertificate
+}
+
+// EvidenceType enumerates the various types of misbehaviour that can be
+// reported by a Process.
+type EvidenceType uint8
+
+const (
+	// EvidenceTypeDoublePropose is reported when two different Proposes are
+	// received from the same Process at the same Height and Round.
+	EvidenceTypeDoublePropose EvidenceType = 1
+	// EvidenceTypeDoublePrevote is reported when two different Prevotes are
+	// received from the same Process at the same Height and Round.
+	EvidenceTypeDoublePrevote EvidenceType = 2
+	// EvidenceTypeDoublePrecommit is reported when two different Precommits are
+	// received from the same Process at the same Height and Round.
+	EvidenceTypeDoublePrecommit EvidenceType = 3
+	// EvidenceTypeOutOfTurnPropose is reported when a Propose is received from
+	// a Process that was not scheduled to propose.
+	EvidenceTypeOutOfTurnPropose EvidenceType = 4
+	// EvidenceTypeOutOfWindow is reported when a message is received from a
+	// Round that is too far ahead of the current Round.
+	EvidenceTypeOutOfWindow EvidenceType = 5
+)
+
+// String implements the Stringer interface.
+func (ty EvidenceType) String() string {
+	switch ty {
+	case EvidenceTypeDoublePropose:
+		return "DoublePropose"
+	case EvidenceTypeDoublePrevote:
+		return "DoublePrevote"
+	case EvidenceTypeDoublePrecommit:
+		return "DoublePrecommit"
+	case EvidenceTypeOutOfTurnPropose:
+		return "OutOfTurnPropose"
+	case EvidenceTypeOutOfWindow:
+		return "OutOfWindow"
+	}
+	return fmt.Sprintf("EvidenceType(%d)", ty)
+}
+
+// ReportEvidence is the Action of reporting misbehaviour to the Catcher. The
+// Messages are the arguments that would have been given to the Catcher: two
+// conflicting messages for double proposes, prevotes, and precommits, and a
+// single message otherwise.
+type ReportEvidence struct {
+	Type     EvidenceType
+	Messages []interface{}
+}
+
+func (Broadcast) isAction()       {}
+func (ScheduleTimeout) isAction() {}
+func (Commit) isAction()          {}
+func (ReportEvidence) isAction()  {}
+
+// An ActionProcess wraps a Process so that its methods return Actions, instead
+// of calling the Timer, Broadcaster, Committer, and Catcher of the Process. The
+// Scheduler, Proposer, Validator, Signer, and Observer of the Process are still
+// called, because they are inputs to the Process (or, in the case of the
+// Observer, because it must not affect the Process).
+//
+// After a Commit Action has been executed, Committed must be called before any
+// other method, so that the Process can start the next Height using the
+// VotingPowers and Scheduler returned by the Committer.
+type ActionProcess struct {
+	Process
+
+	recorder *recorder
+}
+
+// NewActionProcess wraps the Process, replacing its Timer, Broadcaster,
+// Committer, and Catcher.
+func NewActionProcess(p Process) *ActionProcess {
+	recorder := new(recorder)
+	p.timer = recorder
+	p.broadcaster = recorder
+	p.committer = recorder
+	p.catcher = recorder
+	p.deferNextHeight = true
+	return &ActionProcess{
+		Process:  p,
+		recorder: recorder,
+	}
+}
+
+// Start the Process, and return the resulting Actions.
+func (ap *ActionProcess) Start() []Action {
+	ap.Process.Start()
+	return ap.recorder.drain()
+}
+
+// Resume the Process after it has been restored from a snapshot, and return
+// the resulting Actions.
+func (ap *ActionProcess) Resume() []Action {
+	ap.Process.Resume()
+	return ap.recorder.drain()
+}
+
+// StartWithNewSignatories starts the Process with a new committee of n
+// signatories, and returns the resulting Actions.
+func (ap *ActionProcess) StartWithNewSignatories(n uint64, scheduler Scheduler) []Action {
+	ap.Process.StartWithNewSignatories(n, scheduler)
+	return ap.recorder.drain()
+}
+
+// StartWithNewVotingPowers starts the Process with new VotingPowers, and
+// returns the resulting Actions.
+func (ap *ActionProcess) StartWithNewVotingPowers(powers VotingPowers, scheduler Scheduler) []Action {
+	ap.Process.StartWithNewVotingPowers(powers, scheduler)
+	return ap.recorder.drain()
+}
+
+// StartRound of the Process, and return the resulting Actions.
+func (ap *ActionProcess) StartRound(round Round) []Action {
+	ap.Process.StartRound(round)
+	return ap.recorder.drain()
+}
+
+// Propose notifies the Process that a Propose has been received, and returns
+// the resulting Actions.
+func (ap *ActionProcess) Propose(propose Propose) []Action {
+	ap.Process.Propose(propose)
+	return ap.recorder.drain()
+}
+
+// Prevote notifies the Process that a Prevote has been received, and returns
+// the resulting Actions.
+func (ap *ActionProcess) Prevote(prevote Prevote) []Action {
+	ap.Process.Prevote(prevote)
+	return ap.recorder.drain()
+}
+
+// Precommit notifies the Process that a Precommit has been received, and
+// returns the resulting Actions.
+func (ap *ActionProcess) Precommit(precommit Precommit) []Action {
+	ap.Process.Precommit(precommit)
+	return ap.recorder.drain()
+}
+
+// Timeout notifies the Process that a Timeout has been received, and returns
+// the resulting Actions.
+func (ap *ActionProcess) Timeout(timeout Timeout) []Action {
+	ap.Process.Timeout(timeout)
+	return ap.recorder.drain()
+}
+
+// OnTimeoutPropose notifies the Process that a propose timeout has elapsed,
+// and returns the resulting Actions.
+func (ap *ActionProcess) OnTimeoutPropose(height Height, round Round) []Action {
+	ap.Process.OnTimeoutPropose(height, round)
+	return ap.recorder.drain()
+}
+
+// OnTimeoutPrevote notifies the Process that a prevote timeout has elapsed,
+// and returns the resulting Actions.
+func (ap *ActionProcess) OnTimeoutPrevote(height Height, round Round) []Action {
+	ap.Process.OnTimeoutPrevote(height, round)
+	return ap.recorder.drain()
+}
+
+// OnTimeoutPrecommit notifies the Process that a precommit timeout has
+// elapsed, and returns the resulting Actions.
+func (ap *ActionProcess) OnTimeoutPrecommit(height Height, round Round) []Action {
+	ap.Process.OnTimeoutPrecommit(height, round)
+	return ap.recorder.drain()
+}
+
+// Sync the Process with a verified CommitCertificate for its current Height,
+// and return the resulting Actions. Like any other commitment, this results in
+// a Commit Action.
+func (ap *ActionProcess) Sync(cert CommitCertificate) ([]Action, error) {
+	if err := ap.Process.Sync(cert); err != nil {
+		return nil, err
+	}
+	return ap.recorder.drain(), nil
+}
+
+// Committed notifies the Process that a Commit Action has been executed, and
+// starts the first Round of the next Height. If the given VotingPowers and
+// Scheduler are not nil, then they replace the current ones (in the same way
+// as the values returned by a Committer). The Observer of the Process is
+// notified about the commit here, rather than when the Commit Action is
+// returned, so that it is notified after the Committer (as it is when the
+// Process is not wrapped). Likewise, the Tracer of the Process is notified
+// about the input that caused the commit here, with the State in which the
+// next Height has started. It returns the resulting Actions.
+func (ap *ActionProcess) Committed(powers VotingPowers, scheduler Scheduler) []Action {
+	if ap.recorder.committed != nil {
+		if ap.Process.observer != nil {
+			ap.Process.observer.OnCommit(*ap.recorder.committed)
+		}
+		ap.recorder.committed = nil
+	}
+	if powers != nil {
+		ap.Process.powers = powers
+	}
+	if scheduler != nil {
+		ap.Process.scheduler = scheduler
+	}
+	ap.Process.startRound(0)
+	event := TraceEvent{Method: "StartRound"}
+	if ap.Process.deferredTrace != nil {
+		event = *ap.Process.deferredTrace
+		ap.Process.deferredTrace = nil
+	}
+	ap.Process.trace(event)
+	return ap.recorder.drain()
+}
+
+// ActionCallbacks executes Actions by calling a Timer, Broadcaster, Committer,
+// and Catcher, in the same way that a Process calls them when it is not
+// wrapped by an ActionProcess. Nil interfaces are skipped.
+type ActionCallbacks struct {
+	Timer       Timer
+	Broadcaster Broadcaster
+	Committer   Committer
+	Catcher     Catcher
+}
+
+// Execute the Actions, in order, on behalf of the ActionProcess. When a Commit
+// Action is executed, the ActionProcess is notified, and the Actions that
+// result from starting the next Height are executed before the remaining
+// Actions.
+func (callbacks ActionCallbacks) Execute(ap *ActionProcess, actions []Action) {
+	for _, action := range actions {
+		switch action := action.(type) {
+		case Broadcast:
+			if callbacks.Broadcaster == nil {
+				continue
+			}
+			switch msg := action.Message.(type) {
+			case Propose:
+				callbacks.Broadcaster.BroadcastPropose(msg)
+			case Prevote:
+				callbacks.Broadcaster.BroadcastPrevote(msg)
+			case Precommit:
+				callbacks.Broadcaster.BroadcastPrecommit(msg)
+			case Timeout:
+				callbacks.Broadcaster.BroadcastTimeout(msg)
+			}
+		case ScheduleTimeout:
+			if callbacks.Timer == nil {
+				continue
+			}
+			switch action.MessageType {
+			case MessageTypePropose:
+				callbacks.Timer.TimeoutPropose(action.Height, action.Round)
+			case MessageTypePrevote:
+				callbacks.Timer.TimeoutPrevote(action.Height, action.Round)
+			case MessageTypePrecommit:
+				callbacks.Timer.TimeoutPrecommit(action.Height, action.Round)
+			}
+		case Commit:
+			var powers VotingPowers
+			var scheduler Scheduler
+			if callbacks.Committer != nil {
+				powers, scheduler = callbacks.Committer.Commit(action.Certificate)
+			}
+			callbacks.Execute(ap, ap.Committed(powers, scheduler))
+		case ReportEvidence:
+			if callbacks.Catcher == nil {
+				continue
+			}
+			callbacks.report(action)
+		}
+	}
+}
+
+func (callbacks ActionCallbacks) report(action ReportEvidence) {
+	switch action.Type {
+	case EvidenceTypeDoublePropose:
+		callbacks.Catcher.CatchDoublePropose(action.Messages[0].(Propose), action.Messages[1].(Propose))
+	case EvidenceTypeDoublePrevote:
+		callbacks.Catcher.CatchDoublePrevote(action.Messages[0].(Prevote), action.Messages[1].(Prevote))
+	case EvidenceTypeDoublePrecommit:
+		callbacks.Catcher.CatchDoublePrecommit(action.Messages[0].(Precommit), action.Messages[1].(Precommit))
+	case EvidenceTypeOutOfTurnPropose:
+		callbacks.Catcher.CatchOutOfTurnPropose(action.Messages[0].(Propose))
+	case EvidenceTypeOutOfWindow:
+		switch msg := action.Messages[0].(type) {
+		case Propose:
+			callbacks.Catcher.CatchOutOfWindowPropose(msg)
+		case Prevote:
+			callbacks.Catcher.CatchOutOfWindowPrevote(msg)
+		case Precommit:
+			callbacks.Catcher.CatchOutOfWindowPrecommit(msg)
+		case Timeout:
+			callbacks.Catcher.CatchOutOfWindowTimeout(msg)
+		}
+	}
+}
+
+// recorder implements the Timer, Broadcaster, Committer, and Catcher
+// interfaces by recording every call as an Action.
+type recorder struct {
+	actions []Action
+	// committed is the CommitCertificate of the last Commit Action, until
+	// ActionProcess.Committed is called.
+	committed *CommitCertificate
+}
+
+// drain returns the recorded Actions, and forgets them.
+func (r *recorder) drain() []Action {
+	actions := r.actions
+	r.actions = nil
+	return actions
+}
+
+func (r *recorder) record(action Action) {
+	r.actions = append(r.actions, action)
+}
+
+func (r *recorder) TimeoutPropose(height Height, round Round) {
+	r.record(ScheduleTimeout{Height: height, Round: round, MessageType: MessageTypePropose})
+}
+
+func (r *recorder) TimeoutPrevote(height Height, round Round) {
+	r.record(ScheduleTimeout{Height: height, Round: round, MessageType: MessageTypePrevote})
+}
+
+func (r *recorder) TimeoutPrecommit(height Height, round Round) {
+	r.record(ScheduleTimeout{Height: height, Round: round, MessageType: MessageTypePrecommit})
+}
+
+func (r *recorder) BroadcastPropose(propose Propose) {
+	r.record(Broadcast{Message: propose})
+}
+
+func (r *recorder) BroadcastPrevote(prevote Prevote) {
+	r.record(Broadcast{Message: prevote})
+}
+
+func (r *recorder) BroadcastPrecommit(precommit Precommit) {
+	r.record(Broadcast{Message: precommit})
+}
+
+func (r *recorder) BroadcastTimeout(timeout Timeout) {
+	r.record(Broadcast{Message: timeout})
+}
+
+// Commit records the Commit, and keeps the current VotingPowers and Scheduler.
+// They can be replaced when calling ActionProcess.Committed.
+func (r *recorder) Commit(cert CommitCertificate) (VotingPowers, Scheduler) {
+	r.record(Commit{Certificate: cert})
+	r.committed = &cert
+	return nil, nil
+}
+
+func (r *recorder) CatchDoublePropose(propose1, propose2 Propose) {
+	r.record(ReportEvidence{Type: EvidenceTypeDoublePropose, Messages: []interface{}{propose1, propose2}})
+}
+
+func (r *recorder) CatchDoublePrevote(prevote1, prevote2 Prevote) {
+	r.record(ReportEvidence{Type: EvidenceTypeDoublePrevote, Messages: []interface{}{prevote1, prevote2}})
+}
+
+func (r *recorder) CatchDoublePrecommit(precommit1, precommit2 Precommit) {
+	r.record(ReportEvidence{Type: EvidenceTypeDoublePrecommit, Messages: []interface{}{precommit1, precommit2}})
+}
+
+func (r *recorder) CatchOutOfTurnPropose(propose Propose) {
+	r.record(ReportEvidence{Type: EvidenceTypeOutOfTurnPropose, Messages: []interface{}{propose}})
+}
+
+func (r *recorder) CatchOutOfWindowPropose(propose Propose) {
+	r.record(ReportEvidence{Type: EvidenceTypeOutOfWindow, Messages: []interface{}{propose}})
+}
+
+func (r *recorder) CatchOutOfWindowPrevote(prevote Prevote) {
+	r.record(ReportEvidence{Type: EvidenceTypeOutOfWindow, Messages: []interface{}{prevote}})
+}
+
+func (r *recorder) CatchOutOfWindowPrecommit(precommit Precommit) {
+	r.record(ReportEvidence{Type: EvidenceTypeOutOfWindow, Messages: []interface{}{precommit}})
+}
+
+func (r *recorder) CatchOutOfWindowTimeout(timeout Timeout) {
+	r.record(ReportEvidence{Type: EvidenceTypeOutOfWindow, Messages: []interface{}{timeout}})
+}
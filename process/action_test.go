@@ -0,0 +1,336 @@
+package process_test
+
+import (
+	"fmt"
+	"math/rand"
+	"testing/quick"
+	"time"
+
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/hyperdrive/process/processutil"
+	"github.com/renproject/hyperdrive/scheduler"
+	"github.com/renproject/id"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// callLog implements the Timer, Broadcaster, Committer, and Catcher interfaces
+// by logging every call.
+type callLog struct {
+	calls []string
+}
+
+func (log *callLog) TimeoutPropose(height process.Height, round process.Round) {
+	log.calls = append(log.calls, fmt.Sprintf("timeout propose %v %v", height, round))
+}
+
+func (log *callLog) TimeoutPrevote(height process.Height, round process.Round) {
+	log.calls = append(log.calls, fmt.Sprintf("timeout prevote %v %v", height, round))
+}
+
+func (log *callLog) TimeoutPrecommit(height process.Height, round process.Round) {
+	log.calls = append(log.calls, fmt.Sprintf("timeout precommit %v %v", height, round))
+}
+
+func (log *callLog) BroadcastPropose(propose process.Propose) {
+	log.calls = append(log.calls, fmt.Sprintf("broadcast propose %v %v %v", propose.Height, propose.Round, propose.Value))
+}
+
+func (log *callLog) BroadcastPrevote(prevote process.Prevote) {
+	log.calls = append(log.calls, fmt.Sprintf("broadcast prevote %v %v %v", prevote.Height, prevote.Round, prevote.Value))
+}
+
+func (log *callLog) BroadcastPrecommit(precommit process.Precommit) {
+	log.calls = append(log.calls, fmt.Sprintf("broadcast precommit %v %v %v", precommit.Height, precommit.Round, precommit.Value))
+}
+
+func (log *callLog) BroadcastTimeout(timeout process.Timeout) {
+	log.calls = append(log.calls, fmt.Sprintf("broadcast timeout %v %v", timeout.Height, timeout.Round))
+}
+
+func (log *callLog) Commit(cert process.CommitCertificate) (process.VotingPowers, process.Scheduler) {
+	log.calls = append(log.calls, fmt.Sprintf("commit %v %v %v", cert.Height, cert.Round, cert.Value))
+	return nil, nil
+}
+
+func (log *callLog) CatchDoublePropose(propose1, propose2 process.Propose) {
+	log.calls = append(log.calls, fmt.Sprintf("double propose %v", propose1.From))
+}
+
+func (log *callLog) CatchDoublePrevote(prevote1, prevote2 process.Prevote) {
+	log.calls = append(log.calls, fmt.Sprintf("double prevote %v", prevote1.From))
+}
+
+func (log *callLog) CatchDoublePrecommit(precommit1, precommit2 process.Precommit) {
+	log.calls = append(log.calls, fmt.Sprintf("double precommit %v", precommit1.From))
+}
+
+func (log *callLog) CatchOutOfTurnPropose(propose process.Propose) {
+	log.calls = append(log.calls, fmt.Sprintf("out of turn propose %v", propose.From))
+}
+
+func (log *callLog) CatchOutOfWindowPropose(propose process.Propose) {
+	log.calls = append(log.calls, fmt.Sprintf("out of window propose %v", propose.From))
+}
+
+func (log *callLog) CatchOutOfWindowPrevote(prevote process.Prevote) {
+	log.calls = append(log.calls, fmt.Sprintf("out of window prevote %v", prevote.From))
+}
+
+func (log *callLog) CatchOutOfWindowPrecommit(precommit process.Precommit) {
+	log.calls = append(log.calls, fmt.Sprintf("out of window precommit %v", precommit.From))
+}
+
+func (log *callLog) CatchOutOfWindowTimeout(timeout process.Timeout) {
+	log.calls = append(log.calls, fmt.Sprintf("out of window timeout %v", timeout.From))
+}
+
+var _ = Describe("Actions", func() {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	Context("when starting a round", func() {
+		It("should return a broadcast when proposing, and a scheduled timeout otherwise", func() {
+			loop := func() bool {
+				whoami := id.NewPrivKey().Signatory()
+				other := id.NewPrivKey().Signatory()
+				value := processutil.RandomGoodValue(r)
+				proposer := processutil.MockProposer{MockValue: func() process.Value { return value }}
+
+				ap := process.NewActionProcess(process.New(whoami, 4, nil, scheduler.NewRoundRobin([]id.Signatory{whoami}), proposer, nil, nil, nil, nil, nil))
+				actions := ap.Start()
+				Expect(actions).To(HaveLen(1))
+				broadcast, ok := actions[0].(process.Broadcast)
+				Expect(ok).To(BeTrue())
+				Expect(broadcast.Message.(process.Propose).Value).To(Equal(value))
+
+				ap = process.NewActionProcess(process.New(whoami, 4, nil, scheduler.NewRoundRobin([]id.Signatory{other}), proposer, nil, nil, nil, nil, nil))
+				Expect(ap.Start()).To(Equal([]process.Action{
+					process.ScheduleTimeout{Height: process.DefaultHeight, Round: 0, MessageType: process.MessageTypePropose},
+				}))
+				return true
+			}
+			Expect(quick.Check(loop, nil)).To(Succeed())
+		})
+	})
+
+	Context("when resuming a process", func() {
+		It("should return the scheduled timeout of the current step", func() {
+			loop := func() bool {
+				whoami := id.NewPrivKey().Signatory()
+				other := id.NewPrivKey().Signatory()
+				value := processutil.RandomGoodValue(r)
+
+				p := process.New(whoami, 4, nil, scheduler.NewRoundRobin([]id.Signatory{other}), nil, nil, nil, nil, nil, nil)
+				p.Start()
+				p.Propose(process.Propose{Height: 1, Round: 0, ValidRound: process.InvalidRound, Value: value, From: other})
+				Expect(p.CurrentStep).To(Equal(process.Prevoting))
+
+				ap := process.NewActionProcess(process.Process{}.WithSnapshot(p))
+				Expect(ap.Resume()).To(Equal([]process.Action{
+					process.ScheduleTimeout{Height: 1, Round: 0, MessageType: process.MessageTypePrevote},
+				}))
+
+				// the timeout is not returned again by the next call
+				Expect(ap.Prevote(process.Prevote{Height: 1, Round: 0, Value: value, From: id.NewPrivKey().Signatory()})).To(BeEmpty())
+				return true
+			}
+			Expect(quick.Check(loop, nil)).To(Succeed())
+		})
+	})
+
+	Context("when committing a value", func() {
+		It("should return a commit, and wait to start the next height", func() {
+			loop := func() bool {
+				whoami := id.NewPrivKey().Signatory()
+				other := id.NewPrivKey().Signatory()
+				value := processutil.RandomGoodValue(r)
+
+				ap := process.NewActionProcess(process.New(whoami, 4, nil, scheduler.NewRoundRobin([]id.Signatory{other}), nil, nil, nil, nil, nil, nil))
+				ap.Start()
+				Expect(ap.Propose(process.Propose{Height: 1, Round: 0, ValidRound: process.InvalidRound, Value: value, From: other})).To(Equal([]process.Action{
+					process.Broadcast{Message: process.Prevote{Height: 1, Round: 0, Value: value, From: whoami}},
+				}))
+
+				actions := []process.Action{}
+				for i := 0; i < 3; i++ {
+					actions = append(actions, ap.Prevote(process.Prevote{Height: 1, Round: 0, Value: value, From: id.NewPrivKey().Signatory()})...)
+				}
+				Expect(actions).To(ContainElement(process.Broadcast{Message: process.Precommit{Height: 1, Round: 0, Value: value, From: whoami}}))
+
+				actions = []process.Action{}
+				for i := 0; i < 3; i++ {
+					actions = append(actions, ap.Precommit(process.Precommit{Height: 1, Round: 0, Value: value, From: id.NewPrivKey().Signatory()})...)
+				}
+				commits := 0
+				for _, action := range actions {
+					if commit, ok := action.(process.Commit); ok {
+						Expect(commit.Certificate.Height).To(Equal(process.Height(1)))
+						Expect(commit.Certificate.Value).To(Equal(value))
+						Expect(commit.Certificate.Precommits).To(HaveLen(3))
+						commits++
+					}
+				}
+				Expect(commits).To(Equal(1))
+				Expect(ap.CurrentHeight).To(Equal(process.Height(2)))
+
+				// the next height is only started once the commit has been
+				// executed
+				Expect(ap.Committed(nil, nil)).To(Equal([]process.Action{
+					process.ScheduleTimeout{Height: 2, Round: 0, MessageType: process.MessageTypePropose},
+				}))
+				Expect(ap.CurrentRound).To(Equal(process.Round(0)))
+				Expect(ap.CurrentStep).To(Equal(process.Proposing))
+				return true
+			}
+			Expect(quick.Check(loop, nil)).To(Succeed())
+		})
+
+		It("should notify the observer once the commit has been executed", func() {
+			loop := func() bool {
+				whoami := id.NewPrivKey().Signatory()
+				other := id.NewPrivKey().Signatory()
+				value := processutil.RandomGoodValue(r)
+
+				events := []string{}
+				observer := processutil.ObserverCallbacks{
+					OnNewRoundCallback: func(height process.Height, round process.Round) {
+						events = append(events, fmt.Sprintf("round %v %v", height, round))
+					},
+					OnCommitCallback: func(cert process.CommitCertificate) {
+						events = append(events, fmt.Sprintf("commit %v %v", cert.Height, cert.Round))
+					},
+				}
+				ap := process.NewActionProcess(process.New(whoami, 4, nil, scheduler.NewRoundRobin([]id.Signatory{other}), nil, nil, nil, nil, nil, nil).WithObserver(observer))
+				ap.Start()
+				ap.Propose(process.Propose{Height: 1, Round: 0, ValidRound: process.InvalidRound, Value: value, From: other})
+				for i := 0; i < 3; i++ {
+					ap.Precommit(process.Precommit{Height: 1, Round: 0, Value: value, From: id.NewPrivKey().Signatory()})
+				}
+				Expect(events).To(Equal([]string{"round 1 0"}))
+
+				ap.Committed(nil, nil)
+				Expect(events).To(Equal([]string{"round 1 0", "commit 1 0", "round 2 0"}))
+				return true
+			}
+			Expect(quick.Check(loop, nil)).To(Succeed())
+		})
+
+		It("should trace the input that caused the commit once the next height has started", func() {
+			loop := func() bool {
+				whoami := id.NewPrivKey().Signatory()
+				other := id.NewPrivKey().Signatory()
+				value := processutil.RandomGoodValue(r)
+
+				events := []string{}
+				tracer := processutil.TracerCallback{Callback: func(event process.TraceEvent, state process.State) {
+					events = append(events, fmt.Sprintf("%v %v %v", event.Method, state.CurrentHeight, state.CurrentStep))
+				}}
+				ap := process.NewActionProcess(process.New(whoami, 4, nil, scheduler.NewRoundRobin([]id.Signatory{other}), nil, nil, nil, nil, nil, nil).WithTracer(tracer))
+				ap.Start()
+				ap.Propose(process.Propose{Height: 1, Round: 0, ValidRound: process.InvalidRound, Value: value, From: other})
+				for i := 0; i < 3; i++ {
+					ap.Precommit(process.Precommit{Height: 1, Round: 0, Value: value, From: id.NewPrivKey().Signatory()})
+				}
+				Expect(events).To(HaveLen(4))
+
+				ap.Committed(nil, nil)
+				Expect(events).To(HaveLen(5))
+				Expect(events[4]).To(Equal(fmt.Sprintf("Precommit 2 %v", process.Proposing)))
+				return true
+			}
+			Expect(quick.Check(loop, nil)).To(Succeed())
+		})
+	})
+
+	Context("when receiving two different prevotes from the same process", func() {
+		It("should return a report of the evidence", func() {
+			loop := func() bool {
+				from := id.NewPrivKey().Signatory()
+				prevote1 := process.Prevote{Height: 1, Round: 0, Value: processutil.RandomGoodValue(r), From: from}
+				prevote2 := prevote1
+				prevote2.Value = processutil.RandomGoodValue(r)
+				for prevote2.Value.Equal(&prevote1.Value) {
+					prevote2.Value = processutil.RandomGoodValue(r)
+				}
+
+				ap := process.NewActionProcess(process.New(id.NewPrivKey().Signatory(), 4, nil, nil, nil, nil, nil, nil, nil, nil))
+				ap.Start()
+				Expect(ap.Prevote(prevote1)).To(BeEmpty())
+				Expect(ap.Prevote(prevote2)).To(Equal([]process.Action{
+					process.ReportEvidence{Type: process.EvidenceTypeDoublePrevote, Messages: []interface{}{prevote2, prevote1}},
+				}))
+				return true
+			}
+			Expect(quick.Check(loop, nil)).To(Succeed())
+		})
+	})
+
+	Context("when executing actions with callbacks", func() {
+		It("should behave the same as a process with callbacks", func() {
+			loop := func() bool {
+				n := 4
+				privKeys := make([]*id.PrivKey, n)
+				signatories := make([]id.Signatory, n)
+				for i := range privKeys {
+					privKeys[i] = id.NewPrivKey()
+					signatories[i] = privKeys[i].Signatory()
+				}
+				sched := scheduler.NewRoundRobin(signatories)
+				proposer := processutil.MockProposer{MockValue: func() process.Value { return process.Value(id.NewHash([]byte("value"))) }}
+
+				// a random sequence of messages from the other processes,
+				// over a few heights and rounds
+				msgs := make([]interface{}, 200)
+				values := []process.Value{process.Value(id.NewHash([]byte("value"))), processutil.RandomGoodValue(r)}
+				for i := range msgs {
+					height := process.Height(1 + r.Intn(3))
+					round := process.Round(r.Intn(3))
+					from := signatories[1+r.Intn(n-1)]
+					value := values[r.Intn(len(values))]
+					switch r.Intn(4) {
+					case 0:
+						msgs[i] = process.Propose{Height: height, Round: round, ValidRound: process.InvalidRound, Value: value, From: sched.Schedule(height, round)}
+					case 1:
+						msgs[i] = process.Prevote{Height: height, Round: round, Value: value, From: from}
+					case 2:
+						msgs[i] = process.Precommit{Height: height, Round: round, Value: value, From: from}
+					default:
+						msgs[i] = process.Timeout{Height: height, Round: round, From: from}
+					}
+				}
+
+				expected := &callLog{}
+				p := process.New(signatories[0], n, expected, sched, proposer, nil, nil, expected, expected, expected)
+				got := &callLog{}
+				callbacks := process.ActionCallbacks{Timer: got, Broadcaster: got, Committer: got, Catcher: got}
+				ap := process.NewActionProcess(process.New(signatories[0], n, nil, sched, proposer, nil, nil, nil, nil, nil))
+
+				p.Start()
+				callbacks.Execute(ap, ap.Start())
+				for _, msg := range msgs {
+					switch msg := msg.(type) {
+					case process.Propose:
+						p.Propose(msg)
+						callbacks.Execute(ap, ap.Propose(msg))
+					case process.Prevote:
+						p.Prevote(msg)
+						callbacks.Execute(ap, ap.Prevote(msg))
+					case process.Precommit:
+						p.Precommit(msg)
+						callbacks.Execute(ap, ap.Precommit(msg))
+					case process.Timeout:
+						p.Timeout(msg)
+						callbacks.Execute(ap, ap.Timeout(msg))
+					}
+					Expect(ap.CurrentHeight).To(Equal(p.CurrentHeight))
+					Expect(ap.CurrentRound).To(Equal(p.CurrentRound))
+					Expect(ap.CurrentStep).To(Equal(p.CurrentStep))
+				}
+				Expect(got.calls).To(Equal(expected.calls))
+				return true
+			}
+			Expect(quick.Check(loop, nil)).To(Succeed())
+		})
+	})
+})
@@ -0,0 +1,279 @@
+package process
+
+import (
+	"fmt"
+
+	"github.com/renproject/id"
+	"github.com/renproject/surge"
+)
+
+// A CommitCertificate is the proof that a Value was committed at a Height. It
+// contains the Precommits, from a quorum of unique Processes, for the Value at
+// the Round in which the Value was committed. Certificates can be stored
+// alongside committed Values, and handed to other Processes (or light clients)
+// so that they can check the commitment without having taken part in the
+// consensus algorithm.
+type CommitCertificate struct {
+	Height     Height      `json:"height"`
+	Round      Round       `json:"round"`
+	Value      Value       `json:"value"`
+	Precommits []Precommit `json:"precommits"`
+}
+
+// Verify the CommitCertificate against the given set of signatories. An error
+// is returned if the Value is nil, if any Precommit does not match the Height,
+// Round, and Value of the certificate, if any Precommit is not correctly signed
+// by a member of the signatories, or if there are not Precommits from a quorum
+// of N-F unique signatories (where N is the number of signatories, and F is
+// the largest number such that 3F < N).
+func (cert CommitCertificate) Verify(signatories []id.Signatory) error {
+	allowed := make(map[id.Signatory]bool, len(signatories))
+	for _, signatory := range signatories {
+		allowed[signatory] = true
+	}
+	signers, err := cert.verifyPrecommits(allowed)
+	if err != nil {
+		return err
+	}
+
+	threshold := quorum(uint64(len(signatories)))
+	if uint64(len(signers)) < threshold {
+		return fmt.Errorf("insufficient precommits: expected>=%v, got=%v", threshold, len(signers))
+	}
+	return nil
+}
+
+// VerifyWithVotingPowers verifies the CommitCertificate against the given set
+// of weighted signatories. It is the same as Verify, except that the unique
+// signatories of the Precommits must have a quorum of the voting power.
+func (cert CommitCertificate) VerifyWithVotingPowers(powers VotingPowers) error {
+	allowed := make(map[id.Signatory]bool, len(powers))
+	for signatory, power := range powers {
+		allowed[signatory] = power > 0
+	}
+	signers, err := cert.verifyPrecommits(allowed)
+	if err != nil {
+		return err
+	}
+
+	power := uint64(0)
+	for _, signer := range signers {
+		power += powers[signer]
+	}
+	if power < powers.Quorum() {
+		return fmt.Errorf("insufficient voting power: expected>=%v, got=%v", powers.Quorum(), power)
+	}
+	return nil
+}
+
+// verifyPrecommits checks the Value and each of the Precommits in the
+// certificate, and returns the unique signatories of the Precommits.
+func (cert CommitCertificate) verifyPrecommits(allowed map[id.Signatory]bool) ([]id.Signatory, error) {
+	if cert.Value.Equal(&NilValue) {
+		return nil, fmt.Errorf("bad value: nil")
+	}
+
+	signers := make([]id.Signatory, 0, len(cert.Precommits))
+	seen := make(map[id.Signatory]bool, len(cert.Precommits))
+	for _, precommit := range cert.Precommits {
+		if precommit.Height != cert.Height {
+			return nil, fmt.Errorf("bad precommit height: expected=%v, got=%v", cert.Height, precommit.Height)
+		}
+		if precommit.Round != cert.Round {
+			return nil, fmt.Errorf("bad precommit round: expected=%v, got=%v", cert.Round, precommit.Round)
+		}
+		if !precommit.Value.Equal(&cert.Value) {
+			return nil, fmt.Errorf("bad precommit value: expected=%v, got=%v", cert.Value, precommit.Value)
+		}
+		if !allowed[precommit.From] {
+			return nil, fmt.Errorf("bad precommit signatory: %v is not allowed", precommit.From)
+		}
+		if seen[precommit.From] {
+			return nil, fmt.Errorf("bad precommit signatory: %v is duplicated", precommit.From)
+		}
+		if err := precommit.Verify(); err != nil {
+			return nil, fmt.Errorf("bad precommit signature: %v", err)
+		}
+		seen[precommit.From] = true
+		signers = append(signers, precommit.From)
+	}
+	return signers, nil
+}
+
+// SizeHint returns the number of bytes required to represent this certificate
+// in binary.
+func (cert CommitCertificate) SizeHint() int {
+	return surge.SizeHint(cert.Height) +
+		surge.SizeHint(cert.Round) +
+		surge.SizeHint(cert.Value) +
+		surge.SizeHint(cert.Precommits)
+}
+
+// Marshal this certificate into binary.
+func (cert CommitCertificate) Marshal(buf []byte, rem int) ([]byte, int, error) {
+	buf, rem, err := surge.Marshal(cert.Height, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling height=%v: %v", cert.Height, err)
+	}
+	buf, rem, err = surge.Marshal(cert.Round, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling round=%v: %v", cert.Round, err)
+	}
+	buf, rem, err = surge.Marshal(cert.Value, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling value=%v: %v", cert.Value, err)
+	}
+	buf, rem, err = surge.Marshal(cert.Precommits, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling %v precommits: %v", len(cert.Precommits), err)
+	}
+	return buf, rem, nil
+}
+
+// Unmarshal binary into this certificate.
+func (cert *CommitCertificate) Unmarshal(buf []byte, rem int) ([]byte, int, error) {
+	buf, rem, err := surge.Unmarshal(&cert.Height, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling height: %v", err)
+	}
+	buf, rem, err = surge.Unmarshal(&cert.Round, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling round: %v", err)
+	}
+	buf, rem, err = surge.Unmarshal(&cert.Value, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling value: %v", err)
+	}
+	buf, rem, err = surge.Unmarshal(&cert.Precommits, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling precommits: %v", err)
+	}
+	return buf, rem, nil
+}
+
+// A TimeoutCertificate is the proof that a quorum of unique Processes gave up
+// on a Round at a Height. It contains their Timeouts for the Round. A Process
+// that receives the Timeouts in a certificate moves to the next Round, and so
+// certificates can be handed to Processes that have fallen behind (for
+// example, because they missed some of the Timeouts) so that they can catch up
+// deterministically.
+type TimeoutCertificate struct {
+	Height   Height    `json:"height"`
+	Round    Round     `json:"round"`
+	Timeouts []Timeout `json:"timeouts"`
+}
+
+// Verify the TimeoutCertificate against the given set of signatories. An error
+// is returned if any Timeout does not match the Height and Round of the
+// certificate, if any Timeout is not correctly signed by a member of the
+// signatories, or if there are not Timeouts from a quorum of N-F unique
+// signatories (where N is the number of signatories, and F is the largest
+// number such that 3F < N).
+func (cert TimeoutCertificate) Verify(signatories []id.Signatory) error {
+	allowed := make(map[id.Signatory]bool, len(signatories))
+	for _, signatory := range signatories {
+		allowed[signatory] = true
+	}
+	signers, err := cert.verifyTimeouts(allowed)
+	if err != nil {
+		return err
+	}
+
+	threshold := quorum(uint64(len(signatories)))
+	if uint64(len(signers)) < threshold {
+		return fmt.Errorf("insufficient timeouts: expected>=%v, got=%v", threshold, len(signers))
+	}
+	return nil
+}
+
+// VerifyWithVotingPowers verifies the TimeoutCertificate against the given set
+// of weighted signatories. It is the same as Verify, except that the unique
+// signatories of the Timeouts must have a quorum of the voting power.
+func (cert TimeoutCertificate) VerifyWithVotingPowers(powers VotingPowers) error {
+	allowed := make(map[id.Signatory]bool, len(powers))
+	for signatory, power := range powers {
+		allowed[signatory] = power > 0
+	}
+	signers, err := cert.verifyTimeouts(allowed)
+	if err != nil {
+		return err
+	}
+
+	power := uint64(0)
+	for _, signer := range signers {
+		power += powers[signer]
+	}
+	if power < powers.Quorum() {
+		return fmt.Errorf("insufficient voting power: expected>=%v, got=%v", powers.Quorum(), power)
+	}
+	return nil
+}
+
+// verifyTimeouts checks each of the Timeouts in the certificate, and returns
+// the unique signatories of the Timeouts.
+func (cert TimeoutCertificate) verifyTimeouts(allowed map[id.Signatory]bool) ([]id.Signatory, error) {
+	signers := make([]id.Signatory, 0, len(cert.Timeouts))
+	seen := make(map[id.Signatory]bool, len(cert.Timeouts))
+	for _, timeout := range cert.Timeouts {
+		if timeout.Height != cert.Height {
+			return nil, fmt.Errorf("bad timeout height: expected=%v, got=%v", cert.Height, timeout.Height)
+		}
+		if timeout.Round != cert.Round {
+			return nil, fmt.Errorf("bad timeout round: expected=%v, got=%v", cert.Round, timeout.Round)
+		}
+		if !allowed[timeout.From] {
+			return nil, fmt.Errorf("bad timeout signatory: %v is not allowed", timeout.From)
+		}
+		if seen[timeout.From] {
+			return nil, fmt.Errorf("bad timeout signatory: %v is duplicated", timeout.From)
+		}
+		if err := timeout.Verify(); err != nil {
+			return nil, fmt.Errorf("bad timeout signature: %v", err)
+		}
+		seen[timeout.From] = true
+		signers = append(signers, timeout.From)
+	}
+	return signers, nil
+}
+
+// SizeHint returns the number of bytes required to represent this certificate
+// in binary.
+func (cert TimeoutCertificate) SizeHint() int {
+	return surge.SizeHint(cert.Height) +
+		surge.SizeHint(cert.Round) +
+		surge.SizeHint(cert.Timeouts)
+}
+
+// Marshal this certificate into binary.
+func (cert TimeoutCertificate) Marshal(buf []byte, rem int) ([]byte, int, error) {
+	buf, rem, err := surge.Marshal(cert.Height, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling height=%v: %v", cert.Height, err)
+	}
+	buf, rem, err = surge.Marshal(cert.Round, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling round=%v: %v", cert.Round, err)
+	}
+	buf, rem, err = surge.Marshal(cert.Timeouts, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling %v timeouts: %v", len(cert.Timeouts), err)
+	}
+	return buf, rem, nil
+}
+
+// Unmarshal binary into this certificate.
+func (cert *TimeoutCertificate) Unmarshal(buf []byte, rem int) ([]byte, int, error) {
+	buf, rem, err := surge.Unmarshal(&cert.Height, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling height: %v", err)
+	}
+	buf, rem, err = surge.Unmarshal(&cert.Round, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling round: %v", err)
+	}
+	buf, rem, err = surge.Unmarshal(&cert.Timeouts, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling timeouts: %v", err)
+	}
+	return buf, rem, nil
+}
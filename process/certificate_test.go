@@ -0,0 +1,330 @@
+package process_test
+
+import (
+	"math/rand"
+	"testing/quick"
+	"time"
+
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/hyperdrive/process/processutil"
+	"github.com/renproject/id"
+	"github.com/renproject/surge"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Commit certificate", func() {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	randomCert := func(r *rand.Rand, n, precommits int) (process.CommitCertificate, []*id.PrivKey, []id.Signatory) {
+		privKeys := make([]*id.PrivKey, n)
+		signatories := make([]id.Signatory, n)
+		for i := range privKeys {
+			privKeys[i] = id.NewPrivKey()
+			signatories[i] = privKeys[i].Signatory()
+		}
+		value := processutil.RandomGoodValue(r)
+		cert := process.CommitCertificate{
+			Height:     processutil.RandomHeight(r),
+			Round:      processutil.RandomRound(r),
+			Value:      value,
+			Precommits: make([]process.Precommit, precommits),
+		}
+		for i := range cert.Precommits {
+			cert.Precommits[i] = process.Precommit{
+				Height: cert.Height,
+				Round:  cert.Round,
+				Value:  cert.Value,
+			}
+			Expect(cert.Precommits[i].Sign(privKeys[i])).To(Succeed())
+		}
+		return cert, privKeys, signatories
+	}
+
+	Context("when unmarshaling fuzz", func() {
+		It("should not panic", func() {
+			f := func(fuzz []byte) bool {
+				cert := process.CommitCertificate{}
+				Expect(func() { surge.FromBinary(&cert, fuzz) }).ToNot(Panic())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+
+	Context("when marshaling and then unmarshaling", func() {
+		It("should equal itself", func() {
+			loop := func() bool {
+				expected, _, _ := randomCert(r, 4, r.Intn(5))
+				data, err := surge.ToBinary(expected)
+				Expect(err).ToNot(HaveOccurred())
+				got := process.CommitCertificate{}
+				Expect(surge.FromBinary(&got, data)).To(Succeed())
+				Expect(got.Height).To(Equal(expected.Height))
+				Expect(got.Round).To(Equal(expected.Round))
+				Expect(got.Value).To(Equal(expected.Value))
+				Expect(len(got.Precommits)).To(Equal(len(expected.Precommits)))
+				for i := range got.Precommits {
+					Expect(got.Precommits[i].Equal(&expected.Precommits[i])).To(BeTrue())
+					Expect(got.Precommits[i].Signature).To(Equal(expected.Precommits[i].Signature))
+				}
+				return true
+			}
+			Expect(quick.Check(loop, nil)).To(Succeed())
+		})
+
+		It("should return an error when not enough bytes", func() {
+			loop := func() bool {
+				cert, _, _ := randomCert(r, 4, 3)
+				sizeAvailable := r.Intn(cert.SizeHint())
+				buf := make([]byte, cert.SizeHint())
+				_, _, err := cert.Marshal(buf, sizeAvailable)
+				Expect(err).To(HaveOccurred())
+
+				data, err := surge.ToBinary(cert)
+				Expect(err).ToNot(HaveOccurred())
+				got := process.CommitCertificate{}
+				_, _, err = got.Unmarshal(data, sizeAvailable)
+				Expect(err).To(HaveOccurred())
+				return true
+			}
+			Expect(quick.Check(loop, nil)).To(Succeed())
+		})
+	})
+
+	Context("when verifying", func() {
+		It("should succeed with 2f+1 correctly signed precommits", func() {
+			loop := func() bool {
+				f := 1 + r.Intn(4)
+				n := 3*f + 1
+				cert, _, signatories := randomCert(r, n, 2*f+1+r.Intn(f+1))
+				Expect(cert.Verify(signatories)).To(Succeed())
+				return true
+			}
+			Expect(quick.Check(loop, nil)).To(Succeed())
+		})
+
+		It("should fail with less than 2f+1 precommits", func() {
+			loop := func() bool {
+				f := 1 + r.Intn(4)
+				n := 3*f + 1
+				cert, _, signatories := randomCert(r, n, r.Intn(2*f+1))
+				Expect(cert.Verify(signatories)).ToNot(Succeed())
+				return true
+			}
+			Expect(quick.Check(loop, nil)).To(Succeed())
+		})
+
+		It("should require n-f precommits when n is not 3f+1", func() {
+			for _, n := range []int{5, 6, 8} {
+				f := (n - 1) / 3
+				cert, _, signatories := randomCert(r, n, n-f)
+				Expect(cert.Verify(signatories)).To(Succeed())
+				cert.Precommits = cert.Precommits[1:]
+				Expect(cert.Verify(signatories)).ToNot(Succeed())
+			}
+		})
+
+		It("should fail with duplicate precommits", func() {
+			cert, _, signatories := randomCert(r, 4, 2)
+			cert.Precommits = append(cert.Precommits, cert.Precommits[0])
+			Expect(cert.Verify(signatories)).ToNot(Succeed())
+		})
+
+		It("should fail with precommits from unknown signatories", func() {
+			cert, _, signatories := randomCert(r, 4, 3)
+			Expect(cert.Verify(signatories[1:])).ToNot(Succeed())
+		})
+
+		It("should fail with precommits for a different value", func() {
+			cert, privKeys, signatories := randomCert(r, 4, 3)
+			cert.Precommits[0].Value = processutil.RandomGoodValue(r)
+			Expect(cert.Precommits[0].Sign(privKeys[0])).To(Succeed())
+			Expect(cert.Verify(signatories)).ToNot(Succeed())
+		})
+
+		It("should fail with badly signed precommits", func() {
+			cert, _, signatories := randomCert(r, 4, 3)
+			cert.Precommits[0].Signature = cert.Precommits[1].Signature
+			Expect(cert.Verify(signatories)).ToNot(Succeed())
+		})
+
+		It("should succeed with precommits from a quorum of the voting power", func() {
+			loop := func() bool {
+				n := 1 + r.Intn(10)
+				cert, _, signatories := randomCert(r, n, n)
+				powers := process.VotingPowers{}
+				for _, signatory := range signatories {
+					powers[signatory] = uint64(1 + r.Intn(100))
+				}
+				Expect(cert.VerifyWithVotingPowers(powers)).To(Succeed())
+				return true
+			}
+			Expect(quick.Check(loop, nil)).To(Succeed())
+		})
+
+		It("should fail with precommits from less than a quorum of the voting power", func() {
+			loop := func() bool {
+				n := 2 + r.Intn(10)
+				cert, _, signatories := randomCert(r, n, n-1)
+				powers := process.VotingPowers{}
+				for _, signatory := range signatories {
+					powers[signatory] = uint64(1 + r.Intn(100))
+				}
+				// give the signatory without a precommit more than a third of
+				// the voting power
+				powers[signatories[n-1]] = powers.Total()
+				Expect(cert.VerifyWithVotingPowers(powers)).ToNot(Succeed())
+				return true
+			}
+			Expect(quick.Check(loop, nil)).To(Succeed())
+		})
+
+		It("should fail with precommits from signatories without voting power", func() {
+			cert, _, signatories := randomCert(r, 4, 4)
+			powers := process.EqualVotingPowers(signatories)
+			powers[signatories[0]] = 0
+			Expect(cert.VerifyWithVotingPowers(powers)).ToNot(Succeed())
+		})
+
+		It("should fail with a nil value", func() {
+			cert, _, signatories := randomCert(r, 4, 0)
+			cert.Value = process.NilValue
+			Expect(cert.Verify(signatories)).ToNot(Succeed())
+		})
+	})
+})
+
+var _ = Describe("Timeout certificate", func() {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	randomCert := func(r *rand.Rand, n, timeouts int) (process.TimeoutCertificate, []*id.PrivKey, []id.Signatory) {
+		privKeys := make([]*id.PrivKey, n)
+		signatories := make([]id.Signatory, n)
+		for i := range privKeys {
+			privKeys[i] = id.NewPrivKey()
+			signatories[i] = privKeys[i].Signatory()
+		}
+		cert := process.TimeoutCertificate{
+			Height:   processutil.RandomHeight(r),
+			Round:    processutil.RandomRound(r),
+			Timeouts: make([]process.Timeout, timeouts),
+		}
+		for i := range cert.Timeouts {
+			cert.Timeouts[i] = process.Timeout{
+				Height: cert.Height,
+				Round:  cert.Round,
+			}
+			Expect(cert.Timeouts[i].Sign(privKeys[i])).To(Succeed())
+		}
+		return cert, privKeys, signatories
+	}
+
+	Context("when unmarshaling fuzz", func() {
+		It("should not panic", func() {
+			f := func(fuzz []byte) bool {
+				cert := process.TimeoutCertificate{}
+				Expect(func() { surge.FromBinary(&cert, fuzz) }).ToNot(Panic())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+
+	Context("when marshaling and then unmarshaling", func() {
+		It("should equal itself", func() {
+			loop := func() bool {
+				expected, _, _ := randomCert(r, 4, r.Intn(5))
+				data, err := surge.ToBinary(expected)
+				Expect(err).ToNot(HaveOccurred())
+				got := process.TimeoutCertificate{}
+				Expect(surge.FromBinary(&got, data)).To(Succeed())
+				Expect(got.Height).To(Equal(expected.Height))
+				Expect(got.Round).To(Equal(expected.Round))
+				Expect(len(got.Timeouts)).To(Equal(len(expected.Timeouts)))
+				for i := range got.Timeouts {
+					Expect(got.Timeouts[i].Equal(&expected.Timeouts[i])).To(BeTrue())
+					Expect(got.Timeouts[i].Signature).To(Equal(expected.Timeouts[i].Signature))
+				}
+				return true
+			}
+			Expect(quick.Check(loop, nil)).To(Succeed())
+		})
+
+		It("should return an error when not enough bytes", func() {
+			loop := func() bool {
+				cert, _, _ := randomCert(r, 4, 3)
+				sizeAvailable := r.Intn(cert.SizeHint())
+				buf := make([]byte, cert.SizeHint())
+				_, _, err := cert.Marshal(buf, sizeAvailable)
+				Expect(err).To(HaveOccurred())
+
+				data, err := surge.ToBinary(cert)
+				Expect(err).ToNot(HaveOccurred())
+				got := process.TimeoutCertificate{}
+				_, _, err = got.Unmarshal(data, sizeAvailable)
+				Expect(err).To(HaveOccurred())
+				return true
+			}
+			Expect(quick.Check(loop, nil)).To(Succeed())
+		})
+	})
+
+	Context("when verifying", func() {
+		It("should succeed with n-f correctly signed timeouts", func() {
+			loop := func() bool {
+				n := 1 + r.Intn(13)
+				f := (n - 1) / 3
+				cert, _, signatories := randomCert(r, n, n-f+r.Intn(f+1))
+				Expect(cert.Verify(signatories)).To(Succeed())
+				return true
+			}
+			Expect(quick.Check(loop, nil)).To(Succeed())
+		})
+
+		It("should fail with less than n-f timeouts", func() {
+			loop := func() bool {
+				n := 1 + r.Intn(13)
+				f := (n - 1) / 3
+				cert, _, signatories := randomCert(r, n, r.Intn(n-f))
+				Expect(cert.Verify(signatories)).ToNot(Succeed())
+				return true
+			}
+			Expect(quick.Check(loop, nil)).To(Succeed())
+		})
+
+		It("should fail with duplicate timeouts", func() {
+			cert, _, signatories := randomCert(r, 4, 2)
+			cert.Timeouts = append(cert.Timeouts, cert.Timeouts[0])
+			Expect(cert.Verify(signatories)).ToNot(Succeed())
+		})
+
+		It("should fail with timeouts from unknown signatories", func() {
+			cert, _, signatories := randomCert(r, 4, 3)
+			Expect(cert.Verify(signatories[1:])).ToNot(Succeed())
+		})
+
+		It("should fail with timeouts for a different round", func() {
+			cert, privKeys, signatories := randomCert(r, 4, 3)
+			cert.Timeouts[0].Round = cert.Round + 1
+			Expect(cert.Timeouts[0].Sign(privKeys[0])).To(Succeed())
+			Expect(cert.Verify(signatories)).ToNot(Succeed())
+		})
+
+		It("should fail with badly signed timeouts", func() {
+			cert, _, signatories := randomCert(r, 4, 3)
+			cert.Timeouts[0].Signature = cert.Timeouts[1].Signature
+			Expect(cert.Verify(signatories)).ToNot(Succeed())
+		})
+
+		It("should succeed with timeouts from a quorum of the voting power", func() {
+			cert, _, signatories := randomCert(r, 4, 2)
+			powers := process.EqualVotingPowers(signatories)
+			powers[signatories[0]] = 4
+			Expect(cert.VerifyWithVotingPowers(powers)).To(Succeed())
+			powers[signatories[3]] = 4
+			Expect(cert.VerifyWithVotingPowers(powers)).ToNot(Succeed())
+		})
+	})
+})
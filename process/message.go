@@ -46,7 +46,8 @@ type Propose struct {
 	ValidRound Round  `json:"validRound"`
 	Value      Value  `json:"value"`
 
-	From id.Signatory `json:"from"`
+	From      id.Signatory `json:"from"`
+	Signature id.Signature `json:"signature"`
 }
 
 // NewProposeHash receives fields of a propose message and hashes the message
@@ -77,6 +78,33 @@ func NewProposeHashWithBuffer(height Height, round Round, validRound Round, valu
 	return id.NewHash(data), nil
 }
 
+// Sign the Propose using the given private key. The From field is set to the
+// signatory of the private key, and the Signature field is set to the signature
+// over the hash returned by NewProposeHash.
+func (propose *Propose) Sign(privKey *id.PrivKey) error {
+	hash, err := NewProposeHash(propose.Height, propose.Round, propose.ValidRound, propose.Value)
+	if err != nil {
+		return fmt.Errorf("hashing propose: %v", err)
+	}
+	signature, err := privKey.Sign(&hash)
+	if err != nil {
+		return fmt.Errorf("signing propose: %v", err)
+	}
+	propose.From = privKey.Signatory()
+	propose.Signature = signature
+	return nil
+}
+
+// Verify that the Propose was signed by the Process identified by its From
+// field. An error is returned if the signature is missing or invalid.
+func (propose Propose) Verify() error {
+	hash, err := NewProposeHash(propose.Height, propose.Round, propose.ValidRound, propose.Value)
+	if err != nil {
+		return fmt.Errorf("hashing propose: %v", err)
+	}
+	return verifySignatory(&hash, &propose.Signature, &propose.From)
+}
+
 // Equal compares two Proposes. If they are equal, then it return true,
 // otherwise it returns false. The signatures are not checked for equality,
 // because signatures include randomness.
@@ -95,7 +123,8 @@ func (propose Propose) SizeHint() int {
 		surge.SizeHint(propose.Round) +
 		surge.SizeHint(propose.ValidRound) +
 		surge.SizeHint(propose.Value) +
-		surge.SizeHint(propose.From)
+		surge.SizeHint(propose.From) +
+		surge.SizeHint(propose.Signature)
 }
 
 // Marshal this message into binary.
@@ -120,6 +149,10 @@ func (propose Propose) Marshal(buf []byte, rem int) ([]byte, int, error) {
 	if err != nil {
 		return buf, rem, fmt.Errorf("marshaling from=%v: %v", propose.From, err)
 	}
+	buf, rem, err = surge.Marshal(propose.Signature, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling signature=%v: %v", propose.Signature, err)
+	}
 	return buf, rem, nil
 }
 
@@ -145,6 +178,10 @@ func (propose *Propose) Unmarshal(buf []byte, rem int) ([]byte, int, error) {
 	if err != nil {
 		return buf, rem, fmt.Errorf("unmarshaling from: %v", err)
 	}
+	buf, rem, err = surge.Unmarshal(&propose.Signature, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling signature: %v", err)
+	}
 	return buf, rem, nil
 }
 
@@ -158,7 +195,8 @@ type Prevote struct {
 	Round  Round  `json:"round"`
 	Value  Value  `json:"value"`
 
-	From id.Signatory `json:"from"`
+	From      id.Signatory `json:"from"`
+	Signature id.Signature `json:"signature"`
 }
 
 // NewPrevoteHash receives fields of a prevote message and hashes the message
@@ -185,6 +223,33 @@ func NewPrevoteHashWithBuffer(height Height, round Round, value Value, data []by
 	return id.NewHash(data), nil
 }
 
+// Sign the Prevote using the given private key. The From field is set to the
+// signatory of the private key, and the Signature field is set to the signature
+// over the hash returned by NewPrevoteHash.
+func (prevote *Prevote) Sign(privKey *id.PrivKey) error {
+	hash, err := NewPrevoteHash(prevote.Height, prevote.Round, prevote.Value)
+	if err != nil {
+		return fmt.Errorf("hashing prevote: %v", err)
+	}
+	signature, err := privKey.Sign(&hash)
+	if err != nil {
+		return fmt.Errorf("signing prevote: %v", err)
+	}
+	prevote.From = privKey.Signatory()
+	prevote.Signature = signature
+	return nil
+}
+
+// Verify that the Prevote was signed by the Process identified by its From
+// field. An error is returned if the signature is missing or invalid.
+func (prevote Prevote) Verify() error {
+	hash, err := NewPrevoteHash(prevote.Height, prevote.Round, prevote.Value)
+	if err != nil {
+		return fmt.Errorf("hashing prevote: %v", err)
+	}
+	return verifySignatory(&hash, &prevote.Signature, &prevote.From)
+}
+
 // Equal compares two Prevotes. If they are equal, then it return true,
 // otherwise it returns false. The signatures are not checked for equality,
 // because signatures include randomness.
@@ -201,7 +266,8 @@ func (prevote Prevote) SizeHint() int {
 	return surge.SizeHint(prevote.Height) +
 		surge.SizeHint(prevote.Round) +
 		surge.SizeHint(prevote.Value) +
-		surge.SizeHint(prevote.From)
+		surge.SizeHint(prevote.From) +
+		surge.SizeHint(prevote.Signature)
 }
 
 // Marshal this message into binary.
@@ -222,6 +288,10 @@ func (prevote Prevote) Marshal(buf []byte, rem int) ([]byte, int, error) {
 	if err != nil {
 		return buf, rem, fmt.Errorf("marshaling from=%v: %v", prevote.From, err)
 	}
+	buf, rem, err = surge.Marshal(prevote.Signature, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling signature=%v: %v", prevote.Signature, err)
+	}
 	return buf, rem, nil
 }
 
@@ -243,6 +313,10 @@ func (prevote *Prevote) Unmarshal(buf []byte, rem int) ([]byte, int, error) {
 	if err != nil {
 		return buf, rem, fmt.Errorf("unmarshaling from: %v", err)
 	}
+	buf, rem, err = surge.Unmarshal(&prevote.Signature, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling signature: %v", err)
+	}
 	return buf, rem, nil
 }
 
@@ -256,7 +330,8 @@ type Precommit struct {
 	Round  Round  `json:"round"`
 	Value  Value  `json:"value"`
 
-	From id.Signatory `json:"from"`
+	From      id.Signatory `json:"from"`
+	Signature id.Signature `json:"signature"`
 }
 
 // NewPrecommitHash receives fields of a precommit message and hashes the message
@@ -283,6 +358,33 @@ func NewPrecommitHashWithBuffer(height Height, round Round, value Value, data []
 	return id.NewHash(data), nil
 }
 
+// Sign the Precommit using the given private key. The From field is set to the
+// signatory of the private key, and the Signature field is set to the signature
+// over the hash returned by NewPrecommitHash.
+func (precommit *Precommit) Sign(privKey *id.PrivKey) error {
+	hash, err := NewPrecommitHash(precommit.Height, precommit.Round, precommit.Value)
+	if err != nil {
+		return fmt.Errorf("hashing precommit: %v", err)
+	}
+	signature, err := privKey.Sign(&hash)
+	if err != nil {
+		return fmt.Errorf("signing precommit: %v", err)
+	}
+	precommit.From = privKey.Signatory()
+	precommit.Signature = signature
+	return nil
+}
+
+// Verify that the Precommit was signed by the Process identified by its From
+// field. An error is returned if the signature is missing or invalid.
+func (precommit Precommit) Verify() error {
+	hash, err := NewPrecommitHash(precommit.Height, precommit.Round, precommit.Value)
+	if err != nil {
+		return fmt.Errorf("hashing precommit: %v", err)
+	}
+	return verifySignatory(&hash, &precommit.Signature, &precommit.From)
+}
+
 // Equal compares two Precommits. If they are equal, then it return true,
 // otherwise it returns false. The signatures are not checked for equality,
 // because signatures include randomness.
@@ -299,7 +401,8 @@ func (precommit Precommit) SizeHint() int {
 	return surge.SizeHint(precommit.Height) +
 		surge.SizeHint(precommit.Round) +
 		surge.SizeHint(precommit.Value) +
-		surge.SizeHint(precommit.From)
+		surge.SizeHint(precommit.From) +
+		surge.SizeHint(precommit.Signature)
 }
 
 // Marshal this message into binary.
@@ -320,6 +423,10 @@ func (precommit Precommit) Marshal(buf []byte, rem int) ([]byte, int, error) {
 	if err != nil {
 		return buf, rem, fmt.Errorf("marshaling from=%v: %v", precommit.From, err)
 	}
+	buf, rem, err = surge.Marshal(precommit.Signature, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling signature=%v: %v", precommit.Signature, err)
+	}
 	return buf, rem, nil
 }
 
@@ -341,5 +448,170 @@ func (precommit *Precommit) Unmarshal(buf []byte, rem int) ([]byte, int, error)
 	if err != nil {
 		return buf, rem, fmt.Errorf("unmarshaling from: %v", err)
 	}
+	buf, rem, err = surge.Unmarshal(&precommit.Signature, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling signature: %v", err)
+	}
+	return buf, rem, nil
+}
+
+// A Timeout is sent by every correct Process at most once per Round, when its
+// precommit timeout for that Round expires. It signals that the Process has
+// given up on the Round and moved to the next one. Timeouts do not carry a
+// Value, and so two Timeouts from the same Process at the same Height and Round
+// can never conflict. Once a Process receives Timeouts from a quorum of unique
+// Processes for a Round, it moves to the next Round (even if it has fallen
+// behind), which keeps Processes in sync without relying on them eventually
+// seeing F+1 messages in a future Round.
+type Timeout struct {
+	Height Height `json:"height"`
+	Round  Round  `json:"round"`
+
+	From      id.Signatory `json:"from"`
+	Signature id.Signature `json:"signature"`
+}
+
+// NewTimeoutHash receives fields of a timeout message and hashes the message
+func NewTimeoutHash(height Height, round Round) (id.Hash, error) {
+	sizeHint := surge.SizeHint(height) + surge.SizeHint(round)
+	buf := make([]byte, sizeHint)
+	return NewTimeoutHashWithBuffer(height, round, buf)
+}
+
+// NewTimeoutHashWithBuffer receives fields of a timeout message, with a bytes buffer and hashes the message
+func NewTimeoutHashWithBuffer(height Height, round Round, data []byte) (id.Hash, error) {
+	buf, rem, err := surge.Marshal(height, data, surge.MaxBytes)
+	if err != nil {
+		return id.Hash{}, fmt.Errorf("marshaling height=%v: %v", height, err)
+	}
+	buf, rem, err = surge.Marshal(round, buf, rem)
+	if err != nil {
+		return id.Hash{}, fmt.Errorf("marshaling round=%v: %v", round, err)
+	}
+	return id.NewHash(data), nil
+}
+
+// Sign the Timeout using the given private key. The From field is set to the
+// signatory of the private key, and the Signature field is set to the signature
+// over the hash returned by NewTimeoutHash.
+func (timeout *Timeout) Sign(privKey *id.PrivKey) error {
+	hash, err := NewTimeoutHash(timeout.Height, timeout.Round)
+	if err != nil {
+		return fmt.Errorf("hashing timeout: %v", err)
+	}
+	signature, err := privKey.Sign(&hash)
+	if err != nil {
+		return fmt.Errorf("signing timeout: %v", err)
+	}
+	timeout.From = privKey.Signatory()
+	timeout.Signature = signature
+	return nil
+}
+
+// Verify that the Timeout was signed by the Process identified by its From
+// field. An error is returned if the signature is missing or invalid.
+func (timeout Timeout) Verify() error {
+	hash, err := NewTimeoutHash(timeout.Height, timeout.Round)
+	if err != nil {
+		return fmt.Errorf("hashing timeout: %v", err)
+	}
+	return verifySignatory(&hash, &timeout.Signature, &timeout.From)
+}
+
+// Equal compares two Timeouts. If they are equal, then it return true,
+// otherwise it returns false. The signatures are not checked for equality,
+// because signatures include randomness.
+func (timeout Timeout) Equal(other *Timeout) bool {
+	return timeout.Height == other.Height &&
+		timeout.Round == other.Round &&
+		timeout.From.Equal(&other.From)
+}
+
+// SizeHint returns the number of bytes required to represent this message in
+// binary.
+func (timeout Timeout) SizeHint() int {
+	return surge.SizeHint(timeout.Height) +
+		surge.SizeHint(timeout.Round) +
+		surge.SizeHint(timeout.From) +
+		surge.SizeHint(timeout.Signature)
+}
+
+// Marshal this message into binary.
+func (timeout Timeout) Marshal(buf []byte, rem int) ([]byte, int, error) {
+	buf, rem, err := surge.Marshal(timeout.Height, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling height=%v: %v", timeout.Height, err)
+	}
+	buf, rem, err = surge.Marshal(timeout.Round, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling round=%v: %v", timeout.Round, err)
+	}
+	buf, rem, err = surge.Marshal(timeout.From, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling from=%v: %v", timeout.From, err)
+	}
+	buf, rem, err = surge.Marshal(timeout.Signature, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling signature=%v: %v", timeout.Signature, err)
+	}
+	return buf, rem, nil
+}
+
+// Unmarshal binary into this message.
+func (timeout *Timeout) Unmarshal(buf []byte, rem int) ([]byte, int, error) {
+	buf, rem, err := surge.Unmarshal(&timeout.Height, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling height: %v", err)
+	}
+	buf, rem, err = surge.Unmarshal(&timeout.Round, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling round: %v", err)
+	}
+	buf, rem, err = surge.Unmarshal(&timeout.From, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling from: %v", err)
+	}
+	buf, rem, err = surge.Unmarshal(&timeout.Signature, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling signature: %v", err)
+	}
 	return buf, rem, nil
 }
+
+// SignatureVerifier is a Verifier that accepts messages if, and only if, their
+// Signature is a valid ECDSA signature over their hash, produced by the private
+// key of their From signatory.
+type SignatureVerifier struct{}
+
+// VerifyPropose returns an error if the Propose is not correctly signed.
+func (SignatureVerifier) VerifyPropose(propose Propose) error {
+	return propose.Verify()
+}
+
+// VerifyPrevote returns an error if the Prevote is not correctly signed.
+func (SignatureVerifier) VerifyPrevote(prevote Prevote) error {
+	return prevote.Verify()
+}
+
+// VerifyPrecommit returns an error if the Precommit is not correctly signed.
+func (SignatureVerifier) VerifyPrecommit(precommit Precommit) error {
+	return precommit.Verify()
+}
+
+// VerifyTimeout returns an error if the Timeout is not correctly signed.
+func (SignatureVerifier) VerifyTimeout(timeout Timeout) error {
+	return timeout.Verify()
+}
+
+// verifySignatory recovers the signatory of the signature over the hash, and
+// returns an error if it is not the expected signatory.
+func verifySignatory(hash *id.Hash, signature *id.Signature, expected *id.Signatory) error {
+	signatory, err := signature.Signatory(hash)
+	if err != nil {
+		return fmt.Errorf("recovering signatory: %v", err)
+	}
+	if !signatory.Equal(expected) {
+		return fmt.Errorf("bad signatory: expected=%v, got=%v", expected, signatory)
+	}
+	return nil
+}
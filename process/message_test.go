@@ -30,13 +30,14 @@ var _ = Describe("Propose", func() {
 		r := rand.New(rand.NewSource(time.Now().UnixNano()))
 
 		It("should equal itself", func() {
-			f := func(height process.Height, round, validRound process.Round, value process.Value, from id.Signatory) bool {
+			f := func(height process.Height, round, validRound process.Round, value process.Value, from id.Signatory, signature id.Signature) bool {
 				expected := process.Propose{
 					Height:     height,
 					Round:      round,
 					ValidRound: validRound,
 					Value:      value,
 					From:       from,
+					Signature:  signature,
 				}
 				data, err := surge.ToBinary(expected)
 				Expect(err).ToNot(HaveOccurred())
@@ -44,6 +45,7 @@ var _ = Describe("Propose", func() {
 				err = surge.FromBinary(&got, data)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(got.Equal(&expected)).To(BeTrue())
+				Expect(got.Signature.Equal(&expected.Signature)).To(BeTrue())
 				return true
 			}
 			Expect(quick.Check(f, nil)).To(Succeed())
@@ -127,6 +129,56 @@ var _ = Describe("Propose", func() {
 		})
 	})
 
+	Context("when signing", func() {
+		It("should verify", func() {
+			f := func(height process.Height, round, validRound process.Round, value process.Value) bool {
+				privKey := id.NewPrivKey()
+				msg := process.Propose{Height: height, Round: round, ValidRound: validRound, Value: value}
+				Expect(msg.Sign(privKey)).To(Succeed())
+				Expect(msg.From).To(Equal(privKey.Signatory()))
+				Expect(msg.Verify()).To(Succeed())
+				Expect(process.SignatureVerifier{}.VerifyPropose(msg)).To(Succeed())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should not verify when the from field is changed", func() {
+			f := func(height process.Height, round, validRound process.Round, value process.Value) bool {
+				msg := process.Propose{Height: height, Round: round, ValidRound: validRound, Value: value}
+				Expect(msg.Sign(id.NewPrivKey())).To(Succeed())
+				msg.From = id.NewPrivKey().Signatory()
+				Expect(msg.Verify()).ToNot(Succeed())
+				Expect(process.SignatureVerifier{}.VerifyPropose(msg)).ToNot(Succeed())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should not verify when the value is changed", func() {
+			f := func(height process.Height, round, validRound process.Round, value process.Value, otherValue process.Value) bool {
+				if value.Equal(&otherValue) {
+					return true
+				}
+				msg := process.Propose{Height: height, Round: round, ValidRound: validRound, Value: value}
+				Expect(msg.Sign(id.NewPrivKey())).To(Succeed())
+				msg.Value = otherValue
+				Expect(msg.Verify()).ToNot(Succeed())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should not verify when unsigned", func() {
+			f := func(height process.Height, round, validRound process.Round, value process.Value) bool {
+				msg := process.Propose{Height: height, Round: round, ValidRound: validRound, Value: value, From: id.NewPrivKey().Signatory()}
+				Expect(msg.Verify()).ToNot(Succeed())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+
 	Context("when compute the hash", func() {
 		r := rand.New(rand.NewSource(time.Now().UnixNano()))
 
@@ -221,10 +273,11 @@ var _ = Describe("Prevote", func() {
 		It("should equal itself", func() {
 			f := func(height process.Height, round process.Round, value process.Value, from id.Signatory, signature id.Signature) bool {
 				expected := process.Prevote{
-					Height: height,
-					Round:  round,
-					Value:  value,
-					From:   from,
+					Height:    height,
+					Round:     round,
+					Value:     value,
+					From:      from,
+					Signature: signature,
 				}
 				data, err := surge.ToBinary(expected)
 				Expect(err).ToNot(HaveOccurred())
@@ -232,6 +285,57 @@ var _ = Describe("Prevote", func() {
 				err = surge.FromBinary(&got, data)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(got.Equal(&expected)).To(BeTrue())
+				Expect(got.Signature.Equal(&expected.Signature)).To(BeTrue())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+
+	Context("when signing", func() {
+		It("should verify", func() {
+			f := func(height process.Height, round process.Round, value process.Value) bool {
+				privKey := id.NewPrivKey()
+				msg := process.Prevote{Height: height, Round: round, Value: value}
+				Expect(msg.Sign(privKey)).To(Succeed())
+				Expect(msg.From).To(Equal(privKey.Signatory()))
+				Expect(msg.Verify()).To(Succeed())
+				Expect(process.SignatureVerifier{}.VerifyPrevote(msg)).To(Succeed())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should not verify when the from field is changed", func() {
+			f := func(height process.Height, round process.Round, value process.Value) bool {
+				msg := process.Prevote{Height: height, Round: round, Value: value}
+				Expect(msg.Sign(id.NewPrivKey())).To(Succeed())
+				msg.From = id.NewPrivKey().Signatory()
+				Expect(msg.Verify()).ToNot(Succeed())
+				Expect(process.SignatureVerifier{}.VerifyPrevote(msg)).ToNot(Succeed())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should not verify when the value is changed", func() {
+			f := func(height process.Height, round process.Round, value process.Value, otherValue process.Value) bool {
+				if value.Equal(&otherValue) {
+					return true
+				}
+				msg := process.Prevote{Height: height, Round: round, Value: value}
+				Expect(msg.Sign(id.NewPrivKey())).To(Succeed())
+				msg.Value = otherValue
+				Expect(msg.Verify()).ToNot(Succeed())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should not verify when unsigned", func() {
+			f := func(height process.Height, round process.Round, value process.Value) bool {
+				msg := process.Prevote{Height: height, Round: round, Value: value, From: id.NewPrivKey().Signatory()}
+				Expect(msg.Verify()).ToNot(Succeed())
 				return true
 			}
 			Expect(quick.Check(f, nil)).To(Succeed())
@@ -284,10 +388,11 @@ var _ = Describe("Precommit", func() {
 		It("should equal itself", func() {
 			f := func(height process.Height, round process.Round, value process.Value, from id.Signatory, signature id.Signature) bool {
 				expected := process.Precommit{
-					Height: height,
-					Round:  round,
-					Value:  value,
-					From:   from,
+					Height:    height,
+					Round:     round,
+					Value:     value,
+					From:      from,
+					Signature: signature,
 				}
 				data, err := surge.ToBinary(expected)
 				Expect(err).ToNot(HaveOccurred())
@@ -295,6 +400,57 @@ var _ = Describe("Precommit", func() {
 				err = surge.FromBinary(&got, data)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(got.Equal(&expected)).To(BeTrue())
+				Expect(got.Signature.Equal(&expected.Signature)).To(BeTrue())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+
+	Context("when signing", func() {
+		It("should verify", func() {
+			f := func(height process.Height, round process.Round, value process.Value) bool {
+				privKey := id.NewPrivKey()
+				msg := process.Precommit{Height: height, Round: round, Value: value}
+				Expect(msg.Sign(privKey)).To(Succeed())
+				Expect(msg.From).To(Equal(privKey.Signatory()))
+				Expect(msg.Verify()).To(Succeed())
+				Expect(process.SignatureVerifier{}.VerifyPrecommit(msg)).To(Succeed())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should not verify when the from field is changed", func() {
+			f := func(height process.Height, round process.Round, value process.Value) bool {
+				msg := process.Precommit{Height: height, Round: round, Value: value}
+				Expect(msg.Sign(id.NewPrivKey())).To(Succeed())
+				msg.From = id.NewPrivKey().Signatory()
+				Expect(msg.Verify()).ToNot(Succeed())
+				Expect(process.SignatureVerifier{}.VerifyPrecommit(msg)).ToNot(Succeed())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should not verify when the value is changed", func() {
+			f := func(height process.Height, round process.Round, value process.Value, otherValue process.Value) bool {
+				if value.Equal(&otherValue) {
+					return true
+				}
+				msg := process.Precommit{Height: height, Round: round, Value: value}
+				Expect(msg.Sign(id.NewPrivKey())).To(Succeed())
+				msg.Value = otherValue
+				Expect(msg.Verify()).ToNot(Succeed())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should not verify when unsigned", func() {
+			f := func(height process.Height, round process.Round, value process.Value) bool {
+				msg := process.Precommit{Height: height, Round: round, Value: value, From: id.NewPrivKey().Signatory()}
+				Expect(msg.Verify()).ToNot(Succeed())
 				return true
 			}
 			Expect(quick.Check(f, nil)).To(Succeed())
@@ -330,3 +486,85 @@ var _ = Describe("Precommit", func() {
 		})
 	})
 })
+
+var _ = Describe("Timeout", func() {
+	Context("when unmarshaling fuzz", func() {
+		It("should not panic", func() {
+			f := func(fuzz []byte) bool {
+				msg := process.Timeout{}
+				Expect(func() { surge.FromBinary(&msg, fuzz) }).ToNot(Panic())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+
+	Context("when marshaling and then unmarshaling", func() {
+		It("should equal itself", func() {
+			f := func(height process.Height, round process.Round, from id.Signatory, signature id.Signature) bool {
+				expected := process.Timeout{
+					Height:    height,
+					Round:     round,
+					From:      from,
+					Signature: signature,
+				}
+				data, err := surge.ToBinary(expected)
+				Expect(err).ToNot(HaveOccurred())
+				got := process.Timeout{}
+				err = surge.FromBinary(&got, data)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(got.Equal(&expected)).To(BeTrue())
+				Expect(got.Signature.Equal(&expected.Signature)).To(BeTrue())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+
+	Context("when signing", func() {
+		It("should verify", func() {
+			f := func(height process.Height, round process.Round) bool {
+				privKey := id.NewPrivKey()
+				msg := process.Timeout{Height: height, Round: round}
+				Expect(msg.Sign(privKey)).To(Succeed())
+				Expect(msg.From).To(Equal(privKey.Signatory()))
+				Expect(msg.Verify()).To(Succeed())
+				Expect(process.SignatureVerifier{}.VerifyTimeout(msg)).To(Succeed())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should not verify when the from field is changed", func() {
+			f := func(height process.Height, round process.Round) bool {
+				msg := process.Timeout{Height: height, Round: round}
+				Expect(msg.Sign(id.NewPrivKey())).To(Succeed())
+				msg.From = id.NewPrivKey().Signatory()
+				Expect(msg.Verify()).ToNot(Succeed())
+				Expect(process.SignatureVerifier{}.VerifyTimeout(msg)).ToNot(Succeed())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should not verify when the round is changed", func() {
+			f := func(height process.Height, round process.Round) bool {
+				msg := process.Timeout{Height: height, Round: round}
+				Expect(msg.Sign(id.NewPrivKey())).To(Succeed())
+				msg.Round = round + 1
+				Expect(msg.Verify()).ToNot(Succeed())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should not verify when unsigned", func() {
+			f := func(height process.Height, round process.Round) bool {
+				msg := process.Timeout{Height: height, Round: round, From: id.NewPrivKey().Signatory()}
+				Expect(msg.Verify()).ToNot(Succeed())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+})
@@ -0,0 +1,80 @@
+package process
+
+import (
+	"github.com/renproject/id"
+)
+
+// VotingPowers map signatories to the voting power that they have in the
+// consensus algorithm. Signatories that are not in the map have no voting
+// power. Thresholds are measured in voting power, rather than in the number of
+// unique signatories: a quorum requires more than two thirds of the total
+// voting power, and skipping to a future round requires more than the maximum
+// voting power that can be held by malicious adversaries.
+type VotingPowers map[id.Signatory]uint64
+
+// EqualVotingPowers returns VotingPowers in which each of the given signatories
+// has a voting power of one.
+func EqualVotingPowers(signatories []id.Signatory) VotingPowers {
+	powers := make(VotingPowers, len(signatories))
+	for _, signatory := range signatories {
+		powers[signatory] = 1
+	}
+	return powers
+}
+
+// Total returns the sum of the voting power of all signatories.
+func (powers VotingPowers) Total() uint64 {
+	total := uint64(0)
+	for _, power := range powers {
+		total += power
+	}
+	return total
+}
+
+// MaxFaulty returns the maximum voting power that can be held by malicious
+// adversaries while the Processes still maintain safety and liveliness. This is
+// the largest voting power that is strictly less than a third of the total
+// voting power.
+func (powers VotingPowers) MaxFaulty() uint64 {
+	return maxFaulty(powers.Total())
+}
+
+// Quorum returns the voting power required for a quorum. This is the smallest
+// voting power that is strictly greater than two thirds of the total voting
+// power. If there is no voting power, then a quorum can never be reached.
+func (powers VotingPowers) Quorum() uint64 {
+	return quorum(powers.Total())
+}
+
+// SkipThreshold returns the voting power required to skip to a future Round.
+// This is the smallest voting power that guarantees at least one correct
+// Process is in the future Round.
+func (powers VotingPowers) SkipThreshold() uint64 {
+	return skipThreshold(powers.Total())
+}
+
+// maxFaulty returns f, the largest number that is strictly less than a third of
+// n. When n is 3f+1 this is exactly f, but it is also correct for committees
+// of any other size.
+func maxFaulty(n uint64) uint64 {
+	if n == 0 {
+		return 0
+	}
+	return (n - 1) / 3
+}
+
+// quorum returns n-f, the smallest number that is strictly greater than two
+// thirds of n. Any two quorums intersect in at least f+1, and so in at least
+// one correct Process. If n is zero, then a quorum can never be reached.
+func quorum(n uint64) uint64 {
+	if n == 0 {
+		return 1
+	}
+	return n - maxFaulty(n)
+}
+
+// skipThreshold returns f+1, the smallest number that guarantees at least one
+// correct Process.
+func skipThreshold(n uint64) uint64 {
+	return maxFaulty(n) + 1
+}
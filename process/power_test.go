@@ -0,0 +1,88 @@
+package process_test
+
+import (
+	"math/rand"
+	"testing/quick"
+	"time"
+
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/id"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Voting powers", func() {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	randomVotingPowers := func(r *rand.Rand) process.VotingPowers {
+		powers := process.VotingPowers{}
+		for i := 0; i < 1+r.Intn(20); i++ {
+			powers[id.NewPrivKey().Signatory()] = uint64(r.Intn(100))
+		}
+		return powers
+	}
+
+	Context("when all signatories have equal voting power", func() {
+		It("should require 2f+1 for a quorum and f+1 to skip", func() {
+			loop := func() bool {
+				f := r.Intn(10)
+				signatories := make([]id.Signatory, 3*f+1)
+				for i := range signatories {
+					signatories[i] = id.NewPrivKey().Signatory()
+				}
+				powers := process.EqualVotingPowers(signatories)
+				Expect(powers.Total()).To(Equal(uint64(3*f + 1)))
+				Expect(powers.MaxFaulty()).To(Equal(uint64(f)))
+				Expect(powers.Quorum()).To(Equal(uint64(2*f + 1)))
+				Expect(powers.SkipThreshold()).To(Equal(uint64(f + 1)))
+				return true
+			}
+			Expect(quick.Check(loop, nil)).To(Succeed())
+		})
+	})
+
+	Context("when signatories have different voting power", func() {
+		It("should require more than two thirds of the total for a quorum", func() {
+			loop := func() bool {
+				powers := randomVotingPowers(r)
+				total := powers.Total()
+				if total == 0 {
+					return true
+				}
+				quorum := powers.Quorum()
+				Expect(3 * quorum).To(BeNumerically(">", 2*total))
+				Expect(3 * (quorum - 1)).To(BeNumerically("<=", 2*total))
+				return true
+			}
+			Expect(quick.Check(loop, nil)).To(Succeed())
+		})
+
+		It("should require more than the maximum faulty voting power to skip", func() {
+			loop := func() bool {
+				powers := randomVotingPowers(r)
+				total := powers.Total()
+				if total == 0 {
+					return true
+				}
+				Expect(3 * powers.MaxFaulty()).To(BeNumerically("<", total))
+				Expect(powers.SkipThreshold()).To(Equal(powers.MaxFaulty() + 1))
+
+				// any two quorums must intersect in more than the maximum
+				// faulty voting power
+				Expect(2*powers.Quorum() - total).To(BeNumerically(">", powers.MaxFaulty()))
+				return true
+			}
+			Expect(quick.Check(loop, nil)).To(Succeed())
+		})
+	})
+
+	Context("when there is no voting power", func() {
+		It("should never reach a quorum", func() {
+			powers := process.VotingPowers{}
+			Expect(powers.Total()).To(Equal(uint64(0)))
+			Expect(powers.Quorum()).To(BeNumerically(">", 0))
+			Expect(powers.SkipThreshold()).To(BeNumerically(">", 0))
+		})
+	})
+})
@@ -7,7 +7,9 @@
 package process
 
 import (
+	"bytes"
 	"fmt"
+	"sort"
 
 	"github.com/renproject/id"
 	"github.com/renproject/surge"
@@ -44,8 +46,8 @@ type Proposer interface {
 	Propose(Height, Round) Value
 }
 
-// A Broadcaster is used to broadcast Propose, Prevote, and Precommit messages
-// to all Processes in the consensus algorithm, including the Process that
+// A Broadcaster is used to broadcast Propose, Prevote, Precommit, and Timeout
+// messages to all Processes in the consensus algorithm, including the Process that
 // initiated the broadcast. It is assumed that all messages between correct
 // Processes are eventually delivered, although no specific order is assumed.
 //
@@ -53,10 +55,30 @@ type Proposer interface {
 // message, different Values must not be broadcast for that same message type
 // with the same Height and Round. The same restriction applies to valid Rounds
 // broadcast with a Propose message.
+//
+// If the Process has a Signer, then messages are signed before they are given
+// to the Broadcaster. Otherwise, the Broadcaster must sign them (see
+// Propose.Sign, Prevote.Sign, Precommit.Sign, and Timeout.Sign) before sending
+// them to other Processes, otherwise they will be rejected by their Verifiers.
 type Broadcaster interface {
 	BroadcastPropose(Propose)
 	BroadcastPrevote(Prevote)
 	BroadcastPrecommit(Precommit)
+	BroadcastTimeout(Timeout)
+}
+
+// A Signer is used to sign every Propose, Prevote, Precommit, and Timeout
+// message that the Process broadcasts. It returns the signed message, or an
+// error if the message must not be signed. A Signer should refuse to sign
+// messages that conflict with messages it has already signed (for example, a
+// Prevote for a different Value at the same Height and Round), so that the
+// Process cannot equivocate even if it is misconfigured or restarted with a
+// fresh State. The Process does not broadcast messages that it cannot sign.
+type Signer interface {
+	SignPropose(Propose) (Propose, error)
+	SignPrevote(Prevote) (Prevote, error)
+	SignPrecommit(Precommit) (Precommit, error)
+	SignTimeout(Timeout) (Timeout, error)
 }
 
 // A Validator is used to validate a proposed Value. Processes are not required
@@ -65,11 +87,29 @@ type Validator interface {
 	Valid(Height, Round, Value) bool
 }
 
+// A Verifier is used to authenticate Propose, Prevote, Precommit, and Timeout
+// messages before they are passed to a Process. It must return an error if a message was
+// not signed by the Process identified by its From field. Processes do not
+// verify messages themselves; this is the responsibility of the component using
+// the Process.
+type Verifier interface {
+	VerifyPropose(Propose) error
+	VerifyPrevote(Prevote) error
+	VerifyPrecommit(Precommit) error
+	VerifyTimeout(Timeout) error
+}
+
 // A Committer is used to emit Values that are committed. The commitment of a
 // new Value implies that all correct Processes agree on this Value at this
-// Height, and will never revert.
+// Height, and will never revert. The Committer is given a CommitCertificate
+// that contains the Height, Value, and the Precommits that justify the
+// commitment, so that it can keep proof of every decision.
+//
+// The Committer can install a new set of VotingPowers, and a new Scheduler,
+// for the next Height by returning them. If nil is returned, then the current
+// one is kept.
 type Committer interface {
-	Commit(Height, Value) (uint64, Scheduler)
+	Commit(CommitCertificate) (VotingPowers, Scheduler)
 }
 
 // A Catcher is used to catch bad behaviour in other Processes. For example,
@@ -80,11 +120,69 @@ type Committer interface {
 // those dropped messages will not be caught. If it is required that all bad
 // behaviour is caught, then additional checks must be made — outside the
 // context of Hyperdrive — before passing messages to the Process.
+//
+// When the Process has a round window, messages from Rounds that are too far
+// ahead of the current Round are rejected and passed to the Catcher. These
+// messages are not necessarily malicious (the Process might be the one that is
+// lagging behind), but a large number of them from the same signatory is
+// suspicious.
 type Catcher interface {
 	CatchDoublePropose(Propose, Propose)
 	CatchDoublePrevote(Prevote, Prevote)
 	CatchDoublePrecommit(Precommit, Precommit)
 	CatchOutOfTurnPropose(Propose)
+	CatchOutOfWindowPropose(Propose)
+	CatchOutOfWindowPrevote(Prevote)
+	CatchOutOfWindowPrecommit(Precommit)
+	CatchOutOfWindowTimeout(Timeout)
+}
+
+// An Observer is notified about every transition in the State of a Process,
+// and about every message that the Process rejects. It is intended to be used
+// for monitoring and testing, and must not modify the Process. Heights, Rounds,
+// and Values are the ones of the Process at the time of the transition.
+type Observer interface {
+	// OnNewRound is called whenever the Process starts a Round. Starting a
+	// Round also changes the Step to Proposing.
+	OnNewRound(Height, Round)
+	// OnStepChange is called whenever the Step of the Process changes.
+	OnStepChange(Height, Round, Step)
+	// OnLock is called whenever the Process locks a Value.
+	OnLock(Height, Round, Value)
+	// OnValidValue is called whenever the Process updates its valid Value.
+	OnValidValue(Height, Round, Value)
+	// OnCommit is called whenever the Process commits a Value, after the
+	// Committer has been called. When the Process is wrapped by an
+	// ActionProcess, it is called by ActionProcess.Committed, after the Commit
+	// Action has been executed.
+	OnCommit(CommitCertificate)
+	// OnTimeoutScheduled is called whenever the Process schedules a timeout
+	// with its Timer. The MessageType identifies the timeout.
+	OnTimeoutScheduled(Height, Round, MessageType)
+	// OnMessageRejected is called whenever the Process rejects a Propose,
+	// Prevote, Precommit, or Timeout, with the reason for the rejection.
+	OnMessageRejected(interface{}, error)
+}
+
+// A Tracer is notified about every input to a Process, and about the State of
+// the Process after the input has been handled (even if the input was
+// rejected). It is intended to be used to validate executions against a
+// specification of the consensus algorithm (see package trace), and must not
+// modify the Process or the State.
+type Tracer interface {
+	Trace(TraceEvent, State)
+}
+
+// A TraceEvent is an input to a Process. Method is the name of the method of
+// the Process that was called (for example, "Prevote" or "OnTimeoutPropose").
+// Height and Round are the arguments of the timeout methods, and Round is the
+// argument of StartRound. Message is the Propose, Prevote, Precommit, or
+// Timeout that was received, or the CommitCertificate that was synced.
+type TraceEvent struct {
+	Method  string
+	Height  Height
+	Round   Round
+	Message interface{}
 }
 
 // A Process is a deterministic finite state automaton that communicates with
@@ -103,9 +201,29 @@ type Process struct {
 	// whoami represents the identity of this Process. It is assumed that the
 	// ECDSA private key required to prove ownership of this identity is known.
 	whoami id.Signatory
-	// f is the maximum number of malicious adversaries that the Process can
-	// withstand while still maintaining safety and liveliness.
-	f uint64
+	// n is the number of Processes in the committee. The maximum number of
+	// malicious adversaries that the Process can withstand while still
+	// maintaining safety and liveliness is the largest f such that 3f < n. It
+	// is only used when there are no VotingPowers, in which case all
+	// signatories have equal voting power.
+	n uint64
+	// powers is the voting power of each signatory. When it is not nil,
+	// thresholds are measured in voting power instead of being derived from n.
+	powers VotingPowers
+	// deferNextHeight is true when the Process does not start the first Round
+	// of the next Height after committing a Value. It is used by the
+	// ActionProcess, which starts the next Height once the Commit has been
+	// executed.
+	deferNextHeight bool
+	// deferredTrace is the input that caused a commit, when the next Height
+	// has been deferred. It is traced by ActionProcess.Committed, once the
+	// next Height has started, so that the Tracer sees the same State as it
+	// does when the next Height is not deferred.
+	deferredTrace *TraceEvent
+	// roundWindow is the number of Rounds, relative to the current Round, for
+	// which the Process keeps message logs. When it is not positive, the
+	// Process keeps message logs for all Rounds.
+	roundWindow Round
 
 	// Input interface that provide data to the Process.
 	timer     Timer
@@ -114,23 +232,28 @@ type Process struct {
 	validator Validator
 
 	// Output interfaces that received data from the Process.
+	signer      Signer
 	broadcaster Broadcaster
 	committer   Committer
 	catcher     Catcher
+	observer    Observer
+	tracer      Tracer
 
 	// State of the Process.
 	State `json:"state"`
 }
 
 // New returns a new Process that is in the default State with empty message
-// logs.
+// logs. The committee has n Processes with equal voting power, and a quorum is
+// n-f Processes, where f is the largest number such that 3f < n.
 func New(
 	whoami id.Signatory,
-	f int,
+	n int,
 	timer Timer,
 	scheduler Scheduler,
 	proposer Proposer,
 	validator Validator,
+	signer Signer,
 	broadcaster Broadcaster,
 	committer Committer,
 	catcher Catcher,
@@ -138,11 +261,12 @@ func New(
 	return NewWithCurrentHeight(
 		whoami,
 		DefaultHeight,
-		f,
+		n,
 		timer,
 		scheduler,
 		proposer,
 		validator,
+		signer,
 		broadcaster,
 		committer,
 		catcher,
@@ -154,24 +278,26 @@ func New(
 func NewWithCurrentHeight(
 	whoami id.Signatory,
 	height Height,
-	f int,
+	n int,
 	timer Timer,
 	scheduler Scheduler,
 	proposer Proposer,
 	validator Validator,
+	signer Signer,
 	broadcaster Broadcaster,
 	committer Committer,
 	catcher Catcher,
 ) Process {
 	return Process{
 		whoami: whoami,
-		f:      uint64(f),
+		n:      uint64(n),
 
 		timer:     timer,
 		scheduler: scheduler,
 		proposer:  proposer,
 		validator: validator,
 
+		signer:      signer,
 		broadcaster: broadcaster,
 		committer:   committer,
 		catcher:     catcher,
@@ -180,11 +306,88 @@ func NewWithCurrentHeight(
 	}
 }
 
+// NewWithVotingPowers returns a new Process that starts at the given height
+// with empty message logs. Thresholds are measured using the given voting
+// powers, instead of the size of an equal-weight committee.
+func NewWithVotingPowers(
+	whoami id.Signatory,
+	height Height,
+	powers VotingPowers,
+	timer Timer,
+	scheduler Scheduler,
+	proposer Proposer,
+	validator Validator,
+	signer Signer,
+	broadcaster Broadcaster,
+	committer Committer,
+	catcher Catcher,
+) Process {
+	p := NewWithCurrentHeight(
+		whoami,
+		height,
+		0,
+		timer,
+		scheduler,
+		proposer,
+		validator,
+		signer,
+		broadcaster,
+		committer,
+		catcher,
+	)
+	p.powers = powers
+	return p
+}
+
+// WithObserver returns the Process with an Observer that is notified about
+// every transition in its State. By default, there is no Observer.
+func (p Process) WithObserver(observer Observer) Process {
+	p.observer = observer
+	return p
+}
+
+// WithTracer returns the Process with a Tracer that is notified about every
+// input, and the resulting State. By default, there is no Tracer.
+func (p Process) WithTracer(tracer Tracer) Process {
+	p.tracer = tracer
+	return p
+}
+
+// WithRoundWindow returns the Process with a bounded round window. Messages
+// from Rounds more than window Rounds after the current Round are rejected (and
+// passed to the Catcher), and messages from Rounds more than window Rounds
+// before the current Round are dropped. Message logs are pruned whenever the
+// current Round changes. The locked Round and the valid Round are always kept,
+// because they are needed to re-propose and re-vote for locked and valid
+// Values. By default, the round window is not bounded.
+//
+// The window must be large enough to cover the number of Rounds that correct
+// Processes can be apart, otherwise a lagging Process will not be able to skip
+// to the Round of the other Processes.
+func (p Process) WithRoundWindow(window Round) Process {
+	p.roundWindow = window
+	return p
+}
+
+// WithSnapshot returns the Process with the identity, committee, and State of
+// the given snapshot, which is usually a Process that has been unmarshaled. The
+// interfaces, round window, and Observer of the Process are kept, because they
+// are not marshaled. A Process that has been restored from a snapshot must be
+// resumed, instead of started.
+func (p Process) WithSnapshot(snapshot Process) Process {
+	p.whoami = snapshot.whoami
+	p.n = snapshot.n
+	p.powers = snapshot.powers
+	p.State = snapshot.State
+	return p
+}
+
 // SizeHint returns the number of bytes required to represent this Process in
 // binary.
 func (p Process) SizeHint() int {
 	return p.whoami.SizeHint() +
-		surge.SizeHint(p.f) +
+		surge.SizeHint(p.n) +
+		surge.SizeHint(p.powers) +
 		surge.SizeHint(p.State)
 }
 
@@ -194,9 +397,13 @@ func (p Process) Marshal(buf []byte, rem int) ([]byte, int, error) {
 	if err != nil {
 		return buf, rem, fmt.Errorf("marshaling whoami: %v", err)
 	}
-	buf, rem, err = surge.Marshal(p.f, buf, rem)
+	buf, rem, err = surge.Marshal(p.n, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling n: %v", err)
+	}
+	buf, rem, err = surge.Marshal(p.powers, buf, rem)
 	if err != nil {
-		return buf, rem, fmt.Errorf("marshaling f: %v", err)
+		return buf, rem, fmt.Errorf("marshaling voting powers: %v", err)
 	}
 	buf, rem, err = surge.Marshal(p.State, buf, rem)
 	if err != nil {
@@ -211,9 +418,13 @@ func (p *Process) Unmarshal(buf []byte, rem int) ([]byte, int, error) {
 	if err != nil {
 		return buf, rem, fmt.Errorf("unmarshaling whoami: %v", err)
 	}
-	buf, rem, err = surge.Unmarshal(&p.f, buf, rem)
+	buf, rem, err = surge.Unmarshal(&p.n, buf, rem)
 	if err != nil {
-		return buf, rem, fmt.Errorf("unmarshaling f: %v", err)
+		return buf, rem, fmt.Errorf("unmarshaling n: %v", err)
+	}
+	buf, rem, err = surge.Unmarshal(&p.powers, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling voting powers: %v", err)
 	}
 	buf, rem, err = surge.Unmarshal(&p.State, buf, rem)
 	if err != nil {
@@ -222,12 +433,40 @@ func (p *Process) Unmarshal(buf []byte, rem int) ([]byte, int, error) {
 	return buf, rem, nil
 }
 
+// VotingPowers returns the voting power of each signatory, or nil if all
+// signatories have equal voting power.
+func (p Process) VotingPowers() VotingPowers {
+	return p.powers
+}
+
+// Sync the Process with a CommitCertificate for its current Height, which has
+// been received from other Processes (for example, because the Process has
+// fallen behind and missed the Precommits). The Value is committed as if the
+// Process had received the Precommits itself, and the next Height is started.
+// An error is returned if the certificate is not for the current Height.
+//
+// The Process does not know the signatories of its committee, so the
+// certificate must be verified before it is given to the Process (see
+// CommitCertificate.Verify and CommitCertificate.VerifyWithVotingPowers).
+func (p *Process) Sync(cert CommitCertificate) error {
+	defer p.trace(TraceEvent{Method: "Sync", Message: cert})
+	if cert.Height != p.CurrentHeight {
+		return fmt.Errorf("bad height: expected=%v, got=%v", p.CurrentHeight, cert.Height)
+	}
+	p.commit(cert)
+	return nil
+}
+
 // Propose is used to notify the Process that a Propose message has been
 // received (this includes Propose messages that the Process itself has
 // broadcast). All conditions that could be opened by the receipt of a Propose
 // message will be tried.
 func (p *Process) Propose(propose Propose) {
-	if !p.insertPropose(propose) {
+	defer p.trace(TraceEvent{Method: "Propose", Message: propose})
+	if err := p.insertPropose(propose); err != nil {
+		if p.observer != nil {
+			p.observer.OnMessageRejected(propose, err)
+		}
 		return
 	}
 
@@ -243,7 +482,11 @@ func (p *Process) Propose(propose Propose) {
 // broadcast). All conditions that could be opened by the receipt of a Prevote
 // message will be tried.
 func (p *Process) Prevote(prevote Prevote) {
-	if !p.insertPrevote(prevote) {
+	defer p.trace(TraceEvent{Method: "Prevote", Message: prevote})
+	if err := p.insertPrevote(prevote); err != nil {
+		if p.observer != nil {
+			p.observer.OnMessageRejected(prevote, err)
+		}
 		return
 	}
 
@@ -259,7 +502,11 @@ func (p *Process) Prevote(prevote Prevote) {
 // broadcast). All conditions that could be opened by the receipt of a Precommit
 // message will be tried.
 func (p *Process) Precommit(precommit Precommit) {
-	if !p.insertPrecommit(precommit) {
+	defer p.trace(TraceEvent{Method: "Precommit", Message: precommit})
+	if err := p.insertPrecommit(precommit); err != nil {
+		if p.observer != nil {
+			p.observer.OnMessageRejected(precommit, err)
+		}
 		return
 	}
 
@@ -268,6 +515,47 @@ func (p *Process) Precommit(precommit Precommit) {
 	p.tryTimeoutPrecommitUponSufficientPrecommits()
 }
 
+// Timeout is used to notify the Process that a Timeout message has been
+// received (this includes Timeout messages that the Process itself has
+// broadcast). All conditions that could be opened by the receipt of a Timeout
+// message will be tried.
+func (p *Process) Timeout(timeout Timeout) {
+	defer p.trace(TraceEvent{Method: "Timeout", Message: timeout})
+	if err := p.insertTimeout(timeout); err != nil {
+		if p.observer != nil {
+			p.observer.OnMessageRejected(timeout, err)
+		}
+		return
+	}
+
+	p.trySkipToFutureRound(timeout.Round)
+	p.tryStartRoundUponSufficientTimeouts(timeout.Round)
+}
+
+// TimeoutCertificate returns a TimeoutCertificate for the given Round at the
+// current Height, containing the Timeouts that have been received for that
+// Round. If the Timeouts are not from a quorum, then no certificate is
+// returned. The Timeouts are sorted by signatory.
+func (p *Process) TimeoutCertificate(round Round) (TimeoutCertificate, bool) {
+	timeouts := make([]Timeout, 0, len(p.TimeoutLogs[round]))
+	power := uint64(0)
+	for _, timeout := range p.TimeoutLogs[round] {
+		timeouts = append(timeouts, timeout)
+		power += p.votingPower(timeout.From)
+	}
+	if power < p.quorum() {
+		return TimeoutCertificate{}, false
+	}
+	sort.Slice(timeouts, func(i, j int) bool {
+		return bytes.Compare(timeouts[i].From[:], timeouts[j].From[:]) < 0
+	})
+	return TimeoutCertificate{
+		Height:   p.CurrentHeight,
+		Round:    round,
+		Timeouts: timeouts,
+	}, true
+}
+
 // Start the Process.
 //
 // L10:
@@ -275,13 +563,56 @@ func (p *Process) Precommit(precommit Precommit) {
 //		StartRound(0)
 //
 func (p *Process) Start() {
-	p.StartRound(0)
+	defer p.trace(TraceEvent{Method: "Start"})
+	p.startRound(0)
 }
 
-func (p *Process) StartWithNewSignatories(f uint64, scheduler Scheduler) {
-	p.f = f
+// Resume a Process that has been restored from a snapshot. Unlike Start, it
+// does not change the current Round or Step, so the Process does not vote again
+// in a Round in which it has already voted. Timeouts that were scheduled before
+// the snapshot was taken are lost, so the timeout for the current Step is
+// scheduled again.
+func (p *Process) Resume() {
+	defer p.trace(TraceEvent{Method: "Resume"})
+	if p.timer == nil {
+		return
+	}
+	var messageType MessageType
+	switch p.CurrentStep {
+	case Proposing:
+		messageType = MessageTypePropose
+		p.timer.TimeoutPropose(p.CurrentHeight, p.CurrentRound)
+	case Prevoting:
+		messageType = MessageTypePrevote
+		p.timer.TimeoutPrevote(p.CurrentHeight, p.CurrentRound)
+	case Precommitting:
+		messageType = MessageTypePrecommit
+		p.timer.TimeoutPrecommit(p.CurrentHeight, p.CurrentRound)
+	default:
+		return
+	}
+	if p.observer != nil {
+		p.observer.OnTimeoutScheduled(p.CurrentHeight, p.CurrentRound, messageType)
+	}
+}
+
+// StartWithNewSignatories starts the Process with a new committee of n
+// signatories that all have equal voting power, and a new Scheduler.
+func (p *Process) StartWithNewSignatories(n uint64, scheduler Scheduler) {
+	defer p.trace(TraceEvent{Method: "StartWithNewSignatories"})
+	p.n = n
+	p.powers = nil
 	p.scheduler = scheduler
-	p.StartRound(0)
+	p.startRound(0)
+}
+
+// StartWithNewVotingPowers starts the Process with a new set of weighted
+// signatories, and a new Scheduler.
+func (p *Process) StartWithNewVotingPowers(powers VotingPowers, scheduler Scheduler) {
+	defer p.trace(TraceEvent{Method: "StartWithNewVotingPowers"})
+	p.powers = powers
+	p.scheduler = scheduler
+	p.startRound(0)
 }
 
 // StartRound will progress the Process to a new Round. It does not assume that
@@ -302,6 +633,13 @@ func (p *Process) StartWithNewSignatories(f uint64, scheduler Scheduler) {
 //		else
 //			schedule OnTimeoutPropose(currentHeight, currentRound) to be executed after timeoutPropose(currentRound)
 func (p *Process) StartRound(round Round) {
+	defer p.trace(TraceEvent{Method: "StartRound", Round: round})
+	p.startRound(round)
+}
+
+// startRound implements StartRound, without notifying the Tracer, so that it
+// can be called when handling other inputs.
+func (p *Process) startRound(round Round) {
 	defer func() {
 		p.tryPrecommitUponSufficientPrevotes()
 		p.tryPrecommitNilUponSufficientPrevotes()
@@ -318,6 +656,15 @@ func (p *Process) StartRound(round Round) {
 	p.CurrentRound = round
 	p.CurrentStep = Proposing
 
+	// Messages from Rounds that are now below the round window can no longer
+	// be inserted, so their logs can be dropped.
+	p.pruneRoundLogs()
+
+	if p.observer != nil {
+		p.observer.OnNewRound(p.CurrentHeight, p.CurrentRound)
+		p.observer.OnStepChange(p.CurrentHeight, p.CurrentRound, p.CurrentStep)
+	}
+
 	// If we are not the proposer, then we trigger the propose timeout.
 	// We proceed only if we have a scheduler impl, because if not, we never
 	// know who the scheduled proposer is.
@@ -326,6 +673,9 @@ func (p *Process) StartRound(round Round) {
 		if !p.whoami.Equal(&proposer) {
 			if p.timer != nil {
 				p.timer.TimeoutPropose(p.CurrentHeight, p.CurrentRound)
+				if p.observer != nil {
+					p.observer.OnTimeoutScheduled(p.CurrentHeight, p.CurrentRound, MessageTypePropose)
+				}
 			}
 			return
 		}
@@ -338,7 +688,7 @@ func (p *Process) StartRound(round Round) {
 			}
 		}
 		if p.broadcaster != nil {
-			p.broadcaster.BroadcastPropose(Propose{
+			p.broadcastPropose(Propose{
 				Height:     p.CurrentHeight,
 				Round:      p.CurrentRound,
 				ValidRound: p.ValidRound,
@@ -359,9 +709,10 @@ func (p *Process) StartRound(round Round) {
 //			broadcast〈PREVOTE, currentHeight, currentRound, nil
 //			currentStep ← prevote
 func (p *Process) OnTimeoutPropose(height Height, round Round) {
+	defer p.trace(TraceEvent{Method: "OnTimeoutPropose", Height: height, Round: round})
 	if height == p.CurrentHeight && round == p.CurrentRound && p.CurrentStep == Proposing {
 		if p.broadcaster != nil {
-			p.broadcaster.BroadcastPrevote(Prevote{
+			p.broadcastPrevote(Prevote{
 				Height: p.CurrentHeight,
 				Round:  p.CurrentRound,
 				Value:  NilValue,
@@ -382,9 +733,10 @@ func (p *Process) OnTimeoutPropose(height Height, round Round) {
 //			broadcast〈PRECOMMIT, currentHeight, currentRound, nil
 //			currentStep ← precommitting
 func (p *Process) OnTimeoutPrevote(height Height, round Round) {
+	defer p.trace(TraceEvent{Method: "OnTimeoutPrevote", Height: height, Round: round})
 	if height == p.CurrentHeight && round == p.CurrentRound && p.CurrentStep == Prevoting {
 		if p.broadcaster != nil {
-			p.broadcaster.BroadcastPrecommit(Precommit{
+			p.broadcastPrecommit(Precommit{
 				Height: p.CurrentHeight,
 				Round:  p.CurrentRound,
 				Value:  NilValue,
@@ -403,9 +755,21 @@ func (p *Process) OnTimeoutPrevote(height Height, round Round) {
 //	Function OnTimeoutPrecommit(height, round)
 //		if height = currentHeight ∧ round = currentRound then
 //			StartRound(currentRound + 1)
+//
+// Before starting the next Round, the Process broadcasts a Timeout for the
+// current Round, so that Processes that are still in the current Round (or in
+// earlier Rounds) can follow it once a quorum has timed out.
 func (p *Process) OnTimeoutPrecommit(height Height, round Round) {
+	defer p.trace(TraceEvent{Method: "OnTimeoutPrecommit", Height: height, Round: round})
 	if height == p.CurrentHeight && round == p.CurrentRound {
-		p.StartRound(round + 1)
+		if p.broadcaster != nil {
+			p.broadcastTimeout(Timeout{
+				Height: p.CurrentHeight,
+				Round:  p.CurrentRound,
+				From:   p.whoami,
+			})
+		}
+		p.startRound(round + 1)
 	}
 }
 
@@ -437,14 +801,14 @@ func (p *Process) tryPrevoteUponPropose() {
 
 	if p.broadcaster != nil {
 		if (p.LockedRound == InvalidRound || p.LockedValue.Equal(&propose.Value)) && proposeIsValid {
-			p.broadcaster.BroadcastPrevote(Prevote{
+			p.broadcastPrevote(Prevote{
 				Height: p.CurrentHeight,
 				Round:  p.CurrentRound,
 				Value:  propose.Value,
 				From:   p.whoami,
 			})
 		} else {
-			p.broadcaster.BroadcastPrevote(Prevote{
+			p.broadcastPrevote(Prevote{
 				Height: p.CurrentHeight,
 				Round:  p.CurrentRound,
 				Value:  NilValue,
@@ -483,26 +847,26 @@ func (p *Process) tryPrevoteUponSufficientPrevotes() {
 	}
 	proposeIsValid, _ := p.ProposeIsValid[p.CurrentRound]
 
-	prevotesInValidRound := 0
+	prevotesInValidRound := uint64(0)
 	for _, prevote := range p.PrevoteLogs[propose.ValidRound] {
 		if prevote.Value.Equal(&propose.Value) {
-			prevotesInValidRound++
+			prevotesInValidRound += p.votingPower(prevote.From)
 		}
 	}
-	if prevotesInValidRound < int(2*p.f+1) {
+	if prevotesInValidRound < p.quorum() {
 		return
 	}
 
 	if p.broadcaster != nil {
 		if (p.LockedRound <= propose.ValidRound || p.LockedValue.Equal(&propose.Value)) && proposeIsValid {
-			p.broadcaster.BroadcastPrevote(Prevote{
+			p.broadcastPrevote(Prevote{
 				Height: p.CurrentHeight,
 				Round:  p.CurrentRound,
 				Value:  propose.Value,
 				From:   p.whoami,
 			})
 		} else {
-			p.broadcaster.BroadcastPrevote(Prevote{
+			p.broadcastPrevote(Prevote{
 				Height: p.CurrentHeight,
 				Round:  p.CurrentRound,
 				Value:  NilValue,
@@ -531,10 +895,17 @@ func (p *Process) tryTimeoutPrevoteUponSufficientPrevotes() {
 	if p.CurrentStep != Prevoting {
 		return
 	}
-	if len(p.PrevoteLogs[p.CurrentRound]) >= int(2*p.f+1) {
+	prevotes := uint64(0)
+	for signatory := range p.PrevoteLogs[p.CurrentRound] {
+		prevotes += p.votingPower(signatory)
+	}
+	if prevotes >= p.quorum() {
 		if p.timer != nil {
 			p.timer.TimeoutPrevote(p.CurrentHeight, p.CurrentRound)
 			p.setOnceFlag(p.CurrentRound, OnceFlagTimeoutPrevoteUponSufficientPrevotes)
+			if p.observer != nil {
+				p.observer.OnTimeoutScheduled(p.CurrentHeight, p.CurrentRound, MessageTypePrevote)
+			}
 		}
 	}
 }
@@ -571,21 +942,24 @@ func (p *Process) tryPrecommitUponSufficientPrevotes() {
 	if !proposeIsValid {
 		return
 	}
-	prevotesForValue := 0
+	prevotesForValue := uint64(0)
 	for _, prevote := range p.PrevoteLogs[p.CurrentRound] {
 		if prevote.Value.Equal(&propose.Value) {
-			prevotesForValue++
+			prevotesForValue += p.votingPower(prevote.From)
 		}
 	}
-	if prevotesForValue < int(2*p.f+1) {
+	if prevotesForValue < p.quorum() {
 		return
 	}
 
 	if p.CurrentStep == Prevoting {
 		p.LockedValue = propose.Value
 		p.LockedRound = p.CurrentRound
+		if p.observer != nil {
+			p.observer.OnLock(p.CurrentHeight, p.CurrentRound, p.LockedValue)
+		}
 		if p.broadcaster != nil {
-			p.broadcaster.BroadcastPrecommit(Precommit{
+			p.broadcastPrecommit(Precommit{
 				Height: p.CurrentHeight,
 				Round:  p.CurrentRound,
 				Value:  propose.Value,
@@ -607,6 +981,9 @@ func (p *Process) tryPrecommitUponSufficientPrevotes() {
 	}
 	p.ValidValue = propose.Value
 	p.ValidRound = p.CurrentRound
+	if p.observer != nil {
+		p.observer.OnValidValue(p.CurrentHeight, p.CurrentRound, p.ValidValue)
+	}
 	p.setOnceFlag(p.CurrentRound, OnceFlagPrecommitUponSufficientPrevotes)
 }
 
@@ -623,15 +1000,15 @@ func (p *Process) tryPrecommitNilUponSufficientPrevotes() {
 	if p.CurrentStep != Prevoting {
 		return
 	}
-	prevotesForNil := 0
+	prevotesForNil := uint64(0)
 	for _, prevote := range p.PrevoteLogs[p.CurrentRound] {
 		if prevote.Value.Equal(&NilValue) {
-			prevotesForNil++
+			prevotesForNil += p.votingPower(prevote.From)
 		}
 	}
-	if prevotesForNil >= int(2*p.f+1) {
+	if prevotesForNil >= p.quorum() {
 		if p.broadcaster != nil {
-			p.broadcaster.BroadcastPrecommit(Precommit{
+			p.broadcastPrecommit(Precommit{
 				Height: p.CurrentHeight,
 				Round:  p.CurrentRound,
 				Value:  NilValue,
@@ -655,10 +1032,17 @@ func (p *Process) tryTimeoutPrecommitUponSufficientPrecommits() {
 	if p.checkOnceFlag(p.CurrentRound, OnceFlagTimeoutPrecommitUponSufficientPrecommits) {
 		return
 	}
-	if len(p.PrecommitLogs[p.CurrentRound]) == int(2*p.f+1) {
+	precommits := uint64(0)
+	for signatory := range p.PrecommitLogs[p.CurrentRound] {
+		precommits += p.votingPower(signatory)
+	}
+	if precommits >= p.quorum() {
 		if p.timer != nil {
 			p.timer.TimeoutPrecommit(p.CurrentHeight, p.CurrentRound)
 			p.setOnceFlag(p.CurrentRound, OnceFlagTimeoutPrecommitUponSufficientPrecommits)
+			if p.observer != nil {
+				p.observer.OnTimeoutScheduled(p.CurrentHeight, p.CurrentRound, MessageTypePrecommit)
+			}
 		}
 	}
 }
@@ -693,40 +1077,70 @@ func (p *Process) tryCommitUponSufficientPrecommits(round Round) {
 		return
 	}
 
-	precommitsForValue := 0
+	precommitsForValue := make([]Precommit, 0, len(p.PrecommitLogs[round]))
+	votingPowerForValue := uint64(0)
 	for _, precommit := range p.PrecommitLogs[round] {
 		if precommit.Value.Equal(&propose.Value) {
-			precommitsForValue++
+			precommitsForValue = append(precommitsForValue, precommit)
+			votingPowerForValue += p.votingPower(precommit.From)
 		}
 	}
-	if precommitsForValue >= int(2*p.f+1) {
-		f, scheduler := p.committer.Commit(p.CurrentHeight, propose.Value)
-		if f != 0 {
-			p.f = f
-		}
-		if scheduler != nil {
-			p.scheduler = scheduler
-		}
-		p.CurrentHeight++
-
-		// Reset lockedRound, lockedValue, validRound, and validValue to initial
-		// values.
-		p.LockedValue = NilValue
-		p.LockedRound = InvalidRound
-		p.ValidValue = NilValue
-		p.ValidRound = InvalidRound
-
-		// Empty message logs in preparation for the new Height.
-		p.ProposeLogs = map[Round]Propose{}
-		p.ProposeIsValid = map[Round]bool{}
-		p.PrevoteLogs = map[Round]map[id.Signatory]Prevote{}
-		p.PrecommitLogs = map[Round]map[id.Signatory]Precommit{}
-		p.OnceFlags = map[Round]OnceFlag{}
-		p.TraceLogs = map[Round]map[id.Signatory]bool{}
+	if votingPowerForValue >= p.quorum() {
+		// Sort the Precommits by signatory, so that all correct Processes
+		// produce the same certificate from the same set of Precommits.
+		sort.Slice(precommitsForValue, func(i, j int) bool {
+			return bytes.Compare(precommitsForValue[i].From[:], precommitsForValue[j].From[:]) < 0
+		})
+		p.commit(CommitCertificate{
+			Height:     p.CurrentHeight,
+			Round:      round,
+			Value:      propose.Value,
+			Precommits: precommitsForValue,
+		})
+	}
+}
 
-		// Start from the first Round in the new Height.
-		p.StartRound(0)
+// commit the Value of the CommitCertificate at the current Height, and start
+// the next Height.
+func (p *Process) commit(cert CommitCertificate) {
+	powers, scheduler := p.committer.Commit(cert)
+	if p.observer != nil && !p.deferNextHeight {
+		p.observer.OnCommit(cert)
 	}
+	if powers != nil {
+		p.powers = powers
+	}
+	if scheduler != nil {
+		p.scheduler = scheduler
+	}
+	p.CurrentHeight++
+
+	// Reset lockedRound, lockedValue, validRound, and validValue to initial
+	// values.
+	p.LockedValue = NilValue
+	p.LockedRound = InvalidRound
+	p.ValidValue = NilValue
+	p.ValidRound = InvalidRound
+
+	// Empty message logs in preparation for the new Height.
+	p.ProposeLogs = map[Round]Propose{}
+	p.ProposeIsValid = map[Round]bool{}
+	p.PrevoteLogs = map[Round]map[id.Signatory]Prevote{}
+	p.PrecommitLogs = map[Round]map[id.Signatory]Precommit{}
+	p.TimeoutLogs = map[Round]map[id.Signatory]Timeout{}
+	p.OnceFlags = map[Round]OnceFlag{}
+	p.TraceLogs = map[Round]map[id.Signatory]bool{}
+
+	// Start from the first Round in the new Height. When the Process returns
+	// Actions, the Commit has not been executed yet, so the new Height is
+	// started by ActionProcess.Committed instead.
+	if p.deferNextHeight {
+		if p.tracer != nil {
+			p.deferredTrace = &TraceEvent{}
+		}
+		return
+	}
+	p.startRound(0)
 }
 
 // L55:
@@ -735,33 +1149,72 @@ func (p *Process) tryCommitUponSufficientPrecommits(round Round) {
 //      StartRound(r)
 //
 // This method must be tried whenever a Propose is received, a Prevote is
-// received, or a Precommit is received. Because this method checks whichever
-// Round is relevant (i.e. the Round of the Propose/Prevote/Precommit), and an
-// increase in the current Round can only cause this condition to be closed, it
-// does not need to be tried whenever the current Round changes. The f+1
+// received, a Precommit is received, or a Timeout is received. Because this
+// method checks whichever Round is relevant (i.e. the Round of the
+// Propose/Prevote/Precommit/Timeout), and an increase in the current Round can
+// only cause this condition to be closed, it does not need to be tried whenever
+// the current Round changes. The f+1
 // messages (one propose and f prevotes/precommits) must be from f+1 unique
-// signatories.
+// signatories. When using VotingPowers, the unique signatories must have more
+// voting power than can be held by malicious adversaries.
 func (p *Process) trySkipToFutureRound(round Round) {
 	if round <= p.CurrentRound {
 		return
 	}
 
-	// count of unique signatories that we have received any message from in the
-	// given round and at the current height
-	if len(p.TraceLogs[round]) >= int(p.f+1) {
-		p.StartRound(round)
+	// voting power of unique signatories that we have received any message
+	// from in the given round and at the current height
+	power := uint64(0)
+	for signatory := range p.TraceLogs[round] {
+		power += p.votingPower(signatory)
+	}
+	if power >= p.skipThreshold() {
+		p.startRound(round)
+	}
+}
+
+// upon 2f+1〈TIMEOUT, currentHeight, r〉with r ≥ currentRound do
+//     StartRound(r + 1)
+//
+// This method must be tried whenever a Timeout is received. Because this method
+// checks whichever Round is relevant (i.e. the Round of the Timeout), and an
+// increase in the current Round can only cause this condition to be closed, it
+// does not need to be tried whenever the current Round changes. It is not part
+// of the original algorithm, but it is safe: a Process is always allowed to
+// move to a future Round, and it cannot move to the same Round twice.
+func (p *Process) tryStartRoundUponSufficientTimeouts(round Round) {
+	if round < p.CurrentRound {
+		return
+	}
+
+	power := uint64(0)
+	for signatory := range p.TimeoutLogs[round] {
+		power += p.votingPower(signatory)
+	}
+	if power >= p.quorum() {
+		p.startRound(round + 1)
 	}
 }
 
 // insertPropose after validating it and checking for duplicates. If the Propose
-// was accepted and inserted, then it return true, otherwise it returns false.
-func (p *Process) insertPropose(propose Propose) bool {
+// was accepted and inserted, then it returns nil, otherwise it returns an error
+// that describes why the Propose was rejected.
+func (p *Process) insertPropose(propose Propose) error {
 	if propose.Height != p.CurrentHeight {
-		return false
+		return fmt.Errorf("unexpected height=%v: expected %v", propose.Height, p.CurrentHeight)
 	}
 
 	if propose.Round <= InvalidRound {
-		return false
+		return fmt.Errorf("invalid round=%v", propose.Round)
+	}
+	if p.isBelowRoundWindow(propose.Round) {
+		return fmt.Errorf("round=%v below round window", propose.Round)
+	}
+	if p.isAboveRoundWindow(propose.Round) {
+		if p.catcher != nil {
+			p.catcher.CatchOutOfWindowPropose(propose)
+		}
+		return fmt.Errorf("round=%v above round window", propose.Round)
 	}
 
 	// It is important to check the schedule (here), before checking for
@@ -776,7 +1229,7 @@ func (p *Process) insertPropose(propose Propose) bool {
 			if p.catcher != nil {
 				p.catcher.CatchOutOfTurnPropose(propose)
 			}
-			return false
+			return fmt.Errorf("out of turn: expected proposer=%v", proposer)
 		}
 	}
 
@@ -791,19 +1244,19 @@ func (p *Process) insertPropose(propose Propose) bool {
 				p.catcher.CatchDoublePropose(propose, existingPropose)
 			}
 		}
-		return false
+		return fmt.Errorf("duplicate propose")
 	}
 
 	// We discard a nil value proposal. If a validator implementation is provided
 	// we check and store the proposal's validity. In the case of an invalid
 	// proposal, we broadcast a nil prevote, and avoid adding this message to the
-	// trace logs as it is an invalid proposal. We return true as we have in fact
+	// trace logs as it is an invalid proposal. We return nil as we have in fact
 	// inserted the propose message to our propose logs, while explicitly marking
 	// it as invalid.
 	if propose.Value == NilValue || (p.validator != nil && !p.validator.Valid(propose.Height, propose.Round, propose.Value)) {
 		p.ProposeLogs[propose.Round] = propose
 		p.ProposeIsValid[propose.Round] = false
-		return true
+		return nil
 	}
 
 	// If we're here, it means that the proposal is valid. We add the proposer to
@@ -815,14 +1268,24 @@ func (p *Process) insertPropose(propose Propose) bool {
 	}
 	p.TraceLogs[propose.Round][propose.From] = true
 
-	return true
+	return nil
 }
 
 // insertPrevote after validating it and checking for duplicates. If the Prevote
-// was accepted and inserted, then it return true, otherwise it returns false.
-func (p *Process) insertPrevote(prevote Prevote) bool {
+// was accepted and inserted, then it returns nil, otherwise it returns an error
+// that describes why the Prevote was rejected.
+func (p *Process) insertPrevote(prevote Prevote) error {
 	if prevote.Height != p.CurrentHeight {
-		return false
+		return fmt.Errorf("unexpected height=%v: expected %v", prevote.Height, p.CurrentHeight)
+	}
+	if p.isPrevoteBelowRoundWindow(prevote.Round) {
+		return fmt.Errorf("round=%v below round window", prevote.Round)
+	}
+	if p.isAboveRoundWindow(prevote.Round) {
+		if p.catcher != nil {
+			p.catcher.CatchOutOfWindowPrevote(prevote)
+		}
+		return fmt.Errorf("round=%v above round window", prevote.Round)
 	}
 	if _, ok := p.PrevoteLogs[prevote.Round]; !ok {
 		p.PrevoteLogs[prevote.Round] = map[id.Signatory]Prevote{}
@@ -840,7 +1303,7 @@ func (p *Process) insertPrevote(prevote Prevote) bool {
 				p.catcher.CatchDoublePrevote(prevote, existingPrevote)
 			}
 		}
-		return false
+		return fmt.Errorf("duplicate prevote")
 	}
 
 	p.PrevoteLogs[prevote.Round][prevote.From] = prevote
@@ -851,15 +1314,24 @@ func (p *Process) insertPrevote(prevote Prevote) bool {
 	}
 	p.TraceLogs[prevote.Round][prevote.From] = true
 
-	return true
+	return nil
 }
 
 // insertPrecommit after validating it and checking for duplicates. If the
-// Precommit was accepted and inserted, then it return true, otherwise it
-// returns false.
-func (p *Process) insertPrecommit(precommit Precommit) bool {
+// Precommit was accepted and inserted, then it returns nil, otherwise it
+// returns an error that describes why the Precommit was rejected.
+func (p *Process) insertPrecommit(precommit Precommit) error {
 	if precommit.Height != p.CurrentHeight {
-		return false
+		return fmt.Errorf("unexpected height=%v: expected %v", precommit.Height, p.CurrentHeight)
+	}
+	if p.isBelowRoundWindow(precommit.Round) {
+		return fmt.Errorf("round=%v below round window", precommit.Round)
+	}
+	if p.isAboveRoundWindow(precommit.Round) {
+		if p.catcher != nil {
+			p.catcher.CatchOutOfWindowPrecommit(precommit)
+		}
+		return fmt.Errorf("round=%v above round window", precommit.Round)
 	}
 	if _, ok := p.PrecommitLogs[precommit.Round]; !ok {
 		p.PrecommitLogs[precommit.Round] = map[id.Signatory]Precommit{}
@@ -877,7 +1349,7 @@ func (p *Process) insertPrecommit(precommit Precommit) bool {
 				p.catcher.CatchDoublePrecommit(precommit, existingPrecommit)
 			}
 		}
-		return false
+		return fmt.Errorf("duplicate precommit")
 	}
 
 	p.PrecommitLogs[precommit.Round][precommit.From] = precommit
@@ -888,13 +1360,125 @@ func (p *Process) insertPrecommit(precommit Precommit) bool {
 	}
 	p.TraceLogs[precommit.Round][precommit.From] = true
 
+	return nil
+}
+
+// insertTimeout after validating it and checking for duplicates. If the
+// Timeout was accepted and inserted, then it returns nil, otherwise it returns
+// an error that describes why the Timeout was rejected. Timeouts do not have
+// Values, so duplicate Timeouts never conflict.
+func (p *Process) insertTimeout(timeout Timeout) error {
+	if timeout.Height != p.CurrentHeight {
+		return fmt.Errorf("unexpected height=%v: expected %v", timeout.Height, p.CurrentHeight)
+	}
+	if timeout.Round <= InvalidRound {
+		return fmt.Errorf("invalid round=%v", timeout.Round)
+	}
+	if p.isBelowRoundWindow(timeout.Round) {
+		return fmt.Errorf("round=%v below round window", timeout.Round)
+	}
+	if p.isAboveRoundWindow(timeout.Round) {
+		if p.catcher != nil {
+			p.catcher.CatchOutOfWindowTimeout(timeout)
+		}
+		return fmt.Errorf("round=%v above round window", timeout.Round)
+	}
+	if _, ok := p.TimeoutLogs[timeout.Round]; !ok {
+		p.TimeoutLogs[timeout.Round] = map[id.Signatory]Timeout{}
+	}
+	if _, ok := p.TimeoutLogs[timeout.Round][timeout.From]; ok {
+		return fmt.Errorf("duplicate timeout")
+	}
+
+	p.TimeoutLogs[timeout.Round][timeout.From] = timeout
+
+	// add the sender to the appropriate round's trace logs
+	if _, ok := p.TraceLogs[timeout.Round]; !ok {
+		p.TraceLogs[timeout.Round] = map[id.Signatory]bool{}
+	}
+	p.TraceLogs[timeout.Round][timeout.From] = true
+
+	return nil
+}
+
+// isAboveRoundWindow returns true if the Round is more than the round window
+// after the current Round. It always returns false when the round window is not
+// bounded.
+func (p *Process) isAboveRoundWindow(round Round) bool {
+	return p.roundWindow > 0 && round > p.CurrentRound && round-p.CurrentRound > p.roundWindow
+}
+
+// isBelowRoundWindow returns true if the Round is more than the round window
+// before the current Round, and it is neither the locked Round nor the valid
+// Round. It always returns false when the round window is not bounded.
+func (p *Process) isBelowRoundWindow(round Round) bool {
+	return p.roundWindow > 0 && round < p.CurrentRound-p.roundWindow && round != p.LockedRound && round != p.ValidRound
+}
+
+// isPrevoteBelowRoundWindow returns true if the Round is below the round
+// window, and it is not the valid Round of any Propose in the logs. Prevotes
+// from the valid Round of a Propose are needed to prevote for its Value (see
+// tryPrevoteUponSufficientPrevotes), so they are kept no matter how old the
+// valid Round is.
+func (p *Process) isPrevoteBelowRoundWindow(round Round) bool {
+	if !p.isBelowRoundWindow(round) {
+		return false
+	}
+	for _, propose := range p.ProposeLogs {
+		if propose.ValidRound == round {
+			return false
+		}
+	}
 	return true
 }
 
+// pruneRoundLogs removes the message logs, and once flags, of all Rounds that
+// are below the round window, except the Prevotes from the valid Rounds of the
+// Proposes that are kept.
+func (p *Process) pruneRoundLogs() {
+	if p.roundWindow <= 0 {
+		return
+	}
+	for round := range p.ProposeLogs {
+		if p.isBelowRoundWindow(round) {
+			delete(p.ProposeLogs, round)
+			delete(p.ProposeIsValid, round)
+		}
+	}
+	for round := range p.PrevoteLogs {
+		if p.isPrevoteBelowRoundWindow(round) {
+			delete(p.PrevoteLogs, round)
+		}
+	}
+	for round := range p.PrecommitLogs {
+		if p.isBelowRoundWindow(round) {
+			delete(p.PrecommitLogs, round)
+		}
+	}
+	for round := range p.TimeoutLogs {
+		if p.isBelowRoundWindow(round) {
+			delete(p.TimeoutLogs, round)
+		}
+	}
+	for round := range p.OnceFlags {
+		if p.isBelowRoundWindow(round) {
+			delete(p.OnceFlags, round)
+		}
+	}
+	for round := range p.TraceLogs {
+		if p.isBelowRoundWindow(round) {
+			delete(p.TraceLogs, round)
+		}
+	}
+}
+
 // stepToPrevoting puts the Process into the Prevoting Step. This will also try
 // other methods that might now have passing conditions.
 func (p *Process) stepToPrevoting() {
 	p.CurrentStep = Prevoting
+	if p.observer != nil {
+		p.observer.OnStepChange(p.CurrentHeight, p.CurrentRound, p.CurrentStep)
+	}
 
 	// Because the current Step of the Process has changed, new conditions might
 	// be open, so we try the relevant ones. Once flags protect us against
@@ -908,6 +1492,9 @@ func (p *Process) stepToPrevoting() {
 // also try other methods that might now have passing conditions.
 func (p *Process) stepToPrecommitting() {
 	p.CurrentStep = Precommitting
+	if p.observer != nil {
+		p.observer.OnStepChange(p.CurrentHeight, p.CurrentRound, p.CurrentStep)
+	}
 
 	// Because the current Step of the Process has changed, new conditions might
 	// be open, so we try the relevant ones. Once flags protect us against
@@ -915,6 +1502,97 @@ func (p *Process) stepToPrecommitting() {
 	p.tryPrecommitUponSufficientPrevotes()
 }
 
+// broadcastPropose signs the Propose, if there is a Signer, and then broadcasts
+// it. The Propose is dropped if it cannot be signed.
+func (p *Process) broadcastPropose(propose Propose) {
+	if p.signer != nil {
+		var err error
+		if propose, err = p.signer.SignPropose(propose); err != nil {
+			return
+		}
+	}
+	p.broadcaster.BroadcastPropose(propose)
+}
+
+// broadcastPrevote signs the Prevote, if there is a Signer, and then broadcasts
+// it. The Prevote is dropped if it cannot be signed.
+func (p *Process) broadcastPrevote(prevote Prevote) {
+	if p.signer != nil {
+		var err error
+		if prevote, err = p.signer.SignPrevote(prevote); err != nil {
+			return
+		}
+	}
+	p.broadcaster.BroadcastPrevote(prevote)
+}
+
+// broadcastPrecommit signs the Precommit, if there is a Signer, and then
+// broadcasts it. The Precommit is dropped if it cannot be signed.
+func (p *Process) broadcastPrecommit(precommit Precommit) {
+	if p.signer != nil {
+		var err error
+		if precommit, err = p.signer.SignPrecommit(precommit); err != nil {
+			return
+		}
+	}
+	p.broadcaster.BroadcastPrecommit(precommit)
+}
+
+// broadcastTimeout signs the Timeout, if there is a Signer, and then broadcasts
+// it. The Timeout is dropped if it cannot be signed.
+func (p *Process) broadcastTimeout(timeout Timeout) {
+	if p.signer != nil {
+		var err error
+		if timeout, err = p.signer.SignTimeout(timeout); err != nil {
+			return
+		}
+	}
+	p.broadcaster.BroadcastTimeout(timeout)
+}
+
+// trace notifies the Tracer, if there is one, about an input and the current
+// State. It is deferred by the methods that handle inputs, so that the Tracer
+// sees the State after the input has been handled. If the input caused a
+// commit, and the next Height has been deferred, then the input is traced by
+// ActionProcess.Committed instead.
+func (p *Process) trace(event TraceEvent) {
+	if p.tracer == nil {
+		return
+	}
+	if p.deferredTrace != nil {
+		*p.deferredTrace = event
+		return
+	}
+	p.tracer.Trace(event, p.State)
+}
+
+// votingPower returns the voting power of a signatory. Without VotingPowers,
+// every signatory has a voting power of one.
+func (p *Process) votingPower(signatory id.Signatory) uint64 {
+	if p.powers == nil {
+		return 1
+	}
+	return p.powers[signatory]
+}
+
+// quorum returns the voting power required for a quorum (n-f without
+// VotingPowers).
+func (p *Process) quorum() uint64 {
+	if p.powers == nil {
+		return quorum(p.n)
+	}
+	return p.powers.Quorum()
+}
+
+// skipThreshold returns the voting power required to skip to a future Round
+// (f+1 without VotingPowers).
+func (p *Process) skipThreshold() uint64 {
+	if p.powers == nil {
+		return skipThreshold(p.n)
+	}
+	return p.powers.SkipThreshold()
+}
+
 // checkOnceFlag returns true if the OnceFlag has already been set for the given
 // Round. Otherwise, it returns false.
 func (p *Process) checkOnceFlag(round Round, flag OnceFlag) bool {
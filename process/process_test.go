@@ -2,6 +2,8 @@ package process_test
 
 import (
 	"bytes"
+	"fmt"
+	"math"
 	"math/rand"
 	"testing/quick"
 	"time"
@@ -77,6 +79,78 @@ var _ = Describe("Process", func() {
 		})
 	})
 
+	Context("when resuming from a snapshot", func() {
+		r := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+		It("should keep the state, and schedule the timeout for the current step", func() {
+			f := func() bool {
+				snapshot := process.New(id.NewPrivKey().Signatory(), 1+r.Intn(100), nil, nil, nil, nil, nil, nil, nil, nil)
+				snapshot.State = processutil.RandomState(r)
+				data, err := surge.ToBinary(snapshot)
+				Expect(err).ToNot(HaveOccurred())
+				unmarshaled := process.Process{}
+				Expect(surge.FromBinary(&unmarshaled, data)).To(Succeed())
+
+				log := &callLog{}
+				p := process.New(id.NewPrivKey().Signatory(), 1+r.Intn(100), log, nil, nil, nil, nil, nil, nil, nil).WithSnapshot(unmarshaled)
+				p.Resume()
+
+				// the identity, committee, and state are restored
+				restored, err := surge.ToBinary(p)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(restored).To(Equal(data))
+				Expect(p.State.Equal(&snapshot.State)).To(BeTrue())
+
+				switch snapshot.CurrentStep {
+				case process.Proposing:
+					Expect(log.calls).To(Equal([]string{fmt.Sprintf("timeout propose %v %v", snapshot.CurrentHeight, snapshot.CurrentRound)}))
+				case process.Prevoting:
+					Expect(log.calls).To(Equal([]string{fmt.Sprintf("timeout prevote %v %v", snapshot.CurrentHeight, snapshot.CurrentRound)}))
+				case process.Precommitting:
+					Expect(log.calls).To(Equal([]string{fmt.Sprintf("timeout precommit %v %v", snapshot.CurrentHeight, snapshot.CurrentRound)}))
+				default:
+					Expect(log.calls).To(BeEmpty())
+				}
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+
+	Context("when syncing with a commit certificate", func() {
+		r := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+		It("should commit the value, and start the next height", func() {
+			f := func() bool {
+				height := process.Height(1 + r.Intn(100))
+				cert := process.CommitCertificate{Height: height, Round: processutil.RandomRound(r), Value: processutil.RandomGoodValue(r)}
+
+				var committed process.CommitCertificate
+				committer := processutil.CommitterCallback{
+					CertificateCallback: func(cert process.CommitCertificate) { committed = cert },
+				}
+				p := process.NewWithCurrentHeight(id.NewPrivKey().Signatory(), height, 4, nil, nil, nil, nil, nil, nil, committer, nil)
+				p.Start()
+				p.Prevote(process.Prevote{Height: height, Round: 0, Value: processutil.RandomGoodValue(r), From: id.NewPrivKey().Signatory()})
+
+				// certificates from other heights are rejected
+				wrongCert := cert
+				wrongCert.Height = height + process.Height(1+r.Intn(10))
+				Expect(p.Sync(wrongCert)).ToNot(Succeed())
+				Expect(p.CurrentHeight).To(Equal(height))
+
+				Expect(p.Sync(cert)).To(Succeed())
+				Expect(committed).To(Equal(cert))
+				Expect(p.CurrentHeight).To(Equal(height + 1))
+				Expect(p.CurrentRound).To(Equal(process.Round(0)))
+				Expect(p.CurrentStep).To(Equal(process.Proposing))
+				Expect(p.PrevoteLogs).To(BeEmpty())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+
 	// L11:
 	//	Function StartRound(round)
 	//		currentRound ← round
@@ -94,7 +168,7 @@ var _ = Describe("Process", func() {
 
 		It("should start the zeroeth round on start", func() {
 			f := func() bool {
-				p := process.New(id.NewPrivKey().Signatory(), 33, nil, nil, nil, nil, nil, nil, nil)
+				p := process.New(id.NewPrivKey().Signatory(), 100, nil, nil, nil, nil, nil, nil, nil, nil)
 				p.Start()
 				Expect(p.CurrentRound).To(Equal(process.Round(0)))
 				Expect(p.CurrentHeight).To(Equal(process.Height(1)))
@@ -107,7 +181,7 @@ var _ = Describe("Process", func() {
 		It("should set the current round to that round and set the current step to proposing", func() {
 			f := func() bool {
 				round := processutil.RandomRound(r)
-				p := process.New(id.NewPrivKey().Signatory(), 33, nil, nil, nil, nil, nil, nil, nil)
+				p := process.New(id.NewPrivKey().Signatory(), 100, nil, nil, nil, nil, nil, nil, nil, nil)
 				p.StartRound(round)
 				Expect(p.CurrentRound).To(Equal(round))
 				Expect(p.CurrentStep).To(Equal(process.Proposing))
@@ -133,7 +207,7 @@ var _ = Describe("Process", func() {
 								Expect(proposal.Value).To(Equal(value))
 							},
 						}
-						p := process.New(whoami, 33, nil, scheduler, nil, nil, broadcaster, nil, nil)
+						p := process.New(whoami, 100, nil, scheduler, nil, nil, nil, broadcaster, nil, nil)
 						p.State.ValidValue = value
 						p.StartRound(round)
 						return true
@@ -159,7 +233,7 @@ var _ = Describe("Process", func() {
 								Expect(proposal.Value).To(Equal(value))
 							},
 						}
-						p := process.New(whoami, 33, nil, scheduler, proposer, nil, broadcaster, nil, nil)
+						p := process.New(whoami, 100, nil, scheduler, proposer, nil, nil, broadcaster, nil, nil)
 						p.StartRound(round)
 						return true
 					}
@@ -188,7 +262,7 @@ var _ = Describe("Process", func() {
 					}
 					timer := timer.NewLinearTimer(timerOptions, handleProposeTimeout, nil, nil)
 
-					p := process.New(whoami, 33, timer, scheduler, nil, nil, nil, nil, nil)
+					p := process.New(whoami, 100, timer, scheduler, nil, nil, nil, nil, nil, nil)
 					p.StartRound(round)
 
 					return true
@@ -231,7 +305,7 @@ var _ = Describe("Process", func() {
 							handleProposeTimeout := func(timeout timer.Timeout) {}
 							timer := timer.NewLinearTimer(timerOptions, handleProposeTimeout, nil, nil)
 
-							p := process.New(whoami, 33, timer, nil, nil, nil, broadcaster, nil, nil)
+							p := process.New(whoami, 100, timer, nil, nil, nil, nil, broadcaster, nil, nil)
 							p.OnTimeoutPropose(process.Height(1), round)
 							return true
 						}
@@ -262,7 +336,7 @@ var _ = Describe("Process", func() {
 							handleProposeTimeout := func(timeout timer.Timeout) {}
 							timer := timer.NewLinearTimer(timerOptions, handleProposeTimeout, nil, nil)
 
-							p := process.New(whoami, 33, timer, nil, nil, nil, broadcaster, nil, nil)
+							p := process.New(whoami, 100, timer, nil, nil, nil, nil, broadcaster, nil, nil)
 							p.State.CurrentStep = process.Prevoting
 							p.OnTimeoutPropose(process.Height(1), round)
 							return true
@@ -292,7 +366,7 @@ var _ = Describe("Process", func() {
 							WithTimeoutScaling(0)
 						handleProposeTimeout := func(timeout timer.Timeout) {}
 						timer := timer.NewLinearTimer(timerOptions, handleProposeTimeout, nil, nil)
-						p := process.New(whoami, 33, timer, nil, nil, nil, broadcaster, nil, nil)
+						p := process.New(whoami, 100, timer, nil, nil, nil, nil, broadcaster, nil, nil)
 
 						// set the current round
 						p.State.CurrentRound = round
@@ -331,7 +405,7 @@ var _ = Describe("Process", func() {
 						WithTimeoutScaling(0)
 					handleProposeTimeout := func(timeout timer.Timeout) {}
 					timer := timer.NewLinearTimer(timerOptions, handleProposeTimeout, nil, nil)
-					p := process.New(whoami, 33, timer, nil, nil, nil, broadcaster, nil, nil)
+					p := process.New(whoami, 100, timer, nil, nil, nil, nil, broadcaster, nil, nil)
 
 					// when a new process starts, it starts at height == 1
 					// timeout for some other height not equal to 1
@@ -379,7 +453,7 @@ var _ = Describe("Process", func() {
 							handlePrevoteTimeout := func(timeout timer.Timeout) {}
 							timer := timer.NewLinearTimer(timerOptions, nil, handlePrevoteTimeout, nil)
 
-							p := process.New(whoami, 33, timer, nil, nil, nil, broadcaster, nil, nil)
+							p := process.New(whoami, 100, timer, nil, nil, nil, nil, broadcaster, nil, nil)
 							p.State.CurrentStep = process.Prevoting
 							p.State.CurrentRound = round
 							p.OnTimeoutPrevote(process.Height(1), round)
@@ -411,7 +485,7 @@ var _ = Describe("Process", func() {
 							handlePrevoteTimeout := func(timeout timer.Timeout) {}
 							timer := timer.NewLinearTimer(timerOptions, nil, handlePrevoteTimeout, nil)
 
-							p := process.New(whoami, 33, timer, nil, nil, nil, broadcaster, nil, nil)
+							p := process.New(whoami, 100, timer, nil, nil, nil, nil, broadcaster, nil, nil)
 							someOtherStep := processutil.RandomStep(r)
 							for someOtherStep == process.Prevoting {
 								someOtherStep = processutil.RandomStep(r)
@@ -448,7 +522,7 @@ var _ = Describe("Process", func() {
 						handlePrevoteTimeout := func(timeout timer.Timeout) {}
 						timer := timer.NewLinearTimer(timerOptions, nil, handlePrevoteTimeout, nil)
 
-						p := process.New(whoami, 33, timer, nil, nil, nil, broadcaster, nil, nil)
+						p := process.New(whoami, 100, timer, nil, nil, nil, nil, broadcaster, nil, nil)
 						p.State.CurrentStep = process.Prevoting
 						p.State.CurrentRound = round
 						someOtherRound := processutil.RandomRound(r)
@@ -485,7 +559,7 @@ var _ = Describe("Process", func() {
 					handlePrevoteTimeout := func(timeout timer.Timeout) {}
 					timer := timer.NewLinearTimer(timerOptions, nil, handlePrevoteTimeout, nil)
 
-					p := process.New(whoami, 33, timer, nil, nil, nil, broadcaster, nil, nil)
+					p := process.New(whoami, 100, timer, nil, nil, nil, nil, broadcaster, nil, nil)
 					p.State.CurrentStep = process.Prevoting
 					p.State.CurrentRound = round
 					someOtherHeight := processutil.RandomHeight(r)
@@ -517,7 +591,7 @@ var _ = Describe("Process", func() {
 							round = processutil.RandomRound(r)
 						}
 						whoami := id.NewPrivKey().Signatory()
-						p := process.New(whoami, 33, nil, nil, nil, nil, nil, nil, nil)
+						p := process.New(whoami, 100, nil, nil, nil, nil, nil, nil, nil, nil)
 						p.State.CurrentStep = processutil.RandomStep(r)
 						p.State.CurrentRound = round
 
@@ -527,6 +601,33 @@ var _ = Describe("Process", func() {
 					}
 					Expect(quick.Check(f, nil)).To(Succeed())
 				})
+
+				It("should broadcast a timeout for the current round", func() {
+					f := func() bool {
+						round := processutil.RandomRound(r)
+						for round == process.InvalidRound {
+							round = processutil.RandomRound(r)
+						}
+						whoami := id.NewPrivKey().Signatory()
+						broadcasted := false
+						broadcaster := processutil.BroadcasterCallbacks{
+							BroadcastTimeoutCallback: func(timeout process.Timeout) {
+								Expect(timeout.Height).To(Equal(process.Height(1)))
+								Expect(timeout.Round).To(Equal(round))
+								Expect(timeout.From).To(Equal(whoami))
+								broadcasted = true
+							},
+						}
+						p := process.New(whoami, 100, nil, nil, nil, nil, nil, broadcaster, nil, nil)
+						p.State.CurrentStep = processutil.RandomStep(r)
+						p.State.CurrentRound = round
+
+						p.OnTimeoutPrecommit(process.Height(1), round)
+						Expect(broadcasted).To(BeTrue())
+						return true
+					}
+					Expect(quick.Check(f, nil)).To(Succeed())
+				})
 			})
 
 			Context("when the timeout is not for the current round", func() {
@@ -537,7 +638,7 @@ var _ = Describe("Process", func() {
 							round = processutil.RandomRound(r)
 						}
 						whoami := id.NewPrivKey().Signatory()
-						p := process.New(whoami, 33, nil, nil, nil, nil, nil, nil, nil)
+						p := process.New(whoami, 100, nil, nil, nil, nil, nil, nil, nil, nil)
 						p.State.CurrentRound = round
 						p.State.CurrentStep = processutil.RandomStep(r)
 						someOtherRound := processutil.RandomRound(r)
@@ -563,7 +664,7 @@ var _ = Describe("Process", func() {
 						round = processutil.RandomRound(r)
 					}
 					whoami := id.NewPrivKey().Signatory()
-					p := process.New(whoami, 33, nil, nil, nil, nil, nil, nil, nil)
+					p := process.New(whoami, 100, nil, nil, nil, nil, nil, nil, nil, nil)
 					p.State.CurrentStep = processutil.RandomStep(r)
 					p.State.CurrentRound = round
 					someOtherHeight := processutil.RandomHeight(r)
@@ -581,6 +682,128 @@ var _ = Describe("Process", func() {
 		})
 	})
 
+	//  upon 2f+1〈TIMEOUT, currentHeight, r〉with r ≥ currentRound do
+	//      StartRound(r + 1)
+	Context("when receiving timeouts", func() {
+		r := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+		timeouts := func(height process.Height, round process.Round, privKeys []*id.PrivKey) []process.Timeout {
+			timeouts := make([]process.Timeout, len(privKeys))
+			for i := range privKeys {
+				timeouts[i] = process.Timeout{Height: height, Round: round}
+				Expect(timeouts[i].Sign(privKeys[i])).To(Succeed())
+			}
+			return timeouts
+		}
+
+		newPrivKeys := func(n int) ([]*id.PrivKey, []id.Signatory) {
+			privKeys := make([]*id.PrivKey, n)
+			signatories := make([]id.Signatory, n)
+			for i := range privKeys {
+				privKeys[i] = id.NewPrivKey()
+				signatories[i] = privKeys[i].Signatory()
+			}
+			return privKeys, signatories
+		}
+
+		Context("when receiving timeouts from a quorum", func() {
+			It("should start the round after the timed out round", func() {
+				loop := func() bool {
+					f := 1 + r.Intn(5)
+					n := 3*f + 1
+					privKeys, _ := newPrivKeys(n)
+					currentRound := process.Round(r.Intn(10))
+					round := currentRound + process.Round(r.Intn(10))
+
+					p := process.New(id.NewPrivKey().Signatory(), n, nil, nil, nil, nil, nil, nil, nil, nil)
+					p.StartRound(currentRound)
+					for i, timeout := range timeouts(process.DefaultHeight, round, privKeys[:n-f]) {
+						if i < f && round > currentRound {
+							// less than f+1 messages must not cause a skip
+							p.Timeout(timeout)
+							Expect(p.CurrentRound).To(Equal(currentRound))
+							continue
+						}
+						p.Timeout(timeout)
+					}
+					Expect(p.CurrentRound).To(Equal(round + 1))
+					return true
+				}
+				Expect(quick.Check(loop, nil)).To(Succeed())
+			})
+
+			It("should return a timeout certificate", func() {
+				loop := func() bool {
+					f := 1 + r.Intn(5)
+					n := 3*f + 1
+					privKeys, signatories := newPrivKeys(n)
+					round := process.Round(r.Intn(10))
+
+					p := process.New(id.NewPrivKey().Signatory(), n, nil, nil, nil, nil, nil, nil, nil, nil)
+					p.StartRound(round)
+					for _, timeout := range timeouts(process.DefaultHeight, round, privKeys[:n-f-1]) {
+						p.Timeout(timeout)
+					}
+					_, ok := p.TimeoutCertificate(round)
+					Expect(ok).To(BeFalse())
+
+					p.Timeout(timeouts(process.DefaultHeight, round, privKeys[n-f-1:n-f])[0])
+					cert, ok := p.TimeoutCertificate(round)
+					Expect(ok).To(BeTrue())
+					Expect(cert.Height).To(Equal(process.DefaultHeight))
+					Expect(cert.Round).To(Equal(round))
+					Expect(cert.Verify(signatories)).To(Succeed())
+					return true
+				}
+				Expect(quick.Check(loop, nil)).To(Succeed())
+			})
+		})
+
+		Context("when receiving timeouts from less than a quorum", func() {
+			It("should not start a new round", func() {
+				loop := func() bool {
+					f := 1 + r.Intn(5)
+					n := 3*f + 1
+					privKeys, _ := newPrivKeys(n)
+					round := process.Round(r.Intn(10))
+
+					p := process.New(id.NewPrivKey().Signatory(), n, nil, nil, nil, nil, nil, nil, nil, nil)
+					p.StartRound(round)
+					for _, timeout := range timeouts(process.DefaultHeight, round, privKeys[:n-f-1]) {
+						p.Timeout(timeout)
+						p.Timeout(timeout)
+					}
+					Expect(p.CurrentRound).To(Equal(round))
+					return true
+				}
+				Expect(quick.Check(loop, nil)).To(Succeed())
+			})
+		})
+
+		Context("when receiving timeouts for a previous round or height", func() {
+			It("should do nothing", func() {
+				loop := func() bool {
+					f := 1 + r.Intn(5)
+					n := 3*f + 1
+					privKeys, _ := newPrivKeys(n)
+					round := process.Round(1 + r.Intn(10))
+
+					p := process.New(id.NewPrivKey().Signatory(), n, nil, nil, nil, nil, nil, nil, nil, nil)
+					p.StartRound(round)
+					for _, timeout := range timeouts(process.DefaultHeight, round-1, privKeys) {
+						p.Timeout(timeout)
+					}
+					for _, timeout := range timeouts(process.DefaultHeight+1, round, privKeys) {
+						p.Timeout(timeout)
+					}
+					Expect(p.CurrentRound).To(Equal(round))
+					return true
+				}
+				Expect(quick.Check(loop, nil)).To(Succeed())
+			})
+		})
+	})
+
 	// L22:
 	//  upon〈PROPOSAL, currentHeight, currentRound, v, −1〉from proposer(currentHeight, currentRound)
 	//  while currentStep = propose do
@@ -616,7 +839,7 @@ var _ = Describe("Process", func() {
 								scheduler := scheduler.NewRoundRobin([]id.Signatory{scheduledProposer})
 								validator := processutil.MockValidator{MockValid: func(process.Height, process.Round, process.Value) bool { return true }}
 
-								p := process.New(whoami, 33, nil, scheduler, nil, validator, broadcaster, nil, nil)
+								p := process.New(whoami, 100, nil, scheduler, nil, validator, nil, broadcaster, nil, nil)
 								p.StartRound(round)
 
 								p.State.CurrentStep = process.Proposing
@@ -658,7 +881,7 @@ var _ = Describe("Process", func() {
 								scheduler := scheduler.NewRoundRobin([]id.Signatory{scheduledProposer})
 								validator := processutil.MockValidator{MockValid: func(process.Height, process.Round, process.Value) bool { return true }}
 
-								p := process.New(whoami, 33, nil, scheduler, nil, validator, broadcaster, nil, nil)
+								p := process.New(whoami, 100, nil, scheduler, nil, validator, nil, broadcaster, nil, nil)
 								p.StartRound(round)
 
 								someValidRound := processutil.RandomRound(r)
@@ -704,7 +927,7 @@ var _ = Describe("Process", func() {
 								scheduler := scheduler.NewRoundRobin([]id.Signatory{scheduledProposer})
 								validator := processutil.MockValidator{MockValid: func(process.Height, process.Round, process.Value) bool { return true }}
 
-								p := process.New(whoami, 33, nil, scheduler, nil, validator, broadcaster, nil, nil)
+								p := process.New(whoami, 100, nil, scheduler, nil, validator, nil, broadcaster, nil, nil)
 								p.StartRound(round)
 								someValidRound := processutil.RandomRound(r)
 								for someValidRound == process.InvalidRound {
@@ -749,7 +972,7 @@ var _ = Describe("Process", func() {
 							scheduler := scheduler.NewRoundRobin([]id.Signatory{scheduledProposer})
 							validator := processutil.MockValidator{MockValid: func(process.Height, process.Round, process.Value) bool { return false }}
 
-							p := process.New(whoami, 33, nil, scheduler, nil, validator, broadcaster, nil, nil)
+							p := process.New(whoami, 100, nil, scheduler, nil, validator, nil, broadcaster, nil, nil)
 							p.StartRound(round)
 							p.State.CurrentStep = process.Proposing
 							p.Propose(process.Propose{
@@ -778,7 +1001,7 @@ var _ = Describe("Process", func() {
 							// instantiate a new process
 							scheduledProposer := id.NewPrivKey().Signatory()
 							scheduler := scheduler.NewRoundRobin([]id.Signatory{scheduledProposer})
-							p := process.New(whoami, f, nil, scheduler, nil, nil, nil, nil, nil)
+							p := process.New(whoami, 3*f+1, nil, scheduler, nil, nil, nil, nil, nil, nil)
 							p.StartRound(currentRound)
 
 							// update the state
@@ -820,13 +1043,13 @@ var _ = Describe("Process", func() {
 								BroadcastPrecommitCallback: nil,
 							}
 							committer := processutil.CommitterCallback{
-								Callback: func(height process.Height, value process.Value) (uint64, process.Scheduler) {
+								Callback: func(height process.Height, value process.Value) (process.VotingPowers, process.Scheduler) {
 									// we should not commit to the nil value proposal
 									Expect(true).To(BeFalse())
-									return 0, nil
+									return nil, nil
 								},
 							}
-							p := process.New(whoami, f, nil, nil, nil, nil, broadcaster, committer, nil)
+							p := process.New(whoami, 3*f+1, nil, nil, nil, nil, nil, broadcaster, committer, nil)
 
 							p.CurrentHeight = currentHeight
 							p.StartRound(currentRound)
@@ -879,7 +1102,7 @@ var _ = Describe("Process", func() {
 						scheduler := scheduler.NewRoundRobin([]id.Signatory{scheduledProposer})
 						validator := processutil.MockValidator{MockValid: func(process.Height, process.Round, process.Value) bool { return true }}
 
-						p := process.New(whoami, 33, nil, scheduler, nil, validator, broadcaster, nil, nil)
+						p := process.New(whoami, 100, nil, scheduler, nil, validator, nil, broadcaster, nil, nil)
 						p.StartRound(round)
 						someOtherStep := processutil.RandomStep(r)
 						for someOtherStep == process.Proposing {
@@ -922,7 +1145,7 @@ var _ = Describe("Process", func() {
 								return false
 							},
 						}
-						p := process.New(whoami, f, nil, nil, nil, validator, broadcaster, nil, nil)
+						p := process.New(whoami, 3*f+1, nil, nil, nil, validator, nil, broadcaster, nil, nil)
 
 						p.CurrentHeight = currentHeight
 						p.StartRound(currentRound)
@@ -969,7 +1192,7 @@ var _ = Describe("Process", func() {
 									return false
 								},
 							}
-							p := process.New(whoami, f, nil, nil, nil, validator, broadcaster, nil, nil)
+							p := process.New(whoami, 3*f+1, nil, nil, nil, validator, nil, broadcaster, nil, nil)
 
 							p.CurrentHeight = currentHeight
 							p.StartRound(currentRound)
@@ -1060,7 +1283,7 @@ var _ = Describe("Process", func() {
 								return false
 							},
 						}
-						p := process.New(whoami, f, nil, nil, nil, validator, broadcaster, nil, nil)
+						p := process.New(whoami, 3*f+1, nil, nil, nil, validator, nil, broadcaster, nil, nil)
 
 						p.CurrentHeight = currentHeight
 						p.StartRound(currentRound)
@@ -1103,7 +1326,7 @@ var _ = Describe("Process", func() {
 					}
 					scheduler := scheduler.NewRoundRobin([]id.Signatory{id.NewPrivKey().Signatory()})
 
-					p := process.New(whoami, 33, nil, scheduler, nil, nil, broadcaster, nil, nil)
+					p := process.New(whoami, 100, nil, scheduler, nil, nil, nil, broadcaster, nil, nil)
 					p.StartRound(round)
 					p.State.CurrentStep = process.Proposing
 					p.Propose(process.Propose{
@@ -1136,7 +1359,7 @@ var _ = Describe("Process", func() {
 					}
 					scheduler := scheduler.NewRoundRobin([]id.Signatory{id.NewPrivKey().Signatory()})
 
-					p := process.New(whoami, 33, nil, scheduler, nil, nil, broadcaster, nil, nil)
+					p := process.New(whoami, 100, nil, scheduler, nil, nil, nil, broadcaster, nil, nil)
 					p.StartRound(round)
 					p.State.CurrentStep = process.Proposing
 					prevState := p.State
@@ -1232,7 +1455,7 @@ var _ = Describe("Process", func() {
 											},
 										}
 										// create process and start this round
-										p := process.New(whoami, f, nil, mockScheduler, nil, mockValidator, broadcaster, nil, nil)
+										p := process.New(whoami, 3*f+1, nil, mockScheduler, nil, mockValidator, nil, broadcaster, nil, nil)
 										p.StartRound(currentRound)
 										p.State.LockedRound = lockedRound
 
@@ -1298,7 +1521,7 @@ var _ = Describe("Process", func() {
 											},
 										}
 										// create process and start this round
-										p := process.New(whoami, f, nil, mockScheduler, nil, mockValidator, broadcaster, nil, nil)
+										p := process.New(whoami, 3*f+1, nil, mockScheduler, nil, mockValidator, nil, broadcaster, nil, nil)
 										p.StartRound(currentRound)
 										p.State.LockedRound = lockedRound
 										p.State.LockedValue = proposedValue
@@ -1371,7 +1594,7 @@ var _ = Describe("Process", func() {
 											},
 										}
 										// create process and start this round
-										p := process.New(whoami, f, nil, mockScheduler, nil, mockValidator, broadcaster, nil, nil)
+										p := process.New(whoami, 3*f+1, nil, mockScheduler, nil, mockValidator, nil, broadcaster, nil, nil)
 										p.StartRound(currentRound)
 										p.State.LockedRound = lockedRound
 										p.State.LockedValue = lockedValue
@@ -1440,7 +1663,7 @@ var _ = Describe("Process", func() {
 										},
 									}
 									// create process and start this round
-									p := process.New(whoami, f, nil, mockScheduler, nil, mockValidator, broadcaster, nil, nil)
+									p := process.New(whoami, 3*f+1, nil, mockScheduler, nil, mockValidator, nil, broadcaster, nil, nil)
 									p.StartRound(currentRound)
 									p.State.LockedRound = lockedRound
 									p.State.LockedValue = proposedValue
@@ -1506,7 +1729,7 @@ var _ = Describe("Process", func() {
 									},
 								}
 								// create process and start this round
-								p := process.New(whoami, f, nil, mockScheduler, nil, mockValidator, broadcaster, nil, nil)
+								p := process.New(whoami, 3*f+1, nil, mockScheduler, nil, mockValidator, nil, broadcaster, nil, nil)
 								p.StartRound(currentRound)
 								p.State.LockedRound = lockedRound
 								p.State.LockedValue = proposedValue
@@ -1558,7 +1781,7 @@ var _ = Describe("Process", func() {
 							Fail("unexpectedly received a prevote broadcast")
 						},
 					}
-					p := process.New(whoami, f, nil, mockScheduler, nil, mockValidator, broadcaster, nil, nil)
+					p := process.New(whoami, 3*f+1, nil, mockScheduler, nil, mockValidator, nil, broadcaster, nil, nil)
 					p.StartRound(round)
 
 					for t := 0; t < 2*f+1; t++ {
@@ -1629,7 +1852,7 @@ var _ = Describe("Process", func() {
 
 					// instantiate a new process
 					// and start round
-					p := process.New(whoami, f, timer, nil, nil, nil, nil, nil, nil)
+					p := process.New(whoami, 3*f+1, timer, nil, nil, nil, nil, nil, nil, nil)
 					p.StartRound(currentRound)
 					// set the current step to be prevoting
 					p.State.CurrentStep = process.Prevoting
@@ -1703,7 +1926,7 @@ var _ = Describe("Process", func() {
 
 					// instantiate a new process
 					// and start round
-					p := process.New(whoami, f, timer, nil, nil, nil, nil, nil, nil)
+					p := process.New(whoami, 3*f+1, timer, nil, nil, nil, nil, nil, nil, nil)
 					p.StartRound(currentRound)
 					// set the current step to not be prevoting
 					someOtherStep := processutil.RandomStep(r)
@@ -1763,7 +1986,7 @@ var _ = Describe("Process", func() {
 							}
 							timer := timer.NewLinearTimer(timerOptions, nil, handlePrevoteTimeout, nil)
 
-							p := process.New(whoami, f, timer, nil, nil, nil, nil, nil, nil)
+							p := process.New(whoami, 3*f+1, timer, nil, nil, nil, nil, nil, nil, nil)
 
 							p.StartRound(currentRound)
 							p.CurrentHeight = currentHeight
@@ -1825,7 +2048,7 @@ var _ = Describe("Process", func() {
 							}
 							timer := timer.NewLinearTimer(timerOptions, nil, handlePrevoteTimeout, nil)
 
-							p := process.New(whoami, f, timer, nil, nil, nil, nil, nil, nil)
+							p := process.New(whoami, 3*f+1, timer, nil, nil, nil, nil, nil, nil, nil)
 
 							p.StartRound(currentRound)
 							p.CurrentHeight = currentHeight
@@ -1927,7 +2150,7 @@ var _ = Describe("Process", func() {
 							scheduler := scheduler.NewRoundRobin([]id.Signatory{scheduledProposer})
 
 							// instantiate a new process and its state
-							p := process.New(whoami, f, nil, scheduler, nil, nil, broadcaster, nil, nil)
+							p := process.New(whoami, 3*f+1, nil, scheduler, nil, nil, nil, broadcaster, nil, nil)
 							p.State.CurrentHeight = currentHeight
 							p.StartRound(currentRound)
 							p.State.CurrentStep = process.Prevoting
@@ -2026,7 +2249,7 @@ var _ = Describe("Process", func() {
 							scheduler := scheduler.NewRoundRobin([]id.Signatory{scheduledProposer})
 
 							// instantiate a new process and its state
-							p := process.New(whoami, f, nil, scheduler, nil, nil, broadcaster, nil, nil)
+							p := process.New(whoami, 3*f+1, nil, scheduler, nil, nil, nil, broadcaster, nil, nil)
 							p.State.CurrentHeight = currentHeight
 							p.StartRound(currentRound)
 							p.State.CurrentStep = process.Precommitting
@@ -2110,7 +2333,7 @@ var _ = Describe("Process", func() {
 						scheduler := scheduler.NewRoundRobin([]id.Signatory{scheduledProposer})
 
 						// instantiate a new process and its state
-						p := process.New(whoami, f, nil, scheduler, nil, nil, broadcaster, nil, nil)
+						p := process.New(whoami, 3*f+1, nil, scheduler, nil, nil, nil, broadcaster, nil, nil)
 						p.State.CurrentHeight = currentHeight
 						p.StartRound(currentRound)
 						p.State.CurrentStep = process.Proposing
@@ -2181,7 +2404,7 @@ var _ = Describe("Process", func() {
 					validator := processutil.MockValidator{MockValid: func(process.Height, process.Round, process.Value) bool { return false }}
 
 					// instantiate a new process and its state
-					p := process.New(whoami, f, nil, scheduler, nil, validator, broadcaster, nil, nil)
+					p := process.New(whoami, 3*f+1, nil, scheduler, nil, validator, nil, broadcaster, nil, nil)
 					p.State.CurrentHeight = currentHeight
 					p.StartRound(currentRound)
 					p.State.CurrentStep = process.Prevoting
@@ -2262,7 +2485,7 @@ var _ = Describe("Process", func() {
 						},
 					}
 					f := 5 + (r.Int() % 10)
-					p := process.New(whoami, f, nil, nil, nil, nil, broadcaster, nil, nil)
+					p := process.New(whoami, 3*f+1, nil, nil, nil, nil, nil, broadcaster, nil, nil)
 					p.StartRound(currentRound)
 
 					// the process is in the Prevoting step
@@ -2309,7 +2532,7 @@ var _ = Describe("Process", func() {
 						},
 					}
 					f := 5 + (r.Int() % 10)
-					p := process.New(whoami, f, nil, nil, nil, nil, broadcaster, nil, nil)
+					p := process.New(whoami, 3*f+1, nil, nil, nil, nil, nil, broadcaster, nil, nil)
 					p.StartRound(currentRound)
 
 					// the process is NOT in the Prevoting step
@@ -2356,7 +2579,7 @@ var _ = Describe("Process", func() {
 									acknowledge = true
 								},
 							}
-							p := process.New(whoami, f, nil, nil, nil, nil, broadcaster, nil, nil)
+							p := process.New(whoami, 3*f+1, nil, nil, nil, nil, nil, broadcaster, nil, nil)
 
 							p.StartRound(currentRound)
 							p.CurrentHeight = currentHeight
@@ -2394,7 +2617,7 @@ var _ = Describe("Process", func() {
 									Fail("unexpectedly received a precommit broadcast")
 								},
 							}
-							p := process.New(whoami, f, nil, nil, nil, nil, broadcaster, nil, nil)
+							p := process.New(whoami, 3*f+1, nil, nil, nil, nil, nil, broadcaster, nil, nil)
 
 							p.StartRound(currentRound)
 							p.CurrentHeight = currentHeight
@@ -2461,7 +2684,7 @@ var _ = Describe("Process", func() {
 				timer := timer.NewLinearTimer(timerOptions, nil, nil, handlePrecommitTimeout)
 
 				// intantiate the process
-				p := process.New(id.NewPrivKey().Signatory(), f, timer, nil, nil, nil, nil, nil, nil)
+				p := process.New(id.NewPrivKey().Signatory(), 3*f+1, timer, nil, nil, nil, nil, nil, nil, nil)
 				p.StartRound(currentRound)
 
 				// set the process to be at any random step
@@ -2532,7 +2755,7 @@ var _ = Describe("Process", func() {
 				timer := timer.NewLinearTimer(timerOptions, nil, nil, handlePrecommitTimeout)
 
 				// intantiate the process
-				p := process.New(id.NewPrivKey().Signatory(), f, timer, nil, nil, nil, nil, nil, nil)
+				p := process.New(id.NewPrivKey().Signatory(), 3*f+1, timer, nil, nil, nil, nil, nil, nil, nil)
 				p.StartRound(currentRound)
 
 				// set the process to be at any random step
@@ -2589,7 +2812,7 @@ var _ = Describe("Process", func() {
 				timer := timer.NewLinearTimer(timerOptions, nil, nil, handlePrecommitTimeout)
 
 				// intantiate the process
-				p := process.New(id.NewPrivKey().Signatory(), f, timer, nil, nil, nil, nil, nil, nil)
+				p := process.New(id.NewPrivKey().Signatory(), 3*f+1, timer, nil, nil, nil, nil, nil, nil, nil)
 				p.StartRound(currentRound)
 
 				// set the process to be at any random step
@@ -2675,11 +2898,11 @@ var _ = Describe("Process", func() {
 						f := 5 + (r.Int() % 10)
 						acknowledge := false
 						committer := processutil.CommitterCallback{
-							Callback: func(height process.Height, value process.Value) (uint64, process.Scheduler) {
+							Callback: func(height process.Height, value process.Value) (process.VotingPowers, process.Scheduler) {
 								Expect(height).To(Equal(currentHeight))
 								Expect(value).To(Equal(proposedValue))
 								acknowledge = true
-								return 0, nil
+								return nil, nil
 							},
 						}
 						scheduledProposer := id.NewPrivKey().Signatory()
@@ -2688,7 +2911,7 @@ var _ = Describe("Process", func() {
 
 						// instantiate a new process at the current round and height
 						// and at any valid step
-						p := process.New(whoami, f, nil, scheduler, nil, validator, nil, committer, nil)
+						p := process.New(whoami, 3*f+1, nil, scheduler, nil, validator, nil, nil, committer, nil)
 						p.StartRound(currentRound)
 						p.State.CurrentHeight = currentHeight
 						p.State.CurrentStep = process.Step(r.Int() % 3)
@@ -2727,6 +2950,62 @@ var _ = Describe("Process", func() {
 					Expect(quick.Check(loop, nil)).To(Succeed())
 				})
 
+				It("should give the committer a certificate of the commitment", func() {
+					loop := func() bool {
+						currentHeight := process.Height(r.Int63())
+						currentRound := process.Round(r.Int63())
+						proposedValue := processutil.RandomGoodValue(r)
+						whoami := id.NewPrivKey().Signatory()
+						f := 5 + (r.Int() % 10)
+						var cert *process.CommitCertificate
+						committer := processutil.CommitterCallback{
+							CertificateCallback: func(c process.CommitCertificate) {
+								cert = &c
+							},
+						}
+
+						// instantiate a new process at the current round and height
+						p := process.New(whoami, 3*f+1, nil, nil, nil, nil, nil, nil, committer, nil)
+						p.StartRound(currentRound)
+						p.State.CurrentHeight = currentHeight
+
+						// feed the process with f nil precommits, which must not be
+						// part of the certificate, and 2f+1 precommits for the value
+						for t := 0; t < f; t++ {
+							p.Precommit(randomValidPrecommitMsg(r, currentHeight, currentRound, process.NilValue))
+						}
+						for t := 0; t < 2*f+1; t++ {
+							p.Precommit(randomValidPrecommitMsg(r, currentHeight, currentRound, proposedValue))
+						}
+						Expect(cert).To(BeNil())
+
+						// feed the process with a propose message
+						p.Propose(process.Propose{
+							Height:     currentHeight,
+							Round:      currentRound,
+							ValidRound: processutil.RandomRound(r),
+							Value:      proposedValue,
+							From:       id.NewPrivKey().Signatory(),
+						})
+
+						Expect(cert).ToNot(BeNil())
+						Expect(cert.Height).To(Equal(currentHeight))
+						Expect(cert.Round).To(Equal(currentRound))
+						Expect(cert.Value).To(Equal(proposedValue))
+						Expect(len(cert.Precommits)).To(Equal(2*f + 1))
+						for i, precommit := range cert.Precommits {
+							Expect(precommit.Height).To(Equal(currentHeight))
+							Expect(precommit.Round).To(Equal(currentRound))
+							Expect(precommit.Value).To(Equal(proposedValue))
+							if i > 0 {
+								Expect(bytes.Compare(cert.Precommits[i-1].From[:], precommit.From[:])).To(Equal(-1))
+							}
+						}
+						return true
+					}
+					Expect(quick.Check(loop, nil)).To(Succeed())
+				})
+
 				It("should finalise the given height (without scheduler or validator)", func() {
 					loop := func() bool {
 						currentHeight := process.Height(r.Int63())
@@ -2739,17 +3018,17 @@ var _ = Describe("Process", func() {
 						f := 5 + (r.Int() % 10)
 						acknowledge := false
 						committer := processutil.CommitterCallback{
-							Callback: func(height process.Height, value process.Value) (uint64, process.Scheduler) {
+							Callback: func(height process.Height, value process.Value) (process.VotingPowers, process.Scheduler) {
 								Expect(height).To(Equal(currentHeight))
 								Expect(value).To(Equal(proposedValue))
 								acknowledge = true
-								return 0, nil
+								return nil, nil
 							},
 						}
 
 						// instantiate a new process at the current round and height
 						// and at any valid step
-						p := process.New(whoami, f, nil, nil, nil, nil, nil, committer, nil)
+						p := process.New(whoami, 3*f+1, nil, nil, nil, nil, nil, nil, committer, nil)
 						p.StartRound(currentRound)
 						p.State.CurrentHeight = currentHeight
 						p.State.CurrentStep = process.Step(r.Int() % 3)
@@ -2790,7 +3069,7 @@ var _ = Describe("Process", func() {
 			})
 
 			Context("when the committer signals a signatories change", func() {
-				It("should update the f", func() {
+				It("should update the voting powers", func() {
 					loop := func() bool {
 						currentHeight := process.Height(r.Int63())
 						currentRound := process.Round(r.Int63())
@@ -2801,13 +3080,18 @@ var _ = Describe("Process", func() {
 						whoami := id.NewPrivKey().Signatory()
 						f := 5 + (r.Int() % 10)
 						acknowledge := false
-						newF := f + 1
-						newsSheduledProposer := id.NewPrivKey().Signatory()
-						newScheduler :=  scheduler.NewRoundRobin([]id.Signatory{newsSheduledProposer})
+						newSignatories := make([]id.Signatory, 4+r.Intn(4))
+						newPowers := process.VotingPowers{}
+						for i := range newSignatories {
+							newSignatories[i] = id.NewPrivKey().Signatory()
+							newPowers[newSignatories[i]] = uint64(1 + r.Intn(10))
+						}
+						newsSheduledProposer := newSignatories[0]
+						newScheduler := scheduler.NewRoundRobin([]id.Signatory{newsSheduledProposer})
 						committer := processutil.CommitterCallback{
-							Callback: func(height process.Height, value process.Value) (uint64, process.Scheduler) {
+							Callback: func(height process.Height, value process.Value) (process.VotingPowers, process.Scheduler) {
 								acknowledge = true
-								return uint64(newF), newScheduler
+								return newPowers, newScheduler
 							},
 						}
 						scheduledProposer := id.NewPrivKey().Signatory()
@@ -2816,22 +3100,17 @@ var _ = Describe("Process", func() {
 
 						// instantiate a new process at the current round and height
 						// and at any valid step
-						p := process.New(whoami, f, nil, scheduler, nil, validator, nil, committer, nil)
+						p := process.New(whoami, 3*f+1, nil, scheduler, nil, validator, nil, nil, committer, nil)
 						p.StartRound(currentRound)
 						p.State.CurrentHeight = currentHeight
 						p.State.CurrentStep = process.Step(r.Int() % 3)
 
-						// feed the process with 2f+1 precommit messages
-						// we expect nothing to happen
+						// feed the process with 2f+1 precommit messages and a
+						// propose message, so that it commits
 						for t := 0; t < 2*f+1; t++ {
 							msg := randomValidPrecommitMsg(r, currentHeight, currentRound, proposedValue)
 							p.Precommit(msg)
 						}
-						Expect(p.State.CurrentHeight).To(Equal(currentHeight))
-						Expect(p.State.CurrentRound).To(Equal(currentRound))
-						Expect(acknowledge).ToNot(BeTrue())
-
-						// feed the process with a propose message
 						msg := process.Propose{
 							Height:     currentHeight,
 							Round:      currentRound,
@@ -2840,23 +3119,16 @@ var _ = Describe("Process", func() {
 							From:       scheduledProposer,
 						}
 						p.Propose(msg)
-
-						defaultState := process.DefaultState()
 						Expect(p.State.CurrentHeight).To(Equal(currentHeight + 1))
-						Expect(p.CurrentRound).To(Equal(process.Round(0)))
-						Expect(p.State.CurrentStep).To(Equal(defaultState.CurrentStep))
-						Expect(p.State.LockedRound).To(Equal(defaultState.LockedRound))
-						Expect(p.State.LockedValue).To(Equal(defaultState.LockedValue))
-						Expect(p.State.ValidValue).To(Equal(defaultState.ValidValue))
-						Expect(p.State.ValidRound).To(Equal(defaultState.ValidRound))
 						Expect(acknowledge).To(BeTrue())
 
-						// To verify the change of f and scheduler, we simulate another height
+						// To verify the change of voting powers, we simulate
+						// another height
 						acknowledge = false
 
 						// feed the process with a propose message from the new scheduled proposer
 						proposeMsg := process.Propose{
-							Height:     currentHeight +1,
+							Height:     currentHeight + 1,
 							Round:      0,
 							ValidRound: processutil.RandomRound(r),
 							Value:      proposedValue,
@@ -2864,28 +3136,36 @@ var _ = Describe("Process", func() {
 						}
 						p.Propose(proposeMsg)
 
-						// feed the process with 2f+1 precommit messages
+						// feed the process with 2f+1 precommit messages from
+						// signatories that have no voting power
 						// we expect nothing to happen
 						for t := 0; t < 2*f+1; t++ {
-							msg := randomValidPrecommitMsg(r, currentHeight + 1, 0, proposedValue)
+							msg := randomValidPrecommitMsg(r, currentHeight+1, 0, proposedValue)
 							p.Precommit(msg)
 						}
-						Expect(p.State.CurrentHeight).To(Equal(currentHeight +1))
-						Expect(p.State.CurrentRound).To(Equal(process.Round(0)))
+						Expect(p.State.CurrentHeight).To(Equal(currentHeight + 1))
 						Expect(acknowledge).ToNot(BeTrue())
 
-						// Feed two more precommit since newF = f + 1
-						for i := 0 ;i <2 ; i ++ {
-							msg := randomValidPrecommitMsg(r, currentHeight + 1, 0, proposedValue)
+						// feed the process with precommit messages from the new
+						// signatories, until they have a quorum of the voting
+						// power
+						power := uint64(0)
+						for _, signatory := range newSignatories {
+							Expect(p.State.CurrentHeight).To(Equal(currentHeight + 1))
+							Expect(acknowledge).ToNot(BeTrue())
+							msg := randomValidPrecommitMsg(r, currentHeight+1, 0, proposedValue)
+							msg.From = signatory
 							p.Precommit(msg)
+							power += newPowers[signatory]
+							if power >= newPowers.Quorum() {
+								break
+							}
 						}
+
+						defaultState := process.DefaultState()
 						Expect(p.State.CurrentHeight).To(Equal(currentHeight + 2))
 						Expect(p.CurrentRound).To(Equal(process.Round(0)))
 						Expect(p.State.CurrentStep).To(Equal(defaultState.CurrentStep))
-						Expect(p.State.LockedRound).To(Equal(defaultState.LockedRound))
-						Expect(p.State.LockedValue).To(Equal(defaultState.LockedValue))
-						Expect(p.State.ValidValue).To(Equal(defaultState.ValidValue))
-						Expect(p.State.ValidRound).To(Equal(defaultState.ValidRound))
 						Expect(acknowledge).To(BeTrue())
 						return true
 					}
@@ -2903,13 +3183,12 @@ var _ = Describe("Process", func() {
 						whoami := id.NewPrivKey().Signatory()
 						f := 5 + (r.Int() % 10)
 						acknowledge := false
-						newF := f + 1
 						newsSheduledProposer := id.NewPrivKey().Signatory()
 						newScheduler :=  scheduler.NewRoundRobin([]id.Signatory{newsSheduledProposer})
 						committer := processutil.CommitterCallback{
-							Callback: func(height process.Height, value process.Value) (uint64, process.Scheduler) {
+							Callback: func(height process.Height, value process.Value) (process.VotingPowers, process.Scheduler) {
 								acknowledge = true
-								return uint64(newF), newScheduler
+								return nil, newScheduler
 							},
 						}
 						scheduledProposer := id.NewPrivKey().Signatory()
@@ -2918,7 +3197,7 @@ var _ = Describe("Process", func() {
 
 						// instantiate a new process at the current round and height
 						// and at any valid step
-						p := process.New(whoami, f, nil, scheduler, nil, validator, nil, committer, nil)
+						p := process.New(whoami, 3*f+1, nil, scheduler, nil, validator, nil, nil, committer, nil)
 						p.StartRound(currentRound)
 						p.State.CurrentHeight = currentHeight
 						p.State.CurrentStep = process.Step(r.Int() % 3)
@@ -2956,9 +3235,9 @@ var _ = Describe("Process", func() {
 						// To verify the change of f and scheduler, we simulate another height
 						acknowledge = false
 
-						// feed the process with 2 newF+1 precommit messages
+						// feed the process with 2f+1 precommit messages
 						// we expect nothing to happen
-						for t := 0; t < 2*newF+1; t++ {
+						for t := 0; t < 2*f+1; t++ {
 							msg := randomValidPrecommitMsg(r, currentHeight + 1, 0, proposedValue)
 							p.Precommit(msg)
 						}
@@ -3015,9 +3294,9 @@ var _ = Describe("Process", func() {
 						whoami := id.NewPrivKey().Signatory()
 						f := 5 + (r.Int() % 10)
 						committer := processutil.CommitterCallback{
-							Callback: func(height process.Height, value process.Value) (uint64, process.Scheduler) {
+							Callback: func(height process.Height, value process.Value) (process.VotingPowers, process.Scheduler) {
 								Fail("unexpectedly received a commit")
-								return 0, nil
+								return nil, nil
 							},
 						}
 						broadcaster := processutil.BroadcasterCallbacks{
@@ -3031,7 +3310,7 @@ var _ = Describe("Process", func() {
 
 						// instantiate a new process at the current round and height
 						// and at any valid step
-						p := process.New(whoami, f, nil, nil, nil, nil, broadcaster, committer, nil)
+						p := process.New(whoami, 3*f+1, nil, nil, nil, nil, nil, broadcaster, committer, nil)
 						p.StartRound(currentRound)
 						p.State.CurrentHeight = currentHeight
 						p.State.CurrentStep = process.Step(r.Int() % 3)
@@ -3081,16 +3360,16 @@ var _ = Describe("Process", func() {
 						whoami := id.NewPrivKey().Signatory()
 						f := 5 + (r.Int() % 10)
 						committer := processutil.CommitterCallback{
-							Callback: func(height process.Height, value process.Value) (uint64, process.Scheduler) {
+							Callback: func(height process.Height, value process.Value) (process.VotingPowers, process.Scheduler) {
 								Fail("unexpectedly received a commit")
-								return 0, nil
+								return nil, nil
 							},
 						}
 						scheduler := scheduler.NewRoundRobin([]id.Signatory{id.NewPrivKey().Signatory()})
 
 						// instantiate a new process at the current round and height
 						// and at any valid step
-						p := process.New(whoami, f, nil, scheduler, nil, nil, nil, committer, nil)
+						p := process.New(whoami, 3*f+1, nil, scheduler, nil, nil, nil, nil, committer, nil)
 						p.StartRound(currentRound)
 						p.State.CurrentHeight = currentHeight
 						p.State.CurrentStep = process.Step(r.Int() % 3)
@@ -3132,16 +3411,16 @@ var _ = Describe("Process", func() {
 						whoami := id.NewPrivKey().Signatory()
 						f := 5 + (r.Int() % 10)
 						committer := processutil.CommitterCallback{
-							Callback: func(height process.Height, value process.Value) (uint64, process.Scheduler) {
+							Callback: func(height process.Height, value process.Value) (process.VotingPowers, process.Scheduler) {
 								Fail("unexpectedly received a commit")
-								return 0, nil
+								return nil, nil
 							},
 						}
 						validator := processutil.MockValidator{MockValid: func(process.Height, process.Round, process.Value) bool { return false }}
 
 						// instantiate a new process at the current round and height
 						// and at any valid step
-						p := process.New(whoami, f, nil, nil, nil, validator, nil, committer, nil)
+						p := process.New(whoami, 3*f+1, nil, nil, nil, validator, nil, nil, committer, nil)
 						p.StartRound(currentRound)
 						p.State.CurrentHeight = currentHeight
 						p.State.CurrentStep = process.Step(r.Int() % 3)
@@ -3185,14 +3464,14 @@ var _ = Describe("Process", func() {
 								acknowledge := false
 								proposedValue := processutil.RandomGoodValue(r)
 								committer := processutil.CommitterCallback{
-									Callback: func(height process.Height, value process.Value) (uint64, process.Scheduler) {
+									Callback: func(height process.Height, value process.Value) (process.VotingPowers, process.Scheduler) {
 										Expect(height).To(Equal(currentHeight))
 										Expect(value).To(Equal(proposedValue))
 										acknowledge = true
-										return 0, nil
+										return nil, nil
 									},
 								}
-								p := process.New(whoami, f, nil, nil, nil, nil, nil, committer, nil)
+								p := process.New(whoami, 3*f+1, nil, nil, nil, nil, nil, nil, committer, nil)
 
 								p.StartRound(currentRound)
 								p.CurrentHeight = currentHeight
@@ -3233,12 +3512,12 @@ var _ = Describe("Process", func() {
 								f := 5 + (r.Int() % 10)
 								proposedValue := processutil.RandomGoodValue(r)
 								committer := processutil.CommitterCallback{
-									Callback: func(height process.Height, value process.Value) (uint64, process.Scheduler) {
+									Callback: func(height process.Height, value process.Value) (process.VotingPowers, process.Scheduler) {
 										Fail("unexpectedly received a commit")
-										return 0, nil
+										return nil, nil
 									},
 								}
-								p := process.New(whoami, f, nil, nil, nil, nil, nil, committer, nil)
+								p := process.New(whoami, 3*f+1, nil, nil, nil, nil, nil, nil, committer, nil)
 
 								p.StartRound(currentRound)
 								p.CurrentHeight = currentHeight
@@ -3314,7 +3593,7 @@ var _ = Describe("Process", func() {
 						f := 5 + (r.Int() % 10)
 
 						// instantiate a new process
-						p := process.New(whoami, f, nil, nil, nil, nil, nil, nil, nil)
+						p := process.New(whoami, 3*f+1, nil, nil, nil, nil, nil, nil, nil, nil)
 						p.StartRound(currentRound)
 						p.State.CurrentHeight = currentHeight
 
@@ -3350,7 +3629,7 @@ var _ = Describe("Process", func() {
 						f := 5 + (r.Int() % 10)
 
 						// instantiate a new process
-						p := process.New(whoami, f, nil, nil, nil, nil, nil, nil, nil)
+						p := process.New(whoami, 3*f+1, nil, nil, nil, nil, nil, nil, nil, nil)
 						p.StartRound(currentRound)
 						p.State.CurrentHeight = currentHeight
 
@@ -3386,7 +3665,7 @@ var _ = Describe("Process", func() {
 						f := 5 + (r.Int() % 10)
 
 						// instantiate a new process
-						p := process.New(whoami, f, nil, nil, nil, nil, nil, nil, nil)
+						p := process.New(whoami, 3*f+1, nil, nil, nil, nil, nil, nil, nil, nil)
 						p.StartRound(currentRound)
 						p.State.CurrentHeight = currentHeight
 
@@ -3457,7 +3736,7 @@ var _ = Describe("Process", func() {
 						f := 5 + (r.Int() % 10)
 
 						// instantiate a new process
-						p := process.New(whoami, f, nil, nil, nil, nil, nil, nil, nil)
+						p := process.New(whoami, 3*f+1, nil, nil, nil, nil, nil, nil, nil, nil)
 						p.StartRound(currentRound)
 						p.State.CurrentHeight = currentHeight
 
@@ -3517,7 +3796,7 @@ var _ = Describe("Process", func() {
 						f := 5 + (r.Int() % 10)
 
 						// instantiate a new process
-						p := process.New(whoami, f, nil, nil, nil, nil, nil, nil, nil)
+						p := process.New(whoami, 3*f+1, nil, nil, nil, nil, nil, nil, nil, nil)
 						p.StartRound(currentRound)
 						p.State.CurrentHeight = currentHeight
 
@@ -3579,7 +3858,7 @@ var _ = Describe("Process", func() {
 				f := 5 + (r.Int() % 10)
 
 				// instantiate a new process
-				p := process.New(whoami, f, nil, nil, nil, nil, nil, nil, nil)
+				p := process.New(whoami, 3*f+1, nil, nil, nil, nil, nil, nil, nil, nil)
 				p.StartRound(currentRound)
 				p.State.CurrentHeight = currentHeight
 
@@ -3614,7 +3893,7 @@ var _ = Describe("Process", func() {
 				f := 5 + (r.Int() % 10)
 
 				// instantiate a new process
-				p := process.New(whoami, f, nil, nil, nil, nil, nil, nil, nil)
+				p := process.New(whoami, 3*f+1, nil, nil, nil, nil, nil, nil, nil, nil)
 				p.StartRound(currentRound)
 				p.State.CurrentHeight = currentHeight
 
@@ -3679,7 +3958,7 @@ var _ = Describe("Process", func() {
 				f := 5 + (r.Int() % 10)
 
 				// instantiate a new process
-				p := process.New(whoami, f, nil, nil, nil, nil, nil, nil, nil)
+				p := process.New(whoami, 3*f+1, nil, nil, nil, nil, nil, nil, nil, nil)
 				p.StartRound(currentRound)
 				p.State.CurrentHeight = currentHeight
 
@@ -3706,7 +3985,7 @@ var _ = Describe("Process", func() {
 				f := 5 + (r.Int() % 10)
 
 				// instantiate a new process
-				p := process.New(whoami, f, nil, nil, nil, nil, nil, nil, nil)
+				p := process.New(whoami, 3*f+1, nil, nil, nil, nil, nil, nil, nil, nil)
 				p.StartRound(currentRound)
 				p.State.CurrentHeight = currentHeight
 
@@ -3733,7 +4012,7 @@ var _ = Describe("Process", func() {
 				f := 5 + (r.Int() % 10)
 
 				// instantiate a new process
-				p := process.New(whoami, f, nil, nil, nil, nil, nil, nil, nil)
+				p := process.New(whoami, 3*f+1, nil, nil, nil, nil, nil, nil, nil, nil)
 				p.StartRound(currentRound)
 				p.State.CurrentHeight = currentHeight
 
@@ -3768,7 +4047,7 @@ var _ = Describe("Process", func() {
 				f := 5 + (r.Int() % 10)
 
 				// instantiate a new process
-				p := process.New(whoami, f, nil, nil, nil, nil, nil, nil, nil)
+				p := process.New(whoami, 3*f+1, nil, nil, nil, nil, nil, nil, nil, nil)
 				p.StartRound(currentRound)
 				p.State.CurrentHeight = currentHeight
 
@@ -3832,7 +4111,7 @@ var _ = Describe("Process", func() {
 						},
 					}
 					scheduler := scheduler.NewRoundRobin([]id.Signatory{doubleSender})
-					p := process.New(whoami, 33, nil, scheduler, nil, nil, nil, nil, catcher)
+					p := process.New(whoami, 100, nil, scheduler, nil, nil, nil, nil, nil, catcher)
 					p.StartRound(round)
 
 					// receive the first propose msg
@@ -3902,7 +4181,7 @@ var _ = Describe("Process", func() {
 							Fail("unexpectedly caught propose as an out-of-turn propose")
 						},
 					}
-					p := process.New(whoami, 33, nil, nil, nil, nil, nil, nil, catcher)
+					p := process.New(whoami, 100, nil, nil, nil, nil, nil, nil, nil, catcher)
 					p.StartRound(round)
 					p.State.CurrentStep = process.Prevoting
 
@@ -3986,7 +4265,7 @@ var _ = Describe("Process", func() {
 							Fail("unexpectedly caught propose as an out-of-turn propose")
 						},
 					}
-					p := process.New(whoami, 33, nil, nil, nil, nil, nil, nil, catcher)
+					p := process.New(whoami, 100, nil, nil, nil, nil, nil, nil, nil, catcher)
 					p.StartRound(round)
 					p.State.CurrentStep = process.Precommitting
 
@@ -4043,6 +4322,90 @@ var _ = Describe("Process", func() {
 			})
 		})
 
+		Context("when signing outbound messages", func() {
+			It("should broadcast the signed messages", func() {
+				loop := func() bool {
+					privKey := id.NewPrivKey()
+					whoami := privKey.Signatory()
+					value := processutil.RandomGoodValue(r)
+					signer := processutil.SignerCallbacks{
+						SignProposeCallback: func(propose process.Propose) (process.Propose, error) {
+							err := propose.Sign(privKey)
+							return propose, err
+						},
+						SignPrevoteCallback: func(prevote process.Prevote) (process.Prevote, error) {
+							err := prevote.Sign(privKey)
+							return prevote, err
+						},
+					}
+					proposeBroadcasted, prevoteBroadcasted := false, false
+					broadcaster := processutil.BroadcasterCallbacks{
+						BroadcastProposeCallback: func(propose process.Propose) {
+							Expect(propose.Verify()).To(Succeed())
+							Expect(propose.Value).To(Equal(value))
+							proposeBroadcasted = true
+						},
+						BroadcastPrevoteCallback: func(prevote process.Prevote) {
+							Expect(prevote.Verify()).To(Succeed())
+							Expect(prevote.Value).To(Equal(value))
+							prevoteBroadcasted = true
+						},
+					}
+					proposer := processutil.MockProposer{MockValue: func() process.Value { return value }}
+					validator := processutil.MockValidator{MockValid: func(process.Height, process.Round, process.Value) bool { return true }}
+					scheduler := scheduler.NewRoundRobin([]id.Signatory{whoami})
+					p := process.New(whoami, 100, nil, scheduler, proposer, validator, signer, broadcaster, nil, nil)
+					p.Start()
+					Expect(proposeBroadcasted).To(BeTrue())
+
+					// receive our own propose, and expect to prevote for it
+					p.Propose(process.Propose{
+						Height:     process.DefaultHeight,
+						Round:      0,
+						ValidRound: process.InvalidRound,
+						Value:      value,
+						From:       whoami,
+					})
+					Expect(prevoteBroadcasted).To(BeTrue())
+					return true
+				}
+				Expect(quick.Check(loop, nil)).To(Succeed())
+			})
+
+			It("should not broadcast messages that cannot be signed", func() {
+				loop := func() bool {
+					whoami := id.NewPrivKey().Signatory()
+					signer := processutil.SignerCallbacks{
+						SignProposeCallback: func(propose process.Propose) (process.Propose, error) {
+							return propose, fmt.Errorf("conflict")
+						},
+						SignPrevoteCallback: func(prevote process.Prevote) (process.Prevote, error) {
+							return prevote, fmt.Errorf("conflict")
+						},
+					}
+					broadcaster := processutil.BroadcasterCallbacks{
+						BroadcastProposeCallback: func(propose process.Propose) {
+							Fail("unexpectedly broadcast a propose")
+						},
+						BroadcastPrevoteCallback: func(prevote process.Prevote) {
+							Fail("unexpectedly broadcast a prevote")
+						},
+					}
+					proposer := processutil.MockProposer{MockValue: func() process.Value { return processutil.RandomGoodValue(r) }}
+					scheduler := scheduler.NewRoundRobin([]id.Signatory{whoami})
+					p := process.New(whoami, 100, nil, scheduler, proposer, nil, signer, broadcaster, nil, nil)
+					p.Start()
+
+					// the process still moves to the prevoting step when its
+					// prevote cannot be signed
+					p.OnTimeoutPropose(process.DefaultHeight, 0)
+					Expect(p.CurrentStep).To(Equal(process.Prevoting))
+					return true
+				}
+				Expect(quick.Check(loop, nil)).To(Succeed())
+			})
+		})
+
 		Context("when receiving out of turn propose", func() {
 			It("should catch the out of turn propose", func() {
 				loop := func() bool {
@@ -4071,7 +4434,7 @@ var _ = Describe("Process", func() {
 						},
 					}
 					scheduler := scheduler.NewRoundRobin([]id.Signatory{scheduledSender})
-					p := process.New(whoami, 33, nil, scheduler, nil, nil, nil, nil, catcher)
+					p := process.New(whoami, 100, nil, scheduler, nil, nil, nil, nil, nil, catcher)
 					p.StartRound(round)
 
 					// receive propose from out of turn sender, must catch
@@ -4090,4 +4453,321 @@ var _ = Describe("Process", func() {
 			})
 		})
 	})
+
+	Context("when the round window is bounded", func() {
+		r := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+		Context("when receiving messages from beyond the round window", func() {
+			It("should reject and catch them", func() {
+				loop := func() bool {
+					window := process.Round(1 + r.Intn(10))
+					currentRound := process.Round(r.Intn(10))
+					round := currentRound + window + 1 + process.Round(r.Int63n(math.MaxInt64-int64(currentRound+window+1)))
+					from := id.NewPrivKey().Signatory()
+
+					caught := 0
+					catcher := processutil.CatcherCallbacks{
+						CatchOutOfWindowProposeCallback: func(propose process.Propose) {
+							Expect(propose.Round).To(Equal(round))
+							caught++
+						},
+						CatchOutOfWindowPrevoteCallback: func(prevote process.Prevote) {
+							Expect(prevote.Round).To(Equal(round))
+							caught++
+						},
+						CatchOutOfWindowPrecommitCallback: func(precommit process.Precommit) {
+							Expect(precommit.Round).To(Equal(round))
+							caught++
+						},
+						CatchOutOfWindowTimeoutCallback: func(timeout process.Timeout) {
+							Expect(timeout.Round).To(Equal(round))
+							caught++
+						},
+					}
+					p := process.New(id.NewPrivKey().Signatory(), 100, nil, nil, nil, nil, nil, nil, nil, catcher).WithRoundWindow(window)
+					p.StartRound(currentRound)
+
+					p.Propose(process.Propose{Height: process.DefaultHeight, Round: round, ValidRound: process.InvalidRound, Value: processutil.RandomGoodValue(r), From: from})
+					p.Prevote(process.Prevote{Height: process.DefaultHeight, Round: round, Value: processutil.RandomGoodValue(r), From: from})
+					p.Precommit(process.Precommit{Height: process.DefaultHeight, Round: round, Value: processutil.RandomGoodValue(r), From: from})
+					p.Timeout(process.Timeout{Height: process.DefaultHeight, Round: round, From: from})
+					Expect(caught).To(Equal(4))
+
+					Expect(p.ProposeLogs).ToNot(HaveKey(round))
+					Expect(p.PrevoteLogs).ToNot(HaveKey(round))
+					Expect(p.PrecommitLogs).ToNot(HaveKey(round))
+					Expect(p.TimeoutLogs).ToNot(HaveKey(round))
+					Expect(p.TraceLogs).ToNot(HaveKey(round))
+					return true
+				}
+				Expect(quick.Check(loop, nil)).To(Succeed())
+			})
+		})
+
+		Context("when receiving messages from inside the round window", func() {
+			It("should accept them", func() {
+				loop := func() bool {
+					window := process.Round(1 + r.Intn(10))
+					currentRound := process.Round(r.Intn(10))
+					round := currentRound + process.Round(r.Int63n(int64(window)+1))
+					from := id.NewPrivKey().Signatory()
+
+					catcher := processutil.CatcherCallbacks{
+						CatchOutOfWindowPrevoteCallback: func(prevote process.Prevote) {
+							Fail("unexpectedly caught prevote as out of window")
+						},
+						CatchOutOfWindowPrecommitCallback: func(precommit process.Precommit) {
+							Fail("unexpectedly caught precommit as out of window")
+						},
+						CatchOutOfWindowTimeoutCallback: func(timeout process.Timeout) {
+							Fail("unexpectedly caught timeout as out of window")
+						},
+					}
+					p := process.New(id.NewPrivKey().Signatory(), 100, nil, nil, nil, nil, nil, nil, nil, catcher).WithRoundWindow(window)
+					p.StartRound(currentRound)
+
+					p.Prevote(process.Prevote{Height: process.DefaultHeight, Round: round, Value: processutil.RandomGoodValue(r), From: from})
+					p.Precommit(process.Precommit{Height: process.DefaultHeight, Round: round, Value: processutil.RandomGoodValue(r), From: from})
+					p.Timeout(process.Timeout{Height: process.DefaultHeight, Round: round, From: from})
+
+					Expect(p.PrevoteLogs[round]).To(HaveKey(from))
+					Expect(p.PrecommitLogs[round]).To(HaveKey(from))
+					Expect(p.TimeoutLogs[round]).To(HaveKey(from))
+					return true
+				}
+				Expect(quick.Check(loop, nil)).To(Succeed())
+			})
+		})
+
+		Context("when receiving messages from before the round window", func() {
+			It("should drop them without catching them", func() {
+				loop := func() bool {
+					window := process.Round(1 + r.Intn(10))
+					round := process.Round(r.Intn(10))
+					currentRound := round + window + 1 + process.Round(r.Intn(10))
+					from := id.NewPrivKey().Signatory()
+
+					catcher := processutil.CatcherCallbacks{
+						CatchOutOfWindowPrevoteCallback: func(prevote process.Prevote) {
+							Fail("unexpectedly caught prevote as out of window")
+						},
+						CatchOutOfWindowPrecommitCallback: func(precommit process.Precommit) {
+							Fail("unexpectedly caught precommit as out of window")
+						},
+					}
+					p := process.New(id.NewPrivKey().Signatory(), 100, nil, nil, nil, nil, nil, nil, nil, catcher).WithRoundWindow(window)
+					p.StartRound(currentRound)
+
+					p.Prevote(process.Prevote{Height: process.DefaultHeight, Round: round, Value: processutil.RandomGoodValue(r), From: from})
+					p.Precommit(process.Precommit{Height: process.DefaultHeight, Round: round, Value: processutil.RandomGoodValue(r), From: from})
+
+					Expect(p.PrevoteLogs).ToNot(HaveKey(round))
+					Expect(p.PrecommitLogs).ToNot(HaveKey(round))
+					return true
+				}
+				Expect(quick.Check(loop, nil)).To(Succeed())
+			})
+		})
+
+		Context("when receiving a propose with a valid round from before the round window", func() {
+			It("should prevote for the proposed value upon a quorum of prevotes in the valid round", func() {
+				loop := func() bool {
+					window := process.Round(1 + r.Intn(10))
+					validRound := process.Round(r.Intn(10))
+					currentRound := validRound + window + 1 + process.Round(r.Intn(10))
+					whoami := id.NewPrivKey().Signatory()
+					value := processutil.RandomGoodValue(r)
+
+					prevoted := false
+					broadcaster := processutil.BroadcasterCallbacks{
+						BroadcastPrevoteCallback: func(prevote process.Prevote) {
+							Expect(prevote.Round).To(Equal(currentRound))
+							Expect(prevote.Value).To(Equal(value))
+							prevoted = true
+						},
+					}
+					p := process.New(whoami, 4, nil, nil, nil, nil, nil, broadcaster, nil, nil).WithRoundWindow(window)
+					p.StartRound(currentRound)
+
+					p.Propose(process.Propose{Height: process.DefaultHeight, Round: currentRound, ValidRound: validRound, Value: value, From: id.NewPrivKey().Signatory()})
+					for i := 0; i < 3; i++ {
+						p.Prevote(process.Prevote{Height: process.DefaultHeight, Round: validRound, Value: value, From: id.NewPrivKey().Signatory()})
+					}
+
+					Expect(p.PrevoteLogs).To(HaveKey(validRound))
+					Expect(prevoted).To(BeTrue())
+					Expect(p.CurrentStep).To(Equal(process.Prevoting))
+					return true
+				}
+				Expect(quick.Check(loop, nil)).To(Succeed())
+			})
+		})
+
+		Context("when starting a round", func() {
+			It("should prune the logs of rounds before the round window, except the locked and valid rounds", func() {
+				loop := func() bool {
+					window := process.Round(1 + r.Intn(10))
+					p := process.New(id.NewPrivKey().Signatory(), 100, nil, nil, nil, nil, nil, nil, nil, nil).WithRoundWindow(window)
+					p.LockedRound = process.Round(r.Intn(5))
+					p.ValidRound = process.Round(r.Intn(5))
+					for round := process.Round(0); round < 20; round++ {
+						p.Prevote(process.Prevote{Height: process.DefaultHeight, Round: round, Value: processutil.RandomGoodValue(r), From: id.NewPrivKey().Signatory()})
+					}
+
+					currentRound := process.Round(r.Intn(20))
+					p.StartRound(currentRound)
+					for round := process.Round(0); round < 20; round++ {
+						if round < currentRound-window && round != p.LockedRound && round != p.ValidRound {
+							Expect(p.PrevoteLogs).ToNot(HaveKey(round))
+							Expect(p.TraceLogs).ToNot(HaveKey(round))
+							continue
+						}
+						if round <= window {
+							Expect(p.PrevoteLogs).To(HaveKey(round))
+							Expect(p.TraceLogs).To(HaveKey(round))
+						}
+					}
+					return true
+				}
+				Expect(quick.Check(loop, nil)).To(Succeed())
+			})
+		})
+	})
+
+	Context("when observing a process", func() {
+		r := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+		It("should notify the observer about every transition", func() {
+			loop := func() bool {
+				whoami := id.NewPrivKey().Signatory()
+				proposer := id.NewPrivKey().Signatory()
+				value := processutil.RandomGoodValue(r)
+
+				events := []string{}
+				var committed process.CommitCertificate
+				observer := processutil.ObserverCallbacks{
+					OnNewRoundCallback: func(height process.Height, round process.Round) {
+						events = append(events, fmt.Sprintf("round %v %v", height, round))
+					},
+					OnStepChangeCallback: func(height process.Height, round process.Round, step process.Step) {
+						events = append(events, fmt.Sprintf("step %v %v %v", height, round, step))
+					},
+					OnLockCallback: func(height process.Height, round process.Round, locked process.Value) {
+						Expect(locked).To(Equal(value))
+						events = append(events, fmt.Sprintf("lock %v %v", height, round))
+					},
+					OnValidValueCallback: func(height process.Height, round process.Round, valid process.Value) {
+						Expect(valid).To(Equal(value))
+						events = append(events, fmt.Sprintf("valid %v %v", height, round))
+					},
+					OnCommitCallback: func(cert process.CommitCertificate) {
+						committed = cert
+						events = append(events, fmt.Sprintf("commit %v %v", cert.Height, cert.Round))
+					},
+					OnTimeoutScheduledCallback: func(height process.Height, round process.Round, messageType process.MessageType) {
+						events = append(events, fmt.Sprintf("timeout %v %v %v", height, round, messageType))
+					},
+					OnMessageRejectedCallback: func(msg interface{}, err error) {
+						Expect(err).To(HaveOccurred())
+						events = append(events, fmt.Sprintf("reject %T", msg))
+					},
+				}
+				linearTimer := timer.NewLinearTimer(timer.DefaultOptions(), nil, nil, nil)
+				validator := processutil.MockValidator{MockValid: func(process.Height, process.Round, process.Value) bool { return true }}
+				committer := processutil.CommitterCallback{Callback: func(process.Height, process.Value) (process.VotingPowers, process.Scheduler) { return nil, nil }}
+				p := process.New(whoami, 4, linearTimer, scheduler.NewRoundRobin([]id.Signatory{proposer}), nil, validator, nil, processutil.BroadcasterCallbacks{}, committer, nil).WithObserver(observer)
+				p.Start()
+				Expect(events).To(Equal([]string{
+					"round 1 0",
+					"step 1 0 0",
+					"timeout 1 0 Propose",
+				}))
+				events = events[:0]
+
+				propose := process.Propose{Height: 1, Round: 0, ValidRound: process.InvalidRound, Value: value, From: proposer}
+				p.Propose(propose)
+				Expect(events).To(Equal([]string{"step 1 0 1"}))
+				events = events[:0]
+
+				// the same propose is a duplicate
+				p.Propose(propose)
+				Expect(events).To(Equal([]string{"reject process.Propose"}))
+				events = events[:0]
+
+				for i := 0; i < 3; i++ {
+					p.Prevote(process.Prevote{Height: 1, Round: 0, Value: value, From: id.NewPrivKey().Signatory()})
+				}
+				Expect(events).To(Equal([]string{"lock 1 0", "valid 1 0", "step 1 0 2"}))
+				events = events[:0]
+
+				for i := 0; i < 3; i++ {
+					p.Precommit(process.Precommit{Height: 1, Round: 0, Value: value, From: id.NewPrivKey().Signatory()})
+				}
+				Expect(events).To(ContainElement("commit 1 0"))
+				Expect(events).To(ContainElement("round 2 0"))
+				Expect(committed.Value).To(Equal(value))
+				Expect(committed.Precommits).To(HaveLen(3))
+				events = events[:0]
+
+				// messages from the previous height are rejected
+				p.Prevote(process.Prevote{Height: 1, Round: 0, Value: value, From: id.NewPrivKey().Signatory()})
+				p.Timeout(process.Timeout{Height: 1, Round: 0, From: id.NewPrivKey().Signatory()})
+				Expect(events).To(Equal([]string{"reject process.Prevote", "reject process.Timeout"}))
+				return true
+			}
+			Expect(quick.Check(loop, nil)).To(Succeed())
+		})
+	})
+
+	Context("when tracing a process", func() {
+		r := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+		It("should notify the tracer about every input, and the resulting state", func() {
+			loop := func() bool {
+				whoami := id.NewPrivKey().Signatory()
+				proposer := id.NewPrivKey().Signatory()
+				value := processutil.RandomGoodValue(r)
+
+				events := []process.TraceEvent{}
+				states := []process.State{}
+				tracer := processutil.TracerCallback{Callback: func(event process.TraceEvent, state process.State) {
+					events = append(events, event)
+					states = append(states, state)
+				}}
+				validator := processutil.MockValidator{MockValid: func(process.Height, process.Round, process.Value) bool { return true }}
+				p := process.New(whoami, 4, nil, scheduler.NewRoundRobin([]id.Signatory{proposer}), nil, validator, nil, nil, processutil.CommitterCallback{}, nil).WithTracer(tracer)
+				p.Start()
+				Expect(events).To(Equal([]process.TraceEvent{{Method: "Start"}}))
+				Expect(states[0].CurrentHeight).To(Equal(process.Height(1)))
+				Expect(states[0].CurrentStep).To(Equal(process.Proposing))
+
+				// rejected inputs are traced too
+				propose := process.Propose{Height: 1, Round: 0, ValidRound: process.InvalidRound, Value: value, From: proposer}
+				p.Propose(propose)
+				p.Propose(propose)
+				Expect(events[1:]).To(Equal([]process.TraceEvent{
+					{Method: "Propose", Message: propose},
+					{Method: "Propose", Message: propose},
+				}))
+				Expect(states[1].CurrentStep).To(Equal(process.Prevoting))
+
+				// timeouts are traced with their arguments, and the round
+				// that is started after a timeout is not traced on its own
+				p.OnTimeoutPrevote(1, 0)
+				p.OnTimeoutPrecommit(1, 0)
+				Expect(events[3:]).To(Equal([]process.TraceEvent{
+					{Method: "OnTimeoutPrevote", Height: 1, Round: 0},
+					{Method: "OnTimeoutPrecommit", Height: 1, Round: 0},
+				}))
+				Expect(states[3].CurrentStep).To(Equal(process.Precommitting))
+				Expect(states[4].CurrentRound).To(Equal(process.Round(1)))
+
+				p.StartRound(3)
+				Expect(events[5:]).To(Equal([]process.TraceEvent{{Method: "StartRound", Round: 3}}))
+				Expect(states[5].CurrentRound).To(Equal(process.Round(3)))
+				return true
+			}
+			Expect(quick.Check(loop, nil)).To(Succeed())
+		})
+	})
 })
@@ -1,6 +1,7 @@
 package processutil
 
 import (
+	"fmt"
 	"math/rand"
 
 	"github.com/renproject/hyperdrive/process"
@@ -13,6 +14,7 @@ type BroadcasterCallbacks struct {
 	BroadcastProposeCallback   func(process.Propose)
 	BroadcastPrevoteCallback   func(process.Prevote)
 	BroadcastPrecommitCallback func(process.Precommit)
+	BroadcastTimeoutCallback   func(process.Timeout)
 }
 
 // BroadcastPropose passes the propose message to the propose callback, if present
@@ -39,18 +41,75 @@ func (broadcaster BroadcasterCallbacks) BroadcastPrecommit(precommit process.Pre
 	broadcaster.BroadcastPrecommitCallback(precommit)
 }
 
+// BroadcastTimeout passes the timeout message to the timeout callback, if present
+func (broadcaster BroadcasterCallbacks) BroadcastTimeout(timeout process.Timeout) {
+	if broadcaster.BroadcastTimeoutCallback == nil {
+		return
+	}
+	broadcaster.BroadcastTimeoutCallback(timeout)
+}
+
+// SignerCallbacks provide callback functions to test the Signer behaviour
+// required by a Process. If a callback is not present, then the message is
+// returned without being signed.
+type SignerCallbacks struct {
+	SignProposeCallback   func(process.Propose) (process.Propose, error)
+	SignPrevoteCallback   func(process.Prevote) (process.Prevote, error)
+	SignPrecommitCallback func(process.Precommit) (process.Precommit, error)
+	SignTimeoutCallback   func(process.Timeout) (process.Timeout, error)
+}
+
+// SignPropose passes the propose message to the propose callback, if present
+func (signer SignerCallbacks) SignPropose(propose process.Propose) (process.Propose, error) {
+	if signer.SignProposeCallback == nil {
+		return propose, nil
+	}
+	return signer.SignProposeCallback(propose)
+}
+
+// SignPrevote passes the prevote message to the prevote callback, if present
+func (signer SignerCallbacks) SignPrevote(prevote process.Prevote) (process.Prevote, error) {
+	if signer.SignPrevoteCallback == nil {
+		return prevote, nil
+	}
+	return signer.SignPrevoteCallback(prevote)
+}
+
+// SignPrecommit passes the precommit message to the precommit callback, if
+// present
+func (signer SignerCallbacks) SignPrecommit(precommit process.Precommit) (process.Precommit, error) {
+	if signer.SignPrecommitCallback == nil {
+		return precommit, nil
+	}
+	return signer.SignPrecommitCallback(precommit)
+}
+
+// SignTimeout passes the timeout message to the timeout callback, if present
+func (signer SignerCallbacks) SignTimeout(timeout process.Timeout) (process.Timeout, error) {
+	if signer.SignTimeoutCallback == nil {
+		return timeout, nil
+	}
+	return signer.SignTimeoutCallback(timeout)
+}
+
 // CommitterCallback provides a callback function to test the Committer
-// behaviour required by a Process
+// behaviour required by a Process. The CertificateCallback is optional, and
+// receives the whole commit certificate.
 type CommitterCallback struct {
-	Callback func(process.Height, process.Value) (uint64, process.Scheduler)
+	Callback            func(process.Height, process.Value) (process.VotingPowers, process.Scheduler)
+	CertificateCallback func(process.CommitCertificate)
 }
 
-// Commit passes the commitment parameters height and round to the commit callback, if present
-func (committer CommitterCallback) Commit(height process.Height, value process.Value) (uint64, process.Scheduler) {
+// Commit passes the commit certificate to the certificate callback, and the
+// committed height and value to the commit callback, if present
+func (committer CommitterCallback) Commit(cert process.CommitCertificate) (process.VotingPowers, process.Scheduler) {
+	if committer.CertificateCallback != nil {
+		committer.CertificateCallback(cert)
+	}
 	if committer.Callback == nil {
-		return 0, nil
+		return nil, nil
 	}
-	return committer.Callback(height, value)
+	return committer.Callback(cert.Height, cert.Value)
 }
 
 // MockProposer is a mock implementation of the Proposer interface
@@ -83,10 +142,14 @@ func (v MockValidator) Valid(height process.Height, round process.Round, value p
 // CatcherCallbacks provide callback functions to test the Catcher interface
 // required by a Process
 type CatcherCallbacks struct {
-	CatchDoubleProposeCallback    func(process.Propose, process.Propose)
-	CatchDoublePrevoteCallback    func(process.Prevote, process.Prevote)
-	CatchDoublePrecommitCallback  func(process.Precommit, process.Precommit)
-	CatchOutOfTurnProposeCallback func(process.Propose)
+	CatchDoubleProposeCallback        func(process.Propose, process.Propose)
+	CatchDoublePrevoteCallback        func(process.Prevote, process.Prevote)
+	CatchDoublePrecommitCallback      func(process.Precommit, process.Precommit)
+	CatchOutOfTurnProposeCallback     func(process.Propose)
+	CatchOutOfWindowProposeCallback   func(process.Propose)
+	CatchOutOfWindowPrevoteCallback   func(process.Prevote)
+	CatchOutOfWindowPrecommitCallback func(process.Precommit)
+	CatchOutOfWindowTimeoutCallback   func(process.Timeout)
 }
 
 // CatchDoublePropose implements the interface method of handling the event when
@@ -129,6 +192,133 @@ func (catcher CatcherCallbacks) CatchOutOfTurnPropose(propose process.Propose) {
 	catcher.CatchOutOfTurnProposeCallback(propose)
 }
 
+// CatchOutOfWindowPropose implements the interface method of handling the event when
+// a propose message is received from a round that is too far ahead of the current round.
+// In this case, it simply passes it to the appropriate callback function
+func (catcher CatcherCallbacks) CatchOutOfWindowPropose(propose process.Propose) {
+	if catcher.CatchOutOfWindowProposeCallback == nil {
+		return
+	}
+	catcher.CatchOutOfWindowProposeCallback(propose)
+}
+
+// CatchOutOfWindowPrevote implements the interface method of handling the event when
+// a prevote message is received from a round that is too far ahead of the current round.
+// In this case, it simply passes it to the appropriate callback function
+func (catcher CatcherCallbacks) CatchOutOfWindowPrevote(prevote process.Prevote) {
+	if catcher.CatchOutOfWindowPrevoteCallback == nil {
+		return
+	}
+	catcher.CatchOutOfWindowPrevoteCallback(prevote)
+}
+
+// CatchOutOfWindowPrecommit implements the interface method of handling the event when
+// a precommit message is received from a round that is too far ahead of the current round.
+// In this case, it simply passes it to the appropriate callback function
+func (catcher CatcherCallbacks) CatchOutOfWindowPrecommit(precommit process.Precommit) {
+	if catcher.CatchOutOfWindowPrecommitCallback == nil {
+		return
+	}
+	catcher.CatchOutOfWindowPrecommitCallback(precommit)
+}
+
+// CatchOutOfWindowTimeout implements the interface method of handling the event when
+// a timeout message is received from a round that is too far ahead of the current round.
+// In this case, it simply passes it to the appropriate callback function
+func (catcher CatcherCallbacks) CatchOutOfWindowTimeout(timeout process.Timeout) {
+	if catcher.CatchOutOfWindowTimeoutCallback == nil {
+		return
+	}
+	catcher.CatchOutOfWindowTimeoutCallback(timeout)
+}
+
+// ObserverCallbacks provide callback functions to test the Observer interface
+// required by a Process
+type ObserverCallbacks struct {
+	OnNewRoundCallback         func(process.Height, process.Round)
+	OnStepChangeCallback       func(process.Height, process.Round, process.Step)
+	OnLockCallback             func(process.Height, process.Round, process.Value)
+	OnValidValueCallback       func(process.Height, process.Round, process.Value)
+	OnCommitCallback           func(process.CommitCertificate)
+	OnTimeoutScheduledCallback func(process.Height, process.Round, process.MessageType)
+	OnMessageRejectedCallback  func(interface{}, error)
+}
+
+// OnNewRound passes the height and round to the appropriate callback function
+func (observer ObserverCallbacks) OnNewRound(height process.Height, round process.Round) {
+	if observer.OnNewRoundCallback == nil {
+		return
+	}
+	observer.OnNewRoundCallback(height, round)
+}
+
+// OnStepChange passes the height, round and step to the appropriate callback
+// function
+func (observer ObserverCallbacks) OnStepChange(height process.Height, round process.Round, step process.Step) {
+	if observer.OnStepChangeCallback == nil {
+		return
+	}
+	observer.OnStepChangeCallback(height, round, step)
+}
+
+// OnLock passes the height, round and locked value to the appropriate callback
+// function
+func (observer ObserverCallbacks) OnLock(height process.Height, round process.Round, value process.Value) {
+	if observer.OnLockCallback == nil {
+		return
+	}
+	observer.OnLockCallback(height, round, value)
+}
+
+// OnValidValue passes the height, round and valid value to the appropriate
+// callback function
+func (observer ObserverCallbacks) OnValidValue(height process.Height, round process.Round, value process.Value) {
+	if observer.OnValidValueCallback == nil {
+		return
+	}
+	observer.OnValidValueCallback(height, round, value)
+}
+
+// OnCommit passes the commit certificate to the appropriate callback function
+func (observer ObserverCallbacks) OnCommit(cert process.CommitCertificate) {
+	if observer.OnCommitCallback == nil {
+		return
+	}
+	observer.OnCommitCallback(cert)
+}
+
+// OnTimeoutScheduled passes the height, round and message type of the timeout
+// to the appropriate callback function
+func (observer ObserverCallbacks) OnTimeoutScheduled(height process.Height, round process.Round, messageType process.MessageType) {
+	if observer.OnTimeoutScheduledCallback == nil {
+		return
+	}
+	observer.OnTimeoutScheduledCallback(height, round, messageType)
+}
+
+// OnMessageRejected passes the rejected message and the reason for its
+// rejection to the appropriate callback function
+func (observer ObserverCallbacks) OnMessageRejected(msg interface{}, err error) {
+	if observer.OnMessageRejectedCallback == nil {
+		return
+	}
+	observer.OnMessageRejectedCallback(msg, err)
+}
+
+// TracerCallback provides a callback function to test the Tracer interface
+// required by a Process
+type TracerCallback struct {
+	Callback func(process.TraceEvent, process.State)
+}
+
+// Trace passes the event and state to the callback function, if present
+func (tracer TracerCallback) Trace(event process.TraceEvent, state process.State) {
+	if tracer.Callback == nil {
+		return
+	}
+	tracer.Callback(event, state)
+}
+
 // RandomHeight consumes a source of randomness and returns a random height
 // for the consensus mechanism. It returns a truly random height 70% of the times,
 // whereas for the other 30% of the times it returns heights for edge scenarios
@@ -221,6 +411,7 @@ func RandomState(r *rand.Rand) process.State {
 			ProposeLogs:   make(map[process.Round]process.Propose),
 			PrevoteLogs:   make(map[process.Round]map[id.Signatory]process.Prevote),
 			PrecommitLogs: make(map[process.Round]map[id.Signatory]process.Precommit),
+			TimeoutLogs:   make(map[process.Round]map[id.Signatory]process.Timeout),
 			OnceFlags:     make(map[process.Round]process.OnceFlag),
 		}
 	}
@@ -256,6 +447,7 @@ func RandomPropose(r *rand.Rand) process.Propose {
 			ValidRound: RandomRound(r),
 			Value:      RandomValue(r),
 			From:       signatory,
+			Signature:  signature,
 		}
 	default:
 		msg := process.Propose{
@@ -265,7 +457,9 @@ func RandomPropose(r *rand.Rand) process.Propose {
 			Value:      RandomValue(r),
 		}
 		privKey := id.NewPrivKey()
-		msg.From = privKey.Signatory()
+		if err := msg.Sign(privKey); err != nil {
+			panic(fmt.Errorf("signing propose: %v", err))
+		}
 		return msg
 	}
 }
@@ -294,10 +488,11 @@ func RandomPrevote(r *rand.Rand) process.Prevote {
 			signature[i] = byte(r.Int())
 		}
 		return process.Prevote{
-			Height: RandomHeight(r),
-			Round:  RandomRound(r),
-			Value:  RandomValue(r),
-			From:   signatory,
+			Height:    RandomHeight(r),
+			Round:     RandomRound(r),
+			Value:     RandomValue(r),
+			From:      signatory,
+			Signature: signature,
 		}
 	default:
 		msg := process.Prevote{
@@ -306,7 +501,9 @@ func RandomPrevote(r *rand.Rand) process.Prevote {
 			Value:  RandomValue(r),
 		}
 		privKey := id.NewPrivKey()
-		msg.From = privKey.Signatory()
+		if err := msg.Sign(privKey); err != nil {
+			panic(fmt.Errorf("signing prevote: %v", err))
+		}
 		return msg
 	}
 }
@@ -335,19 +532,63 @@ func RandomPrecommit(r *rand.Rand) process.Precommit {
 			signature[i] = byte(r.Int())
 		}
 		return process.Precommit{
+			Height:    RandomHeight(r),
+			Round:     RandomRound(r),
+			Value:     RandomValue(r),
+			From:      signatory,
+			Signature: signature,
+		}
+	default:
+		msg := process.Precommit{
 			Height: RandomHeight(r),
 			Round:  RandomRound(r),
 			Value:  RandomValue(r),
-			From:   signatory,
+		}
+		privKey := id.NewPrivKey()
+		if err := msg.Sign(privKey); err != nil {
+			panic(fmt.Errorf("signing precommit: %v", err))
+		}
+		return msg
+	}
+}
+
+// RandomTimeout consumes a source of randomness and returns a random timeout
+// message. The message is a valid message 70% of the times, and other times
+// this function returns some edge scenarios, including empty message
+func RandomTimeout(r *rand.Rand) process.Timeout {
+	switch r.Int() % 10 {
+	case 0:
+		return process.Timeout{}
+	case 1:
+		return process.Timeout{
+			Height: RandomHeight(r),
+			Round:  RandomRound(r),
+			From:   id.Signatory{},
+		}
+	case 2:
+		signatory := id.Signatory{}
+		for i := range signatory {
+			signatory[i] = byte(r.Int())
+		}
+		signature := id.Signature{}
+		for i := range signature {
+			signature[i] = byte(r.Int())
+		}
+		return process.Timeout{
+			Height:    RandomHeight(r),
+			Round:     RandomRound(r),
+			From:      signatory,
+			Signature: signature,
 		}
 	default:
-		msg := process.Precommit{
+		msg := process.Timeout{
 			Height: RandomHeight(r),
 			Round:  RandomRound(r),
-			Value:  RandomValue(r),
 		}
 		privKey := id.NewPrivKey()
-		msg.From = privKey.Signatory()
+		if err := msg.Sign(privKey); err != nil {
+			panic(fmt.Errorf("signing timeout: %v", err))
+		}
 		return msg
 	}
 }
@@ -66,6 +66,24 @@ var _ = Describe("Process utilities", func() {
 		})
 	})
 
+	Context("when generating random timeouts", func() {
+		It("should not generate the same timeout multiple times", func() {
+			r := rand.New(rand.NewSource(time.Now().UnixNano()))
+			timeouts := make([]process.Timeout, 100)
+			for i := range timeouts {
+				timeouts[i] = processutil.RandomTimeout(r)
+			}
+			all := true
+			for i := range timeouts {
+				if !timeouts[0].Equal(&timeouts[i]) {
+					all = false
+					break
+				}
+			}
+			Expect(all).To(BeFalse())
+		})
+	})
+
 	Context("when generating random states", func() {
 		It("should not generate the same state multiple times", func() {
 			r := rand.New(rand.NewSource(time.Now().UnixNano()))
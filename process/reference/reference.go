@@ -0,0 +1,631 @@
+// Package reference is a deliberately naive implementation of the consensus
+// algorithm, written line-for-line from Algorithm 1 of "The latest gossip on
+// BFT consensus" (Buchman, Kwon, and Milosevic). It is an oracle for
+// differential tests of process.Process, which is an optimised transcription
+// of the same algorithm that uses message logs indexed by Round, OnceFlags,
+// and a hand-picked set of conditions to try after every event.
+//
+// The reference Process does none of that. It keeps every message that it
+// has received at the current Height in a single list and, after every event,
+// evaluates every upon rule against the whole list until none of them can
+// fire. "For the first time" is tracked explicitly for each rule and Round.
+// The paper does not say in which order rules that are enabled at the same
+// time fire, so the order of process.Process is used.
+//
+// The extensions that process.Process makes to the paper are implemented in
+// the same naive way, so that both implementations can be compared:
+//
+//   - only the first message of each type, from each sender, in each Round is
+//     received, and Proposals with a Round of less than zero are ignored,
+//   - Proposals with invalid Values are not counted by the rule at line 55,
+//   - Timeout messages are broadcast before a Process moves to the next Round
+//     in OnTimeoutPrecommit, and a Process starts Round r+1 upon 2f+1 Timeouts
+//     for a Round r ≥ round_p, and
+//   - decisions are returned as process.Commit Actions, and the next Height is
+//     only started once Committed is called (in the same way as
+//     process.ActionProcess).
+//
+// The reference Process does not support VotingPowers, round windows,
+// signing, or catching misbehaviour.
+package reference
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/id"
+)
+
+// A Process is a naive implementation of the consensus algorithm. Its methods
+// return the Actions that result from every event, in the same way as the
+// methods of a process.ActionProcess.
+type Process struct {
+	whoami    id.Signatory
+	n         int
+	scheduler process.Scheduler
+	proposer  process.Proposer
+	validator process.Validator
+
+	// h_p, round_p, step_p, lockedValue_p, lockedRound_p, validValue_p, and
+	// validRound_p in the paper.
+	height      process.Height
+	round       process.Round
+	step        process.Step
+	lockedValue process.Value
+	lockedRound process.Round
+	validValue  process.Value
+	validRound  process.Round
+	// decided is true when decision_p[h_p] is not nil, and the Process is
+	// waiting for Committed to be called.
+	decided bool
+
+	// messages received at the current Height, in the order in which they
+	// were received.
+	messages []interface{}
+	// firstTime records the Rounds in which the rules at lines 34, 36, and 47
+	// have fired.
+	firstTime map[rule]bool
+	actions   []process.Action
+}
+
+type rule struct {
+	line  int
+	round process.Round
+}
+
+// New returns a Process in a committee of n Processes that all have equal
+// voting power. The Scheduler, Proposer, and Validator must be deterministic,
+// because they are called whenever a rule is evaluated.
+func New(whoami id.Signatory, n int, scheduler process.Scheduler, proposer process.Proposer, validator process.Validator) *Process {
+	return &Process{
+		whoami:    whoami,
+		n:         n,
+		scheduler: scheduler,
+		proposer:  proposer,
+		validator: validator,
+
+		height:      process.DefaultHeight,
+		round:       process.DefaultRound,
+		step:        process.Proposing,
+		lockedValue: process.NilValue,
+		lockedRound: process.InvalidRound,
+		validValue:  process.NilValue,
+		validRound:  process.InvalidRound,
+
+		messages:  []interface{}{},
+		firstTime: map[rule]bool{},
+	}
+}
+
+// State returns h_p, round_p, step_p, lockedValue_p, lockedRound_p,
+// validValue_p, and validRound_p as a process.State without message logs.
+func (p *Process) State() process.State {
+	return process.State{
+		CurrentHeight: p.height,
+		CurrentRound:  p.round,
+		CurrentStep:   p.step,
+		LockedValue:   p.lockedValue,
+		LockedRound:   p.lockedRound,
+		ValidValue:    p.validValue,
+		ValidRound:    p.validRound,
+	}
+}
+
+// Start the Process.
+//
+//	upon start do StartRound(0)
+func (p *Process) Start() []process.Action {
+	p.startRound(0)
+	return p.run()
+}
+
+// Committed notifies the Process that its last Commit Action has been
+// executed, and starts the first Round of the next Height.
+func (p *Process) Committed() []process.Action {
+	p.decided = false
+	p.startRound(0)
+	return p.run()
+}
+
+// Propose receives a Proposal.
+func (p *Process) Propose(propose process.Propose) []process.Action {
+	if propose.Height == p.height && propose.Round >= 0 {
+		p.receive(propose)
+	}
+	return p.run()
+}
+
+// Prevote receives a Prevote.
+func (p *Process) Prevote(prevote process.Prevote) []process.Action {
+	if prevote.Height == p.height {
+		p.receive(prevote)
+	}
+	return p.run()
+}
+
+// Precommit receives a Precommit.
+func (p *Process) Precommit(precommit process.Precommit) []process.Action {
+	if precommit.Height == p.height {
+		p.receive(precommit)
+	}
+	return p.run()
+}
+
+// Timeout receives a Timeout.
+func (p *Process) Timeout(timeout process.Timeout) []process.Action {
+	if timeout.Height == p.height && timeout.Round >= 0 {
+		p.receive(timeout)
+	}
+	return p.run()
+}
+
+// OnTimeoutPropose is called when a propose timeout expires.
+//
+//	Function OnTimeoutPropose(height, round):
+//		if height = h_p ∧ round = round_p ∧ step_p = propose then
+//			broadcast ⟨PREVOTE, h_p, round_p, nil⟩
+//			step_p ← prevote
+func (p *Process) OnTimeoutPropose(height process.Height, round process.Round) []process.Action {
+	if height == p.height && round == p.round && p.step == process.Proposing {
+		p.broadcast(process.Prevote{Height: p.height, Round: p.round, Value: process.NilValue, From: p.whoami})
+		p.step = process.Prevoting
+	}
+	return p.run()
+}
+
+// OnTimeoutPrevote is called when a prevote timeout expires.
+//
+//	Function OnTimeoutPrevote(height, round):
+//		if height = h_p ∧ round = round_p ∧ step_p = prevote then
+//			broadcast ⟨PRECOMMIT, h_p, round_p, nil⟩
+//			step_p ← precommit
+func (p *Process) OnTimeoutPrevote(height process.Height, round process.Round) []process.Action {
+	if height == p.height && round == p.round && p.step == process.Prevoting {
+		p.broadcast(process.Precommit{Height: p.height, Round: p.round, Value: process.NilValue, From: p.whoami})
+		p.step = process.Precommitting
+	}
+	return p.run()
+}
+
+// OnTimeoutPrecommit is called when a precommit timeout expires. Broadcasting
+// the Timeout is an extension to the paper.
+//
+//	Function OnTimeoutPrecommit(height, round):
+//		if height = h_p ∧ round = round_p then
+//			broadcast ⟨TIMEOUT, h_p, round_p⟩
+//			StartRound(round_p + 1)
+func (p *Process) OnTimeoutPrecommit(height process.Height, round process.Round) []process.Action {
+	if height == p.height && round == p.round {
+		p.broadcast(process.Timeout{Height: p.height, Round: p.round, From: p.whoami})
+		p.startRound(p.round + 1)
+	}
+	return p.run()
+}
+
+// startRound implements StartRound, at line 10 of the paper:
+//
+//	Function StartRound(round):
+//		round_p ← round
+//		step_p ← propose
+//		if proposer(h_p, round_p) = p then
+//			if validValue_p ≠ nil then
+//				proposal ← validValue_p
+//			else
+//				proposal ← getValue()
+//			broadcast ⟨PROPOSAL, h_p, round_p, proposal, validRound_p⟩
+//		else
+//			schedule OnTimeoutPropose(h_p, round_p) to be executed after timeoutPropose(round_p)
+func (p *Process) startRound(round process.Round) {
+	p.round = round
+	p.step = process.Proposing
+	if p.proposerOf(p.height, p.round) == p.whoami {
+		proposal := p.validValue
+		if proposal == process.NilValue {
+			proposal = p.proposer.Propose(p.height, p.round)
+		}
+		p.broadcast(process.Propose{Height: p.height, Round: p.round, ValidRound: p.validRound, Value: proposal, From: p.whoami})
+	} else {
+		p.schedule(process.MessageTypePropose)
+	}
+}
+
+// run evaluates the rules until none of them fire, and returns the resulting
+// Actions. Rules are not evaluated after a decision, until Committed is
+// called.
+//
+// The paper does not say in which order rules that are enabled at the same
+// time are executed, so the same order as process.Process is used: a Process
+// that moves to a future Round (at line 55, or upon Timeouts) executes every
+// rule that is enabled in that Round before it considers anything else, and
+// otherwise a decision (at line 49) is executed before the rules for the
+// current Round.
+func (p *Process) run() []process.Action {
+	for !p.decided {
+		if p.line55() || p.uponTimeouts() {
+			for p.uponCurrentRound() {
+			}
+			continue
+		}
+		if !p.line49() && !p.uponCurrentRound() {
+			break
+		}
+	}
+	actions := p.actions
+	p.actions = nil
+	return actions
+}
+
+// uponCurrentRound evaluates the rules for the current Round, and returns true
+// as soon as one of them fires. The rules are evaluated in the order in which
+// they appear in the paper, except that the rules at lines 36 and 44 are
+// evaluated before the rule at line 34, so that a Process that can precommit
+// does so without scheduling a prevote timeout first.
+func (p *Process) uponCurrentRound() bool {
+	return p.line22() ||
+		p.line28() ||
+		p.line36() ||
+		p.line44() ||
+		p.line34() ||
+		p.line47()
+}
+
+// line22 implements the rule at line 22 of the paper:
+//
+//	upon ⟨PROPOSAL, h_p, round_p, v, −1⟩ from proposer(h_p, round_p) while step_p = propose do
+//		if valid(v) ∧ (lockedRound_p = −1 ∨ lockedValue_p = v) then
+//			broadcast ⟨PREVOTE, h_p, round_p, id(v)⟩
+//		else
+//			broadcast ⟨PREVOTE, h_p, round_p, nil⟩
+//		step_p ← prevote
+func (p *Process) line22() bool {
+	if p.step != process.Proposing {
+		return false
+	}
+	for _, propose := range p.proposals(p.round) {
+		if propose.ValidRound != process.InvalidRound {
+			continue
+		}
+		if p.valid(propose) && (p.lockedRound == process.InvalidRound || p.lockedValue == propose.Value) {
+			p.broadcast(process.Prevote{Height: p.height, Round: p.round, Value: propose.Value, From: p.whoami})
+		} else {
+			p.broadcast(process.Prevote{Height: p.height, Round: p.round, Value: process.NilValue, From: p.whoami})
+		}
+		p.step = process.Prevoting
+		return true
+	}
+	return false
+}
+
+// line28 implements the rule at line 28 of the paper:
+//
+//	upon ⟨PROPOSAL, h_p, round_p, v, vr⟩ from proposer(h_p, round_p) AND 2f+1 ⟨PREVOTE, h_p, vr, id(v)⟩ while step_p = propose ∧ (vr ≥ 0 ∧ vr < round_p) do
+//		if valid(v) ∧ (lockedRound_p ≤ vr ∨ lockedValue_p = v) then
+//			broadcast ⟨PREVOTE, h_p, round_p, id(v)⟩
+//		else
+//			broadcast ⟨PREVOTE, h_p, round_p, nil⟩
+//		step_p ← prevote
+func (p *Process) line28() bool {
+	if p.step != process.Proposing {
+		return false
+	}
+	for _, propose := range p.proposals(p.round) {
+		vr := propose.ValidRound
+		if !(vr >= 0 && vr < p.round) {
+			continue
+		}
+		if p.prevotes(vr, &propose.Value) < p.quorum() {
+			continue
+		}
+		if p.valid(propose) && (p.lockedRound <= vr || p.lockedValue == propose.Value) {
+			p.broadcast(process.Prevote{Height: p.height, Round: p.round, Value: propose.Value, From: p.whoami})
+		} else {
+			p.broadcast(process.Prevote{Height: p.height, Round: p.round, Value: process.NilValue, From: p.whoami})
+		}
+		p.step = process.Prevoting
+		return true
+	}
+	return false
+}
+
+// line34 implements the rule at line 34 of the paper:
+//
+//	upon 2f+1 ⟨PREVOTE, h_p, round_p, ∗⟩ while step_p = prevote for the first time do
+//		schedule OnTimeoutPrevote(h_p, round_p) to be executed after timeoutPrevote(round_p)
+func (p *Process) line34() bool {
+	if p.step != process.Prevoting || p.firstTime[rule{34, p.round}] {
+		return false
+	}
+	if p.prevotes(p.round, nil) < p.quorum() {
+		return false
+	}
+	p.schedule(process.MessageTypePrevote)
+	p.firstTime[rule{34, p.round}] = true
+	return true
+}
+
+// line36 implements the rule at line 36 of the paper:
+//
+//	upon ⟨PROPOSAL, h_p, round_p, v, ∗⟩ from proposer(h_p, round_p) AND 2f+1 ⟨PREVOTE, h_p, round_p, id(v)⟩ while valid(v) ∧ step_p ≥ prevote for the first time do
+//		if step_p = prevote then
+//			lockedValue_p ← v
+//			lockedRound_p ← round_p
+//			broadcast ⟨PRECOMMIT, h_p, round_p, id(v)⟩
+//			step_p ← precommit
+//		validValue_p ← v
+//		validRound_p ← round_p
+func (p *Process) line36() bool {
+	if p.step < process.Prevoting || p.firstTime[rule{36, p.round}] {
+		return false
+	}
+	for _, propose := range p.proposals(p.round) {
+		if !p.valid(propose) || p.prevotes(p.round, &propose.Value) < p.quorum() {
+			continue
+		}
+		if p.step == process.Prevoting {
+			p.lockedValue = propose.Value
+			p.lockedRound = p.round
+			p.broadcast(process.Precommit{Height: p.height, Round: p.round, Value: propose.Value, From: p.whoami})
+			p.step = process.Precommitting
+		}
+		p.validValue = propose.Value
+		p.validRound = p.round
+		p.firstTime[rule{36, p.round}] = true
+		return true
+	}
+	return false
+}
+
+// line44 implements the rule at line 44 of the paper:
+//
+//	upon 2f+1 ⟨PREVOTE, h_p, round_p, nil⟩ while step_p = prevote do
+//		broadcast ⟨PRECOMMIT, h_p, round_p, nil⟩
+//		step_p ← precommit
+func (p *Process) line44() bool {
+	if p.step != process.Prevoting {
+		return false
+	}
+	if p.prevotes(p.round, &process.NilValue) < p.quorum() {
+		return false
+	}
+	p.broadcast(process.Precommit{Height: p.height, Round: p.round, Value: process.NilValue, From: p.whoami})
+	p.step = process.Precommitting
+	return true
+}
+
+// line47 implements the rule at line 47 of the paper:
+//
+//	upon 2f+1 ⟨PRECOMMIT, h_p, round_p, ∗⟩ for the first time do
+//		schedule OnTimeoutPrecommit(h_p, round_p) to be executed after timeoutPrecommit(round_p)
+func (p *Process) line47() bool {
+	if p.firstTime[rule{47, p.round}] {
+		return false
+	}
+	if p.precommits(p.round, nil) < p.quorum() {
+		return false
+	}
+	p.schedule(process.MessageTypePrecommit)
+	p.firstTime[rule{47, p.round}] = true
+	return true
+}
+
+// line49 implements the rule at line 49 of the paper:
+//
+//	upon ⟨PROPOSAL, h_p, r, v, ∗⟩ from proposer(h_p, r) AND 2f+1 ⟨PRECOMMIT, h_p, r, id(v)⟩ while decision_p[h_p] = nil do
+//		if valid(v) then
+//			decision_p[h_p] = v
+//			h_p ← h_p + 1
+//			reset lockedRound_p, lockedValue_p, validRound_p and validValue_p to initial values and empty message log
+//			StartRound(0)
+//
+// The decision is returned as a process.Commit Action, and StartRound(0) is
+// called by Committed.
+func (p *Process) line49() bool {
+	for _, msg := range p.messages {
+		propose, ok := msg.(process.Propose)
+		if !ok || p.proposerOf(p.height, propose.Round) != propose.From {
+			continue
+		}
+		if !p.valid(propose) || p.precommits(propose.Round, &propose.Value) < p.quorum() {
+			continue
+		}
+		precommits := []process.Precommit{}
+		for _, msg := range p.messages {
+			if precommit, ok := msg.(process.Precommit); ok && precommit.Round == propose.Round && precommit.Value == propose.Value {
+				precommits = append(precommits, precommit)
+			}
+		}
+		sort.Slice(precommits, func(i, j int) bool {
+			return bytes.Compare(precommits[i].From[:], precommits[j].From[:]) < 0
+		})
+		p.actions = append(p.actions, process.Commit{Certificate: process.CommitCertificate{
+			Height:     p.height,
+			Round:      propose.Round,
+			Value:      propose.Value,
+			Precommits: precommits,
+		}})
+		p.decided = true
+		p.height++
+		p.lockedRound = process.InvalidRound
+		p.lockedValue = process.NilValue
+		p.validRound = process.InvalidRound
+		p.validValue = process.NilValue
+		p.messages = []interface{}{}
+		p.firstTime = map[rule]bool{}
+		return true
+	}
+	return false
+}
+
+// line55 implements the rule at line 55 of the paper:
+//
+//	upon f+1 ⟨∗, h_p, round, ∗, ∗⟩ with round > round_p do
+//		StartRound(round)
+//
+// Proposals are only counted if they are from the proposer, and their Value
+// is valid.
+func (p *Process) line55() bool {
+	for _, msg := range p.messages {
+		round := roundOf(msg)
+		if round <= p.round {
+			continue
+		}
+		senders := map[id.Signatory]bool{}
+		for _, msg := range p.messages {
+			if roundOf(msg) != round {
+				continue
+			}
+			if propose, ok := msg.(process.Propose); ok && (p.proposerOf(p.height, round) != propose.From || !p.valid(propose)) {
+				continue
+			}
+			senders[fromOf(msg)] = true
+		}
+		if len(senders) >= p.maxFaulty()+1 {
+			p.startRound(round)
+			return true
+		}
+	}
+	return false
+}
+
+// uponTimeouts implements a rule that is an extension to the paper:
+//
+//	upon 2f+1 ⟨TIMEOUT, h_p, r⟩ with r ≥ round_p do
+//		StartRound(r + 1)
+func (p *Process) uponTimeouts() bool {
+	for _, msg := range p.messages {
+		timeout, ok := msg.(process.Timeout)
+		if !ok || timeout.Round < p.round {
+			continue
+		}
+		senders := 0
+		for _, msg := range p.messages {
+			if other, ok := msg.(process.Timeout); ok && other.Round == timeout.Round {
+				senders++
+			}
+		}
+		if senders >= p.quorum() {
+			p.startRound(timeout.Round + 1)
+			return true
+		}
+	}
+	return false
+}
+
+// receive a message at the current Height, unless a message of the same type
+// has already been received from the same sender in the same Round.
+func (p *Process) receive(msg interface{}) {
+	for _, other := range p.messages {
+		if sameType(msg, other) && roundOf(msg) == roundOf(other) && fromOf(msg) == fromOf(other) {
+			return
+		}
+	}
+	p.messages = append(p.messages, msg)
+}
+
+// proposals returns the Proposals that have been received from the proposer
+// of the Round.
+func (p *Process) proposals(round process.Round) []process.Propose {
+	proposals := []process.Propose{}
+	for _, msg := range p.messages {
+		if propose, ok := msg.(process.Propose); ok && propose.Round == round && propose.From == p.proposerOf(p.height, round) {
+			proposals = append(proposals, propose)
+		}
+	}
+	return proposals
+}
+
+// prevotes returns the number of Prevotes in the Round for the Value, or for
+// any Value if the Value is nil.
+func (p *Process) prevotes(round process.Round, value *process.Value) int {
+	count := 0
+	for _, msg := range p.messages {
+		if prevote, ok := msg.(process.Prevote); ok && prevote.Round == round && (value == nil || prevote.Value == *value) {
+			count++
+		}
+	}
+	return count
+}
+
+// precommits returns the number of Precommits in the Round for the Value, or
+// for any Value if the Value is nil.
+func (p *Process) precommits(round process.Round, value *process.Value) int {
+	count := 0
+	for _, msg := range p.messages {
+		if precommit, ok := msg.(process.Precommit); ok && precommit.Round == round && (value == nil || precommit.Value == *value) {
+			count++
+		}
+	}
+	return count
+}
+
+// valid(v) in the paper. Nil Values are never valid.
+func (p *Process) valid(propose process.Propose) bool {
+	return propose.Value != process.NilValue && (p.validator == nil || p.validator.Valid(propose.Height, propose.Round, propose.Value))
+}
+
+func (p *Process) proposerOf(height process.Height, round process.Round) id.Signatory {
+	return p.scheduler.Schedule(height, round)
+}
+
+func (p *Process) maxFaulty() int {
+	return (p.n - 1) / 3
+}
+
+func (p *Process) quorum() int {
+	return p.n - p.maxFaulty()
+}
+
+func (p *Process) broadcast(msg interface{}) {
+	p.actions = append(p.actions, process.Broadcast{Message: msg})
+}
+
+func (p *Process) schedule(messageType process.MessageType) {
+	p.actions = append(p.actions, process.ScheduleTimeout{Height: p.height, Round: p.round, MessageType: messageType})
+}
+
+func sameType(msg1, msg2 interface{}) bool {
+	switch msg1.(type) {
+	case process.Propose:
+		_, ok := msg2.(process.Propose)
+		return ok
+	case process.Prevote:
+		_, ok := msg2.(process.Prevote)
+		return ok
+	case process.Precommit:
+		_, ok := msg2.(process.Precommit)
+		return ok
+	case process.Timeout:
+		_, ok := msg2.(process.Timeout)
+		return ok
+	}
+	return false
+}
+
+func roundOf(msg interface{}) process.Round {
+	switch msg := msg.(type) {
+	case process.Propose:
+		return msg.Round
+	case process.Prevote:
+		return msg.Round
+	case process.Precommit:
+		return msg.Round
+	case process.Timeout:
+		return msg.Round
+	}
+	return process.InvalidRound
+}
+
+func fromOf(msg interface{}) id.Signatory {
+	switch msg := msg.(type) {
+	case process.Propose:
+		return msg.From
+	case process.Prevote:
+		return msg.From
+	case process.Precommit:
+		return msg.From
+	case process.Timeout:
+		return msg.From
+	}
+	return id.Signatory{}
+}
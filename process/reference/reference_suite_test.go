@@ -0,0 +1,13 @@
+package reference_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestReference(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Reference Suite")
+}
@@ -0,0 +1,270 @@
+package reference_test
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing/quick"
+	"time"
+
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/hyperdrive/process/processutil"
+	"github.com/renproject/hyperdrive/process/reference"
+	"github.com/renproject/hyperdrive/scenario"
+	"github.com/renproject/hyperdrive/scheduler"
+	"github.com/renproject/hyperdrive/sim"
+	"github.com/renproject/hyperdrive/timer"
+	"github.com/renproject/id"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// proposer proposes one of its values, depending on the Height and Round.
+type proposer []process.Value
+
+func (p proposer) Propose(height process.Height, round process.Round) process.Value {
+	return p[(int(height)+int(round))%len(p)]
+}
+
+// validator rejects one value.
+type validator process.Value
+
+func (v validator) Valid(height process.Height, round process.Round, value process.Value) bool {
+	return value != process.Value(v)
+}
+
+// driver feeds the same inputs to a Process and a reference Process, and
+// remembers the messages that they broadcast and the timeouts that they
+// schedule, so that they can be fed back to them.
+type driver struct {
+	r           *rand.Rand
+	signatories []id.Signatory
+	scheduler   process.Scheduler
+	values      []process.Value
+
+	ap  *process.ActionProcess
+	ref *reference.Process
+
+	inputs    []string
+	broadcast []interface{}
+	scheduled []timer.Timeout
+}
+
+func newDriver(seed int64, n int) *driver {
+	r := rand.New(rand.NewSource(seed))
+	signatories := sim.GenerateSignatories(seed, n)
+	whoami := signatories[r.Intn(n)]
+	sched := scheduler.NewRoundRobin(signatories)
+	values := []process.Value{processutil.RandomGoodValue(r), processutil.RandomGoodValue(r), processutil.RandomGoodValue(r)}
+	p, v := proposer(values), validator(values[2])
+	return &driver{
+		r:           r,
+		signatories: signatories,
+		scheduler:   sched,
+		values:      values,
+
+		ap:  process.NewActionProcess(process.New(whoami, n, nil, sched, p, v, nil, nil, nil, nil)),
+		ref: reference.New(whoami, n, sched, p, v),
+	}
+}
+
+// step feeds the next random input to both Processes, and compares their
+// Actions and States.
+func (d *driver) step() {
+	input := d.input()
+	d.inputs = append(d.inputs, scenario.Describe(input))
+
+	var actions, expected []process.Action
+	switch input := input.(type) {
+	case process.Propose:
+		actions, expected = d.ap.Propose(input), d.ref.Propose(input)
+	case process.Prevote:
+		actions, expected = d.ap.Prevote(input), d.ref.Prevote(input)
+	case process.Precommit:
+		actions, expected = d.ap.Precommit(input), d.ref.Precommit(input)
+	case process.Timeout:
+		actions, expected = d.ap.Timeout(input), d.ref.Timeout(input)
+	case timer.Timeout:
+		switch input.MessageType {
+		case process.MessageTypePropose:
+			actions, expected = d.ap.OnTimeoutPropose(input.Height, input.Round), d.ref.OnTimeoutPropose(input.Height, input.Round)
+		case process.MessageTypePrevote:
+			actions, expected = d.ap.OnTimeoutPrevote(input.Height, input.Round), d.ref.OnTimeoutPrevote(input.Height, input.Round)
+		case process.MessageTypePrecommit:
+			actions, expected = d.ap.OnTimeoutPrecommit(input.Height, input.Round), d.ref.OnTimeoutPrecommit(input.Height, input.Round)
+		}
+	}
+	d.compare(actions, expected)
+}
+
+func (d *driver) compare(actions, expected []process.Action) {
+	actions = withoutEvidence(actions)
+	Expect(actions).To(ConsistOf(expected), "after inputs:\n%v\nprocess:\n%v\nreference:\n%v", strings.Join(d.inputs, "\n"), describe(actions), describe(expected))
+
+	Expect(withoutLogs(d.ap.State)).To(Equal(d.ref.State()), "after inputs:\n%v", strings.Join(d.inputs, "\n"))
+
+	commit := false
+	for _, action := range actions {
+		switch action := action.(type) {
+		case process.Broadcast:
+			d.broadcast = append(d.broadcast, action.Message)
+		case process.ScheduleTimeout:
+			d.scheduled = append(d.scheduled, timer.Timeout{MessageType: action.MessageType, Height: action.Height, Round: action.Round})
+		case process.Commit:
+			commit = true
+		}
+	}
+	if commit {
+		d.inputs = append(d.inputs, "Committed")
+		d.compare(d.ap.Committed(nil, nil), d.ref.Committed())
+	}
+}
+
+// input returns a random message, one of the messages that have been
+// broadcast, or one of the timeouts that have been scheduled. Random messages
+// are taken from processutil, but are mostly restricted to the current Height
+// and Round, to a small set of Values and senders, and to the Value that is
+// proposed in the current Round, so that they are likely to trigger the rules
+// of the algorithm.
+func (d *driver) input() interface{} {
+	state := d.ref.State()
+	height := state.CurrentHeight
+	if d.r.Intn(10) == 0 {
+		height += process.Height(d.r.Intn(2)*2 - 1)
+	}
+	round := state.CurrentRound
+	if d.r.Intn(6) == 0 {
+		round += process.Round(d.r.Intn(4) - 1)
+	}
+	value := proposer(d.values).Propose(state.CurrentHeight, state.CurrentRound)
+	if d.r.Intn(2) == 0 {
+		value = process.NilValue
+		if d.r.Intn(3) > 0 {
+			value = d.values[d.r.Intn(len(d.values))]
+		}
+	}
+	from := d.signatories[d.r.Intn(len(d.signatories))]
+
+	switch d.r.Intn(8) {
+	case 0:
+		propose := processutil.RandomPropose(d.r)
+		propose.Height, propose.Round, propose.Value, propose.From = height, round, value, from
+		propose.ValidRound = process.Round(d.r.Intn(int(round)+2)) - 1
+		if height > 0 && round >= 0 && d.r.Intn(4) > 0 {
+			propose.From = d.scheduler.Schedule(height, round)
+		}
+		return propose
+	case 1, 2:
+		prevote := processutil.RandomPrevote(d.r)
+		prevote.Height, prevote.Round, prevote.Value, prevote.From = height, round, value, from
+		return prevote
+	case 3, 4:
+		precommit := processutil.RandomPrecommit(d.r)
+		precommit.Height, precommit.Round, precommit.Value, precommit.From = height, round, value, from
+		return precommit
+	case 5:
+		timeout := processutil.RandomTimeout(d.r)
+		timeout.Height, timeout.Round, timeout.From = height, round, from
+		return timeout
+	case 6:
+		if len(d.broadcast) > 0 {
+			return d.broadcast[d.r.Intn(len(d.broadcast))]
+		}
+	case 7:
+		if len(d.scheduled) > 0 {
+			return d.scheduled[d.r.Intn(len(d.scheduled))]
+		}
+	}
+	return timer.Timeout{MessageType: process.MessageType(d.r.Intn(3) + 1), Height: state.CurrentHeight, Round: state.CurrentRound}
+}
+
+func describe(actions []process.Action) string {
+	descriptions := make([]string, len(actions))
+	for i, action := range actions {
+		descriptions[i] = scenario.Describe(action)
+	}
+	return strings.Join(descriptions, "\n")
+}
+
+func withoutLogs(state process.State) process.State {
+	return process.State{
+		CurrentHeight: state.CurrentHeight,
+		CurrentRound:  state.CurrentRound,
+		CurrentStep:   state.CurrentStep,
+		LockedValue:   state.LockedValue,
+		LockedRound:   state.LockedRound,
+		ValidValue:    state.ValidValue,
+		ValidRound:    state.ValidRound,
+	}
+}
+
+func withoutEvidence(actions []process.Action) []process.Action {
+	filtered := []process.Action{}
+	for _, action := range actions {
+		if _, ok := action.(process.ReportEvidence); !ok {
+			filtered = append(filtered, action)
+		}
+	}
+	return filtered
+}
+
+var _ = Describe("Reference", func() {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	for _, n := range []int{4, 7} {
+		n := n
+		Context(fmt.Sprintf("when fed the same random inputs as a Process in a committee of %v", n), func() {
+			It("should broadcast the same messages, schedule the same timeouts, and commit the same values", func() {
+				f := func() bool {
+					d := newDriver(r.Int63(), n)
+					d.compare(d.ap.Start(), d.ref.Start())
+					for i := 0; i < 500; i++ {
+						d.step()
+					}
+					return true
+				}
+				Expect(quick.Check(f, &quick.Config{MaxCount: 50})).To(Succeed())
+			})
+		})
+	}
+
+	Context("when a quorum precommits a proposal", func() {
+		It("should commit it with a sorted certificate, and wait before starting the next height", func() {
+			signatories := sim.GenerateSignatories(r.Int63(), 4)
+			value := processutil.RandomGoodValue(r)
+			sched := scheduler.NewRoundRobin(signatories)
+			p := reference.New(signatories[0], 4, sched, proposer{value}, nil)
+			Expect(p.Start()).To(Equal([]process.Action{
+				process.ScheduleTimeout{Height: 1, Round: 0, MessageType: process.MessageTypePropose},
+			}))
+
+			propose := process.Propose{Height: 1, Round: 0, ValidRound: process.InvalidRound, Value: value, From: signatories[1]}
+			Expect(p.Propose(propose)).To(Equal([]process.Action{
+				process.Broadcast{Message: process.Prevote{Height: 1, Round: 0, Value: value, From: signatories[0]}},
+			}))
+
+			precommits := make([]process.Precommit, 3)
+			for i := range precommits {
+				precommits[i] = process.Precommit{Height: 1, Round: 0, Value: value, From: signatories[i+1]}
+			}
+			sort.Slice(precommits, func(i, j int) bool {
+				return bytes.Compare(precommits[i].From[:], precommits[j].From[:]) < 0
+			})
+			Expect(p.Precommit(precommits[2])).To(BeEmpty())
+			Expect(p.Precommit(precommits[0])).To(BeEmpty())
+			Expect(p.Precommit(precommits[1])).To(Equal([]process.Action{
+				process.Commit{Certificate: process.CommitCertificate{Height: 1, Round: 0, Value: value, Precommits: precommits}},
+			}))
+
+			// the next height is not started until the commit is executed
+			Expect(p.State().CurrentHeight).To(Equal(process.Height(2)))
+			Expect(p.State().LockedRound).To(Equal(process.InvalidRound))
+			Expect(p.Committed()).To(Equal([]process.Action{
+				process.ScheduleTimeout{Height: 2, Round: 0, MessageType: process.MessageTypePropose},
+			}))
+		})
+	})
+})
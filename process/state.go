@@ -50,10 +50,12 @@ type State struct {
 	PrevoteLogs map[Round]map[id.Signatory]Prevote `json:"prevoteLogs"`
 	// PrecommitLogs store the Precommits for all Processes in all Rounds.
 	PrecommitLogs map[Round]map[id.Signatory]Precommit `json:"precommitLogs"`
+	// TimeoutLogs store the Timeouts for all Processes in all Rounds.
+	TimeoutLogs map[Round]map[id.Signatory]Timeout `json:"timeoutLogs"`
 	// OnceFlags prevents events from happening more than once.
 	OnceFlags map[Round]OnceFlag `json:"onceFlags"`
 	// TraceLogs store the unique signatories from which we have received a msg
-	// (propose/prevote/precommit) in a specific round for the current height
+	// (propose/prevote/precommit/timeout) in a specific round for the current height
 	TraceLogs map[Round]map[id.Signatory]bool
 }
 
@@ -72,6 +74,7 @@ func DefaultState() State {
 		ProposeIsValid: make(map[Round]bool),
 		PrevoteLogs:    make(map[Round]map[id.Signatory]Prevote),
 		PrecommitLogs:  make(map[Round]map[id.Signatory]Precommit),
+		TimeoutLogs:    make(map[Round]map[id.Signatory]Timeout),
 		TraceLogs:      make(map[Round]map[id.Signatory]bool),
 		OnceFlags:      make(map[Round]OnceFlag),
 	}
@@ -100,6 +103,7 @@ func (state State) Clone() State {
 		ProposeIsValid: make(map[Round]bool),
 		PrevoteLogs:    make(map[Round]map[id.Signatory]Prevote),
 		PrecommitLogs:  make(map[Round]map[id.Signatory]Precommit),
+		TimeoutLogs:    make(map[Round]map[id.Signatory]Timeout),
 		TraceLogs:      make(map[Round]map[id.Signatory]bool),
 		OnceFlags:      make(map[Round]OnceFlag),
 	}
@@ -121,6 +125,12 @@ func (state State) Clone() State {
 			cloned.PrecommitLogs[round][signatory] = precommit
 		}
 	}
+	for round, timeouts := range state.TimeoutLogs {
+		cloned.TimeoutLogs[round] = make(map[id.Signatory]Timeout)
+		for signatory, timeout := range timeouts {
+			cloned.TimeoutLogs[round][signatory] = timeout
+		}
+	}
 	for round, onceFlag := range state.OnceFlags {
 		cloned.OnceFlags[round] = onceFlag
 	}
@@ -160,6 +170,7 @@ func (state State) SizeHint() int {
 		surge.SizeHint(state.ProposeIsValid) +
 		surge.SizeHint(state.PrevoteLogs) +
 		surge.SizeHint(state.PrecommitLogs) +
+		surge.SizeHint(state.TimeoutLogs) +
 		surge.SizeHint(state.OnceFlags) +
 		surge.SizeHint(state.TraceLogs)
 }
@@ -210,6 +221,10 @@ func (state State) Marshal(buf []byte, rem int) ([]byte, int, error) {
 	if err != nil {
 		return buf, rem, fmt.Errorf("marshaling %v precommit logs: %v", len(state.PrecommitLogs), err)
 	}
+	buf, rem, err = surge.Marshal(state.TimeoutLogs, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling %v timeout logs: %v", len(state.TimeoutLogs), err)
+	}
 	buf, rem, err = surge.Marshal(state.OnceFlags, buf, rem)
 	if err != nil {
 		return buf, rem, fmt.Errorf("marshaling %v once flags: %v", len(state.OnceFlags), err)
@@ -267,6 +282,10 @@ func (state *State) Unmarshal(buf []byte, rem int) ([]byte, int, error) {
 	if err != nil {
 		return buf, rem, fmt.Errorf("unmarshaling precommit logs: %v", err)
 	}
+	buf, rem, err = surge.Unmarshal(&state.TimeoutLogs, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling timeout logs: %v", err)
+	}
 	buf, rem, err = surge.Unmarshal(&state.OnceFlags, buf, rem)
 	if err != nil {
 		return buf, rem, fmt.Errorf("unmarshaling once flags: %v", err)
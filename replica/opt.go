@@ -3,15 +3,33 @@ package replica
 import (
 	"github.com/renproject/hyperdrive/mq"
 	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/hyperdrive/wal"
+	"github.com/renproject/id"
 
 	"go.uber.org/zap"
 )
 
+// DefaultRoundWindow is the default number of Rounds, relative to the current
+// Round, for which the Process of a Replica keeps message logs.
+const DefaultRoundWindow = process.Round(100)
+
+// A SchedulerFunc returns the Scheduler for a committee of signatories, starting
+// at the given Height.
+type SchedulerFunc func(process.Height, []id.Signatory) process.Scheduler
+
 // Options represent the options for a Hyperdrive Replica
 type Options struct {
 	Logger           *zap.Logger
 	StartingHeight   process.Height
 	MessageQueueOpts mq.Options
+	VotingPowers     process.VotingPowers
+	WAL              wal.WAL
+	RoundWindow      process.Round
+	Observer         process.Observer
+	Tracer           process.Tracer
+	Syncer           Syncer
+	Scheduler        SchedulerFunc
+	Membership       Membership
 }
 
 // DefaultOptions returns the default options for a Hyperdrive Replica
@@ -24,6 +42,7 @@ func DefaultOptions() Options {
 		Logger:           logger,
 		StartingHeight:   process.DefaultHeight,
 		MessageQueueOpts: mq.DefaultOptions(),
+		RoundWindow:      DefaultRoundWindow,
 	}
 }
 
@@ -44,3 +63,66 @@ func (opts Options) WithMqOptions(mqOpts mq.Options) Options {
 	opts.MessageQueueOpts = mqOpts
 	return opts
 }
+
+// WithVotingPowers updates the voting power of each signatory. By default,
+// every signatory has equal voting power.
+func (opts Options) WithVotingPowers(powers process.VotingPowers) Options {
+	opts.VotingPowers = powers
+	return opts
+}
+
+// WithWAL updates the write-ahead log used by the Replica to recover after a
+// crash. By default, there is no write-ahead log.
+func (opts Options) WithWAL(w wal.WAL) Options {
+	opts.WAL = w
+	return opts
+}
+
+// WithRoundWindow updates the number of Rounds, relative to the current Round,
+// for which the Process keeps message logs. Messages from Rounds outside of the
+// window are dropped. A window that is not positive is not bounded.
+func (opts Options) WithRoundWindow(window process.Round) Options {
+	opts.RoundWindow = window
+	return opts
+}
+
+// WithObserver updates the Observer that is notified about every transition in
+// the State of the Replica's Process. By default, there is no Observer.
+func (opts Options) WithObserver(observer process.Observer) Options {
+	opts.Observer = observer
+	return opts
+}
+
+// WithTracer updates the Tracer that is notified about every input to the
+// Replica's Process, and the resulting State. By default, there is no Tracer.
+func (opts Options) WithTracer(tracer process.Tracer) Options {
+	opts.Tracer = tracer
+	return opts
+}
+
+// WithSyncer updates the Syncer that is used by the Replica to catch up with
+// the other Replicas when it falls behind. By default, there is no Syncer, and
+// the Replica must be reset to a greater height if it falls behind.
+func (opts Options) WithSyncer(syncer Syncer) Options {
+	opts.Syncer = syncer
+	return opts
+}
+
+// WithScheduler updates the function that returns the Scheduler whenever the
+// Replica installs a committee that was not returned by its Committer: when it
+// is created or restored from a Snapshot, when its height is reset, and when a
+// height reset (or the next Height after a commit) is replayed from its WAL.
+// If the Committer returns Schedulers, then the function must return the same
+// Scheduler for the same Height and signatories. By default, the Scheduler is a
+// round robin over the signatories.
+func (opts Options) WithScheduler(f SchedulerFunc) Options {
+	opts.Scheduler = f
+	return opts
+}
+
+// WithMembership updates the Membership that is notified whenever the allowed
+// signatories of the Replica change. By default, there is no Membership.
+func (opts Options) WithMembership(membership Membership) Options {
+	opts.Membership = membership
+	return opts
+}
@@ -6,7 +6,11 @@ import (
 	"time"
 
 	"github.com/renproject/hyperdrive/mq"
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/hyperdrive/process/processutil"
 	"github.com/renproject/hyperdrive/replica"
+	"github.com/renproject/hyperdrive/scheduler"
+	"github.com/renproject/id"
 
 	"go.uber.org/zap"
 
@@ -29,6 +33,63 @@ var _ = Describe("Replica Opts", func() {
 			_ = replica.DefaultOptions().WithLogger(logger)
 		})
 
+		Specify("with voting powers", func() {
+			powers := process.VotingPowers{id.NewPrivKey().Signatory(): 1}
+			opts := replica.DefaultOptions().WithVotingPowers(powers)
+			Expect(opts.VotingPowers).To(Equal(powers))
+		})
+
+		Specify("with observer", func() {
+			Expect(replica.DefaultOptions().Observer).To(BeNil())
+
+			observer := processutil.ObserverCallbacks{}
+			opts := replica.DefaultOptions().WithObserver(observer)
+			Expect(opts.Observer).To(Equal(observer))
+		})
+
+		Specify("with tracer", func() {
+			Expect(replica.DefaultOptions().Tracer).To(BeNil())
+
+			tracer := processutil.TracerCallback{}
+			opts := replica.DefaultOptions().WithTracer(tracer)
+			Expect(opts.Tracer).To(Equal(tracer))
+		})
+
+		Specify("with syncer", func() {
+			Expect(replica.DefaultOptions().Syncer).To(BeNil())
+
+			syncer := &mockSyncer{}
+			opts := replica.DefaultOptions().WithSyncer(syncer)
+			Expect(opts.Syncer).To(Equal(syncer))
+		})
+
+		Specify("with membership", func() {
+			Expect(replica.DefaultOptions().Membership).To(BeNil())
+
+			membership := &mockMembership{}
+			opts := replica.DefaultOptions().WithMembership(membership)
+			Expect(opts.Membership).To(Equal(membership))
+		})
+
+		Specify("with scheduler", func() {
+			Expect(replica.DefaultOptions().Scheduler).To(BeNil())
+
+			signatories := []id.Signatory{id.NewPrivKey().Signatory()}
+			opts := replica.DefaultOptions().WithScheduler(func(height process.Height, signatories []id.Signatory) process.Scheduler {
+				return scheduler.NewRoundRobin(signatories)
+			})
+			Expect(opts.Scheduler).ToNot(BeNil())
+			Expect(opts.Scheduler(1, signatories).Schedule(1, 0)).To(Equal(signatories[0]))
+		})
+
+		Specify("with round window", func() {
+			Expect(replica.DefaultOptions().RoundWindow).To(Equal(replica.DefaultRoundWindow))
+
+			window := process.Round(r.Int63())
+			opts := replica.DefaultOptions().WithRoundWindow(window)
+			Expect(opts.RoundWindow).To(Equal(window))
+		})
+
 		Specify("with message queue opts", func() {
 			loop := func() bool {
 				capacity := int(r.Int63())
@@ -1,44 +1,126 @@
 package replica
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"sort"
+	"sync"
 
 	"github.com/renproject/hyperdrive/mq"
 	"github.com/renproject/hyperdrive/process"
 	"github.com/renproject/hyperdrive/scheduler"
 	"github.com/renproject/hyperdrive/timer"
+	"github.com/renproject/hyperdrive/wal"
 	"github.com/renproject/id"
+
+	"go.uber.org/zap"
 )
 
 // DidHandleMessage is called by the Replica after it has finished handling an
-// input message (i.e. Propose, Prevote, or Precommit), or timeout. The message
+// input message (i.e. Propose, Prevote, Precommit, or Timeout), or timeout. The message
 // could have been either accepted and inserted into the processing queue, or
 // filtered out and dropped. The callback is also called when the context
 // within which the Replica runs gets cancelled.
 type DidHandleMessage func()
 
+// A Membership is notified whenever the signatories that are allowed to send
+// messages to a Replica change: when its Committer returns new VotingPowers,
+// and when its height is reset with new signatories. It is usually implemented
+// by the transport that connects the Replica to its peers (see
+// transport.Transport), so that signatories that are no longer allowed are
+// disconnected. The Membership is called by the Run loop of the Replica, so it
+// must not block.
+type Membership interface {
+	SetAllowed([]id.Signatory)
+}
+
 // A Replica represents a process in a replicated state machine that
 // participates in the Hyperdrive Consensus Algorithm. It encapsulates a
 // Hyperdrive Process and exposes an interface for the Hyperdrive user to
-// insert messages (propose, prevote, precommit, timeouts). A Replica then
+// insert messages (propose, prevote, precommit, timeout, and timeout
+// certificates) and local timeouts. A Replica then
 // handles these messages asynchronously, after sorting them in an increasing
 // order of height and round. A Replica is instantiated by passing in the set
 // of signatories participating in the consensus mechanism, and it filters out
 // messages that have not been sent by one of the known set of allowed
-// signatories.
+// signatories. Messages that fail verification are dropped before they are
+// inserted into the message queue.
+//
+// A Replica can be moved to another host by taking a Snapshot, and resuming
+// from it with NewFromSnapshot.
+//
+// The Status of a Replica can be requested from any goroutine. Requests are
+// handled by the Run loop of the Replica, so the Status is always consistent.
+//
+// If the options specify an Observer, then it is notified about every
+// transition in the State of the Process, and about every message that is
+// rejected by the Replica or its Process. Transitions are also observed while
+// the WAL is being replayed.
+//
+// If the options specify a Syncer, then the Replica uses it to catch up with
+// the other Replicas whenever it receives verified messages from a greater
+// Height. It fetches, verifies, and commits the CommitCertificates of the
+// Heights that it has missed, one Height at a time, until it has caught up.
+//
+// If the options specify a Membership, then it is notified whenever the
+// allowed signatories change, including while the WAL is being replayed.
+//
+// If the options specify a WAL, then every input is appended to the WAL before
+// it is fed to the Process, and every message is appended to the WAL before it
+// is broadcast. When the Replica starts running, it replays the WAL to rebuild
+// the State of its Process, and it never broadcasts a message that conflicts
+// with one that it has broadcast before.
 type Replica struct {
 	opts Options
 
 	proc         process.Process
 	procsAllowed map[id.Signatory]bool
+	verifier     process.Verifier
+
+	// signatories are the allowed signatories, in the order in which they were
+	// given to the Replica, so that they can be included in a Snapshot.
+	signatories []id.Signatory
+	// restored is true when the Process has been restored from a Snapshot, in
+	// which case it is resumed instead of started.
+	restored bool
+
+	// tip is the greatest Height of the verified messages from allowed
+	// signatories, which is used to decide whether to sync.
+	tip process.Height
+	// syncing is true while the Syncer is fetching a CommitCertificate.
+	syncing bool
+
+	// sent stores the messages that have been broadcast at the current Height,
+	// when there is a WAL.
+	sent map[broadcastKey]interface{}
+	// replaying is true while the WAL is being replayed.
+	replaying bool
+	// committed is the reset to the next Height after a Value has been
+	// committed during the replay, which is written to the WAL once the
+	// replay is done.
+	committed *wal.ResetHeight
 
 	mch chan interface{}
 	mq  mq.MessageQueue
 
+	// reqs receives requests for a Status or a Snapshot from other
+	// goroutines.
+	reqs chan interface{}
+	// summary of the State of the Process, which can be read from other
+	// goroutines while holding the summaryMu mutex.
+	summaryMu *sync.RWMutex
+	summary   summary
+
 	didHandleMessage DidHandleMessage
 }
 
-// New instantiates and returns a pointer to a new Hyperdrive replica machine
+// New instantiates and returns a pointer to a new Hyperdrive replica machine.
+// If the given Verifier is nil, then a process.SignatureVerifier is used to
+// authenticate messages. If the given Signer is nil, then the Broadcaster must
+// sign messages itself. If the options specify VotingPowers, then thresholds
+// are measured in voting power, otherwise all signatories have equal voting
+// power.
 func New(
 	opts Options,
 	whoami id.Signatory,
@@ -46,53 +128,104 @@ func New(
 	linearTimer process.Timer,
 	propose process.Proposer,
 	validate process.Validator,
+	verify process.Verifier,
+	sign process.Signer,
 	commit process.Committer,
 	catch process.Catcher,
 	broadcast process.Broadcaster,
 	didHandleMessage DidHandleMessage,
 ) *Replica {
-	f := len(signatories) / 3
-	scheduler := scheduler.NewRoundRobin(signatories)
-	proc := process.NewWithCurrentHeight(
-		whoami,
-		opts.StartingHeight,
-		f,
-		linearTimer,
-		scheduler,
-		propose,
-		validate,
-		broadcast,
-		commit,
-		catch,
-	)
-
 	procsAllowed := make(map[id.Signatory]bool)
 	for _, signatory := range signatories {
 		procsAllowed[signatory] = true
 	}
 
-	return &Replica{
+	if verify == nil {
+		verify = process.SignatureVerifier{}
+	}
+
+	replica := &Replica{
 		opts: opts,
 
-		proc:         proc,
 		procsAllowed: procsAllowed,
+		verifier:     verify,
+
+		signatories: signatories,
 
 		mch: make(chan interface{}, opts.MessageQueueOpts.MaxCapacity),
 		mq:  mq.New(opts.MessageQueueOpts),
 
+		reqs:      make(chan interface{}),
+		summaryMu: new(sync.RWMutex),
+
 		didHandleMessage: didHandleMessage,
 	}
+
+	scheduler := replica.scheduler(opts.StartingHeight, signatories)
+	committer := committer{replica: replica, committer: commit}
+	if opts.WAL != nil && broadcast != nil {
+		replica.sent = map[broadcastKey]interface{}{}
+		broadcast = broadcaster{replica: replica, broadcaster: broadcast}
+	}
+	if opts.VotingPowers != nil {
+		replica.proc = process.NewWithVotingPowers(
+			whoami,
+			opts.StartingHeight,
+			opts.VotingPowers,
+			linearTimer,
+			scheduler,
+			propose,
+			validate,
+			sign,
+			broadcast,
+			committer,
+			catch,
+		)
+	} else {
+		replica.proc = process.NewWithCurrentHeight(
+			whoami,
+			opts.StartingHeight,
+			len(signatories),
+			linearTimer,
+			scheduler,
+			propose,
+			validate,
+			sign,
+			broadcast,
+			committer,
+			catch,
+		)
+	}
+	replica.proc = replica.proc.
+		WithRoundWindow(opts.RoundWindow).
+		WithObserver(opts.Observer).
+		WithTracer(opts.Tracer)
+	replica.summarise()
+	return replica
 }
 
-// Run starts the Hyperdrive replica's process
+// Run starts the Hyperdrive replica's process. If there is a WAL, it is
+// replayed before any new messages are handled. If the WAL cannot be read, then
+// the Replica does not run, because it could otherwise equivocate.
 func (replica *Replica) Run(ctx context.Context) {
-	replica.proc.Start()
+	if err := replica.Start(); err != nil {
+		replica.logError("reading wal", err)
+		return
+	}
+
+	// The context is cancelled when the Run loop exits, so that goroutines
+	// started by the Run loop (see trySync) do not outlive it.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
 	isRunning := true
 	for isRunning {
 		func() {
+			didHandleMessage := true
 			defer func() {
-				if replica.didHandleMessage != nil {
+				replica.trySync(ctx)
+				replica.summarise()
+				if didHandleMessage && replica.didHandleMessage != nil {
 					replica.didHandleMessage()
 				}
 			}()
@@ -101,55 +234,168 @@ func (replica *Replica) Run(ctx context.Context) {
 			case <-ctx.Done():
 				isRunning = false
 				return
-			case m := <-replica.mch:
-				switch m := m.(type) {
-				case timer.Timeout:
-					switch m.MessageType {
-					case process.MessageTypePropose:
-						replica.proc.OnTimeoutPropose(m.Height, m.Round)
-					case process.MessageTypePrevote:
-						replica.proc.OnTimeoutPrevote(m.Height, m.Round)
-					case process.MessageTypePrecommit:
-						replica.proc.OnTimeoutPrecommit(m.Height, m.Round)
-					default:
-						return
-					}
-				case process.Propose:
-					if !replica.filterHeight(m.Height) {
-						return
-					}
-					replica.mq.InsertPropose(m)
-				case process.Prevote:
-					if !replica.filterHeight(m.Height) {
-						return
-					}
-					replica.mq.InsertPrevote(m)
-				case process.Precommit:
-					if !replica.filterHeight(m.Height) {
-						return
-					}
-					replica.mq.InsertPrecommit(m)
-				case ResetHeightMessage:
-					replica.proc.State = process.DefaultState().WithCurrentHeight(m.height)
-					replica.mq.DropMessagesBelowHeight(m.height)
-
-					// If the signatories change in the new height
-					if len(m.signatories) != 0 {
-						f := len(m.signatories) / 3
-						replica.proc.StartWithNewSignatories(uint64(f), m.scheduler)
-						replica.procsAllowed = map[id.Signatory]bool{}
-						for _, sig := range m.signatories {
-							replica.procsAllowed[sig] = true
-						}
-					}
+			case req := <-replica.reqs:
+				// Requests do not change the State of the Process, so they
+				// are not reported as handled messages.
+				didHandleMessage = false
+				switch req := req.(type) {
+				case statusRequest:
+					req.response <- replica.status()
+				case snapshotRequest:
+					req.response <- replica.snapshot()
+					isRunning = false
 				}
+				return
+			case m := <-replica.mch:
+				replica.handle(m)
 			}
-
-			replica.flush()
 		}()
 	}
 }
 
+// Start the Process of the Replica, without running the Replica, so that its
+// inputs can be handled one at a time by calling Handle. This is intended for
+// simulating Replicas deterministically, in a single goroutine (see package
+// sim). If there is a WAL, it is replayed, and an error is returned if it
+// cannot be read. Start must only be called once, and never for a Replica
+// that is run.
+func (replica *Replica) Start() error {
+	entries, err := replica.entries()
+	if err != nil {
+		return err
+	}
+	switch {
+	case replica.restored:
+		replica.proc.Resume()
+	case replica.startHeight(entries):
+		// The Process has been started at the Height in the WAL.
+	default:
+		replica.proc.Start()
+	}
+	replica.replay(entries)
+	replica.summarise()
+	replica.syncing = false
+	return nil
+}
+
+// Handle an input in the goroutine of the caller, in the same way that it is
+// handled by the Run loop, after the Replica has been started by calling
+// Start. The input is a process.Propose, process.Prevote, process.Precommit,
+// process.Timeout, process.TimeoutCertificate, or timer.Timeout (the Replica
+// uses the MessageType of a timer.Timeout to decide which timeout has
+// expired). If the Replica needs to sync, then its Syncer is called in the
+// goroutine of the caller too, with the given context, until the Replica has
+// caught up or the Syncer fails. Handle must not be called concurrently, or
+// for a Replica that is run.
+func (replica *Replica) Handle(ctx context.Context, m interface{}) {
+	replica.handle(m)
+	for replica.needsSync() {
+		cert, err := replica.opts.Syncer.Sync(ctx, replica.proc.CurrentHeight)
+		replica.handle(syncResult{cert: cert, err: err})
+	}
+	replica.summarise()
+	if replica.didHandleMessage != nil {
+		replica.didHandleMessage()
+	}
+}
+
+// handle an input from the Run loop, or from Handle, and then feed the
+// messages that are no longer from future Heights to the Process.
+func (replica *Replica) handle(m interface{}) {
+	defer replica.flush()
+
+	switch m := m.(type) {
+	case timer.Timeout:
+		if !replica.append(wal.Entry{Type: wal.EntryTypeTimeout, Value: m}) {
+			return
+		}
+		replica.timeout(m)
+	case process.Propose:
+		if !replica.filterHeight(m.Height) {
+			replica.reject(m, fmt.Errorf("previous height=%v", m.Height))
+			return
+		}
+		if err := replica.verifier.VerifyPropose(m); err != nil {
+			replica.reject(m, err)
+			return
+		}
+		replica.observeHeight(m.Height, m.From)
+		replica.mq.InsertPropose(m)
+	case process.Prevote:
+		if !replica.filterHeight(m.Height) {
+			replica.reject(m, fmt.Errorf("previous height=%v", m.Height))
+			return
+		}
+		if err := replica.verifier.VerifyPrevote(m); err != nil {
+			replica.reject(m, err)
+			return
+		}
+		replica.observeHeight(m.Height, m.From)
+		replica.mq.InsertPrevote(m)
+	case process.Precommit:
+		if !replica.filterHeight(m.Height) {
+			replica.reject(m, fmt.Errorf("previous height=%v", m.Height))
+			return
+		}
+		if err := replica.verifier.VerifyPrecommit(m); err != nil {
+			replica.reject(m, err)
+			return
+		}
+		replica.observeHeight(m.Height, m.From)
+		replica.mq.InsertPrecommit(m)
+	case process.Timeout:
+		if !replica.filterHeight(m.Height) {
+			replica.reject(m, fmt.Errorf("previous height=%v", m.Height))
+			return
+		}
+		if err := replica.verifier.VerifyTimeout(m); err != nil {
+			replica.reject(m, err)
+			return
+		}
+		replica.observeHeight(m.Height, m.From)
+		replica.mq.InsertTimeout(m)
+	case process.TimeoutCertificate:
+		if !replica.filterHeight(m.Height) {
+			replica.reject(m, fmt.Errorf("previous height=%v", m.Height))
+			return
+		}
+		// The Timeouts in the certificate are handled like any
+		// other Timeouts, so the Process moves to the next round
+		// once it has received Timeouts from a quorum.
+		for _, timeout := range m.Timeouts {
+			if timeout.Height != m.Height || timeout.Round != m.Round {
+				replica.reject(m, fmt.Errorf("timeout at height=%v, round=%v", timeout.Height, timeout.Round))
+				return
+			}
+			if err := replica.verifier.VerifyTimeout(timeout); err != nil {
+				replica.reject(m, err)
+				return
+			}
+		}
+		for _, timeout := range m.Timeouts {
+			replica.observeHeight(timeout.Height, timeout.From)
+			replica.mq.InsertTimeout(timeout)
+		}
+	case syncResult:
+		replica.handleSync(m)
+	case ResetHeightMessage:
+		// Messages from previous heights are not needed to rebuild
+		// the state of the process after the reset, so they are
+		// replaced by the reset in a single truncation. If the
+		// truncation fails, then the WAL is unchanged and the reset
+		// is dropped.
+		reset := wal.ResetHeight{
+			Height:      m.height,
+			Signatories: m.signatories,
+			Powers:      m.powers,
+		}
+		if !replica.truncate(wal.Entry{Type: wal.EntryTypeResetHeight, Value: reset}) {
+			return
+		}
+		replica.resetHeight(m)
+	}
+}
+
 // Propose adds a propose message to the replica. This message will be
 // asynchronously inserted into the replica's message queue asynchronously,
 // and consumed when the replica does not have any immediate task to do
@@ -180,6 +426,27 @@ func (replica *Replica) Precommit(ctx context.Context, precommit process.Precomm
 	}
 }
 
+// Timeout adds a timeout message to the replica. This message will be
+// asynchronously inserted into the replica's message queue asynchronously,
+// and consumed when the replica does not have any immediate task to do
+func (replica *Replica) Timeout(ctx context.Context, timeout process.Timeout) {
+	select {
+	case <-ctx.Done():
+	case replica.mch <- timeout:
+	}
+}
+
+// TimeoutCertificate adds the timeout messages in a timeout certificate to the
+// replica. This is used to catch up with other replicas that have moved to a
+// later round. The certificate is dropped if any of its timeout messages fails
+// verification.
+func (replica *Replica) TimeoutCertificate(ctx context.Context, cert process.TimeoutCertificate) {
+	select {
+	case <-ctx.Done():
+	case replica.mch <- cert:
+	}
+}
+
 // TimeoutPropose adds a propose timeout message to the replica. This message
 // will be filtered based on the replica's consensus height, and inserted
 // asynchronously into the replica's message queue. It will be consumed when
@@ -217,16 +484,22 @@ func (replica *Replica) TimeoutPrecommit(ctx context.Context, timeout timer.Time
 // be used when resynchronising the chain. If the given height is less than or
 // equal to the current height, nothing happens.
 //
+// If the given signatories are not empty, they replace the current
+// signatories. They are weighted by the given voting powers, or have equal
+// voting power if the given voting powers are nil, and they are scheduled by
+// the SchedulerFunc of the options (see Options.WithScheduler).
+//
 // NOTE: All messages that are currently in the message queue for heights less
 // than the given height will be dropped.
-func (replica *Replica) ResetHeight(ctx context.Context, newHeight process.Height, signatories []id.Signatory) {
-	if newHeight <= replica.proc.State.CurrentHeight {
+func (replica *Replica) ResetHeight(ctx context.Context, newHeight process.Height, signatories []id.Signatory, powers process.VotingPowers) {
+	if newHeight <= replica.CurrentHeight() {
 		return
 	}
 	message := ResetHeightMessage{
 		height:      newHeight,
 		signatories: signatories,
-		scheduler:   scheduler.NewRoundRobin(signatories),
+		powers:      powers,
+		scheduler:   replica.scheduler(newHeight, signatories),
 	}
 	select {
 	case <-ctx.Done():
@@ -234,14 +507,43 @@ func (replica *Replica) ResetHeight(ctx context.Context, newHeight process.Heigh
 	}
 }
 
-// State returns the current height, round and step of the underlying process.
-func (replica Replica) State() (process.Height, process.Round, process.Step) {
-	return replica.proc.CurrentHeight, replica.proc.CurrentRound, replica.proc.CurrentStep
+// Status returns a consistent snapshot of the State of the Replica. The
+// snapshot is taken by the Run loop between handling messages, so Status blocks
+// until the Replica is running and has handled the request, or until the
+// context is done.
+func (replica *Replica) Status(ctx context.Context) (Status, error) {
+	req := statusRequest{response: make(chan Status, 1)}
+	select {
+	case <-ctx.Done():
+		return Status{}, ctx.Err()
+	case replica.reqs <- req:
+	}
+	select {
+	case <-ctx.Done():
+		return Status{}, ctx.Err()
+	case status := <-req.response:
+		return status, nil
+	}
+}
+
+// State returns the current height, round and step of the underlying process,
+// as of the last message that was handled by the Run loop. It is safe to call
+// from any goroutine, and it does not block when the Replica is not running.
+func (replica *Replica) State() (process.Height, process.Round, process.Step) {
+	replica.summaryMu.RLock()
+	defer replica.summaryMu.RUnlock()
+
+	return replica.summary.height, replica.summary.round, replica.summary.step
 }
 
-// CurrentHeight returns the current height of the underlying process.
-func (replica Replica) CurrentHeight() process.Height {
-	return replica.proc.CurrentHeight
+// CurrentHeight returns the current height of the underlying process, as of
+// the last message that was handled by the Run loop. It is safe to call from
+// any goroutine.
+func (replica *Replica) CurrentHeight() process.Height {
+	replica.summaryMu.RLock()
+	defer replica.summaryMu.RUnlock()
+
+	return replica.summary.height
 }
 
 func (replica *Replica) filterHeight(height process.Height) bool {
@@ -249,12 +551,33 @@ func (replica *Replica) filterHeight(height process.Height) bool {
 }
 
 func (replica *Replica) flush() {
+	propose := func(propose process.Propose) {
+		if replica.append(wal.Entry{Type: wal.EntryTypePropose, Value: propose}) {
+			replica.proc.Propose(propose)
+		}
+	}
+	prevote := func(prevote process.Prevote) {
+		if replica.append(wal.Entry{Type: wal.EntryTypePrevote, Value: prevote}) {
+			replica.proc.Prevote(prevote)
+		}
+	}
+	precommit := func(precommit process.Precommit) {
+		if replica.append(wal.Entry{Type: wal.EntryTypePrecommit, Value: precommit}) {
+			replica.proc.Precommit(precommit)
+		}
+	}
+	timeout := func(timeout process.Timeout) {
+		if replica.append(wal.Entry{Type: wal.EntryTypeTimeoutMessage, Value: timeout}) {
+			replica.proc.Timeout(timeout)
+		}
+	}
 	for {
 		n := replica.mq.Consume(
 			replica.proc.CurrentHeight,
-			replica.proc.Propose,
-			replica.proc.Prevote,
-			replica.proc.Precommit,
+			propose,
+			prevote,
+			precommit,
+			timeout,
 			replica.procsAllowed,
 		)
 		if n == 0 {
@@ -263,8 +586,338 @@ func (replica *Replica) flush() {
 	}
 }
 
+func (replica *Replica) timeout(timeout timer.Timeout) {
+	switch timeout.MessageType {
+	case process.MessageTypePropose:
+		replica.proc.OnTimeoutPropose(timeout.Height, timeout.Round)
+	case process.MessageTypePrevote:
+		replica.proc.OnTimeoutPrevote(timeout.Height, timeout.Round)
+	case process.MessageTypePrecommit:
+		replica.proc.OnTimeoutPrecommit(timeout.Height, timeout.Round)
+	}
+}
+
+func (replica *Replica) resetHeight(m ResetHeightMessage) {
+	replica.proc.State = process.DefaultState().WithCurrentHeight(m.height)
+	replica.mq.DropMessagesBelowHeight(m.height)
+
+	// If the signatories change in the new height
+	if len(m.signatories) != 0 {
+		if m.powers != nil {
+			replica.proc.StartWithNewVotingPowers(m.powers, m.scheduler)
+		} else {
+			replica.proc.StartWithNewSignatories(uint64(len(m.signatories)), m.scheduler)
+		}
+		replica.allow(m.signatories)
+	}
+}
+
+// allow the signatories to send messages to the Replica, instead of the
+// current ones, and notify the Membership.
+func (replica *Replica) allow(signatories []id.Signatory) {
+	replica.procsAllowed = make(map[id.Signatory]bool, len(signatories))
+	for _, signatory := range signatories {
+		replica.procsAllowed[signatory] = true
+	}
+	replica.signatories = signatories
+	if replica.opts.Membership != nil {
+		replica.opts.Membership.SetAllowed(signatories)
+	}
+}
+
+// entries returns the entries in the WAL, and restores the messages that have
+// been broadcast at the current height, so that conflicting messages are never
+// broadcast. Without a WAL, there are no entries.
+func (replica *Replica) entries() ([]wal.Entry, error) {
+	if replica.opts.WAL == nil {
+		return nil, nil
+	}
+	entries, err := replica.opts.WAL.Entries()
+	if err != nil {
+		return nil, err
+	}
+	replica.restoreSent(entries)
+	return entries, nil
+}
+
+// restoreSent restores the messages that have been broadcast in the given
+// entries, so that they are broadcast again instead of conflicting messages.
+func (replica *Replica) restoreSent(entries []wal.Entry) {
+	if replica.sent != nil {
+		for _, entry := range entries {
+			switch m := entry.Value.(type) {
+			case process.Propose:
+				if entry.Type == wal.EntryTypeBroadcastPropose {
+					replica.sent[broadcastKey{process.MessageTypePropose, m.Height, m.Round}] = m
+				}
+			case process.Prevote:
+				if entry.Type == wal.EntryTypeBroadcastPrevote {
+					replica.sent[broadcastKey{process.MessageTypePrevote, m.Height, m.Round}] = m
+				}
+			case process.Precommit:
+				if entry.Type == wal.EntryTypeBroadcastPrecommit {
+					replica.sent[broadcastKey{process.MessageTypePrecommit, m.Height, m.Round}] = m
+				}
+			}
+		}
+	}
+}
+
+// startHeight starts the Process at the Height of the reset at the start of
+// the WAL, if there is one and it is above the current Height, and returns
+// true if it did. The WAL starts with a reset whenever it has been truncated,
+// either because the Height was reset or because a Value was committed, so the
+// Process does not start at a Height that it has already left.
+func (replica *Replica) startHeight(entries []wal.Entry) bool {
+	if len(entries) == 0 || entries[0].Type != wal.EntryTypeResetHeight {
+		return false
+	}
+	reset := entries[0].Value.(wal.ResetHeight)
+	if reset.Height <= replica.proc.CurrentHeight {
+		return false
+	}
+	replica.resetHeight(ResetHeightMessage{
+		height:      reset.Height,
+		signatories: reset.Signatories,
+		powers:      reset.Powers,
+		scheduler:   replica.scheduler(reset.Height, reset.Signatories),
+	})
+	// Without new signatories, the reset does not start the Process.
+	if len(reset.Signatories) == 0 {
+		replica.proc.Start()
+	}
+	return true
+}
+
+// replay the inputs in the given entries to the Process, in the order in which
+// they were originally fed to it. Broadcast entries are not replayed, because
+// the Process will broadcast them again (and the broadcaster makes sure that
+// they are identical to the originals).
+//
+// The WAL is not truncated while it is being replayed, because it would lose
+// the entries that have not been replayed yet. If a Value is committed during
+// the replay (because the Replica crashed before the WAL was truncated), then
+// the WAL is truncated after the replay instead, keeping the entries that
+// followed the commit.
+func (replica *Replica) replay(entries []wal.Entry) {
+	replica.replaying = true
+	replica.committed = nil
+	defer func() {
+		replica.replaying = false
+		replica.committed = nil
+	}()
+
+	var marker *wal.ResetHeight
+	since := 0
+	for i, entry := range entries {
+		switch entry.Type {
+		case wal.EntryTypePropose:
+			replica.proc.Propose(entry.Value.(process.Propose))
+		case wal.EntryTypePrevote:
+			replica.proc.Prevote(entry.Value.(process.Prevote))
+		case wal.EntryTypePrecommit:
+			replica.proc.Precommit(entry.Value.(process.Precommit))
+		case wal.EntryTypeTimeoutMessage:
+			replica.proc.Timeout(entry.Value.(process.Timeout))
+		case wal.EntryTypeTimeout:
+			replica.timeout(entry.Value.(timer.Timeout))
+		case wal.EntryTypeSync:
+			// The certificate was verified before it was appended.
+			replica.proc.Sync(entry.Value.(process.CommitCertificate))
+		case wal.EntryTypeResetHeight:
+			reset := entry.Value.(wal.ResetHeight)
+			if reset.Height <= replica.proc.CurrentHeight {
+				continue
+			}
+			replica.resetHeight(ResetHeightMessage{
+				height:      reset.Height,
+				signatories: reset.Signatories,
+				powers:      reset.Powers,
+				scheduler:   replica.scheduler(reset.Height, reset.Signatories),
+			})
+		}
+		if replica.committed != nil {
+			marker, since = replica.committed, i+1
+			replica.committed = nil
+		}
+	}
+	if marker != nil {
+		kept := append([]wal.Entry{{Type: wal.EntryTypeResetHeight, Value: *marker}}, entries[since:]...)
+		replica.truncate(kept...)
+	}
+}
+
+// append an entry to the WAL. It returns false if the entry could not be
+// appended, in which case the entry must not be acted upon. Without a WAL, it
+// always returns true.
+func (replica *Replica) append(entry wal.Entry) bool {
+	if replica.opts.WAL == nil {
+		return true
+	}
+	if err := replica.opts.WAL.Append(entry); err != nil {
+		replica.logError("appending to wal", err)
+		return false
+	}
+	return true
+}
+
+// truncate the WAL, replacing its entries with the given entries, and forget
+// the messages that have been broadcast in the removed entries. It returns
+// false if the WAL could not be truncated, in which case it is unchanged.
+// Without a WAL, it always returns true.
+func (replica *Replica) truncate(entries ...wal.Entry) bool {
+	if replica.opts.WAL == nil {
+		return true
+	}
+	if err := replica.opts.WAL.Truncate(entries...); err != nil {
+		replica.logError("truncating wal", err)
+		return false
+	}
+	if replica.sent != nil {
+		replica.sent = map[broadcastKey]interface{}{}
+		replica.restoreSent(entries)
+	}
+	return true
+}
+
+// scheduler returns the Scheduler for the signatories, starting at the given
+// Height. It is a round robin over the signatories, unless the options specify
+// a SchedulerFunc.
+func (replica *Replica) scheduler(height process.Height, signatories []id.Signatory) process.Scheduler {
+	if replica.opts.Scheduler != nil {
+		return replica.opts.Scheduler(height, signatories)
+	}
+	return scheduler.NewRoundRobin(signatories)
+}
+
+// reject notifies the Observer, if there is one, that the message was rejected
+// before it could be fed to the Process.
+func (replica *Replica) reject(msg interface{}, err error) {
+	if replica.opts.Observer != nil {
+		replica.opts.Observer.OnMessageRejected(msg, err)
+	}
+}
+
+func (replica *Replica) logError(msg string, err error) {
+	if replica.opts.Logger != nil {
+		replica.opts.Logger.Error(msg, zap.Error(err))
+	}
+}
+
 type ResetHeightMessage struct {
 	height      process.Height
 	signatories []id.Signatory
+	powers      process.VotingPowers
 	scheduler   process.Scheduler
 }
+
+// committer wraps the Committer given to the Replica, so that the allowed
+// signatories are updated whenever the Committer installs new voting powers.
+type committer struct {
+	replica   *Replica
+	committer process.Committer
+}
+
+// Commit calls the Committer, and then replaces the entries in the WAL with a
+// reset to the next Height, with the signatories and voting powers of the next
+// Height, because entries from the committed Height are not needed to rebuild
+// the state of the process.
+//
+// If the Replica crashes after the Committer has been called but before the
+// WAL has been truncated (or if the truncation fails), then the committed
+// Height is replayed from the WAL after a restart, and the Committer is called
+// again with the same CommitCertificate. Committers must tolerate this.
+func (c committer) Commit(cert process.CommitCertificate) (process.VotingPowers, process.Scheduler) {
+	powers, scheduler := c.committer.Commit(cert)
+
+	if powers != nil {
+		signatories := make([]id.Signatory, 0, len(powers))
+		for signatory, power := range powers {
+			if power > 0 {
+				signatories = append(signatories, signatory)
+			}
+		}
+		// Voting powers are not ordered, so the signatories are sorted to
+		// make Snapshots deterministic.
+		sort.Slice(signatories, func(i, j int) bool {
+			return bytes.Compare(signatories[i][:], signatories[j][:]) < 0
+		})
+		c.replica.allow(signatories)
+	}
+
+	marker := wal.ResetHeight{
+		Height:      cert.Height + 1,
+		Signatories: c.replica.signatories,
+		Powers:      powers,
+	}
+	if marker.Powers == nil {
+		marker.Powers = c.replica.proc.VotingPowers()
+	}
+	if c.replica.replaying {
+		c.replica.committed = &marker
+	} else {
+		c.replica.truncate(wal.Entry{Type: wal.EntryTypeResetHeight, Value: marker})
+	}
+	return powers, scheduler
+}
+
+// broadcastKey identifies a message that can only be broadcast once.
+type broadcastKey struct {
+	messageType process.MessageType
+	height      process.Height
+	round       process.Round
+}
+
+// broadcaster wraps the Broadcaster given to the Replica, so that every message
+// is appended to the WAL before it is broadcast. If a message has already been
+// broadcast for the same type, height, and round (for example, before a crash),
+// then the original message is broadcast again instead.
+type broadcaster struct {
+	replica     *Replica
+	broadcaster process.Broadcaster
+}
+
+func (b broadcaster) BroadcastPropose(propose process.Propose) {
+	key := broadcastKey{process.MessageTypePropose, propose.Height, propose.Round}
+	if sent, ok := b.replica.sent[key]; ok {
+		b.broadcaster.BroadcastPropose(sent.(process.Propose))
+		return
+	}
+	if !b.replica.append(wal.Entry{Type: wal.EntryTypeBroadcastPropose, Value: propose}) {
+		return
+	}
+	b.replica.sent[key] = propose
+	b.broadcaster.BroadcastPropose(propose)
+}
+
+func (b broadcaster) BroadcastPrevote(prevote process.Prevote) {
+	key := broadcastKey{process.MessageTypePrevote, prevote.Height, prevote.Round}
+	if sent, ok := b.replica.sent[key]; ok {
+		b.broadcaster.BroadcastPrevote(sent.(process.Prevote))
+		return
+	}
+	if !b.replica.append(wal.Entry{Type: wal.EntryTypeBroadcastPrevote, Value: prevote}) {
+		return
+	}
+	b.replica.sent[key] = prevote
+	b.broadcaster.BroadcastPrevote(prevote)
+}
+
+func (b broadcaster) BroadcastPrecommit(precommit process.Precommit) {
+	key := broadcastKey{process.MessageTypePrecommit, precommit.Height, precommit.Round}
+	if sent, ok := b.replica.sent[key]; ok {
+		b.broadcaster.BroadcastPrecommit(sent.(process.Precommit))
+		return
+	}
+	if !b.replica.append(wal.Entry{Type: wal.EntryTypeBroadcastPrecommit, Value: precommit}) {
+		return
+	}
+	b.replica.sent[key] = precommit
+	b.broadcaster.BroadcastPrecommit(precommit)
+}
+
+// BroadcastTimeout does not append the Timeout to the WAL, because Timeouts do
+// not have Values and so they cannot conflict with each other.
+func (b broadcaster) BroadcastTimeout(timeout process.Timeout) {
+	b.broadcaster.BroadcastTimeout(timeout)
+}
@@ -3,16 +3,22 @@ package replica_test
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
 	"math/rand"
 	"os"
-	"strconv"
+	"path/filepath"
 	"sync"
+	"testing/quick"
 	"time"
 
 	"github.com/renproject/hyperdrive/process"
 	"github.com/renproject/hyperdrive/process/processutil"
 	"github.com/renproject/hyperdrive/replica"
+	"github.com/renproject/hyperdrive/scenario"
+	"github.com/renproject/hyperdrive/scheduler"
+	"github.com/renproject/hyperdrive/sim"
 	"github.com/renproject/hyperdrive/timer"
+	"github.com/renproject/hyperdrive/wal"
 	"github.com/renproject/id"
 	"github.com/renproject/surge"
 
@@ -23,7 +29,7 @@ import (
 var _ = Describe("Replica", func() {
 	setup := func(
 		seed int64,
-		f, n, completion uint8,
+		size, f, n, completion uint8,
 		targetHeight process.Height,
 		mq *[]Message,
 		commits *map[uint8]map[process.Height]process.Value,
@@ -31,7 +37,7 @@ var _ = Describe("Replica", func() {
 		validationFn func(process.Value) bool,
 	) (
 		*rand.Rand,
-		Scenario,
+		recording,
 		bool,
 		*sync.Mutex,
 		chan struct{},
@@ -41,30 +47,45 @@ var _ = Describe("Replica", func() {
 		[]context.CancelFunc,
 		context.CancelFunc,
 	) {
-		// create private keys for the signatories participating in consensus
-		// rounds, and get their signatories
-		privKeys := make([]*id.PrivKey, 3*f+1)
-		signatories := make([]id.Signatory, 3*f+1)
+		// create private keys for the size signatories participating in
+		// consensus rounds, and get their signatories
+		privKeys := make([]*id.PrivKey, size)
+		signatories := make([]id.Signatory, size)
 		for i := range privKeys {
 			privKeys[i] = id.NewPrivKey()
 			signatories[i] = privKeys[i].Signatory()
 			(*commits)[uint8(i)] = make(map[process.Height]process.Value)
 		}
 
-		// construct the scenario for this test case
-		scenario := Scenario{seed, f, n, completion, signatories, []Message{}}
+		// construct the recording of this test case
+		rec := recording{
+			Scenario: scenario.Scenario{
+				Seed:        seed,
+				Signatories: signatories,
+				Byzantine:   []int{},
+				RoundWindow: replica.DefaultRoundWindow,
+				Faults:      []scenario.Fault{},
+				Steps:       []scenario.Step{},
+			},
+			completion: completion,
+			start:      time.Now(),
+		}
+		if proposerFn != nil || validationFn != nil {
+			for i := 0; i < int(f); i++ {
+				rec.Byzantine = append(rec.Byzantine, i)
+			}
+		}
 
-		// whether we are running the test in replay mode
-		replayMode := readBoolEnvVar("REPLAY_MODE")
+		// whether we are replaying the recording of a failed test
+		replayMode := os.Getenv("REPLAY_SCENARIO") != ""
 
-		// fetch from the dump file in that case
+		// read the recording in that case
 		if replayMode {
-			scenario = readFromFile("failure.dump")
-			seed = scenario.seed
-			f = scenario.f
-			n = scenario.n
-			completion = scenario.completion
-			signatories = scenario.signatories
+			s, err := scenario.ReadFile(os.Getenv("REPLAY_SCENARIO"))
+			Expect(err).ToNot(HaveOccurred())
+			rec.Scenario = s
+			seed = s.Seed
+			signatories = s.Signatories
 		}
 
 		// random number generator
@@ -153,9 +174,13 @@ var _ = Describe("Replica", func() {
 						return true
 					},
 				},
+				// Verifier
+				nil,
+				// Signer
+				nil,
 				// Committer
 				processutil.CommitterCallback{
-					Callback: func(height process.Height, value process.Value) (uint64, process.Scheduler) {
+					Callback: func(height process.Height, value process.Value) (process.VotingPowers, process.Scheduler) {
 						// add to the map of commits
 						mqMutex.Lock()
 						(*commits)[replicaIndex][height] = value
@@ -165,7 +190,7 @@ var _ = Describe("Replica", func() {
 						if height == targetHeight {
 							completionSignal <- true
 						}
-						return 0, nil
+						return nil, nil
 					},
 				},
 				// Catcher
@@ -173,6 +198,9 @@ var _ = Describe("Replica", func() {
 				// Broadcaster
 				processutil.BroadcasterCallbacks{
 					BroadcastProposeCallback: func(propose process.Propose) {
+						if err := propose.Sign(privKeys[replicaIndex]); err != nil {
+							panic(fmt.Errorf("signing propose: %v", err))
+						}
 						for j := uint8(0); j < n; j++ {
 							mqMutex.Lock()
 							*mq = append(*mq, Message{
@@ -184,6 +212,9 @@ var _ = Describe("Replica", func() {
 						}
 					},
 					BroadcastPrevoteCallback: func(prevote process.Prevote) {
+						if err := prevote.Sign(privKeys[replicaIndex]); err != nil {
+							panic(fmt.Errorf("signing prevote: %v", err))
+						}
 						for j := uint8(0); j < n; j++ {
 							mqMutex.Lock()
 							*mq = append(*mq, Message{
@@ -195,6 +226,9 @@ var _ = Describe("Replica", func() {
 						}
 					},
 					BroadcastPrecommitCallback: func(precommit process.Precommit) {
+						if err := precommit.Sign(privKeys[replicaIndex]); err != nil {
+							panic(fmt.Errorf("signing precommit: %v", err))
+						}
 						for j := uint8(0); j < n; j++ {
 							mqMutex.Lock()
 							*mq = append(*mq, Message{
@@ -205,6 +239,20 @@ var _ = Describe("Replica", func() {
 							mqMutex.Unlock()
 						}
 					},
+					BroadcastTimeoutCallback: func(timeout process.Timeout) {
+						if err := timeout.Sign(privKeys[replicaIndex]); err != nil {
+							panic(fmt.Errorf("signing timeout: %v", err))
+						}
+						for j := uint8(0); j < n; j++ {
+							mqMutex.Lock()
+							*mq = append(*mq, Message{
+								to:          j,
+								messageType: 7,
+								value:       timeout,
+							})
+							mqMutex.Unlock()
+						}
+					},
 				},
 				// Flusher
 				func() {
@@ -222,11 +270,11 @@ var _ = Describe("Replica", func() {
 			replicaCtxs[i], replicaCtxCancels[i] = context.WithCancel(ctx)
 		}
 
-		return r, scenario, replayMode, mqMutex, mqSignal, completionSignal, replicas, replicaCtxs, replicaCtxCancels, cancel
+		return r, rec, replayMode, mqMutex, mqSignal, completionSignal, replicas, replicaCtxs, replicaCtxCancels, cancel
 	}
 
 	play := func(
-		scenario *Scenario,
+		rec *recording,
 		timeout time.Duration,
 		mq *[]Message,
 		mqMutex *sync.Mutex,
@@ -235,18 +283,18 @@ var _ = Describe("Replica", func() {
 		replicas []*replica.Replica,
 		killedReplicas *map[uint8]bool,
 		successFn func(),
-		failureFn func(*Scenario),
-		inspectFn func(*Scenario),
+		failureFn func(*recording),
+		inspectFn func(*recording),
 	) {
 		isRunning := true
 		for timeoutSignal := time.After(timeout); isRunning; {
 			select {
 			case <-timeoutSignal:
-				failureFn(scenario)
+				failureFn(rec)
 				isRunning = false
 			case <-mqSignal:
 				// the consensus target has been achieved on every replica
-				if len(completionSignal) == int(scenario.completion) {
+				if len(completionSignal) == int(rec.completion) {
 					mqMutex.Lock()
 					successFn()
 					mqMutex.Unlock()
@@ -279,7 +327,7 @@ var _ = Describe("Replica", func() {
 				}
 
 				// append the message to message history
-				scenario.messages = append(scenario.messages, m)
+				rec.record(m)
 
 				// is the recipient replica killed
 				mqMutex.Lock()
@@ -297,6 +345,8 @@ var _ = Describe("Replica", func() {
 						replica.Prevote(context.Background(), value)
 					case process.Precommit:
 						replica.Precommit(context.Background(), value)
+					case process.Timeout:
+						replica.Timeout(context.Background(), value)
 					case timer.Timeout:
 						switch m.messageType {
 						case 4:
@@ -313,7 +363,7 @@ var _ = Describe("Replica", func() {
 					}
 
 					mqMutex.Lock()
-					inspectFn(scenario)
+					inspectFn(rec)
 					mqMutex.Unlock()
 				} else {
 					mqSignal <- struct{}{}
@@ -323,14 +373,14 @@ var _ = Describe("Replica", func() {
 	}
 
 	replay := func(
-		scenario *Scenario,
+		rec *recording,
 		mqMutex *sync.Mutex,
 		mqSignal chan struct{},
 		completionSignal chan bool,
 		replicas []*replica.Replica,
 		killedReplicas *map[uint8]bool,
 		successFn func(),
-		inspectFn func(*Scenario),
+		inspectFn func(*recording),
 	) {
 		// dummy goroutine to keep consuming the mqSignal
 		go func() {
@@ -339,28 +389,39 @@ var _ = Describe("Replica", func() {
 		}()
 
 		// handle every message in the messages history
-		for _, message := range scenario.messages {
-			if _, isKilled := (*killedReplicas)[uint8(message.to)]; !isKilled {
+		for _, step := range rec.Steps {
+			if _, isKilled := (*killedReplicas)[uint8(step.To)]; !isKilled {
 				time.Sleep(1 * time.Millisecond)
-				recipient := replicas[message.to]
-				switch value := message.value.(type) {
+				recipient := replicas[step.To]
+				switch value := step.Input.(type) {
 				case process.Propose:
 					recipient.Propose(context.Background(), value)
 				case process.Prevote:
 					recipient.Prevote(context.Background(), value)
 				case process.Precommit:
 					recipient.Precommit(context.Background(), value)
+				case process.Timeout:
+					recipient.Timeout(context.Background(), value)
+				case timer.Timeout:
+					switch value.MessageType {
+					case process.MessageTypePropose:
+						recipient.TimeoutPropose(context.Background(), value)
+					case process.MessageTypePrevote:
+						recipient.TimeoutPrevote(context.Background(), value)
+					case process.MessageTypePrecommit:
+						recipient.TimeoutPrecommit(context.Background(), value)
+					}
 				default:
-					panic(fmt.Errorf("non-exhaustive pattern: message.value has type %T", value))
+					panic(fmt.Errorf("non-exhaustive pattern: step.Input has type %T", value))
 				}
 
 				mqMutex.Lock()
-				inspectFn(scenario)
+				inspectFn(rec)
 				mqMutex.Unlock()
 			}
 
 			// exit replay if the consensus target has been achieved
-			if len(completionSignal) == int(scenario.completion) {
+			if len(completionSignal) == int(rec.completion) {
 				mqMutex.Lock()
 				successFn()
 				mqMutex.Unlock()
@@ -370,139 +431,45 @@ var _ = Describe("Replica", func() {
 	}
 
 	Context("with sufficient replicas online for consensus to progress", func() {
-		Context("with 3f+1 replicas online", func() {
-			It("should be able to reach consensus", func() {
-				// randomness seed
-				seed := time.Now().UnixNano()
-				// maximum number of adversaries that the consensus network can tolerate
-				f := uint8(3)
-				// number of replicas online
-				n := 3*f + 1
-				// number of replicas to signal completion
-				completion := n
-				// target height of consensus to mark the test as succeeded
-				targetHeight := process.Height(30)
-				// dynamic slice to hold the messages being sent between replicas
-				mq := []Message{}
-				// commits from replicas
-				commits := make(map[uint8]map[process.Height]process.Value)
-				// map to keep a record of which replica was killed
-				killedReplicas := make(map[uint8]bool)
-
-				// setup the test scenario
-				_, scenario, replayMode, mqMutex, mqSignal, completionSignal, replicas, replicaCtxs, replicaCtxCancels, cancel := setup(seed, f, n, completion, targetHeight, &mq, &commits, nil, nil)
-
-				// Run all of the replicas in independent background goroutines
-				for i := range replicas {
-					go replicas[i].Run(replicaCtxs[i])
-				}
-
-				// callback function called on test failure
-				failureFn := func(scenario *Scenario) {
-					cancel()
-					dumpToFile("failure.dump", *scenario)
-					Fail("test failed to complete within the expected timeframe")
-				}
-
-				// callback function called on test success
-				successFn := func() {
-					// cancel the replica contexts
-					for i := range replicaCtxs {
-						replicaCtxCancels[i]()
-					}
-
-					// fetch the first replica's commits
-					referenceCommits := commits[0]
-
-					// ensure that all replicas have the same commits
-					for j := uint8(0); j < n; j++ {
-						for h := process.Height(1); h <= targetHeight; {
-							Expect(commits[j][h]).To(Equal(referenceCommits[h]))
-							h++
-						}
-					}
-				}
+		// simulate runs the first n replicas of a committee of 3f+1 on a
+		// virtual clock (see sim.ReplicaSimulation), until they have all
+		// committed a value at the target height, and expects them to have
+		// committed the same values
+		simulate := func(f, n int) {
+			// randomness seed, which reproduces the simulation exactly
+			seed := time.Now().UnixNano()
+			// target height of consensus to mark the test as succeeded
+			targetHeight := process.Height(30)
 
-				// callback function called on every message processed
-				inspectFn := func(scenario *Scenario) {}
+			opts := sim.DefaultOptions().
+				WithSeed(seed).
+				WithTimeout(500 * time.Millisecond)
+			s := sim.NewReplicaSimulation(opts, 3*f+1, n)
+			Expect(s.RunUntilHeight(targetHeight, time.Hour)).To(Succeed(), "seed=%v", seed)
 
-				if !replayMode {
-					timeout := 15 * time.Second
+			// fetch the first replica's commits
+			referenceCommits := s.Commits(0)
 
-					play(&scenario, timeout, &mq, mqMutex, mqSignal, completionSignal, replicas, &killedReplicas, successFn, failureFn, inspectFn)
+			// ensure that all replicas have the same commits
+			for j := 0; j < n; j++ {
+				commits := s.Commits(j)
+				for h := process.Height(1); h <= targetHeight; h++ {
+					Expect(commits[h]).To(Equal(referenceCommits[h]), "seed=%v", seed)
 				}
+			}
+		}
 
-				if replayMode {
-					replay(&scenario, mqMutex, mqSignal, completionSignal, replicas, &killedReplicas, successFn, inspectFn)
-				}
+		Context("with 3f+1 replicas online", func() {
+			It("should be able to reach consensus", func() {
+				f := 3
+				simulate(f, 3*f+1)
 			})
 		})
 
 		Context("with 2f+1 replicas online", func() {
 			It("should be able to reach consensus", func() {
-				// randomness seed
-				seed := time.Now().UnixNano()
-				// maximum number of adversaries that the consensus network can tolerate
-				f := uint8(3)
-				// number of replicas online
-				n := 2*f + 1
-				// number of replicas that should signal completion
-				completion := n
-				// target height of consensus to mark the test as succeeded
-				targetHeight := process.Height(30)
-				// dynamic slice to hold the messages being sent between replicas
-				mq := []Message{}
-				// commits from replicas
-				commits := make(map[uint8]map[process.Height]process.Value)
-				// map to keep a record of which replica was killed
-				killedReplicas := make(map[uint8]bool)
-
-				// setup the test scenario
-				_, scenario, replayMode, mqMutex, mqSignal, completionSignal, replicas, replicaCtxs, replicaCtxCancels, cancel := setup(seed, f, n, completion, targetHeight, &mq, &commits, nil, nil)
-
-				// Run all of the replicas in independent background goroutines.
-				for i := range replicas {
-					go replicas[i].Run(replicaCtxs[i])
-				}
-
-				// callback function called on test failure
-				failureFn := func(scenario *Scenario) {
-					cancel()
-					dumpToFile("failure.dump", *scenario)
-					Fail("test failed to complete within the expected timeframe")
-				}
-
-				// callback function called on test success
-				successFn := func() {
-					// cancel the replica contexts
-					for i := range replicaCtxs {
-						replicaCtxCancels[i]()
-					}
-
-					// fetch the first replica's commits
-					referenceCommits := commits[0]
-
-					// ensure that all replicas have the same commits
-					for j := uint8(0); j < n; j++ {
-						for h := process.Height(1); h <= targetHeight; {
-							Expect(commits[j][h]).To(Equal(referenceCommits[h]))
-							h++
-						}
-					}
-				}
-
-				// callback function called on every message processed
-				inspectFn := func(scenario *Scenario) {}
-
-				if !replayMode {
-					timeout := 35 * time.Second
-
-					play(&scenario, timeout, &mq, mqMutex, mqSignal, completionSignal, replicas, &killedReplicas, successFn, failureFn, inspectFn)
-				}
-
-				if replayMode {
-					replay(&scenario, mqMutex, mqSignal, completionSignal, replicas, &killedReplicas, successFn, inspectFn)
-				}
+				f := 3
+				simulate(f, 2*f+1)
 			})
 		})
 	})
@@ -527,7 +494,7 @@ var _ = Describe("Replica", func() {
 			killedReplicas := make(map[uint8]bool)
 
 			// setup the test scenario
-			r, scenario, replayMode, mqMutex, mqSignal, completionSignal, replicas, replicaCtxs, replicaCtxCancels, cancel := setup(seed, f, n, completion, targetHeight, &mq, &commits, nil, nil)
+			r, rec, replayMode, mqMutex, mqSignal, completionSignal, replicas, replicaCtxs, replicaCtxCancels, cancel := setup(seed, 3*f+1, f, n, completion, targetHeight, &mq, &commits, nil, nil)
 
 			// Run all of the replicas in independent background goroutines
 			for i := range replicas {
@@ -535,9 +502,9 @@ var _ = Describe("Replica", func() {
 			}
 
 			// callback function called on test failure
-			failureFn := func(scenario *Scenario) {
+			failureFn := func(rec *recording) {
 				cancel()
-				dumpToFile("failure.dump", *scenario)
+				writeRecording(rec)
 				Fail("test failed to complete within the expected timeframe")
 			}
 
@@ -571,7 +538,7 @@ var _ = Describe("Replica", func() {
 			}
 
 			// callback function called on every message processed
-			inspectFn := func(scenario *Scenario) {
+			inspectFn := func(rec *recording) {
 				// we wish to kill at the most f replicas
 				if len(killedReplicas) < int(f) {
 					if r.Float64() < 0.01 {
@@ -591,11 +558,11 @@ var _ = Describe("Replica", func() {
 			if !replayMode {
 				timeout := 30 * time.Second
 
-				play(&scenario, timeout, &mq, mqMutex, mqSignal, completionSignal, replicas, &killedReplicas, successFn, failureFn, inspectFn)
+				play(&rec, timeout, &mq, mqMutex, mqSignal, completionSignal, replicas, &killedReplicas, successFn, failureFn, inspectFn)
 			}
 
 			if replayMode {
-				replay(&scenario, mqMutex, mqSignal, completionSignal, replicas, &killedReplicas, successFn, inspectFn)
+				replay(&rec, mqMutex, mqSignal, completionSignal, replicas, &killedReplicas, successFn, inspectFn)
 			}
 		})
 	})
@@ -632,7 +599,7 @@ var _ = Describe("Replica", func() {
 					return false
 				}
 				// setup the test scenario
-				_, scenario, replayMode, mqMutex, mqSignal, completionSignal, replicas, replicaCtxs, replicaCtxCancels, cancel := setup(seed, f, n, completion, targetHeight, &mq, &commits, proposerFn, validationFn)
+				_, rec, replayMode, mqMutex, mqSignal, completionSignal, replicas, replicaCtxs, replicaCtxCancels, cancel := setup(seed, 3*f+1, f, n, completion, targetHeight, &mq, &commits, proposerFn, validationFn)
 
 				// Run all of the replicas in independent background goroutines
 				for i := range replicas {
@@ -640,9 +607,9 @@ var _ = Describe("Replica", func() {
 				}
 
 				// callback function called on test failure
-				failureFn := func(scenario *Scenario) {
+				failureFn := func(rec *recording) {
 					cancel()
-					dumpToFile("failure.dump", *scenario)
+					writeRecording(rec)
 					Fail("test failed to complete within the expected timeframe")
 				}
 
@@ -666,16 +633,16 @@ var _ = Describe("Replica", func() {
 				}
 
 				// callback function called on every message processed
-				inspectFn := func(scenario *Scenario) {}
+				inspectFn := func(rec *recording) {}
 
 				if !replayMode {
 					timeout := 45 * time.Second
 
-					play(&scenario, timeout, &mq, mqMutex, mqSignal, completionSignal, replicas, &killedReplicas, successFn, failureFn, inspectFn)
+					play(&rec, timeout, &mq, mqMutex, mqSignal, completionSignal, replicas, &killedReplicas, successFn, failureFn, inspectFn)
 				}
 
 				if replayMode {
-					replay(&scenario, mqMutex, mqSignal, completionSignal, replicas, &killedReplicas, successFn, inspectFn)
+					replay(&rec, mqMutex, mqSignal, completionSignal, replicas, &killedReplicas, successFn, inspectFn)
 				}
 			})
 		})
@@ -701,7 +668,7 @@ var _ = Describe("Replica", func() {
 			killedReplicas := make(map[uint8]bool)
 
 			// setup the test scenario
-			_, scenario, replayMode, mqMutex, mqSignal, completionSignal, replicas, replicaCtxs, replicaCtxCancels, cancel := setup(seed, f, n, completion, targetHeight, &mq, &commits, nil, nil)
+			_, rec, replayMode, mqMutex, mqSignal, completionSignal, replicas, replicaCtxs, replicaCtxCancels, cancel := setup(seed, 3*f+1, f, n, completion, targetHeight, &mq, &commits, nil, nil)
 
 			// Run all of the replicas in independent background goroutines
 			for i := range replicas {
@@ -709,7 +676,7 @@ var _ = Describe("Replica", func() {
 			}
 
 			// callback function called on test failure
-			failureFn := func(scenario *Scenario) {
+			failureFn := func(rec *recording) {
 				// cancel the replica contexts
 				for i := range replicaCtxs {
 					replicaCtxCancels[i]()
@@ -731,16 +698,16 @@ var _ = Describe("Replica", func() {
 			}
 
 			// callback function called on every message processed
-			inspectFn := func(scenario *Scenario) {}
+			inspectFn := func(rec *recording) {}
 
 			if !replayMode {
 				timeout := 10 * time.Second
 
-				play(&scenario, timeout, &mq, mqMutex, mqSignal, completionSignal, replicas, &killedReplicas, successFn, failureFn, inspectFn)
+				play(&rec, timeout, &mq, mqMutex, mqSignal, completionSignal, replicas, &killedReplicas, successFn, failureFn, inspectFn)
 			}
 
 			if replayMode {
-				replay(&scenario, mqMutex, mqSignal, completionSignal, replicas, &killedReplicas, successFn, inspectFn)
+				replay(&rec, mqMutex, mqSignal, completionSignal, replicas, &killedReplicas, successFn, inspectFn)
 			}
 		})
 	})
@@ -767,7 +734,7 @@ var _ = Describe("Replica", func() {
 			killedReplicas := make(map[uint8]bool)
 
 			// setup the test scenario
-			r, scenario, replayMode, mqMutex, mqSignal, completionSignal, replicas, replicaCtxs, replicaCtxCancels, cancel := setup(seed, f, n, completion, targetHeight, &mq, &commits, nil, nil)
+			r, rec, replayMode, mqMutex, mqSignal, completionSignal, replicas, replicaCtxs, replicaCtxCancels, cancel := setup(seed, 3*f+1, f, n, completion, targetHeight, &mq, &commits, nil, nil)
 
 			// Run all of the replicas in independent background goroutines
 			for i := range replicas {
@@ -775,7 +742,7 @@ var _ = Describe("Replica", func() {
 			}
 
 			// callback function called on test failure
-			failureFn := func(scenario *Scenario) {
+			failureFn := func(rec *recording) {
 				// cancel the replica contexts
 				for i := range replicaCtxs {
 					replicaCtxCancels[i]()
@@ -816,7 +783,7 @@ var _ = Describe("Replica", func() {
 			}
 
 			// callback function called on every message processed
-			inspectFn := func(scenario *Scenario) {
+			inspectFn := func(rec *recording) {
 				// we wish to kill only one replica
 				if len(killedReplicas) < 1 {
 					if r.Float64() < 0.003 {
@@ -837,267 +804,1030 @@ var _ = Describe("Replica", func() {
 			if !replayMode {
 				timeout := 30 * time.Second
 
-				play(&scenario, timeout, &mq, mqMutex, mqSignal, completionSignal, replicas, &killedReplicas, successFn, failureFn, inspectFn)
+				play(&rec, timeout, &mq, mqMutex, mqSignal, completionSignal, replicas, &killedReplicas, successFn, failureFn, inspectFn)
 			}
 
 			if replayMode {
-				replay(&scenario, mqMutex, mqSignal, completionSignal, replicas, &killedReplicas, successFn, inspectFn)
+				replay(&rec, mqMutex, mqSignal, completionSignal, replicas, &killedReplicas, successFn, inspectFn)
 			}
 		})
 	})
-})
 
-// Scenario describes a test scenario with test configuration and message history
-type Scenario struct {
-	seed        int64
-	f           uint8
-	n           uint8
-	completion  uint8
-	signatories []id.Signatory
-	messages    []Message
-}
+	Context("with committees that are not of size 3f+1", func() {
+		for _, size := range []uint8{5, 6, 8} {
+			size := size
+
+			Context(fmt.Sprintf("with %v signatories, and n-f replicas online", size), func() {
+				It("should be able to reach consensus", func() {
+					// randomness seed
+					seed := time.Now().UnixNano()
+					// maximum number of adversaries that the consensus network can tolerate
+					f := (size - 1) / 3
+					// number of replicas online
+					n := size - f
+					// number of replicas to signal completion
+					completion := n
+					// target height of consensus to mark the test as succeeded
+					targetHeight := process.Height(10)
+					// dynamic slice to hold the messages being sent between replicas
+					mq := []Message{}
+					// commits from replicas
+					commits := make(map[uint8]map[process.Height]process.Value)
+					// map to keep a record of which replica was killed
+					killedReplicas := make(map[uint8]bool)
+
+					// setup the test scenario
+					_, rec, replayMode, mqMutex, mqSignal, completionSignal, replicas, replicaCtxs, replicaCtxCancels, cancel := setup(seed, size, f, n, completion, targetHeight, &mq, &commits, nil, nil)
+
+					// Run all of the replicas in independent background goroutines
+					for i := range replicas {
+						go replicas[i].Run(replicaCtxs[i])
+					}
 
-// SizeHint implements surge SizeHinter for Scenario
-func (s Scenario) SizeHint() int {
-	return surge.SizeHint(s.seed) +
-		surge.SizeHint(s.f) +
-		surge.SizeHint(s.n) +
-		surge.SizeHint(s.completion) +
-		surge.SizeHint(s.signatories) +
-		surge.SizeHint(s.messages)
-}
+					// callback function called on test failure
+					failureFn := func(rec *recording) {
+						cancel()
+						writeRecording(rec)
+						Fail("test failed to complete within the expected timeframe")
+					}
 
-// Marshal implements surge Marshaler for Scenario
-func (s Scenario) Marshal(buf []byte, rem int) ([]byte, int, error) {
-	buf, rem, err := surge.Marshal(s.seed, buf, rem)
-	if err != nil {
-		return buf, rem, fmt.Errorf("marshaling seed=%v: %v", s.seed, err)
-	}
-	buf, rem, err = surge.Marshal(s.f, buf, rem)
-	if err != nil {
-		return buf, rem, fmt.Errorf("marshaling f=%v: %v", s.f, err)
-	}
-	buf, rem, err = surge.Marshal(s.n, buf, rem)
-	if err != nil {
-		return buf, rem, fmt.Errorf("marshaling n=%v: %v", s.n, err)
-	}
-	buf, rem, err = surge.Marshal(s.completion, buf, rem)
-	if err != nil {
-		return buf, rem, fmt.Errorf("marshaling completion=%v: %v", s.completion, err)
-	}
-	buf, rem, err = surge.Marshal(s.signatories, buf, rem)
-	if err != nil {
-		return buf, rem, fmt.Errorf("marshaling signatories=%v: %v", s.signatories, err)
-	}
-	buf, rem, err = surge.Marshal(s.messages, buf, rem)
-	if err != nil {
-		return buf, rem, fmt.Errorf("marshaling messages=%v: %v", s.messages, err)
-	}
+					// callback function called on test success
+					successFn := func() {
+						// cancel the replica contexts
+						for i := range replicaCtxs {
+							replicaCtxCancels[i]()
+						}
 
-	return buf, rem, nil
-}
+						// fetch the first replica's commits
+						referenceCommits := commits[0]
 
-// Unmarshal implements surge Unmarshaler for Scenario
-func (s *Scenario) Unmarshal(buf []byte, rem int) ([]byte, int, error) {
-	buf, rem, err := surge.Unmarshal(&s.seed, buf, rem)
-	if err != nil {
-		return buf, rem, fmt.Errorf("unmarshaling seed: %v", err)
-	}
-	buf, rem, err = surge.Unmarshal(&s.f, buf, rem)
-	if err != nil {
-		return buf, rem, fmt.Errorf("unmarshaling f: %v", err)
-	}
-	buf, rem, err = surge.Unmarshal(&s.n, buf, rem)
-	if err != nil {
-		return buf, rem, fmt.Errorf("unmarshaling n: %v", err)
-	}
-	buf, rem, err = surge.Unmarshal(&s.completion, buf, rem)
-	if err != nil {
-		return buf, rem, fmt.Errorf("unmarshaling completion: %v", err)
-	}
-	buf, rem, err = surge.Unmarshal(&s.signatories, buf, rem)
-	if err != nil {
-		return buf, rem, fmt.Errorf("unmarshaling signatories: %v", err)
-	}
-	buf, rem, err = surge.Unmarshal(&s.messages, buf, rem)
-	if err != nil {
-		return buf, rem, fmt.Errorf("unmarshaling messages: %v", err)
-	}
+						// ensure that all replicas have the same commits
+						for j := uint8(0); j < n; j++ {
+							for h := process.Height(1); h <= targetHeight; {
+								Expect(commits[j][h]).To(Equal(referenceCommits[h]))
+								h++
+							}
+						}
+					}
 
-	return buf, rem, nil
-}
+					// callback function called on every message processed
+					inspectFn := func(rec *recording) {}
 
-// Message describes a message sent between two replicas in the consensus
-// mechanism
-type Message struct {
-	to          uint8
-	messageType uint8
-	value       surge.Marshaler
-}
+					if !replayMode {
+						timeout := 35 * time.Second
 
-// SizeHint implements surge SizeHinter for Message
-func (m Message) SizeHint() int {
-	switch value := m.value.(type) {
-	case process.Propose:
-		return surge.SizeHint(m.to) +
-			surge.SizeHint(m.messageType) +
-			surge.SizeHint(value)
-	case process.Prevote:
-		return surge.SizeHint(m.to) +
-			surge.SizeHint(m.messageType) +
-			surge.SizeHint(value)
-	case process.Precommit:
-		return surge.SizeHint(m.to) +
-			surge.SizeHint(m.messageType) +
-			surge.SizeHint(value)
-	case timer.Timeout:
-		return surge.SizeHint(m.to) +
-			surge.SizeHint(m.messageType) +
-			surge.SizeHint(value)
-	default:
-		panic(fmt.Errorf("non-exhaustive pattern: message.value has type %T", value))
-	}
-}
+						play(&rec, timeout, &mq, mqMutex, mqSignal, completionSignal, replicas, &killedReplicas, successFn, failureFn, inspectFn)
+					}
 
-// Marshal implements surge Marshaler for Message
-func (m Message) Marshal(buf []byte, rem int) ([]byte, int, error) {
-	buf, rem, err := surge.Marshal(m.to, buf, rem)
-	if err != nil {
-		return buf, rem, fmt.Errorf("marshaling to=%v: %v", m.to, err)
-	}
-	buf, rem, err = surge.Marshal(m.messageType, buf, rem)
-	if err != nil {
-		return buf, rem, fmt.Errorf("marshaling messageType=%v: %v", m.messageType, err)
-	}
+					if replayMode {
+						replay(&rec, mqMutex, mqSignal, completionSignal, replicas, &killedReplicas, successFn, inspectFn)
+					}
+				})
+			})
 
-	switch value := m.value.(type) {
-	case process.Propose:
-		buf, rem, err = surge.Marshal(value, buf, rem)
-		if err != nil {
-			return buf, rem, fmt.Errorf("marshaling value=%v: %v", value, err)
+			Context(fmt.Sprintf("with %v signatories, and less than n-f replicas online", size), func() {
+				It("should stall consensus and should not progress", func() {
+					// randomness seed
+					seed := time.Now().UnixNano()
+					// f is the maximum no. of adversaries
+					f := (size - 1) / 3
+					// n is the number of honest replicas online
+					n := size - f - 1
+					// number of replicas to signal completion
+					completion := n
+					// target height of consensus to mark the test as succeeded
+					targetHeight := process.Height(10)
+					// dynamic slice to hold the messages being sent between replicas
+					mq := []Message{}
+					// commits from replicas
+					commits := make(map[uint8]map[process.Height]process.Value)
+					// map to keep a record of which replica was killed
+					killedReplicas := make(map[uint8]bool)
+
+					// setup the test scenario
+					_, rec, replayMode, mqMutex, mqSignal, completionSignal, replicas, replicaCtxs, replicaCtxCancels, cancel := setup(seed, size, f, n, completion, targetHeight, &mq, &commits, nil, nil)
+
+					// Run all of the replicas in independent background goroutines
+					for i := range replicas {
+						go replicas[i].Run(replicaCtxs[i])
+					}
+
+					// callback function called on test failure
+					failureFn := func(rec *recording) {
+						// cancel the replica contexts
+						for i := range replicaCtxs {
+							replicaCtxCancels[i]()
+						}
+
+						// ensure that none of the replicas have reached consensus for any height
+						for j := uint8(0); j < n; j++ {
+							for h := process.Height(1); h <= targetHeight; {
+								Expect(commits[j][h]).To(Equal(process.NilValue))
+								h++
+							}
+						}
+					}
+
+					// callback function called on test success
+					successFn := func() {
+						cancel()
+						Fail("test was not expected to reach target consensus")
+					}
+
+					// callback function called on every message processed
+					inspectFn := func(rec *recording) {}
+
+					if !replayMode {
+						timeout := 10 * time.Second
+
+						play(&rec, timeout, &mq, mqMutex, mqSignal, completionSignal, replicas, &killedReplicas, successFn, failureFn, inspectFn)
+					}
+
+					if replayMode {
+						replay(&rec, mqMutex, mqSignal, completionSignal, replicas, &killedReplicas, successFn, inspectFn)
+					}
+				})
+			})
 		}
-	case process.Prevote:
-		buf, rem, err = surge.Marshal(value, buf, rem)
-		if err != nil {
-			return buf, rem, fmt.Errorf("marshaling value=%v: %v", value, err)
+	})
+
+	Context("with an observer", func() {
+		r := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+		It("should notify the observer about transitions and rejected messages", func() {
+			privKeys := make([]*id.PrivKey, 4)
+			signatories := make([]id.Signatory, 4)
+			for i := range privKeys {
+				privKeys[i] = id.NewPrivKey()
+				signatories[i] = privKeys[i].Signatory()
+			}
+			rounds := make(chan process.Round, 10)
+			rejected := make(chan interface{}, 10)
+			observer := processutil.ObserverCallbacks{
+				OnNewRoundCallback: func(height process.Height, round process.Round) {
+					rounds <- round
+				},
+				OnMessageRejectedCallback: func(msg interface{}, err error) {
+					Expect(err).To(HaveOccurred())
+					rejected <- msg
+				},
+			}
+			rep := replica.New(
+				replica.DefaultOptions().WithObserver(observer),
+				signatories[0],
+				signatories,
+				// Timer
+				nil,
+				// Proposer
+				processutil.MockProposer{MockValue: func() process.Value { return processutil.RandomGoodValue(r) }},
+				// Validator
+				nil,
+				// Verifier
+				nil,
+				// Signer
+				nil,
+				// Committer
+				processutil.CommitterCallback{},
+				// Catcher
+				nil,
+				// Broadcaster
+				nil,
+				// Flusher
+				nil,
+			)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go rep.Run(ctx)
+			Eventually(rounds).Should(Receive(Equal(process.Round(0))))
+
+			// a prevote with a bad signature is rejected by the replica
+			badPrevote := process.Prevote{Height: 1, Round: 0, Value: processutil.RandomGoodValue(r)}
+			Expect(badPrevote.Sign(privKeys[1])).To(Succeed())
+			badPrevote.From = signatories[2]
+			rep.Prevote(ctx, badPrevote)
+			Eventually(rejected).Should(Receive(Equal(badPrevote)))
+
+			// a duplicate prevote is rejected by the process
+			prevote := process.Prevote{Height: 1, Round: 0, Value: processutil.RandomGoodValue(r)}
+			Expect(prevote.Sign(privKeys[1])).To(Succeed())
+			rep.Prevote(ctx, prevote)
+			rep.Prevote(ctx, prevote)
+			Eventually(rejected).Should(Receive(Equal(prevote)))
+		})
+	})
+
+	Context("with status requests", func() {
+		r := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+		It("should return a consistent snapshot of the replica", func() {
+			privKeys := make([]*id.PrivKey, 4)
+			signatories := make([]id.Signatory, 4)
+			for i := range privKeys {
+				privKeys[i] = id.NewPrivKey()
+				signatories[i] = privKeys[i].Signatory()
+			}
+			rep := replica.New(
+				replica.DefaultOptions(),
+				signatories[0],
+				signatories,
+				// Timer
+				nil,
+				// Proposer
+				processutil.MockProposer{MockValue: func() process.Value { return processutil.RandomGoodValue(r) }},
+				// Validator
+				nil,
+				// Verifier
+				nil,
+				// Signer
+				nil,
+				// Committer
+				processutil.CommitterCallback{},
+				// Catcher
+				nil,
+				// Broadcaster
+				nil,
+				// Flusher
+				nil,
+			)
+
+			// the status cannot be requested until the replica is running
+			timeoutCtx, timeoutCancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer timeoutCancel()
+			_, err := rep.Status(timeoutCtx)
+			Expect(err).To(HaveOccurred())
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go rep.Run(ctx)
+
+			// prevotes at the current height are fed to the process, and
+			// prevotes at future heights are queued
+			for _, privKey := range privKeys[1:3] {
+				prevote := process.Prevote{Height: 1, Round: 0, Value: processutil.RandomGoodValue(r)}
+				Expect(prevote.Sign(privKey)).To(Succeed())
+				rep.Prevote(ctx, prevote)
+			}
+			prevote := process.Prevote{Height: 2, Round: 0, Value: processutil.RandomGoodValue(r)}
+			Expect(prevote.Sign(privKeys[3])).To(Succeed())
+			rep.Prevote(ctx, prevote)
+
+			Eventually(func() replica.Status {
+				status, err := rep.Status(ctx)
+				Expect(err).ToNot(HaveOccurred())
+				return status
+			}).Should(Equal(replica.Status{
+				CurrentHeight:   1,
+				CurrentRound:    0,
+				CurrentStep:     process.Proposing,
+				LockedValue:     process.NilValue,
+				LockedRound:     process.InvalidRound,
+				ValidValue:      process.NilValue,
+				ValidRound:      process.InvalidRound,
+				Votes:           map[process.Round]replica.VoteCount{0: {Prevotes: 2}},
+				QueuedMessages:  1,
+				PendingMessages: 0,
+			}))
+
+			height, round, step := rep.State()
+			Expect(height).To(Equal(process.Height(1)))
+			Expect(round).To(Equal(process.Round(0)))
+			Expect(step).To(Equal(process.Proposing))
+			Expect(rep.CurrentHeight()).To(Equal(process.Height(1)))
+		})
+	})
+
+	Context("with snapshots", func() {
+		r := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+		It("should not panic when unmarshaling fuzz", func() {
+			f := func(fuzz []byte) bool {
+				snapshot := replica.Snapshot{}
+				Expect(func() { surge.FromBinary(&snapshot, fuzz) }).ToNot(Panic())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should resume from a snapshot with the same state and queued messages", func() {
+			privKeys := make([]*id.PrivKey, 4)
+			signatories := make([]id.Signatory, 4)
+			for i := range privKeys {
+				privKeys[i] = id.NewPrivKey()
+				signatories[i] = privKeys[i].Signatory()
+			}
+			// the scheduler of the restored replica is supplied by the caller
+			scheduled := []process.Height{}
+			schedulerFunc := func(height process.Height, signatories []id.Signatory) process.Scheduler {
+				scheduled = append(scheduled, height)
+				return scheduler.NewRoundRobin(signatories)
+			}
+			newReplica := func(snapshot *replica.Snapshot) *replica.Replica {
+				proposer := processutil.MockProposer{MockValue: func() process.Value { return processutil.RandomGoodValue(r) }}
+				if snapshot != nil {
+					opts := replica.DefaultOptions().WithScheduler(schedulerFunc)
+					return replica.NewFromSnapshot(opts, *snapshot, nil, proposer, nil, nil, nil, processutil.CommitterCallback{}, nil, nil, nil)
+				}
+				return replica.New(replica.DefaultOptions(), signatories[0], signatories, nil, proposer, nil, nil, nil, processutil.CommitterCallback{}, nil, nil, nil)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			rep := newReplica(nil)
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				rep.Run(ctx)
+			}()
+
+			// prevotes at the current height are fed to the process, and
+			// prevotes at future heights are queued
+			for _, privKey := range privKeys[1:3] {
+				prevote := process.Prevote{Height: 1, Round: 0, Value: processutil.RandomGoodValue(r)}
+				Expect(prevote.Sign(privKey)).To(Succeed())
+				rep.Prevote(ctx, prevote)
+			}
+			prevote := process.Prevote{Height: 2, Round: 0, Value: processutil.RandomGoodValue(r)}
+			Expect(prevote.Sign(privKeys[3])).To(Succeed())
+			rep.Prevote(ctx, prevote)
+
+			var status replica.Status
+			Eventually(func() int {
+				var err error
+				status, err = rep.Status(ctx)
+				Expect(err).ToNot(HaveOccurred())
+				return status.QueuedMessages + status.Votes[0].Prevotes
+			}).Should(Equal(3))
+
+			// the replica stops running once the snapshot has been taken
+			snapshot, err := rep.Snapshot(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Eventually(done).Should(BeClosed())
+			Expect(snapshot.Signatories).To(Equal(signatories))
+			Expect(snapshot.Messages).To(Equal([]interface{}{prevote}))
+
+			data, err := surge.ToBinary(snapshot)
+			Expect(err).ToNot(HaveOccurred())
+			restored := replica.Snapshot{}
+			Expect(surge.FromBinary(&restored, data)).To(Succeed())
+
+			rep = newReplica(&restored)
+			Expect(scheduled).To(Equal([]process.Height{status.CurrentHeight}))
+			go rep.Run(ctx)
+			got, err := rep.Status(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(got).To(Equal(status))
+
+			height, round, step := rep.State()
+			Expect(height).To(Equal(status.CurrentHeight))
+			Expect(round).To(Equal(status.CurrentRound))
+			Expect(step).To(Equal(status.CurrentStep))
+		})
+	})
+
+	Context("with a syncer", func() {
+		r := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+		// newCert returns a commit certificate at the given height, with
+		// precommits from the given private keys
+		newCert := func(height process.Height, privKeys []*id.PrivKey) process.CommitCertificate {
+			cert := process.CommitCertificate{Height: height, Round: process.Round(r.Intn(10)), Value: processutil.RandomGoodValue(r)}
+			for _, privKey := range privKeys {
+				precommit := process.Precommit{Height: cert.Height, Round: cert.Round, Value: cert.Value}
+				Expect(precommit.Sign(privKey)).To(Succeed())
+				cert.Precommits = append(cert.Precommits, precommit)
+			}
+			return cert
 		}
-	case process.Precommit:
-		buf, rem, err = surge.Marshal(value, buf, rem)
-		if err != nil {
-			return buf, rem, fmt.Errorf("marshaling value=%v: %v", value, err)
+
+		// newReplica returns the replica of the first signatory out of four,
+		// and a channel that receives the heights that it commits
+		newReplica := func(syncer replica.Syncer, observer process.Observer) (*replica.Replica, []*id.PrivKey, chan process.Height) {
+			privKeys := make([]*id.PrivKey, 4)
+			signatories := make([]id.Signatory, 4)
+			for i := range privKeys {
+				privKeys[i] = id.NewPrivKey()
+				signatories[i] = privKeys[i].Signatory()
+			}
+			commits := make(chan process.Height, 100)
+			rep := replica.New(
+				replica.DefaultOptions().WithSyncer(syncer).WithObserver(observer),
+				signatories[0],
+				signatories,
+				// Timer
+				nil,
+				// Proposer
+				processutil.MockProposer{MockValue: func() process.Value { return processutil.RandomGoodValue(r) }},
+				// Validator
+				nil,
+				// Verifier
+				nil,
+				// Signer
+				nil,
+				// Committer
+				processutil.CommitterCallback{
+					Callback: func(height process.Height, value process.Value) (process.VotingPowers, process.Scheduler) {
+						commits <- height
+						return nil, nil
+					},
+				},
+				// Catcher
+				nil,
+				// Broadcaster
+				nil,
+				// Flusher
+				nil,
+			)
+			return rep, privKeys, commits
 		}
-	case timer.Timeout:
-		buf, rem, err = surge.Marshal(value, buf, rem)
-		if err != nil {
-			return buf, rem, fmt.Errorf("marshaling value=%v: %v", value, err)
+
+		It("should commit the missing heights, and catch up with the other replicas", func() {
+			syncer := &mockSyncer{certs: map[process.Height]process.CommitCertificate{}}
+			rep, privKeys, commits := newReplica(syncer, nil)
+			tip := process.Height(2 + r.Intn(10))
+			for height := process.Height(1); height < tip; height++ {
+				syncer.certs[height] = newCert(height, privKeys[1:])
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go rep.Run(ctx)
+
+			// a prevote from the tip shows that the replica is behind
+			prevote := process.Prevote{Height: tip, Round: 0, Value: processutil.RandomGoodValue(r)}
+			Expect(prevote.Sign(privKeys[1])).To(Succeed())
+			rep.Prevote(ctx, prevote)
+
+			for height := process.Height(1); height < tip; height++ {
+				Eventually(commits).Should(Receive(Equal(height)))
+			}
+			Eventually(rep.CurrentHeight).Should(Equal(tip))
+			Consistently(commits).ShouldNot(Receive())
+		})
+
+		It("should reject certificates that fail verification", func() {
+			rejected := make(chan interface{}, 10)
+			observer := processutil.ObserverCallbacks{
+				OnMessageRejectedCallback: func(msg interface{}, err error) {
+					rejected <- msg
+				},
+			}
+			syncer := &mockSyncer{certs: map[process.Height]process.CommitCertificate{}}
+			rep, privKeys, commits := newReplica(syncer, observer)
+
+			// precommits from less than a quorum of signatories
+			cert := newCert(1, privKeys[1:3])
+			syncer.certs[1] = cert
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go rep.Run(ctx)
+
+			prevote := process.Prevote{Height: 2, Round: 0, Value: processutil.RandomGoodValue(r)}
+			Expect(prevote.Sign(privKeys[1])).To(Succeed())
+			rep.Prevote(ctx, prevote)
+
+			Eventually(rejected).Should(Receive(Equal(cert)))
+			Consistently(commits).ShouldNot(Receive())
+			Expect(rep.CurrentHeight()).To(Equal(process.Height(1)))
+		})
+
+		It("should stop syncing when it stops running", func() {
+			syncer := &blockingSyncer{started: make(chan struct{}), stopped: make(chan struct{})}
+			rep, privKeys, _ := newReplica(syncer, nil)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				rep.Run(ctx)
+			}()
+
+			prevote := process.Prevote{Height: 2, Round: 0, Value: processutil.RandomGoodValue(r)}
+			Expect(prevote.Sign(privKeys[1])).To(Succeed())
+			rep.Prevote(ctx, prevote)
+			Eventually(syncer.started).Should(BeClosed())
+
+			// taking a snapshot stops the replica without cancelling the
+			// context, and the sync should be abandoned
+			_, err := rep.Snapshot(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Eventually(done).Should(BeClosed())
+			Eventually(syncer.stopped).Should(BeClosed())
+		})
+	})
+
+	Context("with a membership", func() {
+		r := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+		// newReplica returns the replica of the first signatory out of four,
+		// whose committer replaces the last signatory with a new one after
+		// the first height
+		newReplica := func(membership replica.Membership, syncer replica.Syncer) (*replica.Replica, []*id.PrivKey, id.Signatory) {
+			privKeys := make([]*id.PrivKey, 4)
+			signatories := make([]id.Signatory, 4)
+			for i := range privKeys {
+				privKeys[i] = id.NewPrivKey()
+				signatories[i] = privKeys[i].Signatory()
+			}
+			added := id.NewPrivKey().Signatory()
+			rep := replica.New(
+				replica.DefaultOptions().WithMembership(membership).WithSyncer(syncer),
+				signatories[0],
+				signatories,
+				// Timer
+				nil,
+				// Proposer
+				processutil.MockProposer{MockValue: func() process.Value { return processutil.RandomGoodValue(r) }},
+				// Validator
+				nil,
+				// Verifier
+				nil,
+				// Signer
+				nil,
+				// Committer
+				processutil.CommitterCallback{
+					Callback: func(height process.Height, value process.Value) (process.VotingPowers, process.Scheduler) {
+						powers := process.VotingPowers{added: 1}
+						for _, signatory := range signatories[:3] {
+							powers[signatory] = 1
+						}
+						return powers, nil
+					},
+				},
+				// Catcher
+				nil,
+				// Broadcaster
+				nil,
+				// Flusher
+				nil,
+			)
+			return rep, privKeys, added
 		}
-	default:
-		panic(fmt.Errorf("non-exhaustive pattern: message.value has type %T", value))
-	}
 
-	return buf, rem, nil
-}
+		It("should notify the membership when the committer returns new voting powers", func() {
+			membership := &mockMembership{allowed: make(chan []id.Signatory, 10)}
+			syncer := &mockSyncer{certs: map[process.Height]process.CommitCertificate{}}
+			rep, privKeys, added := newReplica(membership, syncer)
+
+			// commit the first height by syncing its certificate
+			cert := process.CommitCertificate{Height: 1, Round: 0, Value: processutil.RandomGoodValue(r)}
+			for _, privKey := range privKeys[1:] {
+				precommit := process.Precommit{Height: cert.Height, Round: cert.Round, Value: cert.Value}
+				Expect(precommit.Sign(privKey)).To(Succeed())
+				cert.Precommits = append(cert.Precommits, precommit)
+			}
+			syncer.certs[1] = cert
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go rep.Run(ctx)
+
+			prevote := process.Prevote{Height: 2, Round: 0, Value: processutil.RandomGoodValue(r)}
+			Expect(prevote.Sign(privKeys[1])).To(Succeed())
+			rep.Prevote(ctx, prevote)
+
+			Eventually(membership.allowed).Should(Receive(ConsistOf(
+				privKeys[0].Signatory(),
+				privKeys[1].Signatory(),
+				privKeys[2].Signatory(),
+				added,
+			)))
+			Eventually(rep.CurrentHeight).Should(Equal(process.Height(2)))
+		})
 
-// Unmarshal implements surge Unmarshaler for Message
-func (m *Message) Unmarshal(buf []byte, rem int) ([]byte, int, error) {
-	buf, rem, err := surge.Unmarshal(&m.to, buf, rem)
-	if err != nil {
-		return buf, rem, fmt.Errorf("unmarshaling to: %v", err)
-	}
-	buf, rem, err = surge.Unmarshal(&m.messageType, buf, rem)
-	if err != nil {
-		return buf, rem, fmt.Errorf("unmarshaling to: %v", err)
-	}
+		It("should notify the membership when the height is reset with new signatories", func() {
+			membership := &mockMembership{allowed: make(chan []id.Signatory, 10)}
+			rep, _, _ := newReplica(membership, nil)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go rep.Run(ctx)
+
+			signatories := []id.Signatory{id.NewPrivKey().Signatory(), id.NewPrivKey().Signatory()}
+			rep.ResetHeight(ctx, 10, signatories, nil)
+			Eventually(membership.allowed).Should(Receive(Equal(signatories)))
+			Consistently(membership.allowed).ShouldNot(Receive())
+		})
+	})
 
-	switch m.messageType {
-	case 1:
-		message := process.Propose{}
-		buf, rem, err = message.Unmarshal(buf, rem)
-		if err != nil {
-			return buf, rem, fmt.Errorf("unmarshaling value: %v", err)
+	Context("with timeout certificates", func() {
+		r := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+		// newReplica returns the replica of the first signatory out of four,
+		// and a channel that is signalled whenever it has handled a message
+		newReplica := func() (*replica.Replica, []*id.PrivKey, chan struct{}) {
+			privKeys := make([]*id.PrivKey, 4)
+			signatories := make([]id.Signatory, 4)
+			for i := range privKeys {
+				privKeys[i] = id.NewPrivKey()
+				signatories[i] = privKeys[i].Signatory()
+			}
+			handled := make(chan struct{}, 1)
+			rep := replica.New(
+				replica.DefaultOptions(),
+				signatories[0],
+				signatories,
+				// Timer
+				nil,
+				// Proposer
+				processutil.MockProposer{MockValue: func() process.Value { return processutil.RandomGoodValue(r) }},
+				// Validator
+				nil,
+				// Verifier
+				nil,
+				// Signer
+				nil,
+				// Committer
+				processutil.CommitterCallback{},
+				// Catcher
+				nil,
+				// Broadcaster
+				nil,
+				// Flusher
+				func() {
+					select {
+					case handled <- struct{}{}:
+					default:
+					}
+				},
+			)
+			return rep, privKeys, handled
 		}
-		m.value = message
-	case 2:
-		message := process.Prevote{}
-		buf, rem, err = message.Unmarshal(buf, rem)
-		if err != nil {
-			return buf, rem, fmt.Errorf("unmarshaling value: %v", err)
+
+		newCert := func(round process.Round, privKeys []*id.PrivKey) process.TimeoutCertificate {
+			cert := process.TimeoutCertificate{Height: 1, Round: round}
+			for _, privKey := range privKeys {
+				timeout := process.Timeout{Height: 1, Round: round}
+				Expect(timeout.Sign(privKey)).To(Succeed())
+				cert.Timeouts = append(cert.Timeouts, timeout)
+			}
+			return cert
 		}
-		m.value = message
-	case 3:
-		message := process.Precommit{}
-		buf, rem, err = message.Unmarshal(buf, rem)
-		if err != nil {
-			return buf, rem, fmt.Errorf("unmarshaling value: %v", err)
+
+		It("should move to the round after the certified round", func() {
+			rep, privKeys, handled := newReplica()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go rep.Run(ctx)
+
+			round := process.Round(r.Intn(10))
+			rep.TimeoutCertificate(ctx, newCert(round, privKeys[1:]))
+			Eventually(func() process.Round {
+				select {
+				case <-handled:
+				default:
+				}
+				_, currentRound, _ := rep.State()
+				return currentRound
+			}).Should(Equal(round + 1))
+		})
+
+		It("should drop certificates that fail verification", func() {
+			rep, privKeys, handled := newReplica()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go rep.Run(ctx)
+
+			// if the certificate was not dropped, then the valid timeouts
+			// would be enough for the replica to skip to the certified round
+			round := process.Round(1 + r.Intn(10))
+			cert := newCert(round, privKeys[1:])
+			cert.Timeouts[0].Signature = cert.Timeouts[1].Signature
+			rep.TimeoutCertificate(ctx, cert)
+			Consistently(func() process.Round {
+				select {
+				case <-handled:
+				default:
+				}
+				_, currentRound, _ := rep.State()
+				return currentRound
+			}).Should(Equal(process.Round(0)))
+		})
+	})
+
+	Context("with a write-ahead log", func() {
+		r := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+		var dir string
+		var w *wal.FileWAL
+		var privKeys []*id.PrivKey
+		var signatories []id.Signatory
+		var mu *sync.Mutex
+		var prevotes []process.Prevote
+		var precommits []process.Precommit
+		var commits []process.Height
+		var scheduled []process.Height
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "replica")
+			Expect(err).ToNot(HaveOccurred())
+			w, err = wal.Open(filepath.Join(dir, "wal"))
+			Expect(err).ToNot(HaveOccurred())
+
+			privKeys = make([]*id.PrivKey, 4)
+			signatories = make([]id.Signatory, 4)
+			for i := range privKeys {
+				privKeys[i] = id.NewPrivKey()
+				signatories[i] = privKeys[i].Signatory()
+			}
+			mu = new(sync.Mutex)
+			prevotes = []process.Prevote{}
+			precommits = []process.Precommit{}
+			commits = []process.Height{}
+			scheduled = []process.Height{}
+		})
+
+		AfterEach(func() {
+			w.Close()
+			os.RemoveAll(dir)
+		})
+
+		// newReplica returns the replica of the first signatory, which records
+		// everything that it broadcasts
+		newReplica := func(valid bool) *replica.Replica {
+			opts := replica.DefaultOptions().
+				WithWAL(w).
+				WithScheduler(func(height process.Height, signatories []id.Signatory) process.Scheduler {
+					mu.Lock()
+					defer mu.Unlock()
+					scheduled = append(scheduled, height)
+					return scheduler.NewRoundRobin(signatories)
+				})
+			return replica.New(
+				opts,
+				signatories[0],
+				signatories,
+				// Timer
+				nil,
+				// Proposer
+				processutil.MockProposer{MockValue: func() process.Value { return processutil.RandomGoodValue(r) }},
+				// Validator
+				processutil.MockValidator{MockValid: func(process.Height, process.Round, process.Value) bool { return valid }},
+				// Verifier
+				nil,
+				// Signer
+				nil,
+				// Committer
+				processutil.CommitterCallback{
+					CertificateCallback: func(cert process.CommitCertificate) {
+						mu.Lock()
+						defer mu.Unlock()
+						commits = append(commits, cert.Height)
+					},
+				},
+				// Catcher
+				nil,
+				// Broadcaster
+				processutil.BroadcasterCallbacks{
+					BroadcastPrevoteCallback: func(prevote process.Prevote) {
+						mu.Lock()
+						defer mu.Unlock()
+						prevotes = append(prevotes, prevote)
+					},
+					BroadcastPrecommitCallback: func(precommit process.Precommit) {
+						mu.Lock()
+						defer mu.Unlock()
+						precommits = append(precommits, precommit)
+					},
+				},
+				// Flusher
+				nil,
+			)
 		}
-		m.value = message
-	case 4:
-		message := timer.Timeout{}
-		buf, rem, err = message.Unmarshal(buf, rem)
-		if err != nil {
-			return buf, rem, fmt.Errorf("unmarshaling value: %v", err)
+
+		// lock the replica on a value in the first round at the first height,
+		// and then stop it
+		lockAndStop := func(value process.Value) {
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			rep := newReplica(true)
+			go func() {
+				defer close(done)
+				rep.Run(ctx)
+			}()
+
+			// the second signatory is scheduled to propose at the first height
+			// and round
+			propose := process.Propose{Height: 1, Round: 0, ValidRound: process.InvalidRound, Value: value}
+			Expect(propose.Sign(privKeys[1])).To(Succeed())
+			rep.Propose(ctx, propose)
+			for i := 1; i < len(privKeys); i++ {
+				prevote := process.Prevote{Height: 1, Round: 0, Value: value}
+				Expect(prevote.Sign(privKeys[i])).To(Succeed())
+				rep.Prevote(ctx, prevote)
+			}
+			Eventually(func() int {
+				mu.Lock()
+				defer mu.Unlock()
+				return len(precommits)
+			}).Should(Equal(1))
+
+			cancel()
+			<-done
+
+			mu.Lock()
+			defer mu.Unlock()
+			Expect(prevotes).To(HaveLen(1))
+			Expect(prevotes[0].Value).To(Equal(value))
+			Expect(precommits[0].Value).To(Equal(value))
+			prevotes = []process.Prevote{}
+			precommits = []process.Precommit{}
 		}
-		m.value = message
-	default:
-		panic(fmt.Errorf("non-exhaustive pattern: messageType has type %T", m.messageType))
-	}
 
-	return buf, rem, nil
+		It("should rebuild its state after a restart", func() {
+			value := processutil.RandomGoodValue(r)
+			lockAndStop(value)
+
+			// restart the replica without sending it any messages
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go newReplica(true).Run(ctx)
+
+			// it should precommit again, which it can only do if it has
+			// recovered its prevotes and its lock
+			Eventually(func() int {
+				mu.Lock()
+				defer mu.Unlock()
+				return len(precommits)
+			}).Should(Equal(1))
+			mu.Lock()
+			defer mu.Unlock()
+			Expect(prevotes).To(HaveLen(1))
+			Expect(prevotes[0].Value).To(Equal(value))
+			Expect(precommits[0].Value).To(Equal(value))
+		})
+
+		It("should not broadcast conflicting votes after a restart", func() {
+			value := processutil.RandomGoodValue(r)
+			lockAndStop(value)
+
+			// restart the replica with a validator that rejects the value, so
+			// that it would prevote nil if it did not remember its prevote
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go newReplica(false).Run(ctx)
+
+			Eventually(func() int {
+				mu.Lock()
+				defer mu.Unlock()
+				return len(prevotes)
+			}).Should(Equal(1))
+			Consistently(func() bool {
+				mu.Lock()
+				defer mu.Unlock()
+				for _, prevote := range prevotes {
+					if !prevote.Value.Equal(&value) {
+						return false
+					}
+				}
+				for _, precommit := range precommits {
+					if !precommit.Value.Equal(&value) {
+						return false
+					}
+				}
+				return true
+			}).Should(BeTrue())
+		})
+
+		It("should resume at the next height after a restart", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			rep := newReplica(true)
+			go func() {
+				defer close(done)
+				rep.Run(ctx)
+			}()
+
+			// commit a value at the first height
+			value := processutil.RandomGoodValue(r)
+			propose := process.Propose{Height: 1, Round: 0, ValidRound: process.InvalidRound, Value: value}
+			Expect(propose.Sign(privKeys[1])).To(Succeed())
+			rep.Propose(ctx, propose)
+			for i := 1; i < len(privKeys); i++ {
+				precommit := process.Precommit{Height: 1, Round: 0, Value: value}
+				Expect(precommit.Sign(privKeys[i])).To(Succeed())
+				rep.Precommit(ctx, precommit)
+			}
+			Eventually(rep.CurrentHeight).Should(Equal(process.Height(2)))
+			cancel()
+			<-done
+
+			// restart the replica, which should start at the second height
+			// without committing the first height again
+			ctx, cancel = context.WithCancel(context.Background())
+			defer cancel()
+			rep = newReplica(true)
+			go rep.Run(ctx)
+
+			Eventually(func() process.Height {
+				status, err := rep.Status(ctx)
+				if err != nil {
+					return 0
+				}
+				return status.CurrentHeight
+			}).Should(Equal(process.Height(2)))
+			mu.Lock()
+			defer mu.Unlock()
+			Expect(commits).To(Equal([]process.Height{1}))
+			// the scheduler of the second height is supplied by the caller,
+			// because the replica cannot recover it from its committer
+			Expect(scheduled).To(Equal([]process.Height{1, 1, 2}))
+		})
+
+		It("should replay synced certificates after a restart", func() {
+			// the replica crashed after appending a synced certificate, but
+			// before the wal was truncated
+			cert := process.CommitCertificate{Height: 1, Round: 0, Value: processutil.RandomGoodValue(r)}
+			for _, privKey := range privKeys[1:] {
+				precommit := process.Precommit{Height: cert.Height, Round: cert.Round, Value: cert.Value}
+				Expect(precommit.Sign(privKey)).To(Succeed())
+				cert.Precommits = append(cert.Precommits, precommit)
+			}
+			Expect(w.Append(wal.Entry{Type: wal.EntryTypeSync, Value: cert})).To(Succeed())
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			rep := newReplica(true)
+			go rep.Run(ctx)
+
+			Eventually(rep.CurrentHeight).Should(Equal(process.Height(2)))
+			mu.Lock()
+			defer mu.Unlock()
+			Expect(commits).To(Equal([]process.Height{1}))
+		})
+	})
+})
+
+// recording is the scenario.Scenario of a test, which records every message
+// and timeout in the order in which it was delivered to a replica, along with
+// the number of replicas that must reach the target height. If a test fails,
+// its recording is written to a file, and the test can be replayed by running
+// it again with the REPLAY_SCENARIO environment variable set to the name of the
+// file.
+type recording struct {
+	scenario.Scenario
+	completion uint8
+	start      time.Time
 }
 
-// readBoolEnvVar reads an environment variable `name` and returns its boolean value
-func readBoolEnvVar(name string) bool {
-	envVar := os.Getenv(name)
-	boolEnvVar, err := strconv.ParseBool(envVar)
-	if err != nil {
-		panic(fmt.Errorf("error parsing env var %v to boolean", name))
-	}
-	return boolEnvVar
+// record the delivery of a message to a replica.
+func (rec *recording) record(m Message) {
+	rec.Steps = append(rec.Steps, scenario.Step{
+		Time:    time.Since(rec.start),
+		To:      int(m.to),
+		Input:   m.value,
+		Outputs: []process.Action{},
+	})
 }
 
-// dumpToFile dumps a serialised test scenario data to a dump file
-func dumpToFile(filename string, scenario Scenario) {
-	file, err := os.Create(filename)
-	if err != nil {
-		fmt.Printf("unable to create dump file: %v\n", err)
-	}
-	defer file.Close()
+// Message describes a message sent between two replicas in the consensus
+// mechanism
+type Message struct {
+	to          uint8
+	messageType uint8
+	value       surge.Marshaler
+}
 
-	fmt.Printf("dumping msg history to file %s\n", filename)
+// blockingSyncer never returns a commit certificate. It closes started when it
+// is first called, and stopped once the context of that call is done.
+type blockingSyncer struct {
+	started chan struct{}
+	stopped chan struct{}
+}
 
-	data := make([]byte, surge.SizeHint(scenario))
-	_, _, err = surge.Marshal(scenario, data, surge.SizeHint(scenario))
-	if err != nil {
-		fmt.Printf("unable to marshal scenario: %v\n", err)
-	}
+func (syncer *blockingSyncer) Sync(ctx context.Context, height process.Height) (process.CommitCertificate, error) {
+	close(syncer.started)
+	<-ctx.Done()
+	close(syncer.stopped)
+	return process.CommitCertificate{}, ctx.Err()
+}
 
-	_, err = file.Write(data)
-	if err != nil {
-		fmt.Printf("unable to write data to file: %v\n", err)
-	}
+// mockMembership sends the signatories that it is given to a channel.
+type mockMembership struct {
+	allowed chan []id.Signatory
 }
 
-// readFromFile reads and deserialises a dump file to return the test scenario
-func readFromFile(filename string) Scenario {
-	file, err := os.Open(filename)
-	if err != nil {
-		fmt.Printf("unable to open dump file: %v\n", err)
-	}
-	defer file.Close()
+func (membership *mockMembership) SetAllowed(signatories []id.Signatory) {
+	membership.allowed <- signatories
+}
 
-	fmt.Printf("reading msg history from file %s\n", filename)
+// mockSyncer returns the commit certificates that it has been given.
+type mockSyncer struct {
+	certs map[process.Height]process.CommitCertificate
+}
 
-	data := make([]byte, surge.MaxBytes)
-	_, err = file.Read(data)
-	if err != nil {
-		fmt.Printf("unable to read data from file: %v\n", err)
+func (syncer *mockSyncer) Sync(ctx context.Context, height process.Height) (process.CommitCertificate, error) {
+	cert, ok := syncer.certs[height]
+	if !ok {
+		return process.CommitCertificate{}, fmt.Errorf("no certificate at height=%v", height)
 	}
+	return cert, nil
+}
 
-	var scenario Scenario
-	_, _, err = surge.Unmarshal(&scenario, data, surge.MaxBytes)
-	if err != nil {
-		fmt.Printf("unable to unmarshal scenario: %v\n", err)
+// writeRecording writes the recording of a failed test to a file, so that the
+// test can be replayed.
+func writeRecording(rec *recording) {
+	name := "failure.scenario"
+	if err := scenario.WriteFile(name, rec.Scenario); err != nil {
+		fmt.Printf("unable to write scenario: %v\n", err)
+		return
 	}
-
-	return scenario
+	fmt.Printf("wrote scenario to %v, replay it with REPLAY_SCENARIO=%v\n", name, name)
 }
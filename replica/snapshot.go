@@ -0,0 +1,242 @@
+package replica
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/id"
+	"github.com/renproject/surge"
+)
+
+// A Snapshot of a Replica contains everything that is needed to resume the
+// Replica on another host without losing its State at the current Height: the
+// Process (which includes its State), the signatories that are allowed to send
+// messages (in the order in which they were given to the Replica), and the
+// messages that are waiting in the message queue.
+//
+// The write-ahead log of the Replica is not part of the Snapshot.
+type Snapshot struct {
+	Process     process.Process
+	Signatories []id.Signatory
+	// Messages are the Propose, Prevote, Precommit, and Timeout messages in the
+	// message queue.
+	Messages []interface{}
+}
+
+// SizeHint returns the number of bytes required to represent this Snapshot in
+// binary.
+func (snapshot Snapshot) SizeHint() int {
+	sizeHint := snapshot.Process.SizeHint() +
+		surge.SizeHint(snapshot.Signatories) +
+		surge.SizeHint(uint32(len(snapshot.Messages)))
+	for _, msg := range snapshot.Messages {
+		sizeHint += surge.SizeHint(int8(0))
+		if msg, ok := msg.(surge.SizeHinter); ok {
+			sizeHint += msg.SizeHint()
+		}
+	}
+	return sizeHint
+}
+
+// Marshal this Snapshot into binary. Every message is prefixed with its
+// process.MessageType.
+func (snapshot Snapshot) Marshal(buf []byte, rem int) ([]byte, int, error) {
+	buf, rem, err := snapshot.Process.Marshal(buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling process: %v", err)
+	}
+	buf, rem, err = surge.Marshal(snapshot.Signatories, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling %v signatories: %v", len(snapshot.Signatories), err)
+	}
+	buf, rem, err = surge.Marshal(uint32(len(snapshot.Messages)), buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling len=%v: %v", len(snapshot.Messages), err)
+	}
+	for _, msg := range snapshot.Messages {
+		var ty process.MessageType
+		switch msg.(type) {
+		case process.Propose:
+			ty = process.MessageTypePropose
+		case process.Prevote:
+			ty = process.MessageTypePrevote
+		case process.Precommit:
+			ty = process.MessageTypePrecommit
+		case process.Timeout:
+			ty = process.MessageTypeTimeout
+		default:
+			return buf, rem, fmt.Errorf("marshaling message: unknown type=%T", msg)
+		}
+		buf, rem, err = surge.Marshal(int8(ty), buf, rem)
+		if err != nil {
+			return buf, rem, fmt.Errorf("marshaling type=%v: %v", ty, err)
+		}
+		buf, rem, err = msg.(surge.Marshaler).Marshal(buf, rem)
+		if err != nil {
+			return buf, rem, fmt.Errorf("marshaling message=%v: %v", msg, err)
+		}
+	}
+	return buf, rem, nil
+}
+
+// Unmarshal binary into this Snapshot.
+func (snapshot *Snapshot) Unmarshal(buf []byte, rem int) ([]byte, int, error) {
+	buf, rem, err := snapshot.Process.Unmarshal(buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling process: %v", err)
+	}
+	buf, rem, err = surge.Unmarshal(&snapshot.Signatories, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling signatories: %v", err)
+	}
+	var n uint32
+	buf, rem, err = surge.Unmarshal(&n, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling len: %v", err)
+	}
+	// Every message takes at least one byte, so the length cannot be larger
+	// than the remaining buffer. This stops malicious lengths from causing
+	// large allocations.
+	if uint64(n) > uint64(len(buf)) {
+		return buf, rem, fmt.Errorf("unmarshaling len=%v: expected at most %v", n, len(buf))
+	}
+	snapshot.Messages = make([]interface{}, 0, n)
+	for i := uint32(0); i < n; i++ {
+		var ty process.MessageType
+		buf, rem, err = surge.Unmarshal((*int8)(&ty), buf, rem)
+		if err != nil {
+			return buf, rem, fmt.Errorf("unmarshaling type: %v", err)
+		}
+		switch ty {
+		case process.MessageTypePropose:
+			msg := process.Propose{}
+			buf, rem, err = msg.Unmarshal(buf, rem)
+			snapshot.Messages = append(snapshot.Messages, msg)
+		case process.MessageTypePrevote:
+			msg := process.Prevote{}
+			buf, rem, err = msg.Unmarshal(buf, rem)
+			snapshot.Messages = append(snapshot.Messages, msg)
+		case process.MessageTypePrecommit:
+			msg := process.Precommit{}
+			buf, rem, err = msg.Unmarshal(buf, rem)
+			snapshot.Messages = append(snapshot.Messages, msg)
+		case process.MessageTypeTimeout:
+			msg := process.Timeout{}
+			buf, rem, err = msg.Unmarshal(buf, rem)
+			snapshot.Messages = append(snapshot.Messages, msg)
+		default:
+			return buf, rem, fmt.Errorf("unmarshaling message: unknown type=%v", ty)
+		}
+		if err != nil {
+			return buf, rem, fmt.Errorf("unmarshaling message: %v", err)
+		}
+	}
+	return buf, rem, nil
+}
+
+// NewFromSnapshot returns a Replica that resumes from the given Snapshot,
+// instead of starting at the beginning of a Height. The arguments are the same
+// as for New, except that the identity, committee, and State of the Process,
+// and the allowed signatories, are restored from the Snapshot. The Scheduler
+// is returned by the SchedulerFunc of the options for the Height and
+// signatories of the Snapshot (see Options.WithScheduler), so that a Scheduler
+// that was returned by the Committer is not lost. The messages of the
+// Snapshot are inserted into the message queue, and fed to the Process once it
+// is running.
+//
+// The Snapshot must not be restored more than once, and the Replica from which
+// it was taken must not run again, otherwise the Replicas could vote
+// differently in the same Round.
+func NewFromSnapshot(
+	opts Options,
+	snapshot Snapshot,
+	linearTimer process.Timer,
+	propose process.Proposer,
+	validate process.Validator,
+	verify process.Verifier,
+	sign process.Signer,
+	commit process.Committer,
+	catch process.Catcher,
+	broadcast process.Broadcaster,
+	didHandleMessage DidHandleMessage,
+) *Replica {
+	// The identity of the Process is restored from the Snapshot, so it is not
+	// needed here.
+	replica := New(
+		opts.WithStartingHeight(snapshot.Process.CurrentHeight),
+		id.Signatory{},
+		snapshot.Signatories,
+		linearTimer,
+		propose,
+		validate,
+		verify,
+		sign,
+		commit,
+		catch,
+		broadcast,
+		didHandleMessage,
+	)
+	replica.proc = replica.proc.WithSnapshot(snapshot.Process)
+	replica.proc.State = replica.proc.State.Clone()
+	replica.restored = true
+	for _, msg := range snapshot.Messages {
+		switch msg := msg.(type) {
+		case process.Propose:
+			replica.mq.InsertPropose(msg)
+		case process.Prevote:
+			replica.mq.InsertPrevote(msg)
+		case process.Precommit:
+			replica.mq.InsertPrecommit(msg)
+		case process.Timeout:
+			replica.mq.InsertTimeout(msg)
+		}
+	}
+	replica.summarise()
+	return replica
+}
+
+// snapshotRequest is sent to the Run loop to request a Snapshot. The Snapshot
+// is written to the response channel, which must be buffered.
+type snapshotRequest struct {
+	response chan Snapshot
+}
+
+// Snapshot returns a Snapshot of the Replica, which can be used to resume the
+// Replica on another host (see NewFromSnapshot). The Snapshot is taken by the
+// Run loop, so Snapshot blocks until the Replica is running and has handled the
+// request, or until the context is done.
+//
+// Once the Snapshot has been taken, the Replica stops running. Otherwise, it
+// could vote after the Snapshot was taken, and the resumed Replica could vote
+// differently in the same Round.
+func (replica *Replica) Snapshot(ctx context.Context) (Snapshot, error) {
+	req := snapshotRequest{response: make(chan Snapshot, 1)}
+	select {
+	case <-ctx.Done():
+		return Snapshot{}, ctx.Err()
+	case replica.reqs <- req:
+	}
+	select {
+	case <-ctx.Done():
+		return Snapshot{}, ctx.Err()
+	case snapshot := <-req.response:
+		return snapshot, nil
+	}
+}
+
+// snapshot returns a Snapshot of the Replica. It must only be called from the
+// Run loop.
+func (replica *Replica) snapshot() Snapshot {
+	// The Process in the Snapshot does not have any interfaces, so that its
+	// methods cannot be called by accident.
+	proc := process.Process{}.WithSnapshot(replica.proc)
+	proc.State = proc.State.Clone()
+	signatories := make([]id.Signatory, len(replica.signatories))
+	copy(signatories, replica.signatories)
+	return Snapshot{
+		Process:     proc,
+		Signatories: signatories,
+		Messages:    replica.mq.Messages(),
+	}
+}
@@ -0,0 +1,101 @@
+package replica
+
+import (
+	"github.com/renproject/hyperdrive/process"
+)
+
+// Status is a consistent snapshot of the State of a Replica. It is taken by
+// the Run loop of the Replica between handling messages, so it is safe to
+// request from any goroutine.
+type Status struct {
+	CurrentHeight process.Height
+	CurrentRound  process.Round
+	CurrentStep   process.Step
+	LockedValue   process.Value
+	LockedRound   process.Round
+	ValidValue    process.Value
+	ValidRound    process.Round
+
+	// Votes stores the number of votes that have been received in every Round
+	// of the current Height.
+	Votes map[process.Round]VoteCount
+	// QueuedMessages is the number of messages that have been verified and are
+	// waiting in the message queue, usually because they are from a future
+	// Height.
+	QueuedMessages int
+	// PendingMessages is the number of messages (and local timeouts) that have
+	// been given to the Replica, but have not been handled by the Run loop yet.
+	PendingMessages int
+}
+
+// VoteCount stores the number of Prevotes, Precommits, and Timeouts that have
+// been received in a Round. Each signatory is only counted once per message
+// type.
+type VoteCount struct {
+	Prevotes   int
+	Precommits int
+	Timeouts   int
+}
+
+// statusRequest is sent to the Run loop to request a Status. The Status is
+// written to the response channel, which must be buffered.
+type statusRequest struct {
+	response chan Status
+}
+
+// status returns a Status for the current State of the Process. It must only
+// be called from the Run loop.
+func (replica *Replica) status() Status {
+	state := replica.proc.State
+	votes := map[process.Round]VoteCount{}
+	for round, prevotes := range state.PrevoteLogs {
+		count := votes[round]
+		count.Prevotes = len(prevotes)
+		votes[round] = count
+	}
+	for round, precommits := range state.PrecommitLogs {
+		count := votes[round]
+		count.Precommits = len(precommits)
+		votes[round] = count
+	}
+	for round, timeouts := range state.TimeoutLogs {
+		count := votes[round]
+		count.Timeouts = len(timeouts)
+		votes[round] = count
+	}
+	return Status{
+		CurrentHeight:   state.CurrentHeight,
+		CurrentRound:    state.CurrentRound,
+		CurrentStep:     state.CurrentStep,
+		LockedValue:     state.LockedValue,
+		LockedRound:     state.LockedRound,
+		ValidValue:      state.ValidValue,
+		ValidRound:      state.ValidRound,
+		Votes:           votes,
+		QueuedMessages:  replica.mq.Len(),
+		PendingMessages: len(replica.mch),
+	}
+}
+
+// summary is the current height, round, and step of the Process. It is updated
+// by the Run loop, and can be read from any goroutine while holding the mutex
+// of the Replica.
+type summary struct {
+	height process.Height
+	round  process.Round
+	step   process.Step
+}
+
+// summarise the State of the Process, so that it can be read by other
+// goroutines. It must only be called from the Run loop (or before the Replica
+// starts running).
+func (replica *Replica) summarise() {
+	replica.summaryMu.Lock()
+	defer replica.summaryMu.Unlock()
+
+	replica.summary = summary{
+		height: replica.proc.CurrentHeight,
+		round:  replica.proc.CurrentRound,
+		step:   replica.proc.CurrentStep,
+	}
+}
@@ -0,0 +1,109 @@
+package replica
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/hyperdrive/wal"
+	"github.com/renproject/id"
+)
+
+// A Syncer is used by a Replica that has fallen behind the other Replicas to
+// fetch the Values that have been committed at the Heights it has missed. The
+// Syncer is usually implemented by asking peers for the CommitCertificates
+// that they have stored (see process.Committer). The certificates are verified
+// by the Replica, so the Syncer does not need to trust its peers.
+type Syncer interface {
+	// Sync returns the CommitCertificate for the Value that was committed at
+	// the given Height. It returns an error if the certificate cannot be
+	// fetched, for example because no peer has committed a Value at the Height
+	// yet.
+	Sync(ctx context.Context, height process.Height) (process.CommitCertificate, error)
+}
+
+// syncResult is sent to the Run loop once the Syncer has returned.
+type syncResult struct {
+	cert process.CommitCertificate
+	err  error
+}
+
+// observeHeight records the Height of a message that has been verified. If the
+// message is from an allowed signatory, and its Height is greater than the
+// current Height, then the other Replicas are (at least) at that Height, and
+// the Replica can sync with them.
+func (replica *Replica) observeHeight(height process.Height, from id.Signatory) {
+	if replica.procsAllowed[from] && height > replica.tip {
+		replica.tip = height
+	}
+}
+
+// trySync fetches the CommitCertificate for the current Height, if the Replica
+// needs to sync (see needsSync). The certificate is fetched in the
+// background, and the result is sent to the Run loop, unless the context is
+// done first (the context is scoped to the Run loop, so that the fetch is
+// abandoned when the Run loop exits for any reason). Certificates are
+// fetched one Height at a time, because the committee of the next Height (and
+// so the verification of its certificate) depends on the Value that is
+// committed at the current Height.
+func (replica *Replica) trySync(ctx context.Context) {
+	if !replica.needsSync() {
+		return
+	}
+	replica.syncing = true
+	height := replica.proc.CurrentHeight
+	go func() {
+		cert, err := replica.opts.Syncer.Sync(ctx, height)
+		select {
+		case <-ctx.Done():
+		case replica.mch <- syncResult{cert: cert, err: err}:
+		}
+	}()
+}
+
+// needsSync returns true if there is a Syncer, if the Replica is not already
+// syncing, and if the Replica has observed messages from a greater Height.
+func (replica *Replica) needsSync() bool {
+	return replica.opts.Syncer != nil && !replica.syncing && replica.tip > replica.proc.CurrentHeight
+}
+
+// handleSync handles the result of the Syncer. If the CommitCertificate can be
+// verified against the current committee, then the Process commits its Value
+// and moves to the next Height. Otherwise, the Replica does not sync again
+// until it observes another message from a greater Height, so that it does not
+// keep asking for a certificate that is not available.
+func (replica *Replica) handleSync(result syncResult) {
+	replica.syncing = false
+	if result.err != nil {
+		replica.logError("syncing", result.err)
+		replica.tip = replica.proc.CurrentHeight
+		return
+	}
+	if err := replica.sync(result.cert); err != nil {
+		replica.reject(result.cert, err)
+		replica.tip = replica.proc.CurrentHeight
+	}
+}
+
+// sync verifies the CommitCertificate against the current committee, and
+// syncs the Process with it. The certificate is appended to the WAL before the
+// Process is synced, like any other input, so that the commit is not lost if
+// the Replica crashes before the WAL is truncated.
+func (replica *Replica) sync(cert process.CommitCertificate) error {
+	var err error
+	if powers := replica.proc.VotingPowers(); powers != nil {
+		err = cert.VerifyWithVotingPowers(powers)
+	} else {
+		err = cert.Verify(replica.signatories)
+	}
+	if err != nil {
+		return err
+	}
+	if cert.Height != replica.proc.CurrentHeight {
+		return fmt.Errorf("unexpected height=%v: expected %v", cert.Height, replica.proc.CurrentHeight)
+	}
+	if !replica.append(wal.Entry{Type: wal.EntryTypeSync, Value: cert}) {
+		return fmt.Errorf("appending certificate to wal")
+	}
+	return replica.proc.Sync(cert)
+}
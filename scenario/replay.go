@@ -0,0 +1,204 @@
+package scenario
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/hyperdrive/process/processutil"
+	"github.com/renproject/hyperdrive/scheduler"
+	"github.com/renproject/hyperdrive/timer"
+	"github.com/renproject/surge"
+)
+
+// A Divergence is a Step at which the replayed Process returned different
+// Actions from the recorded Process.
+type Divergence struct {
+	// Index of the Step in the Scenario.
+	Index    int
+	Step     Step
+	Expected []process.Action
+	Actual   []process.Action
+}
+
+// String returns a human-readable report of the Divergence.
+func (d Divergence) String() string {
+	lines := []string{
+		fmt.Sprintf("diverged at step=%v (time=%v, process=%v)", d.Index, d.Step.Time, d.Step.To),
+		fmt.Sprintf("input: %v", Describe(d.Step.Input)),
+		"expected:",
+	}
+	for _, action := range d.Expected {
+		lines = append(lines, "\t"+Describe(action))
+	}
+	lines = append(lines, "actual:")
+	for _, action := range d.Actual {
+		lines = append(lines, "\t"+Describe(action))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Replay the Steps of the Scenario against the current Process code, and
+// return the first Divergence from the recorded Actions. Steps of Byzantine
+// Processes are skipped. Nil is returned if the replayed Processes behave
+// exactly as they did when the Scenario was recorded. An error is returned if
+// the Scenario is malformed.
+//
+// Proposers in the replay propose the Values that were proposed in the
+// recorded run, in the same order, so that the Values are the same even though
+// they were chosen at random.
+func Replay(s Scenario) (*Divergence, error) {
+	n := len(s.Signatories)
+	procs := make([]*process.ActionProcess, n)
+	for i := range procs {
+		proposer := processutil.MockProposer{MockValue: recordedValues(s, i)}
+		validator := processutil.MockValidator{
+			MockValid: func(_ process.Height, _ process.Round, value process.Value) bool {
+				return value != process.NilValue
+			},
+		}
+		proc := process.New(s.Signatories[i], n, nil, scheduler.NewRoundRobin(s.Signatories), proposer, validator, nil, nil, nil, nil).
+			WithRoundWindow(s.RoundWindow)
+		procs[i] = process.NewActionProcess(proc)
+	}
+
+	for index, step := range s.Steps {
+		if step.To < 0 || step.To >= n {
+			return nil, fmt.Errorf("bad process at step=%v: expected<%v, got=%v", index, n, step.To)
+		}
+		if s.IsByzantine(step.To) {
+			continue
+		}
+		actual := apply(procs[step.To], step.Input)
+		if !equal(actual, step.Outputs) {
+			return &Divergence{Index: index, Step: step, Expected: step.Outputs, Actual: actual}, nil
+		}
+	}
+	return nil, nil
+}
+
+// apply the input to the Process, and return the resulting Actions. If the
+// Process commits a Value, then it is notified that the Commit has been
+// executed, and the resulting Actions are included directly after the Commit.
+func apply(proc *process.ActionProcess, input interface{}) []process.Action {
+	var actions []process.Action
+	switch input := input.(type) {
+	case Start:
+		actions = proc.Start()
+	case process.Propose:
+		actions = proc.Propose(input)
+	case process.Prevote:
+		actions = proc.Prevote(input)
+	case process.Precommit:
+		actions = proc.Precommit(input)
+	case process.Timeout:
+		actions = proc.Timeout(input)
+	case timer.Timeout:
+		switch input.MessageType {
+		case process.MessageTypePropose:
+			actions = proc.OnTimeoutPropose(input.Height, input.Round)
+		case process.MessageTypePrevote:
+			actions = proc.OnTimeoutPrevote(input.Height, input.Round)
+		case process.MessageTypePrecommit:
+			actions = proc.OnTimeoutPrecommit(input.Height, input.Round)
+		}
+	case process.CommitCertificate:
+		actions, _ = proc.Sync(input)
+	}
+
+	return flatten(proc, actions)
+}
+
+// flatten the Actions of a Process by notifying it of every Commit, and
+// including the resulting Actions directly after the Commit.
+func flatten(proc *process.ActionProcess, actions []process.Action) []process.Action {
+	outputs := make([]process.Action, 0, len(actions))
+	for _, action := range actions {
+		outputs = append(outputs, action)
+		if _, ok := action.(process.Commit); ok {
+			outputs = append(outputs, flatten(proc, proc.Committed(nil, nil))...)
+		}
+	}
+	return outputs
+}
+
+// recordedValues returns a function that returns the Values that were
+// proposed by the Process with the given index in the recorded run, in order.
+// Only Proposes without a valid Round are included, because the Proposer is
+// not called when the Process re-proposes its valid Value. Once the recorded
+// Values have been used up, the function returns process.NilValue.
+func recordedValues(s Scenario, i int) func() process.Value {
+	values := []process.Value{}
+	for _, step := range s.Steps {
+		if step.To != i {
+			continue
+		}
+		for _, output := range step.Outputs {
+			broadcast, ok := output.(process.Broadcast)
+			if !ok {
+				continue
+			}
+			if propose, ok := broadcast.Message.(process.Propose); ok && propose.ValidRound == process.InvalidRound {
+				values = append(values, propose.Value)
+			}
+		}
+	}
+	return func() process.Value {
+		if len(values) == 0 {
+			return process.NilValue
+		}
+		value := values[0]
+		values = values[1:]
+		return value
+	}
+}
+
+// equal returns true if the Actions have the same binary representation. This
+// is used instead of reflect.DeepEqual, because unmarshaled Actions can have
+// empty lists where the original Actions have nil lists.
+func equal(actions1, actions2 []process.Action) bool {
+	data1, err := surge.ToBinary(actions(actions1))
+	if err != nil {
+		return false
+	}
+	data2, err := surge.ToBinary(actions(actions2))
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(data1, data2)
+}
+
+// Describe returns a human-readable description of an input, Action, or
+// message in a Scenario.
+func Describe(v interface{}) string {
+	switch v := v.(type) {
+	case Start:
+		return "Start"
+	case process.Propose:
+		return fmt.Sprintf("Propose(height=%v, round=%v, validRound=%v, value=%v, from=%v)", v.Height, v.Round, v.ValidRound, v.Value, v.From)
+	case process.Prevote:
+		return fmt.Sprintf("Prevote(height=%v, round=%v, value=%v, from=%v)", v.Height, v.Round, v.Value, v.From)
+	case process.Precommit:
+		return fmt.Sprintf("Precommit(height=%v, round=%v, value=%v, from=%v)", v.Height, v.Round, v.Value, v.From)
+	case process.Timeout:
+		return fmt.Sprintf("Timeout(height=%v, round=%v, from=%v)", v.Height, v.Round, v.From)
+	case timer.Timeout:
+		return fmt.Sprintf("On%vTimeout(height=%v, round=%v)", v.MessageType, v.Height, v.Round)
+	case process.CommitCertificate:
+		return fmt.Sprintf("Sync(height=%v, round=%v, value=%v)", v.Height, v.Round, v.Value)
+	case process.Broadcast:
+		return fmt.Sprintf("Broadcast %v", Describe(v.Message))
+	case process.ScheduleTimeout:
+		return fmt.Sprintf("Schedule%vTimeout(height=%v, round=%v)", v.MessageType, v.Height, v.Round)
+	case process.Commit:
+		return fmt.Sprintf("Commit(height=%v, round=%v, value=%v)", v.Certificate.Height, v.Certificate.Round, v.Certificate.Value)
+	case process.ReportEvidence:
+		messages := make([]string, len(v.Messages))
+		for i, msg := range v.Messages {
+			messages[i] = Describe(msg)
+		}
+		return fmt.Sprintf("ReportEvidence(type=%v, messages=[%v])", v.Type, strings.Join(messages, ", "))
+	}
+	return fmt.Sprintf("%v", v)
+}
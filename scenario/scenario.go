@@ -0,0 +1,610 @@
+// Package scenario defines a documented, versioned file format for recorded
+// runs of Processes, and replays them against the current Process code to
+// find where its behaviour diverges from the recorded run.
+//
+// A Scenario records the configuration of a run (the seed, the signatories,
+// and the round window), the faults that were injected into its messages, and
+// every Step of the run: an input that was given to a Process (a message, a
+// timeout, a commit certificate, or the start of the Process), and the Actions
+// that the Process returned in response. Steps are recorded in the order in
+// which they happened, so the order in which messages were delivered is fully
+// determined by the Scenario, and no timers or networks are needed to replay
+// it. Scenarios are recorded by sim.Simulation when it is tracing.
+//
+// Version 1 of the format is a 4-byte magic string "HDSC", followed by the
+// surge encoding of:
+//
+//	version     uint16
+//	seed        int64
+//	signatories []id.Signatory
+//	byzantine   []uint64
+//	roundWindow process.Round
+//	faults      []Fault
+//	steps       []Step
+//
+// A Fault is the surge encoding of its virtual time (int64 nanoseconds), kind
+// (uint8, see fault.Kind), sender and receiver indices (uint64), message
+// (a value), and delay (int64 nanoseconds). A Step is the surge encoding of its
+// virtual time, the index of the Process (uint64), its input (a value), and
+// its outputs (a list of values). A value is a Kind (uint8) followed by the
+// surge encoding of the value itself:
+//
+//	KindStart                    (nothing)
+//	KindPropose                  process.Propose
+//	KindPrevote                  process.Prevote
+//	KindPrecommit                process.Precommit
+//	KindTimeout                  process.Timeout
+//	KindTimer                    timer.Timeout
+//	KindCertificate              process.CommitCertificate
+//	KindBroadcast                value
+//	KindScheduleTimeout          timer.Timeout
+//	KindCommit                   process.CommitCertificate
+//	KindReportEvidence           process.EvidenceType (uint8), []value
+//
+// Processes in a Scenario use a round-robin scheduler over the signatories,
+// and a validator that accepts every Value except process.NilValue. These are
+// the same as in a sim.Simulation.
+package scenario
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/renproject/hyperdrive/fault"
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/hyperdrive/timer"
+	"github.com/renproject/id"
+	"github.com/renproject/surge"
+)
+
+// Version of the format in which Scenarios are written. Scenarios with a
+// different version cannot be read.
+const Version uint16 = 1
+
+// magic is the string with which every Scenario file starts.
+var magic = []byte("HDSC")
+
+// Kind enumerates the kinds of values that are stored in a Scenario.
+type Kind uint8
+
+const (
+	// KindStart is the input that starts a Process.
+	KindStart Kind = 1
+	// KindPropose is a process.Propose.
+	KindPropose Kind = 2
+	// KindPrevote is a process.Prevote.
+	KindPrevote Kind = 3
+	// KindPrecommit is a process.Precommit.
+	KindPrecommit Kind = 4
+	// KindTimeout is a process.Timeout message.
+	KindTimeout Kind = 5
+	// KindTimer is a timer.Timeout that has expired.
+	KindTimer Kind = 6
+	// KindCertificate is a process.CommitCertificate with which a Process is
+	// synced.
+	KindCertificate Kind = 7
+	// KindBroadcast is a process.Broadcast Action.
+	KindBroadcast Kind = 8
+	// KindScheduleTimeout is a process.ScheduleTimeout Action.
+	KindScheduleTimeout Kind = 9
+	// KindCommit is a process.Commit Action.
+	KindCommit Kind = 10
+	// KindReportEvidence is a process.ReportEvidence Action.
+	KindReportEvidence Kind = 11
+)
+
+// Start is the input that starts a Process.
+type Start struct{}
+
+// A Step is an input that was given to a Process, and the Actions that the
+// Process returned in response. The input is a Start, a process.Propose,
+// process.Prevote, process.Precommit, or process.Timeout message, a
+// timer.Timeout that has expired, or a process.CommitCertificate with which
+// the Process was synced. If the Process committed a Value, then the Actions
+// returned by process.ActionProcess.Committed are included in the outputs,
+// directly after the process.Commit Action.
+type Step struct {
+	Time    time.Duration
+	To      int
+	Input   interface{}
+	Outputs []process.Action
+}
+
+// A Fault that was injected into a message when it was sent from one Process
+// to another.
+type Fault struct {
+	Time    time.Duration
+	Kind    fault.Kind
+	From    int
+	To      int
+	Message interface{}
+	Delay   time.Duration
+}
+
+// A Scenario is a recorded run of Processes.
+type Scenario struct {
+	Seed        int64
+	Signatories []id.Signatory
+	// Byzantine are the indices of the Processes that misbehaved. Their Steps
+	// are recorded, but they are not replayed, because their behaviour does
+	// not follow from the Process code.
+	Byzantine   []int
+	RoundWindow process.Round
+	Faults      []Fault
+	Steps       []Step
+}
+
+// IsByzantine returns true if the Process with the given index misbehaved.
+func (s Scenario) IsByzantine(i int) bool {
+	for _, j := range s.Byzantine {
+		if i == j {
+			return true
+		}
+	}
+	return false
+}
+
+// SizeHint returns the number of bytes required to represent this Scenario in
+// binary, excluding the magic string.
+func (s Scenario) SizeHint() int {
+	return surge.SizeHint(Version) +
+		surge.SizeHint(s.Seed) +
+		surge.SizeHint(s.Signatories) +
+		surge.SizeHint(indices(s.Byzantine)) +
+		surge.SizeHint(s.RoundWindow) +
+		surge.SizeHint(s.Faults) +
+		surge.SizeHint(s.Steps)
+}
+
+// Marshal this Scenario into binary, excluding the magic string.
+func (s Scenario) Marshal(buf []byte, rem int) ([]byte, int, error) {
+	buf, rem, err := surge.Marshal(Version, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling version=%v: %v", Version, err)
+	}
+	buf, rem, err = surge.Marshal(s.Seed, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling seed=%v: %v", s.Seed, err)
+	}
+	buf, rem, err = surge.Marshal(s.Signatories, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling %v signatories: %v", len(s.Signatories), err)
+	}
+	buf, rem, err = surge.Marshal(indices(s.Byzantine), buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling byzantine=%v: %v", s.Byzantine, err)
+	}
+	buf, rem, err = surge.Marshal(s.RoundWindow, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling round window=%v: %v", s.RoundWindow, err)
+	}
+	buf, rem, err = surge.Marshal(s.Faults, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling %v faults: %v", len(s.Faults), err)
+	}
+	buf, rem, err = surge.Marshal(s.Steps, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling %v steps: %v", len(s.Steps), err)
+	}
+	return buf, rem, nil
+}
+
+// Unmarshal binary into this Scenario, excluding the magic string. An error is
+// returned if the binary is not in the current Version of the format.
+func (s *Scenario) Unmarshal(buf []byte, rem int) ([]byte, int, error) {
+	version := uint16(0)
+	buf, rem, err := surge.Unmarshal(&version, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling version: %v", err)
+	}
+	if version != Version {
+		return buf, rem, fmt.Errorf("unsupported version: expected=%v, got=%v", Version, version)
+	}
+	buf, rem, err = surge.Unmarshal(&s.Seed, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling seed: %v", err)
+	}
+	buf, rem, err = surge.Unmarshal(&s.Signatories, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling signatories: %v", err)
+	}
+	byzantine := []uint64{}
+	buf, rem, err = surge.Unmarshal(&byzantine, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling byzantine: %v", err)
+	}
+	s.Byzantine = make([]int, len(byzantine))
+	for i := range byzantine {
+		s.Byzantine[i] = int(byzantine[i])
+	}
+	buf, rem, err = surge.Unmarshal(&s.RoundWindow, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling round window: %v", err)
+	}
+	buf, rem, err = surge.Unmarshal(&s.Faults, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling faults: %v", err)
+	}
+	buf, rem, err = surge.Unmarshal(&s.Steps, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling steps: %v", err)
+	}
+	return buf, rem, nil
+}
+
+// SizeHint returns the number of bytes required to represent this Step in
+// binary.
+func (step Step) SizeHint() int {
+	return surge.SizeHint(step.Time) +
+		surge.SizeHint(uint64(step.To)) +
+		value{step.Input}.SizeHint() +
+		surge.SizeHint(actions(step.Outputs))
+}
+
+// Marshal this Step into binary.
+func (step Step) Marshal(buf []byte, rem int) ([]byte, int, error) {
+	buf, rem, err := surge.Marshal(step.Time, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling time=%v: %v", step.Time, err)
+	}
+	buf, rem, err = surge.Marshal(uint64(step.To), buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling to=%v: %v", step.To, err)
+	}
+	buf, rem, err = value{step.Input}.Marshal(buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling input=%v: %v", step.Input, err)
+	}
+	buf, rem, err = surge.Marshal(actions(step.Outputs), buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling %v outputs: %v", len(step.Outputs), err)
+	}
+	return buf, rem, nil
+}
+
+// Unmarshal binary into this Step.
+func (step *Step) Unmarshal(buf []byte, rem int) ([]byte, int, error) {
+	buf, rem, err := surge.Unmarshal(&step.Time, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling time: %v", err)
+	}
+	to := uint64(0)
+	buf, rem, err = surge.Unmarshal(&to, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling to: %v", err)
+	}
+	step.To = int(to)
+	input := value{}
+	buf, rem, err = input.Unmarshal(buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling input: %v", err)
+	}
+	step.Input = input.v
+	outputs := []value{}
+	buf, rem, err = surge.Unmarshal(&outputs, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling outputs: %v", err)
+	}
+	step.Outputs = make([]process.Action, len(outputs))
+	for i, output := range outputs {
+		action, ok := output.v.(process.Action)
+		if !ok {
+			return buf, rem, fmt.Errorf("unmarshaling outputs: expected action, got %T", output.v)
+		}
+		step.Outputs[i] = action
+	}
+	return buf, rem, nil
+}
+
+// SizeHint returns the number of bytes required to represent this Fault in
+// binary.
+func (f Fault) SizeHint() int {
+	return surge.SizeHint(f.Time) +
+		surge.SizeHint(uint8(f.Kind)) +
+		surge.SizeHint(uint64(f.From)) +
+		surge.SizeHint(uint64(f.To)) +
+		value{f.Message}.SizeHint() +
+		surge.SizeHint(f.Delay)
+}
+
+// Marshal this Fault into binary.
+func (f Fault) Marshal(buf []byte, rem int) ([]byte, int, error) {
+	buf, rem, err := surge.Marshal(f.Time, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling time=%v: %v", f.Time, err)
+	}
+	buf, rem, err = surge.Marshal(uint8(f.Kind), buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling kind=%v: %v", f.Kind, err)
+	}
+	buf, rem, err = surge.Marshal(uint64(f.From), buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling from=%v: %v", f.From, err)
+	}
+	buf, rem, err = surge.Marshal(uint64(f.To), buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling to=%v: %v", f.To, err)
+	}
+	buf, rem, err = value{f.Message}.Marshal(buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling message=%v: %v", f.Message, err)
+	}
+	buf, rem, err = surge.Marshal(f.Delay, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling delay=%v: %v", f.Delay, err)
+	}
+	return buf, rem, nil
+}
+
+// Unmarshal binary into this Fault.
+func (f *Fault) Unmarshal(buf []byte, rem int) ([]byte, int, error) {
+	buf, rem, err := surge.Unmarshal(&f.Time, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling time: %v", err)
+	}
+	buf, rem, err = surge.Unmarshal((*uint8)(&f.Kind), buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling kind: %v", err)
+	}
+	from, to := uint64(0), uint64(0)
+	buf, rem, err = surge.Unmarshal(&from, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling from: %v", err)
+	}
+	buf, rem, err = surge.Unmarshal(&to, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling to: %v", err)
+	}
+	f.From, f.To = int(from), int(to)
+	msg := value{}
+	buf, rem, err = msg.Unmarshal(buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling message: %v", err)
+	}
+	f.Message = msg.v
+	buf, rem, err = surge.Unmarshal(&f.Delay, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling delay: %v", err)
+	}
+	return buf, rem, nil
+}
+
+// Write the Scenario, in the current Version of the format.
+func Write(w io.Writer, s Scenario) error {
+	sizeHint := s.SizeHint()
+	buf := make([]byte, len(magic)+sizeHint)
+	copy(buf, magic)
+	if _, _, err := s.Marshal(buf[len(magic):], sizeHint); err != nil {
+		return fmt.Errorf("marshaling scenario: %v", err)
+	}
+	if _, err := w.Write(buf); err != nil {
+		return fmt.Errorf("writing scenario: %v", err)
+	}
+	return nil
+}
+
+// Read a Scenario. An error is returned if it is not in the current Version
+// of the format.
+func Read(r io.Reader) (Scenario, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return Scenario{}, fmt.Errorf("reading scenario: %v", err)
+	}
+	if !bytes.HasPrefix(data, magic) {
+		return Scenario{}, fmt.Errorf("reading scenario: bad magic")
+	}
+	s := Scenario{}
+	if _, _, err := s.Unmarshal(data[len(magic):], surge.MaxBytes); err != nil {
+		return Scenario{}, fmt.Errorf("unmarshaling scenario: %v", err)
+	}
+	return s, nil
+}
+
+// WriteFile writes the Scenario to the file with the given name, creating the
+// file if it does not exist, and truncating it if it does.
+func WriteFile(name string, s Scenario) error {
+	file, err := os.Create(name)
+	if err != nil {
+		return fmt.Errorf("creating %v: %v", name, err)
+	}
+	if err := Write(file, s); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// ReadFile reads the Scenario from the file with the given name.
+func ReadFile(name string) (Scenario, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return Scenario{}, fmt.Errorf("opening %v: %v", name, err)
+	}
+	defer file.Close()
+	return Read(file)
+}
+
+// value wraps one of the values that are stored in a Scenario, so that it can
+// be marshaled along with its Kind.
+type value struct {
+	v interface{}
+}
+
+// kind returns the Kind of the value, and the Marshaler of its contents.
+func (v value) kind() (Kind, surge.Marshaler, error) {
+	switch v := v.v.(type) {
+	case Start:
+		return KindStart, nil, nil
+	case process.Propose:
+		return KindPropose, v, nil
+	case process.Prevote:
+		return KindPrevote, v, nil
+	case process.Precommit:
+		return KindPrecommit, v, nil
+	case process.Timeout:
+		return KindTimeout, v, nil
+	case timer.Timeout:
+		return KindTimer, v, nil
+	case process.CommitCertificate:
+		return KindCertificate, v, nil
+	case process.Broadcast:
+		return KindBroadcast, value{v.Message}, nil
+	case process.ScheduleTimeout:
+		return KindScheduleTimeout, timer.Timeout{MessageType: v.MessageType, Height: v.Height, Round: v.Round}, nil
+	case process.Commit:
+		return KindCommit, v.Certificate, nil
+	case process.ReportEvidence:
+		return KindReportEvidence, evidence(v), nil
+	}
+	return 0, nil, fmt.Errorf("unknown value type %T", v.v)
+}
+
+func (v value) SizeHint() int {
+	kind, contents, err := v.kind()
+	if err != nil {
+		return 0
+	}
+	if contents == nil {
+		return surge.SizeHint(uint8(kind))
+	}
+	return surge.SizeHint(uint8(kind)) + contents.SizeHint()
+}
+
+func (v value) Marshal(buf []byte, rem int) ([]byte, int, error) {
+	kind, contents, err := v.kind()
+	if err != nil {
+		return buf, rem, err
+	}
+	buf, rem, err = surge.Marshal(uint8(kind), buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling kind=%v: %v", kind, err)
+	}
+	if contents == nil {
+		return buf, rem, nil
+	}
+	return contents.Marshal(buf, rem)
+}
+
+func (v *value) Unmarshal(buf []byte, rem int) ([]byte, int, error) {
+	kind := Kind(0)
+	buf, rem, err := surge.Unmarshal((*uint8)(&kind), buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling kind: %v", err)
+	}
+
+	switch kind {
+	case KindStart:
+		v.v = Start{}
+	case KindPropose:
+		propose := process.Propose{}
+		buf, rem, err = propose.Unmarshal(buf, rem)
+		v.v = propose
+	case KindPrevote:
+		prevote := process.Prevote{}
+		buf, rem, err = prevote.Unmarshal(buf, rem)
+		v.v = prevote
+	case KindPrecommit:
+		precommit := process.Precommit{}
+		buf, rem, err = precommit.Unmarshal(buf, rem)
+		v.v = precommit
+	case KindTimeout:
+		timeout := process.Timeout{}
+		buf, rem, err = timeout.Unmarshal(buf, rem)
+		v.v = timeout
+	case KindTimer:
+		timeout := timer.Timeout{}
+		buf, rem, err = timeout.Unmarshal(buf, rem)
+		v.v = timeout
+	case KindCertificate:
+		cert := process.CommitCertificate{}
+		buf, rem, err = cert.Unmarshal(buf, rem)
+		v.v = cert
+	case KindBroadcast:
+		msg := value{}
+		buf, rem, err = msg.Unmarshal(buf, rem)
+		v.v = process.Broadcast{Message: msg.v}
+	case KindScheduleTimeout:
+		timeout := timer.Timeout{}
+		buf, rem, err = timeout.Unmarshal(buf, rem)
+		v.v = process.ScheduleTimeout{Height: timeout.Height, Round: timeout.Round, MessageType: timeout.MessageType}
+	case KindCommit:
+		cert := process.CommitCertificate{}
+		buf, rem, err = cert.Unmarshal(buf, rem)
+		v.v = process.Commit{Certificate: cert}
+	case KindReportEvidence:
+		report := evidence{}
+		buf, rem, err = report.Unmarshal(buf, rem)
+		v.v = process.ReportEvidence(report)
+	default:
+		return buf, rem, fmt.Errorf("unmarshaling value: unknown kind=%v", kind)
+	}
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling value: %v", err)
+	}
+	return buf, rem, nil
+}
+
+// evidence wraps a process.ReportEvidence so that it can be marshaled.
+type evidence process.ReportEvidence
+
+func (e evidence) messages() []value {
+	messages := make([]value, len(e.Messages))
+	for i, msg := range e.Messages {
+		messages[i] = value{msg}
+	}
+	return messages
+}
+
+func (e evidence) SizeHint() int {
+	return surge.SizeHint(uint8(e.Type)) +
+		surge.SizeHint(e.messages())
+}
+
+func (e evidence) Marshal(buf []byte, rem int) ([]byte, int, error) {
+	buf, rem, err := surge.Marshal(uint8(e.Type), buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling type=%v: %v", e.Type, err)
+	}
+	buf, rem, err = surge.Marshal(e.messages(), buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling %v messages: %v", len(e.Messages), err)
+	}
+	return buf, rem, nil
+}
+
+func (e *evidence) Unmarshal(buf []byte, rem int) ([]byte, int, error) {
+	buf, rem, err := surge.Unmarshal((*uint8)(&e.Type), buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling type: %v", err)
+	}
+	messages := []value{}
+	buf, rem, err = surge.Unmarshal(&messages, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling messages: %v", err)
+	}
+	e.Messages = make([]interface{}, len(messages))
+	for i, msg := range messages {
+		e.Messages[i] = msg.v
+	}
+	return buf, rem, nil
+}
+
+func actions(outputs []process.Action) []value {
+	values := make([]value, len(outputs))
+	for i, output := range outputs {
+		values[i] = value{output}
+	}
+	return values
+}
+
+func indices(is []int) []uint64 {
+	us := make([]uint64, len(is))
+	for k, i := range is {
+		us[k] = uint64(i)
+	}
+	return us
+}
@@ -0,0 +1,198 @@
+package scenario_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing/quick"
+	"time"
+
+	"github.com/renproject/hyperdrive/byzantine"
+	"github.com/renproject/hyperdrive/fault"
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/hyperdrive/process/processutil"
+	"github.com/renproject/hyperdrive/scenario"
+	"github.com/renproject/hyperdrive/sim"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Scenario", func() {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	// record a scenario by running a traced simulation until every process
+	// has committed ten heights
+	record := func(opts sim.Options, n int) scenario.Scenario {
+		s := sim.New(opts.WithTrace(true), n)
+		s.RunUntil(func() bool {
+			for i := 0; i < n; i++ {
+				if s.CurrentHeight(i) <= 10 {
+					return false
+				}
+			}
+			return true
+		}, time.Hour)
+		return s.Scenario()
+	}
+
+	// faults returns a fault model that drops, duplicates, and delays messages
+	faults := func() fault.Model {
+		return fault.NewModel().
+			Drop(fault.Always(), fault.AllLinks(), 0.05).
+			Duplicate(fault.Always(), fault.AllLinks(), 0.05).
+			Delay(fault.Always(), fault.AllLinks(), 0, time.Second)
+	}
+
+	Context("when writing and reading a scenario", func() {
+		It("should equal itself", func() {
+			f := func(seed int64) bool {
+				s := record(sim.DefaultOptions().WithSeed(seed).WithFaults(faults()), 4)
+				Expect(s.Steps).ToNot(BeEmpty())
+				Expect(s.Faults).ToNot(BeEmpty())
+
+				buf := new(bytes.Buffer)
+				Expect(scenario.Write(buf, s)).To(Succeed())
+				read, err := scenario.Read(buf)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(read.Seed).To(Equal(s.Seed))
+				Expect(read.Signatories).To(Equal(s.Signatories))
+				Expect(read.Byzantine).To(Equal(s.Byzantine))
+				Expect(read.RoundWindow).To(Equal(s.RoundWindow))
+				Expect(read.Faults).To(HaveLen(len(s.Faults)))
+				Expect(read.Steps).To(HaveLen(len(s.Steps)))
+
+				// writing the read scenario gives the same bytes
+				buf1, buf2 := new(bytes.Buffer), new(bytes.Buffer)
+				Expect(scenario.Write(buf1, s)).To(Succeed())
+				Expect(scenario.Write(buf2, read)).To(Succeed())
+				Expect(buf1.Bytes()).To(Equal(buf2.Bytes()))
+				return true
+			}
+			Expect(quick.Check(f, &quick.Config{MaxCount: 5})).To(Succeed())
+		})
+
+		It("should round-trip through a file", func() {
+			dir, err := ioutil.TempDir("", "scenario")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(dir)
+
+			s := record(sim.DefaultOptions().WithSeed(r.Int63()), 4)
+			name := filepath.Join(dir, "scenario.hdsc")
+			Expect(scenario.WriteFile(name, s)).To(Succeed())
+			read, err := scenario.ReadFile(name)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(read.Steps).To(HaveLen(len(s.Steps)))
+		})
+	})
+
+	Context("when reading a scenario in a bad format", func() {
+		It("should return an error for a bad magic string", func() {
+			buf := new(bytes.Buffer)
+			Expect(scenario.Write(buf, scenario.Scenario{})).To(Succeed())
+			data := buf.Bytes()
+			data[0] = 'X'
+			_, err := scenario.Read(bytes.NewReader(data))
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should return an error for an unsupported version", func() {
+			buf := new(bytes.Buffer)
+			Expect(scenario.Write(buf, scenario.Scenario{})).To(Succeed())
+			data := buf.Bytes()
+			// the version follows the magic string
+			data[4], data[5] = 0xFF, 0xFF
+			_, err := scenario.Read(bytes.NewReader(data))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("version"))
+		})
+
+		It("should return an error for truncated data", func() {
+			s := record(sim.DefaultOptions().WithSeed(r.Int63()), 4)
+			buf := new(bytes.Buffer)
+			Expect(scenario.Write(buf, s)).To(Succeed())
+			data := buf.Bytes()
+			_, err := scenario.Read(bytes.NewReader(data[:len(data)/2]))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("when replaying a recorded scenario", func() {
+		It("should not diverge", func() {
+			f := func(seed int64) bool {
+				s := record(sim.DefaultOptions().WithSeed(seed).WithFaults(faults()), 4)
+				buf := new(bytes.Buffer)
+				Expect(scenario.Write(buf, s)).To(Succeed())
+				read, err := scenario.Read(buf)
+				Expect(err).ToNot(HaveOccurred())
+
+				divergence, err := scenario.Replay(read)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(divergence).To(BeNil())
+				return true
+			}
+			Expect(quick.Check(f, &quick.Config{MaxCount: 5})).To(Succeed())
+		})
+
+		It("should not diverge when processes are byzantine", func() {
+			opts := sim.DefaultOptions().
+				WithSeed(r.Int63()).
+				WithBehaviour(0, byzantine.EquivocatingProposer()).
+				WithSync(true)
+			s := record(opts, 4)
+			Expect(s.Byzantine).To(Equal([]int{0}))
+
+			divergence, err := scenario.Replay(s)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(divergence).To(BeNil())
+		})
+
+		It("should report the first step at which the outputs differ", func() {
+			s := record(sim.DefaultOptions().WithSeed(r.Int63()), 4)
+
+			// tamper with the value of the first prevote that was broadcast
+			// in response to a propose
+			index := -1
+			for i, step := range s.Steps {
+				if _, ok := step.Input.(process.Propose); !ok {
+					continue
+				}
+				for j, output := range step.Outputs {
+					broadcast, ok := output.(process.Broadcast)
+					if !ok {
+						continue
+					}
+					if prevote, ok := broadcast.Message.(process.Prevote); ok {
+						prevote.Value = processutil.RandomGoodValue(r)
+						outputs := append([]process.Action{}, step.Outputs...)
+						outputs[j] = process.Broadcast{Message: prevote}
+						s.Steps[i].Outputs = outputs
+						index = i
+						break
+					}
+				}
+				if index >= 0 {
+					break
+				}
+			}
+			Expect(index).To(BeNumerically(">=", 0))
+
+			divergence, err := scenario.Replay(s)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(divergence).ToNot(BeNil())
+			Expect(divergence.Index).To(Equal(index))
+			Expect(divergence.Expected).To(Equal(s.Steps[index].Outputs))
+			Expect(divergence.Actual).ToNot(Equal(divergence.Expected))
+			Expect(divergence.String()).To(ContainSubstring("diverged at step"))
+		})
+
+		It("should return an error for a step of an unknown process", func() {
+			s := record(sim.DefaultOptions().WithSeed(r.Int63()), 4)
+			s.Steps[len(s.Steps)-1].To = 4
+			_, err := scenario.Replay(s)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,212 @@
+// Package signer implements a process.Signer that protects against signing
+// conflicting messages (and being slashed for equivocation), even across
+// restarts.
+package signer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/id"
+)
+
+// LastSigned describes the last message signed by a FileSigner. Messages are
+// ordered by their Height, then their Round, and then their Step (Proposes are
+// signed in the Proposing step, Prevotes in the Prevoting step, and Precommits
+// in the Precommitting step).
+type LastSigned struct {
+	Height    process.Height `json:"height"`
+	Round     process.Round  `json:"round"`
+	Step      process.Step   `json:"step"`
+	Value     process.Value  `json:"value"`
+	Hash      id.Hash        `json:"hash"`
+	Signature id.Signature   `json:"signature"`
+}
+
+// A FileSigner signs messages using a private key, and persists the last
+// message that it signed to a file before returning the signature. It refuses
+// to sign a message that comes before the last signed message, and a message
+// at the same Height, Round, and Step that is different from the last signed
+// message. Signing the same message again returns the same signature. It is
+// safe for concurrent use.
+//
+// The file must not be shared between FileSigners, and must not be deleted
+// when restarting, otherwise the protection is lost.
+type FileSigner struct {
+	mu      *sync.Mutex
+	privKey *id.PrivKey
+	name    string
+	last    LastSigned
+}
+
+// NewFileSigner returns a FileSigner that signs messages using the given
+// private key, and persists the last signed message to the file with the given
+// name. If the file exists, then the last signed message is loaded from it.
+func NewFileSigner(privKey *id.PrivKey, name string) (*FileSigner, error) {
+	signer := &FileSigner{
+		mu:      new(sync.Mutex),
+		privKey: privKey,
+		name:    name,
+	}
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return signer, nil
+		}
+		return nil, fmt.Errorf("reading %v: %v", name, err)
+	}
+	if err := json.Unmarshal(data, &signer.last); err != nil {
+		return nil, fmt.Errorf("unmarshaling %v: %v", name, err)
+	}
+	return signer, nil
+}
+
+// LastSigned returns the last message signed by the FileSigner.
+func (signer *FileSigner) LastSigned() LastSigned {
+	signer.mu.Lock()
+	defer signer.mu.Unlock()
+
+	return signer.last
+}
+
+// SignPropose signs the Propose, unless it conflicts with the last signed
+// message.
+func (signer *FileSigner) SignPropose(propose process.Propose) (process.Propose, error) {
+	hash, err := process.NewProposeHash(propose.Height, propose.Round, propose.ValidRound, propose.Value)
+	if err != nil {
+		return propose, fmt.Errorf("hashing propose: %v", err)
+	}
+	signature, err := signer.sign(propose.Height, propose.Round, process.Proposing, propose.Value, hash)
+	if err != nil {
+		return propose, fmt.Errorf("signing propose: %v", err)
+	}
+	propose.From = signer.privKey.Signatory()
+	propose.Signature = signature
+	return propose, nil
+}
+
+// SignPrevote signs the Prevote, unless it conflicts with the last signed
+// message.
+func (signer *FileSigner) SignPrevote(prevote process.Prevote) (process.Prevote, error) {
+	hash, err := process.NewPrevoteHash(prevote.Height, prevote.Round, prevote.Value)
+	if err != nil {
+		return prevote, fmt.Errorf("hashing prevote: %v", err)
+	}
+	signature, err := signer.sign(prevote.Height, prevote.Round, process.Prevoting, prevote.Value, hash)
+	if err != nil {
+		return prevote, fmt.Errorf("signing prevote: %v", err)
+	}
+	prevote.From = signer.privKey.Signatory()
+	prevote.Signature = signature
+	return prevote, nil
+}
+
+// SignPrecommit signs the Precommit, unless it conflicts with the last signed
+// message.
+func (signer *FileSigner) SignPrecommit(precommit process.Precommit) (process.Precommit, error) {
+	hash, err := process.NewPrecommitHash(precommit.Height, precommit.Round, precommit.Value)
+	if err != nil {
+		return precommit, fmt.Errorf("hashing precommit: %v", err)
+	}
+	signature, err := signer.sign(precommit.Height, precommit.Round, process.Precommitting, precommit.Value, hash)
+	if err != nil {
+		return precommit, fmt.Errorf("signing precommit: %v", err)
+	}
+	precommit.From = signer.privKey.Signatory()
+	precommit.Signature = signature
+	return precommit, nil
+}
+
+// SignTimeout signs the Timeout. Timeouts do not have Values, and so they can
+// never conflict with each other (or with other messages). They are signed
+// without being persisted, and without changing the last signed message.
+func (signer *FileSigner) SignTimeout(timeout process.Timeout) (process.Timeout, error) {
+	hash, err := process.NewTimeoutHash(timeout.Height, timeout.Round)
+	if err != nil {
+		return timeout, fmt.Errorf("hashing timeout: %v", err)
+	}
+	signature, err := signer.privKey.Sign(&hash)
+	if err != nil {
+		return timeout, fmt.Errorf("signing timeout: %v", err)
+	}
+	timeout.From = signer.privKey.Signatory()
+	timeout.Signature = signature
+	return timeout, nil
+}
+
+func (signer *FileSigner) sign(height process.Height, round process.Round, step process.Step, value process.Value, hash id.Hash) (id.Signature, error) {
+	signer.mu.Lock()
+	defer signer.mu.Unlock()
+
+	last := signer.last
+	if last.Height != 0 {
+		if height < last.Height || (height == last.Height && round < last.Round) || (height == last.Height && round == last.Round && step < last.Step) {
+			return id.Signature{}, fmt.Errorf("regression: last signed height=%v, round=%v, step=%v", last.Height, last.Round, last.Step)
+		}
+		if height == last.Height && round == last.Round && step == last.Step {
+			if hash.Equal(&last.Hash) {
+				return last.Signature, nil
+			}
+			return id.Signature{}, fmt.Errorf("conflict: already signed value=%v at height=%v, round=%v, step=%v", last.Value, last.Height, last.Round, last.Step)
+		}
+	}
+
+	signature, err := signer.privKey.Sign(&hash)
+	if err != nil {
+		return id.Signature{}, err
+	}
+	next := LastSigned{
+		Height:    height,
+		Round:     round,
+		Step:      step,
+		Value:     value,
+		Hash:      hash,
+		Signature: signature,
+	}
+	if err := signer.persist(next); err != nil {
+		return id.Signature{}, fmt.Errorf("persisting last signed: %v", err)
+	}
+	signer.last = next
+	return signature, nil
+}
+
+// persist the last signed message, by writing it to a temporary file and then
+// renaming the temporary file, so that the file is never partially written. The
+// directory is synced after the rename, so that the rename is durable before
+// the signature is returned.
+func (signer *FileSigner) persist(last LastSigned) error {
+	data, err := json.Marshal(last)
+	if err != nil {
+		return err
+	}
+	tmp := signer.name + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, signer.name); err != nil {
+		return err
+	}
+	dir, err := os.Open(filepath.Dir(signer.name))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
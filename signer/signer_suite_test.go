@@ -0,0 +1,13 @@
+package signer_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestSigner(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Signer Suite")
+}
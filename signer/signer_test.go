@@ -0,0 +1,140 @@
+package signer_test
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/hyperdrive/process/processutil"
+	"github.com/renproject/hyperdrive/signer"
+	"github.com/renproject/id"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Signer", func() {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "signer")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	newSigner := func(privKey *id.PrivKey) *signer.FileSigner {
+		s, err := signer.NewFileSigner(privKey, filepath.Join(dir, "signer"))
+		Expect(err).ToNot(HaveOccurred())
+		return s
+	}
+
+	Context("when signing messages", func() {
+		It("should return messages that can be verified", func() {
+			privKey := id.NewPrivKey()
+			s := newSigner(privKey)
+			value := processutil.RandomGoodValue(r)
+
+			propose, err := s.SignPropose(process.Propose{Height: 1, Round: 0, ValidRound: process.InvalidRound, Value: value})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(propose.From).To(Equal(privKey.Signatory()))
+			Expect(propose.Verify()).To(Succeed())
+
+			prevote, err := s.SignPrevote(process.Prevote{Height: 1, Round: 0, Value: value})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(prevote.From).To(Equal(privKey.Signatory()))
+			Expect(prevote.Verify()).To(Succeed())
+
+			precommit, err := s.SignPrecommit(process.Precommit{Height: 1, Round: 0, Value: value})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(precommit.From).To(Equal(privKey.Signatory()))
+			Expect(precommit.Verify()).To(Succeed())
+
+			last := s.LastSigned()
+			Expect(last.Height).To(Equal(process.Height(1)))
+			Expect(last.Round).To(Equal(process.Round(0)))
+			Expect(last.Step).To(Equal(process.Precommitting))
+			Expect(last.Value).To(Equal(value))
+		})
+
+		It("should return the same signature when signing the same message again", func() {
+			s := newSigner(id.NewPrivKey())
+			prevote := process.Prevote{Height: 1, Round: 0, Value: processutil.RandomGoodValue(r)}
+
+			first, err := s.SignPrevote(prevote)
+			Expect(err).ToNot(HaveOccurred())
+			second, err := s.SignPrevote(prevote)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(second.Signature).To(Equal(first.Signature))
+		})
+
+		It("should refuse to sign a conflicting message", func() {
+			s := newSigner(id.NewPrivKey())
+			_, err := s.SignPrevote(process.Prevote{Height: 1, Round: 0, Value: processutil.RandomGoodValue(r)})
+			Expect(err).ToNot(HaveOccurred())
+			_, err = s.SignPrevote(process.Prevote{Height: 1, Round: 0, Value: process.NilValue})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should refuse to sign a message before the last signed message", func() {
+			s := newSigner(id.NewPrivKey())
+			value := processutil.RandomGoodValue(r)
+			_, err := s.SignPrevote(process.Prevote{Height: 2, Round: 1, Value: value})
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = s.SignPrevote(process.Prevote{Height: 1, Round: 1, Value: value})
+			Expect(err).To(HaveOccurred())
+			_, err = s.SignPrevote(process.Prevote{Height: 2, Round: 0, Value: value})
+			Expect(err).To(HaveOccurred())
+			_, err = s.SignPropose(process.Propose{Height: 2, Round: 1, ValidRound: process.InvalidRound, Value: value})
+			Expect(err).To(HaveOccurred())
+
+			_, err = s.SignPrevote(process.Prevote{Height: 2, Round: 2, Value: value})
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+
+	Context("when signing timeouts", func() {
+		It("should not change the last signed message", func() {
+			s := newSigner(id.NewPrivKey())
+			value := processutil.RandomGoodValue(r)
+			_, err := s.SignPrecommit(process.Precommit{Height: 1, Round: 1, Value: value})
+			Expect(err).ToNot(HaveOccurred())
+			last := s.LastSigned()
+
+			timeout, err := s.SignTimeout(process.Timeout{Height: 1, Round: 1})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(timeout.Verify()).To(Succeed())
+			Expect(s.LastSigned()).To(Equal(last))
+
+			_, err = s.SignPrecommit(process.Precommit{Height: 1, Round: 1, Value: value})
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+
+	Context("when restarting", func() {
+		It("should refuse to sign messages that conflict with messages signed before the restart", func() {
+			privKey := id.NewPrivKey()
+			value := processutil.RandomGoodValue(r)
+			_, err := newSigner(privKey).SignPrecommit(process.Precommit{Height: 3, Round: 2, Value: value})
+			Expect(err).ToNot(HaveOccurred())
+
+			s := newSigner(privKey)
+			Expect(s.LastSigned().Height).To(Equal(process.Height(3)))
+			_, err = s.SignPrecommit(process.Precommit{Height: 3, Round: 2, Value: process.NilValue})
+			Expect(err).To(HaveOccurred())
+			_, err = s.SignPrevote(process.Prevote{Height: 3, Round: 2, Value: value})
+			Expect(err).To(HaveOccurred())
+			_, err = s.SignPrecommit(process.Precommit{Height: 3, Round: 2, Value: value})
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+})
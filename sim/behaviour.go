@@ -0,0 +1,64 @@
+package sim
+
+import (
+	"math/rand"
+
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/id"
+)
+
+// A Behaviour controls the messages that are sent by a Process, and is used to
+// simulate Byzantine Processes (see the byzantine package). Whenever the
+// Process broadcasts a message, the Behaviour is given the message, and
+// returns the Deliveries that are actually sent. An honest Process delivers
+// every message to every Process. Behaviours are called on the same goroutine
+// as the Process, so they can also modify the State of the Process.
+type Behaviour interface {
+	Broadcast(node Node, msg interface{}) []Delivery
+}
+
+// BehaviourFunc is a function that implements the Behaviour interface.
+type BehaviourFunc func(node Node, msg interface{}) []Delivery
+
+// Broadcast implements the Behaviour interface.
+func (f BehaviourFunc) Broadcast(node Node, msg interface{}) []Delivery {
+	return f(node, msg)
+}
+
+// A Delivery of a message to the Process with the given index.
+type Delivery struct {
+	To      int
+	Message interface{}
+}
+
+// A Node is the view of a Process, and of the Simulation, that is given to the
+// Behaviour of the Process.
+type Node struct {
+	// Index of the Process in the Simulation.
+	Index int
+	// Process that is controlled by the Behaviour.
+	Process *process.Process
+	// Signatories of all Processes in the Simulation, in order of their index.
+	Signatories []id.Signatory
+	// Scheduler that is used by all Processes.
+	Scheduler process.Scheduler
+	// Rand is the random number generator of the Simulation. Behaviours must
+	// use it for all random choices, so that the Simulation can be
+	// reproduced.
+	Rand *rand.Rand
+}
+
+// Signatory returns the signatory of the Process.
+func (node Node) Signatory() id.Signatory {
+	return node.Signatories[node.Index]
+}
+
+// Broadcast returns the Deliveries of the message to every Process, which is
+// what an honest Process would send.
+func (node Node) Broadcast(msg interface{}) []Delivery {
+	deliveries := make([]Delivery, len(node.Signatories))
+	for i := range deliveries {
+		deliveries[i] = Delivery{To: i, Message: msg}
+	}
+	return deliveries
+}
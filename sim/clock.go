@@ -0,0 +1,81 @@
+package sim
+
+import (
+	"container/heap"
+	"time"
+)
+
+// clock is the virtual clock of a simulation. It keeps the Events that have
+// been scheduled, and jumps straight to the next one when it is handled.
+type clock struct {
+	now    time.Duration
+	seq    uint64
+	steps  uint64
+	events eventQueue
+}
+
+// Now returns the virtual time since the start of the simulation.
+func (c *clock) Now() time.Duration {
+	return c.now
+}
+
+// Steps returns the number of Events that have been handled.
+func (c *clock) Steps() uint64 {
+	return c.steps
+}
+
+// schedule an Event to happen after the given delay.
+func (c *clock) schedule(delay time.Duration, to int, msg interface{}) {
+	heap.Push(&c.events, Event{Time: c.now + delay, To: to, Message: msg, seq: c.seq})
+	c.seq++
+}
+
+// next removes the next Event, and advances the virtual time to when it
+// happens. It returns false if there are no more Events.
+func (c *clock) next() (Event, bool) {
+	if c.events.Len() == 0 {
+		return Event{}, false
+	}
+	event := heap.Pop(&c.events).(Event)
+	c.now = event.Time
+	c.steps++
+	return event, true
+}
+
+// runUntil handles Events by calling step until the condition is true, or
+// until the given duration of virtual time has passed. It returns whether or
+// not the condition is true.
+func (c *clock) runUntil(step func() bool, cond func() bool, d time.Duration) bool {
+	deadline := c.now + d
+	for !cond() {
+		if c.events.Len() == 0 || c.events[0].Time > deadline {
+			return false
+		}
+		step()
+	}
+	return true
+}
+
+// eventQueue is a min-heap of Events, ordered by their Time, and then by the
+// order in which they were scheduled.
+type eventQueue []Event
+
+func (q eventQueue) Len() int { return len(q) }
+
+func (q eventQueue) Less(i, j int) bool {
+	if q[i].Time != q[j].Time {
+		return q[i].Time < q[j].Time
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q eventQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *eventQueue) Push(x interface{}) { *q = append(*q, x.(Event)) }
+
+func (q *eventQueue) Pop() interface{} {
+	old := *q
+	event := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return event
+}
@@ -0,0 +1,149 @@
+package sim
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/renproject/hyperdrive/fault"
+	"github.com/renproject/hyperdrive/process"
+)
+
+const (
+	// DefaultMinDelay is the minimum virtual time that it takes to deliver a
+	// message.
+	DefaultMinDelay = 10 * time.Millisecond
+	// DefaultMaxDelay is the maximum virtual time that it takes to deliver a
+	// message.
+	DefaultMaxDelay = 100 * time.Millisecond
+	// DefaultTimeout is the virtual duration of a timeout in the first Round.
+	DefaultTimeout = time.Second
+	// DefaultTimeoutScaling is the factor by which timeouts grow with every
+	// Round.
+	DefaultTimeoutScaling = 0.5
+)
+
+// Options represent the options for a Simulation
+type Options struct {
+	Seed           int64
+	MinDelay       time.Duration
+	MaxDelay       time.Duration
+	Timeout        time.Duration
+	TimeoutScaling float64
+	RoundWindow    process.Round
+	Faults         fault.Model
+	Behaviours     map[int]Behaviour
+	Tracers        map[int]process.Tracer
+	Sync           bool
+	Trace          bool
+}
+
+// DefaultOptions returns the default options for a Simulation
+func DefaultOptions() Options {
+	return Options{
+		Seed:           0,
+		MinDelay:       DefaultMinDelay,
+		MaxDelay:       DefaultMaxDelay,
+		Timeout:        DefaultTimeout,
+		TimeoutScaling: DefaultTimeoutScaling,
+		RoundWindow:    0,
+		Faults:         fault.NewModel(),
+		Behaviours:     map[int]Behaviour{},
+		Tracers:        map[int]process.Tracer{},
+		Sync:           false,
+		Trace:          false,
+	}
+}
+
+// WithSeed updates the seed from which all randomness in the Simulation is
+// derived. Simulations with the same options, and the same seed, are
+// identical.
+func (opts Options) WithSeed(seed int64) Options {
+	opts.Seed = seed
+	return opts
+}
+
+// WithDelay updates the minimum and maximum virtual time that it takes to
+// deliver a message. The delay of each message is chosen uniformly at random
+// from this range.
+func (opts Options) WithDelay(min, max time.Duration) Options {
+	opts.MinDelay = min
+	opts.MaxDelay = max
+	return opts
+}
+
+// WithTimeout updates the virtual duration of a timeout in the first Round
+func (opts Options) WithTimeout(timeout time.Duration) Options {
+	opts.Timeout = timeout
+	return opts
+}
+
+// WithTimeoutScaling updates the factor by which timeouts grow with every
+// Round
+func (opts Options) WithTimeoutScaling(timeoutScaling float64) Options {
+	opts.TimeoutScaling = timeoutScaling
+	return opts
+}
+
+// WithRoundWindow updates the round window of every Process (see
+// process.Process.WithRoundWindow). By default, the round window is not
+// bounded.
+func (opts Options) WithRoundWindow(window process.Round) Options {
+	opts.RoundWindow = window
+	return opts
+}
+
+// WithFaults updates the Model of the network faults that are injected into
+// every message
+func (opts Options) WithFaults(faults fault.Model) Options {
+	opts.Faults = faults
+	return opts
+}
+
+// WithBehaviour updates the Behaviour of the Process with the given index.
+// Processes without a Behaviour are honest.
+func (opts Options) WithBehaviour(i int, behaviour Behaviour) Options {
+	behaviours := make(map[int]Behaviour, len(opts.Behaviours)+1)
+	for j, b := range opts.Behaviours {
+		behaviours[j] = b
+	}
+	behaviours[i] = behaviour
+	opts.Behaviours = behaviours
+	return opts
+}
+
+// WithTracer updates the Tracer of the Process with the given index. Processes
+// without a Tracer are not traced.
+func (opts Options) WithTracer(i int, tracer process.Tracer) Options {
+	tracers := make(map[int]process.Tracer, len(opts.Tracers)+1)
+	for j, t := range opts.Tracers {
+		tracers[j] = t
+	}
+	tracers[i] = tracer
+	opts.Tracers = tracers
+	return opts
+}
+
+// WithSync updates whether or not Processes that have fallen behind are synced
+// with the CommitCertificates of the Processes that are ahead of them. Without
+// syncing, a Process that misses the Precommits of a Height can only catch up
+// if it receives them again, which never happens in a Simulation.
+func (opts Options) WithSync(sync bool) Options {
+	opts.Sync = sync
+	return opts
+}
+
+// WithTrace updates whether or not the Simulation records a trace of every
+// Event that it handles, and every fault that it injects
+func (opts Options) WithTrace(trace bool) Options {
+	opts.Trace = trace
+	return opts
+}
+
+// delay returns a random delay for the delivery of a message, chosen uniformly
+// from the range of delays.
+func (opts Options) delay(r *rand.Rand) time.Duration {
+	if opts.MaxDelay <= opts.MinDelay {
+		return opts.MinDelay
+	}
+	return opts.MinDelay + time.Duration(r.Int63n(int64(opts.MaxDelay-opts.MinDelay)+1))
+}
@@ -0,0 +1,94 @@
+package sim_test
+
+import (
+	"time"
+
+	"github.com/renproject/hyperdrive/fault"
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/hyperdrive/process/processutil"
+	"github.com/renproject/hyperdrive/sim"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Sim Opts", func() {
+	Context("Sim Opts", func() {
+		Specify("with default opts", func() {
+			opts := sim.DefaultOptions()
+			Expect(opts.Seed).To(Equal(int64(0)))
+			Expect(opts.MinDelay).To(Equal(sim.DefaultMinDelay))
+			Expect(opts.MaxDelay).To(Equal(sim.DefaultMaxDelay))
+			Expect(opts.Timeout).To(Equal(sim.DefaultTimeout))
+			Expect(opts.TimeoutScaling).To(Equal(sim.DefaultTimeoutScaling))
+			Expect(opts.RoundWindow).To(Equal(process.Round(0)))
+			Expect(opts.Faults.Rules()).To(BeEmpty())
+			Expect(opts.Behaviours).To(BeEmpty())
+			Expect(opts.Tracers).To(BeEmpty())
+			Expect(opts.Sync).To(BeFalse())
+			Expect(opts.Trace).To(BeFalse())
+		})
+
+		Specify("with seed", func() {
+			opts := sim.DefaultOptions().WithSeed(42)
+			Expect(opts.Seed).To(Equal(int64(42)))
+		})
+
+		Specify("with delay", func() {
+			opts := sim.DefaultOptions().WithDelay(time.Millisecond, time.Second)
+			Expect(opts.MinDelay).To(Equal(time.Millisecond))
+			Expect(opts.MaxDelay).To(Equal(time.Second))
+		})
+
+		Specify("with timeout", func() {
+			opts := sim.DefaultOptions().WithTimeout(time.Minute)
+			Expect(opts.Timeout).To(Equal(time.Minute))
+		})
+
+		Specify("with timeout scaling", func() {
+			opts := sim.DefaultOptions().WithTimeoutScaling(2.0)
+			Expect(opts.TimeoutScaling).To(Equal(2.0))
+		})
+
+		Specify("with faults", func() {
+			faults := fault.NewModel().Drop(fault.Always(), fault.AllLinks(), 0.5)
+			opts := sim.DefaultOptions().WithFaults(faults)
+			Expect(opts.Faults.Rules()).To(HaveLen(1))
+		})
+
+		Specify("with round window", func() {
+			opts := sim.DefaultOptions().WithRoundWindow(10)
+			Expect(opts.RoundWindow).To(Equal(process.Round(10)))
+		})
+
+		Specify("with behaviour", func() {
+			behaviour := sim.BehaviourFunc(func(node sim.Node, msg interface{}) []sim.Delivery {
+				return node.Broadcast(msg)
+			})
+			opts := sim.DefaultOptions().WithBehaviour(1, behaviour)
+			other := opts.WithBehaviour(2, behaviour)
+			Expect(opts.Behaviours).To(HaveLen(1))
+			Expect(opts.Behaviours).To(HaveKey(1))
+			Expect(other.Behaviours).To(HaveLen(2))
+		})
+
+		Specify("with tracer", func() {
+			tracer := processutil.TracerCallback{}
+			opts := sim.DefaultOptions().WithTracer(1, tracer)
+			other := opts.WithTracer(2, tracer)
+			Expect(opts.Tracers).To(HaveLen(1))
+			Expect(opts.Tracers).To(HaveKey(1))
+			Expect(other.Tracers).To(HaveLen(2))
+		})
+
+		Specify("with sync", func() {
+			opts := sim.DefaultOptions().WithSync(true)
+			Expect(opts.Sync).To(BeTrue())
+		})
+
+		Specify("with trace", func() {
+			opts := sim.DefaultOptions().WithTrace(true)
+			Expect(opts.Trace).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,315 @@
+package sim
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/hyperdrive/process/processutil"
+	"github.com/renproject/hyperdrive/replica"
+	"github.com/renproject/hyperdrive/timer"
+	"github.com/renproject/id"
+
+	"go.uber.org/zap"
+)
+
+// A ReplicaSimulation of a committee of replica.Replicas. It uses the same
+// virtual clock as a Simulation, but every Replica is driven through its
+// single-step API (see replica.Replica.Start and replica.Replica.Handle)
+// instead of its Run loop: the timeouts of a Replica are scheduled by a
+// virtual Timer, and the messages that it broadcasts are signed, and delivered
+// to the other Replicas, by an injected Broadcaster. This means that the
+// message queue, signature verification, and Syncer of every Replica are
+// exercised, without any goroutines or real time.
+//
+// The Behaviours in the options are ignored, because every Replica is honest,
+// and no scenario.Scenario is recorded, because the inputs of a Replica are
+// not the inputs of its Process.
+type ReplicaSimulation struct {
+	opts  Options
+	r     *rand.Rand
+	timer *timer.LinearTimer
+
+	privKeys     []*id.PrivKey
+	signatories  []id.Signatory
+	replicas     []*replicaNode
+	certificates map[process.Height]process.CommitCertificate
+
+	clock
+	trace []Record
+}
+
+// replicaNode is a simulated Replica, along with the Values that it has
+// committed.
+type replicaNode struct {
+	replica *replica.Replica
+	commits map[process.Height]process.Value
+}
+
+// NewReplicaSimulation returns a ReplicaSimulation of a committee of the given
+// size, in which only the first n Replicas are online. All of the online
+// Replicas have been started.
+func NewReplicaSimulation(opts Options, size, n int) *ReplicaSimulation {
+	sim := &ReplicaSimulation{
+		opts:  opts,
+		r:     rand.New(rand.NewSource(opts.Seed)),
+		timer: timer.NewLinearTimer(timer.Options{Timeout: opts.Timeout, TimeoutScaling: opts.TimeoutScaling}, nil, nil, nil),
+
+		privKeys:     GeneratePrivKeys(opts.Seed, size),
+		signatories:  make([]id.Signatory, size),
+		replicas:     make([]*replicaNode, n),
+		certificates: map[process.Height]process.CommitCertificate{},
+	}
+	for i, privKey := range sim.privKeys {
+		sim.signatories[i] = privKey.Signatory()
+	}
+	proposer := processutil.MockProposer{
+		MockValue: func() process.Value {
+			return processutil.RandomGoodValue(sim.r)
+		},
+	}
+	validator := processutil.MockValidator{
+		MockValid: func(_ process.Height, _ process.Round, value process.Value) bool {
+			return value != process.NilValue
+		},
+	}
+	for i := range sim.replicas {
+		node := &replicaNode{commits: map[process.Height]process.Value{}}
+		replicaOpts := replica.DefaultOptions().
+			WithLogger(zap.NewNop()).
+			WithRoundWindow(opts.RoundWindow).
+			WithTracer(opts.Tracers[i])
+		if opts.Sync {
+			replicaOpts = replicaOpts.WithSyncer(replicaSyncer{sim: sim})
+		}
+		node.replica = replica.New(
+			replicaOpts,
+			sim.signatories[i],
+			sim.signatories,
+			replicaTimer{sim: sim, i: i},
+			proposer,
+			validator,
+			nil,
+			nil,
+			replicaCommitter{sim: sim, node: node},
+			nil,
+			replicaBroadcaster{sim: sim, i: i},
+			nil,
+		)
+		sim.replicas[i] = node
+	}
+	for _, node := range sim.replicas {
+		if err := node.replica.Start(); err != nil {
+			panic(fmt.Errorf("starting replica: %v", err))
+		}
+	}
+	return sim
+}
+
+// GeneratePrivKeys returns the private keys of the n Replicas in a
+// ReplicaSimulation with the given seed, in order of their index.
+func GeneratePrivKeys(seed int64, n int) []*id.PrivKey {
+	r := rand.New(rand.NewSource(seed))
+	privKeys := make([]*id.PrivKey, n)
+	for i := range privKeys {
+		privKeys[i] = new(id.PrivKey)
+		buf := make([]byte, id.SizeHintPrivKey)
+		for {
+			r.Read(buf)
+			// the bytes are not a valid private key if they are not less
+			// than the order of the curve, which is unlikely
+			if _, _, err := privKeys[i].Unmarshal(buf, len(buf)); err == nil {
+				break
+			}
+		}
+	}
+	return privKeys
+}
+
+// Signatories returns the signatories of the committee, in order of their
+// index.
+func (sim *ReplicaSimulation) Signatories() []id.Signatory {
+	signatories := make([]id.Signatory, len(sim.signatories))
+	copy(signatories, sim.signatories)
+	return signatories
+}
+
+// CurrentHeight returns the current Height of the Replica with the given
+// index.
+func (sim *ReplicaSimulation) CurrentHeight(i int) process.Height {
+	return sim.replicas[i].replica.CurrentHeight()
+}
+
+// Commits returns the Values that have been committed by the Replica with the
+// given index.
+func (sim *ReplicaSimulation) Commits(i int) map[process.Height]process.Value {
+	commits := make(map[process.Height]process.Value, len(sim.replicas[i].commits))
+	for height, value := range sim.replicas[i].commits {
+		commits[height] = value
+	}
+	return commits
+}
+
+// Trace returns the Records of the ReplicaSimulation, in the order in which
+// they were made. It is empty unless the ReplicaSimulation is tracing.
+func (sim *ReplicaSimulation) Trace() []Record {
+	trace := make([]Record, len(sim.trace))
+	copy(trace, sim.trace)
+	return trace
+}
+
+// Step handles the next Event, advancing the virtual time to when it happens.
+// It returns false if there are no more Events.
+func (sim *ReplicaSimulation) Step() bool {
+	event, ok := sim.next()
+	if !ok {
+		return false
+	}
+	if sim.opts.Trace {
+		sim.trace = append(sim.trace, Record{Time: sim.now, Event: &event})
+	}
+	sim.replicas[event.To].replica.Handle(context.Background(), event.Message)
+	return true
+}
+
+// RunUntil handles Events until the condition is true, or until the given
+// duration of virtual time has passed. It returns whether or not the condition
+// is true.
+func (sim *ReplicaSimulation) RunUntil(cond func() bool, d time.Duration) bool {
+	return sim.runUntil(sim.Step, cond, d)
+}
+
+// RunUntilHeight handles Events until every online Replica has committed a
+// Value at the given Height, or until the given duration of virtual time has
+// passed. It returns an error if not every online Replica has committed a
+// Value.
+func (sim *ReplicaSimulation) RunUntilHeight(height process.Height, d time.Duration) error {
+	ok := sim.RunUntil(func() bool {
+		for _, node := range sim.replicas {
+			if _, ok := node.commits[height]; !ok {
+				return false
+			}
+		}
+		return true
+	}, d)
+	if !ok {
+		return fmt.Errorf("running until height=%v: timed out at time=%v", height, sim.now)
+	}
+	return nil
+}
+
+// send a message from one Replica to another, injecting faults from the
+// fault.Model. Duplicates of a message are delivered with their own random
+// delay.
+func (sim *ReplicaSimulation) send(from, to int, msg interface{}) {
+	effect := sim.opts.Faults.Apply(sim.signatories[from], sim.signatories[to], msg, sim.now, sim.r)
+	if sim.opts.Trace {
+		for k := range effect.Injections {
+			sim.trace = append(sim.trace, Record{Time: sim.now, Injection: &effect.Injections[k]})
+		}
+	}
+	if effect.Drop {
+		return
+	}
+	for k := 0; k <= effect.Duplicates; k++ {
+		sim.schedule(effect.Delay+sim.opts.delay(sim.r), to, msg)
+	}
+}
+
+// broadcast a message to every online Replica (including the one that
+// broadcast it).
+func (sim *ReplicaSimulation) broadcast(from int, msg interface{}) {
+	for to := range sim.replicas {
+		sim.send(from, to, msg)
+	}
+}
+
+// replicaTimer schedules the timeouts of a Replica on the virtual clock, after
+// the same duration as a timer.LinearTimer.
+type replicaTimer struct {
+	sim *ReplicaSimulation
+	i   int
+}
+
+func (t replicaTimer) TimeoutPropose(height process.Height, round process.Round) {
+	t.timeout(process.MessageTypePropose, height, round)
+}
+
+func (t replicaTimer) TimeoutPrevote(height process.Height, round process.Round) {
+	t.timeout(process.MessageTypePrevote, height, round)
+}
+
+func (t replicaTimer) TimeoutPrecommit(height process.Height, round process.Round) {
+	t.timeout(process.MessageTypePrecommit, height, round)
+}
+
+func (t replicaTimer) timeout(messageType process.MessageType, height process.Height, round process.Round) {
+	timeout := timer.Timeout{MessageType: messageType, Height: height, Round: round}
+	t.sim.schedule(t.sim.timer.DurationAtHeightAndRound(height, round), t.i, timeout)
+}
+
+// replicaBroadcaster signs the messages of a Replica with its private key, and
+// delivers them to every online Replica.
+type replicaBroadcaster struct {
+	sim *ReplicaSimulation
+	i   int
+}
+
+func (b replicaBroadcaster) BroadcastPropose(propose process.Propose) {
+	if err := propose.Sign(b.sim.privKeys[b.i]); err != nil {
+		panic(fmt.Errorf("signing propose: %v", err))
+	}
+	b.sim.broadcast(b.i, propose)
+}
+
+func (b replicaBroadcaster) BroadcastPrevote(prevote process.Prevote) {
+	if err := prevote.Sign(b.sim.privKeys[b.i]); err != nil {
+		panic(fmt.Errorf("signing prevote: %v", err))
+	}
+	b.sim.broadcast(b.i, prevote)
+}
+
+func (b replicaBroadcaster) BroadcastPrecommit(precommit process.Precommit) {
+	if err := precommit.Sign(b.sim.privKeys[b.i]); err != nil {
+		panic(fmt.Errorf("signing precommit: %v", err))
+	}
+	b.sim.broadcast(b.i, precommit)
+}
+
+func (b replicaBroadcaster) BroadcastTimeout(timeout process.Timeout) {
+	if err := timeout.Sign(b.sim.privKeys[b.i]); err != nil {
+		panic(fmt.Errorf("signing timeout: %v", err))
+	}
+	b.sim.broadcast(b.i, timeout)
+}
+
+// replicaCommitter records the Values that are committed by a Replica, and
+// keeps the first CommitCertificate of every Height for syncing.
+type replicaCommitter struct {
+	sim  *ReplicaSimulation
+	node *replicaNode
+}
+
+func (c replicaCommitter) Commit(cert process.CommitCertificate) (process.VotingPowers, process.Scheduler) {
+	c.node.commits[cert.Height] = cert.Value
+	if _, ok := c.sim.certificates[cert.Height]; !ok {
+		c.sim.certificates[cert.Height] = cert
+	}
+	return nil, nil
+}
+
+// replicaSyncer returns the CommitCertificates that have been committed by the
+// Replicas, as if they had been fetched from a peer.
+type replicaSyncer struct {
+	sim *ReplicaSimulation
+}
+
+func (s replicaSyncer) Sync(ctx context.Context, height process.Height) (process.CommitCertificate, error) {
+	cert, ok := s.sim.certificates[height]
+	if !ok {
+		return process.CommitCertificate{}, fmt.Errorf("no certificate at height=%v", height)
+	}
+	return cert, nil
+}
@@ -0,0 +1,92 @@
+package sim_test
+
+import (
+	"testing/quick"
+	"time"
+
+	"github.com/renproject/hyperdrive/fault"
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/hyperdrive/sim"
+	"github.com/renproject/id"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Replica simulation", func() {
+	// expectAgreement expects every online Replica to have committed the same
+	// Values at every Height up to (and including) the given Height
+	expectAgreement := func(s *sim.ReplicaSimulation, n int, height process.Height) {
+		commits := s.Commits(0)
+		for h := process.Height(1); h <= height; h++ {
+			Expect(commits).To(HaveKey(h))
+		}
+		for i := 1; i < n; i++ {
+			other := s.Commits(i)
+			for h := process.Height(1); h <= height; h++ {
+				Expect(other[h]).To(Equal(commits[h]))
+			}
+		}
+	}
+
+	Context("when all replicas are online", func() {
+		It("should commit the same values at every height", func() {
+			f := func(seed int64) bool {
+				s := sim.NewReplicaSimulation(sim.DefaultOptions().WithSeed(seed), 4, 4)
+				Expect(s.RunUntilHeight(10, time.Hour)).To(Succeed())
+				expectAgreement(s, 4, 10)
+				return true
+			}
+			Expect(quick.Check(f, &quick.Config{MaxCount: 10})).To(Succeed())
+		})
+	})
+
+	Context("when less than a quorum of replicas is online", func() {
+		It("should not commit any values", func() {
+			s := sim.NewReplicaSimulation(sim.DefaultOptions().WithSeed(time.Now().UnixNano()), 4, 2)
+			Expect(s.RunUntil(func() bool { return false }, time.Hour)).To(BeFalse())
+			for i := 0; i < 2; i++ {
+				Expect(s.Commits(i)).To(BeEmpty())
+			}
+		})
+	})
+
+	Context("when running a replica simulation with the same seed", func() {
+		It("should reproduce the simulation exactly", func() {
+			f := func(seed int64) bool {
+				s1 := sim.NewReplicaSimulation(sim.DefaultOptions().WithSeed(seed), 4, 4)
+				s2 := sim.NewReplicaSimulation(sim.DefaultOptions().WithSeed(seed), 4, 4)
+				Expect(s1.Signatories()).To(Equal(s2.Signatories()))
+				Expect(s1.RunUntilHeight(10, time.Hour)).To(Succeed())
+				Expect(s2.RunUntilHeight(10, time.Hour)).To(Succeed())
+				Expect(s1.Now()).To(Equal(s2.Now()))
+				Expect(s1.Steps()).To(Equal(s2.Steps()))
+				for i := 0; i < 4; i++ {
+					Expect(s1.Commits(i)).To(Equal(s2.Commits(i)))
+				}
+				return true
+			}
+			Expect(quick.Check(f, &quick.Config{MaxCount: 5})).To(Succeed())
+		})
+	})
+
+	Context("when a minority is partitioned between two heights, and replicas are synced", func() {
+		It("should catch up the minority after the partition", func() {
+			n := 4
+			seed := time.Now().UnixNano()
+			signatories := make([]id.Signatory, n)
+			for i, privKey := range sim.GeneratePrivKeys(seed, n) {
+				signatories[i] = privKey.Signatory()
+			}
+			minority, majority := signatories[:1], signatories[1:]
+			opts := sim.DefaultOptions().
+				WithSeed(seed).
+				WithFaults(fault.NewModel().Partition(fault.Heights(10, 20), minority, majority)).
+				WithSync(true)
+			s := sim.NewReplicaSimulation(opts, n, n)
+			Expect(s.Signatories()).To(Equal(signatories))
+			Expect(s.RunUntilHeight(30, time.Hour)).To(Succeed())
+			expectAgreement(s, n, 30)
+		})
+	})
+})
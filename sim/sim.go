@@ -0,0 +1,456 @@
+// Package sim implements a deterministic simulator for Processes and Replicas.
+// A Simulation runs a committee of Processes in a single goroutine, and
+// replaces real time with virtual time: messages are delivered after a random
+// delay, and timeouts expire after the same duration as they would when using
+// a timer.LinearTimer, but the Simulation never sleeps. Instead, it jumps
+// straight to the next event. This makes it possible to simulate thousands of
+// Heights in seconds.
+//
+// All randomness in a Simulation (the signatories, the proposed Values, and
+// the delay of every message) is derived from a seed, and events that happen
+// at the same virtual time are handled in the order in which they were
+// scheduled. This means that a Simulation can be reproduced exactly from its
+// options.
+//
+// Network faults can be injected into the messages of a Simulation using a
+// fault.Model, and every fault that is injected is recorded in the trace of the
+// Simulation. When tracing, the Simulation also records a scenario.Scenario,
+// which can be saved and replayed against other versions of the Process code.
+//
+// Byzantine Processes are simulated by giving them a Behaviour, which controls
+// the messages that they send. The evidence of misbehaviour that is caught by
+// each Process is recorded.
+//
+// A Simulation drives process.ActionProcesses directly, so the message queue,
+// write-ahead log, signature verification, and Syncer of a Replica are not
+// exercised by a Simulation. Instead, each simulated Process buffers messages
+// from future Heights until it has reached them and, if syncing is enabled, a
+// Process that observes messages from future Heights fetches the
+// CommitCertificate for its current Height from the Processes that have
+// committed it. Messages are not signed, because a Process in a Simulation
+// cannot forge the messages of another Process (its Behaviour can only change
+// the messages that it sends).
+//
+// A ReplicaSimulation runs replica.Replicas on the same virtual clock instead,
+// by handling one input at a time with replica.Replica.Handle. Their messages
+// are signed and verified, and they are synced by their Syncers.
+package sim
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/renproject/hyperdrive/fault"
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/hyperdrive/process/processutil"
+	"github.com/renproject/hyperdrive/scenario"
+	"github.com/renproject/hyperdrive/scheduler"
+	"github.com/renproject/hyperdrive/timer"
+	"github.com/renproject/id"
+)
+
+// An Event is the delivery of a message to a Process, or the expiry of a
+// timeout that was scheduled by a Process.
+type Event struct {
+	// Time is the virtual time, since the start of the Simulation, at which
+	// the Event happens.
+	Time time.Duration
+	// To is the index of the Process that handles the Event.
+	To int
+	// Message is a process.Propose, process.Prevote, process.Precommit, or
+	// process.Timeout for the delivery of a message, a timer.Timeout for the
+	// expiry of a timeout, and a process.CommitCertificate for the delivery of
+	// a certificate to a Process that is syncing.
+	Message interface{}
+
+	// seq is the order in which the Event was scheduled, and breaks ties
+	// between Events that happen at the same Time.
+	seq uint64
+}
+
+// A Record in the trace of a Simulation. Exactly one of Event and Injection is
+// not nil.
+type Record struct {
+	// Time is the virtual time at which the Record was made.
+	Time time.Duration
+	// Event is an Event that was handled.
+	Event *Event
+	// Injection is a fault that was injected into a message when it was
+	// broadcast.
+	Injection *fault.Injection
+}
+
+// A Simulation of a committee of Processes.
+type Simulation struct {
+	opts  Options
+	r     *rand.Rand
+	timer *timer.LinearTimer
+
+	signatories  []id.Signatory
+	scheduler    process.Scheduler
+	nodes        []*node
+	certificates map[process.Height]process.CommitCertificate
+
+	clock
+	trace    []Record
+	scenario scenario.Scenario
+}
+
+// node is a simulated Process, along with the messages that it has buffered,
+// and the Values and evidence that it has committed and caught.
+type node struct {
+	proc      *process.ActionProcess
+	behaviour Behaviour
+	buffer    []interface{}
+	syncing   bool
+	commits   map[process.Height]process.Value
+	evidence  []process.ReportEvidence
+}
+
+// New returns a Simulation of n Processes, all of which have been started.
+func New(opts Options, n int) *Simulation {
+	sim := &Simulation{
+		opts:  opts,
+		r:     rand.New(rand.NewSource(opts.Seed)),
+		timer: timer.NewLinearTimer(timer.Options{Timeout: opts.Timeout, TimeoutScaling: opts.TimeoutScaling}, nil, nil, nil),
+
+		signatories:  GenerateSignatories(opts.Seed, n),
+		nodes:        make([]*node, n),
+		certificates: map[process.Height]process.CommitCertificate{},
+	}
+	sim.scheduler = scheduler.NewRoundRobin(sim.signatories)
+	if opts.Trace {
+		sim.scenario = scenario.Scenario{
+			Seed:        opts.Seed,
+			Signatories: sim.Signatories(),
+			Byzantine:   []int{},
+			RoundWindow: opts.RoundWindow,
+			Faults:      []scenario.Fault{},
+			Steps:       []scenario.Step{},
+		}
+		for i := 0; i < n; i++ {
+			if opts.Behaviours[i] != nil {
+				sim.scenario.Byzantine = append(sim.scenario.Byzantine, i)
+			}
+		}
+	}
+	proposer := processutil.MockProposer{
+		MockValue: func() process.Value {
+			return processutil.RandomGoodValue(sim.r)
+		},
+	}
+	validator := processutil.MockValidator{
+		MockValid: func(_ process.Height, _ process.Round, value process.Value) bool {
+			return value != process.NilValue
+		},
+	}
+	for i := range sim.nodes {
+		proc := process.New(sim.signatories[i], n, nil, sim.scheduler, proposer, validator, nil, nil, nil, nil).
+			WithRoundWindow(opts.RoundWindow).
+			WithTracer(opts.Tracers[i])
+		sim.nodes[i] = &node{
+			proc:      process.NewActionProcess(proc),
+			behaviour: opts.Behaviours[i],
+			commits:   map[process.Height]process.Value{},
+		}
+	}
+	for i, node := range sim.nodes {
+		sim.input(i, scenario.Start{}, node.proc.Start())
+	}
+	return sim
+}
+
+// GenerateSignatories returns the signatories of the n Processes in a
+// Simulation with the given seed, in order of their index. This is useful for
+// building a fault.Model before the Simulation is created.
+func GenerateSignatories(seed int64, n int) []id.Signatory {
+	r := rand.New(rand.NewSource(seed))
+	signatories := make([]id.Signatory, n)
+	for i := range signatories {
+		r.Read(signatories[i][:])
+	}
+	return signatories
+}
+
+// Signatories returns the signatories of the Processes, in order of their
+// index.
+func (sim *Simulation) Signatories() []id.Signatory {
+	signatories := make([]id.Signatory, len(sim.signatories))
+	copy(signatories, sim.signatories)
+	return signatories
+}
+
+// CurrentHeight returns the current Height of the Process with the given
+// index.
+func (sim *Simulation) CurrentHeight(i int) process.Height {
+	return sim.nodes[i].proc.CurrentHeight
+}
+
+// Commits returns the Values that have been committed by the Process with the
+// given index.
+func (sim *Simulation) Commits(i int) map[process.Height]process.Value {
+	commits := make(map[process.Height]process.Value, len(sim.nodes[i].commits))
+	for height, value := range sim.nodes[i].commits {
+		commits[height] = value
+	}
+	return commits
+}
+
+// Evidence returns the evidence of misbehaviour that has been caught by the
+// Process with the given index, in the order in which it was caught.
+func (sim *Simulation) Evidence(i int) []process.ReportEvidence {
+	evidence := make([]process.ReportEvidence, len(sim.nodes[i].evidence))
+	copy(evidence, sim.nodes[i].evidence)
+	return evidence
+}
+
+// Trace returns the Records of the Simulation, in the order in which they
+// were made. It is empty unless the Simulation is tracing.
+func (sim *Simulation) Trace() []Record {
+	trace := make([]Record, len(sim.trace))
+	copy(trace, sim.trace)
+	return trace
+}
+
+// Scenario returns the recorded scenario.Scenario of the Simulation. It has no
+// Steps unless the Simulation is tracing.
+func (sim *Simulation) Scenario() scenario.Scenario {
+	s := sim.scenario
+	s.Byzantine = append([]int{}, s.Byzantine...)
+	s.Faults = append([]scenario.Fault{}, s.Faults...)
+	s.Steps = append([]scenario.Step{}, s.Steps...)
+	return s
+}
+
+// Step handles the next Event, advancing the virtual time to when it happens.
+// It returns false if there are no more Events.
+func (sim *Simulation) Step() bool {
+	event, ok := sim.next()
+	if !ok {
+		return false
+	}
+	if sim.opts.Trace {
+		sim.trace = append(sim.trace, Record{Time: sim.now, Event: &event})
+	}
+	sim.handle(event)
+	return true
+}
+
+// RunUntil handles Events until the condition is true, or until the given
+// duration of virtual time has passed. It returns whether or not the condition
+// is true.
+func (sim *Simulation) RunUntil(cond func() bool, d time.Duration) bool {
+	return sim.runUntil(sim.Step, cond, d)
+}
+
+// RunUntilHeight handles Events until every Process has committed a Value at
+// the given Height, or until the given duration of virtual time has passed. It
+// returns an error if not every Process has committed a Value.
+func (sim *Simulation) RunUntilHeight(height process.Height, d time.Duration) error {
+	ok := sim.RunUntil(func() bool {
+		for _, node := range sim.nodes {
+			if node.proc.CurrentHeight <= height {
+				return false
+			}
+		}
+		return true
+	}, d)
+	if !ok {
+		return fmt.Errorf("running until height=%v: timed out at time=%v", height, sim.now)
+	}
+	return nil
+}
+
+// delay returns a random delay for the delivery of a message.
+func (sim *Simulation) delay() time.Duration {
+	return sim.opts.delay(sim.r)
+}
+
+// handle an Event. Messages from future Heights are buffered until the Process
+// reaches them.
+func (sim *Simulation) handle(event Event) {
+	node := sim.nodes[event.To]
+	if cert, ok := event.Message.(process.CommitCertificate); ok {
+		node.syncing = false
+		// the Process can have reached the Height of the certificate on its
+		// own, in which case the Sync fails and returns no Actions
+		actions, _ := node.proc.Sync(cert)
+		sim.input(event.To, cert, actions)
+	} else if timeout, ok := event.Message.(timer.Timeout); ok {
+		var actions []process.Action
+		switch timeout.MessageType {
+		case process.MessageTypePropose:
+			actions = node.proc.OnTimeoutPropose(timeout.Height, timeout.Round)
+		case process.MessageTypePrevote:
+			actions = node.proc.OnTimeoutPrevote(timeout.Height, timeout.Round)
+		case process.MessageTypePrecommit:
+			actions = node.proc.OnTimeoutPrecommit(timeout.Height, timeout.Round)
+		}
+		sim.input(event.To, timeout, actions)
+	} else if heightOf(event.Message) > node.proc.CurrentHeight {
+		node.buffer = append(node.buffer, event.Message)
+		sim.trySync(event.To)
+		return
+	} else {
+		sim.input(event.To, event.Message, node.receive(event.Message))
+	}
+	sim.flush(event.To)
+}
+
+// flush the buffered messages of a Process that are no longer from future
+// Heights, in the order in which they were received. This is repeated until
+// the Height of the Process stops changing.
+func (sim *Simulation) flush(i int) {
+	node := sim.nodes[i]
+	for {
+		height := node.proc.CurrentHeight
+		buffer := node.buffer
+		node.buffer = nil
+		for _, msg := range buffer {
+			if heightOf(msg) > node.proc.CurrentHeight {
+				node.buffer = append(node.buffer, msg)
+				continue
+			}
+			sim.input(i, msg, node.receive(msg))
+		}
+		if node.proc.CurrentHeight == height {
+			sim.trySync(i)
+			return
+		}
+	}
+}
+
+// trySync sends the CommitCertificate for the current Height of a Process to
+// the Process, if syncing is enabled, if the Process is not already syncing,
+// if it has buffered messages from future Heights, and if another Process has
+// committed a Value at its current Height. The certificate is delivered after
+// a random delay, as if it had been fetched from a peer.
+func (sim *Simulation) trySync(i int) {
+	node := sim.nodes[i]
+	if !sim.opts.Sync || node.syncing {
+		return
+	}
+	cert, ok := sim.certificates[node.proc.CurrentHeight]
+	if !ok {
+		return
+	}
+	for _, msg := range node.buffer {
+		if heightOf(msg) > node.proc.CurrentHeight {
+			node.syncing = true
+			sim.schedule(sim.delay(), i, cert)
+			return
+		}
+	}
+}
+
+// input records an input to a Process as a new scenario.Step, if the
+// Simulation is tracing, and executes the Actions that the Process returned in
+// response to the input. The Actions, including those that result from
+// executing a Commit, are recorded as the outputs of the Step.
+func (sim *Simulation) input(i int, input interface{}, actions []process.Action) {
+	if sim.opts.Trace {
+		sim.scenario.Steps = append(sim.scenario.Steps, scenario.Step{Time: sim.now, To: i, Input: input, Outputs: []process.Action{}})
+	}
+	sim.execute(i, actions)
+}
+
+// execute the Actions of a Process. Broadcasts are delivered to every Process
+// (including the one that broadcast them) after a random delay, unless a fault
+// is injected or the Process has a Behaviour, and timeouts expire after the
+// duration given by the timer.LinearTimer. It must only be called by input
+// (or by itself), so that there is always a Step to record the Actions in.
+func (sim *Simulation) execute(i int, actions []process.Action) {
+	node := sim.nodes[i]
+	for _, action := range actions {
+		if sim.opts.Trace {
+			step := &sim.scenario.Steps[len(sim.scenario.Steps)-1]
+			step.Outputs = append(step.Outputs, action)
+		}
+		switch action := action.(type) {
+		case process.Broadcast:
+			if node.behaviour == nil {
+				for j := range sim.nodes {
+					sim.send(i, j, action.Message)
+				}
+				continue
+			}
+			view := Node{
+				Index:       i,
+				Process:     &node.proc.Process,
+				Signatories: sim.signatories,
+				Scheduler:   sim.scheduler,
+				Rand:        sim.r,
+			}
+			for _, delivery := range node.behaviour.Broadcast(view, action.Message) {
+				sim.send(i, delivery.To, delivery.Message)
+			}
+		case process.ScheduleTimeout:
+			timeout := timer.Timeout{MessageType: action.MessageType, Height: action.Height, Round: action.Round}
+			sim.schedule(sim.timer.DurationAtHeightAndRound(action.Height, action.Round), i, timeout)
+		case process.Commit:
+			node.commits[action.Certificate.Height] = action.Certificate.Value
+			if _, ok := sim.certificates[action.Certificate.Height]; !ok {
+				sim.certificates[action.Certificate.Height] = action.Certificate
+			}
+			sim.execute(i, node.proc.Committed(nil, nil))
+		case process.ReportEvidence:
+			node.evidence = append(node.evidence, action)
+		}
+	}
+}
+
+// send a message from one Process to another, injecting faults from the
+// fault.Model. Duplicates of a message are delivered with their own random
+// delay.
+func (sim *Simulation) send(from, to int, msg interface{}) {
+	effect := sim.opts.Faults.Apply(sim.signatories[from], sim.signatories[to], msg, sim.now, sim.r)
+	if sim.opts.Trace {
+		for k, injection := range effect.Injections {
+			sim.trace = append(sim.trace, Record{Time: sim.now, Injection: &effect.Injections[k]})
+			sim.scenario.Faults = append(sim.scenario.Faults, scenario.Fault{
+				Time:    sim.now,
+				Kind:    injection.Kind,
+				From:    from,
+				To:      to,
+				Message: injection.Message,
+				Delay:   injection.Delay,
+			})
+		}
+	}
+	if effect.Drop {
+		return
+	}
+	for k := 0; k <= effect.Duplicates; k++ {
+		sim.schedule(effect.Delay+sim.delay(), to, msg)
+	}
+}
+
+// receive a message, and return the resulting Actions.
+func (node *node) receive(msg interface{}) []process.Action {
+	switch msg := msg.(type) {
+	case process.Propose:
+		return node.proc.Propose(msg)
+	case process.Prevote:
+		return node.proc.Prevote(msg)
+	case process.Precommit:
+		return node.proc.Precommit(msg)
+	case process.Timeout:
+		return node.proc.Timeout(msg)
+	}
+	return nil
+}
+
+func heightOf(msg interface{}) process.Height {
+	switch msg := msg.(type) {
+	case process.Propose:
+		return msg.Height
+	case process.Prevote:
+		return msg.Height
+	case process.Precommit:
+		return msg.Height
+	case process.Timeout:
+		return msg.Height
+	}
+	return 0
+}
@@ -0,0 +1,296 @@
+package sim_test
+
+import (
+	"math/rand"
+	"testing/quick"
+	"time"
+
+	"github.com/renproject/hyperdrive/fault"
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/hyperdrive/sim"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Simulation", func() {
+	// expectAgreement expects every Process to have committed the same Values
+	// at every Height up to (and including) the given Height
+	expectAgreement := func(s *sim.Simulation, n int, height process.Height) {
+		commits := s.Commits(0)
+		for h := process.Height(1); h <= height; h++ {
+			Expect(commits).To(HaveKey(h))
+		}
+		for i := 1; i < n; i++ {
+			other := s.Commits(i)
+			for h := process.Height(1); h <= height; h++ {
+				Expect(other[h]).To(Equal(commits[h]))
+			}
+		}
+	}
+
+	Context("when all processes are honest", func() {
+		It("should commit the same values at every height", func() {
+			f := func(seed int64, size uint8) bool {
+				n := 1 + int(size%7)
+				s := sim.New(sim.DefaultOptions().WithSeed(seed), n)
+				Expect(s.RunUntilHeight(10, time.Hour)).To(Succeed())
+				expectAgreement(s, n, 10)
+				return true
+			}
+			Expect(quick.Check(f, &quick.Config{MaxCount: 20})).To(Succeed())
+		})
+
+		It("should simulate thousands of heights in seconds", func() {
+			n := 4
+			s := sim.New(sim.DefaultOptions().WithSeed(time.Now().UnixNano()), n)
+			start := time.Now()
+			Expect(s.RunUntilHeight(2000, 24*time.Hour)).To(Succeed())
+			Expect(time.Since(start)).To(BeNumerically("<", 30*time.Second))
+			expectAgreement(s, n, 2000)
+
+			// virtual time has passed, even though the simulation did not
+			// sleep
+			Expect(s.Now()).To(BeNumerically(">=", 2000*sim.DefaultMinDelay))
+		})
+	})
+
+	Context("when messages are delayed for longer than timeouts", func() {
+		It("should eventually commit, because timeouts grow with every round", func() {
+			n := 4
+			opts := sim.DefaultOptions().
+				WithSeed(time.Now().UnixNano()).
+				WithDelay(100*time.Millisecond, 2*time.Second).
+				WithTimeout(100 * time.Millisecond)
+			s := sim.New(opts, n)
+			Expect(s.RunUntilHeight(5, 24*time.Hour)).To(Succeed())
+			expectAgreement(s, n, 5)
+		})
+	})
+
+	Context("when running a simulation with the same seed", func() {
+		It("should reproduce the simulation exactly", func() {
+			f := func(seed int64) bool {
+				s1 := sim.New(sim.DefaultOptions().WithSeed(seed), 4)
+				s2 := sim.New(sim.DefaultOptions().WithSeed(seed), 4)
+				Expect(s1.Signatories()).To(Equal(s2.Signatories()))
+				Expect(s1.Signatories()).To(Equal(sim.GenerateSignatories(seed, 4)))
+				Expect(s1.RunUntilHeight(20, time.Hour)).To(Succeed())
+				Expect(s2.RunUntilHeight(20, time.Hour)).To(Succeed())
+				Expect(s1.Now()).To(Equal(s2.Now()))
+				Expect(s1.Steps()).To(Equal(s2.Steps()))
+				for i := 0; i < 4; i++ {
+					Expect(s1.Commits(i)).To(Equal(s2.Commits(i)))
+				}
+				return true
+			}
+			Expect(quick.Check(f, &quick.Config{MaxCount: 10})).To(Succeed())
+		})
+	})
+
+	Context("when running a simulation with a different seed", func() {
+		It("should run a different simulation", func() {
+			r := rand.New(rand.NewSource(time.Now().UnixNano()))
+			seed := r.Int63()
+			s1 := sim.New(sim.DefaultOptions().WithSeed(seed), 4)
+			s2 := sim.New(sim.DefaultOptions().WithSeed(seed+1), 4)
+			Expect(s1.RunUntilHeight(20, time.Hour)).To(Succeed())
+			Expect(s2.RunUntilHeight(20, time.Hour)).To(Succeed())
+			Expect(s1.Commits(0)).ToNot(Equal(s2.Commits(0)))
+		})
+	})
+
+	Context("when the deadline passes", func() {
+		It("should stop running", func() {
+			s := sim.New(sim.DefaultOptions(), 4)
+			Expect(s.RunUntilHeight(1000, time.Second)).ToNot(Succeed())
+			Expect(s.Now()).To(BeNumerically("<=", time.Second))
+			Expect(s.CurrentHeight(0)).To(BeNumerically("<", 1000))
+		})
+	})
+
+	Context("when a minority is partitioned between two heights", func() {
+		It("should commit values on the majority, and leave the minority behind", func() {
+			n := 4
+			seed := time.Now().UnixNano()
+			signatories := sim.GenerateSignatories(seed, n)
+			minority, majority := signatories[:1], signatories[1:]
+			opts := sim.DefaultOptions().
+				WithSeed(seed).
+				WithFaults(fault.NewModel().Partition(fault.Heights(10, 20), minority, majority)).
+				WithTrace(true)
+			s := sim.New(opts, n)
+			Expect(s.RunUntil(func() bool {
+				for i := 1; i < n; i++ {
+					if s.CurrentHeight(i) <= 30 {
+						return false
+					}
+				}
+				return true
+			}, time.Hour)).To(BeTrue())
+
+			// the minority cannot reach height 10 without syncing
+			Expect(s.CurrentHeight(0)).To(Equal(process.Height(10)))
+			for i := 2; i < n; i++ {
+				Expect(s.Commits(i)).To(Equal(s.Commits(1)))
+			}
+			for h, value := range s.Commits(0) {
+				Expect(s.Commits(1)[h]).To(Equal(value))
+			}
+
+			// the partition is recorded in the trace
+			drops := 0
+			for _, record := range s.Trace() {
+				if record.Injection == nil {
+					continue
+				}
+				Expect(record.Injection.Kind).To(Equal(fault.KindDrop))
+				height := heightOf(record.Injection.Message)
+				Expect(height).To(BeNumerically(">=", 10))
+				Expect(height).To(BeNumerically("<", 20))
+				drops++
+			}
+			Expect(drops).To(BeNumerically(">", 0))
+		})
+	})
+
+	Context("when a minority is partitioned between two heights, and processes are synced", func() {
+		It("should catch up the minority after the partition", func() {
+			n := 4
+			seed := time.Now().UnixNano()
+			signatories := sim.GenerateSignatories(seed, n)
+			minority, majority := signatories[:1], signatories[1:]
+			opts := sim.DefaultOptions().
+				WithSeed(seed).
+				WithFaults(fault.NewModel().Partition(fault.Heights(10, 20), minority, majority)).
+				WithSync(true)
+			s := sim.New(opts, n)
+			Expect(s.RunUntilHeight(30, time.Hour)).To(Succeed())
+			expectAgreement(s, n, 30)
+		})
+	})
+
+	Context("when a partition leaves no quorum", func() {
+		It("should not commit any values", func() {
+			n := 4
+			seed := time.Now().UnixNano()
+			signatories := sim.GenerateSignatories(seed, n)
+			opts := sim.DefaultOptions().
+				WithSeed(seed).
+				WithFaults(fault.NewModel().Partition(fault.Always(), signatories[:2], signatories[2:]))
+			s := sim.New(opts, n)
+			Expect(s.RunUntil(func() bool { return false }, time.Hour)).To(BeFalse())
+			for i := 0; i < n; i++ {
+				Expect(s.Commits(i)).To(BeEmpty())
+			}
+		})
+	})
+
+	Context("when messages are lost", func() {
+		It("should never commit different values at the same height", func() {
+			f := func(seed int64) bool {
+				n := 4
+				faults := fault.NewModel().Drop(fault.Always(), fault.AllLinks(), 0.1)
+				s := sim.New(sim.DefaultOptions().WithSeed(seed).WithFaults(faults), n)
+
+				// messages are never sent again, so consensus can stall
+				// after enough messages have been lost
+				s.RunUntil(func() bool { return s.CurrentHeight(0) > 100 }, time.Hour)
+				for i := 1; i < n; i++ {
+					for h, value := range s.Commits(i) {
+						if other, ok := s.Commits(0)[h]; ok {
+							Expect(value).To(Equal(other))
+						}
+					}
+				}
+				return true
+			}
+			Expect(quick.Check(f, &quick.Config{MaxCount: 20})).To(Succeed())
+		})
+	})
+
+	Context("when messages are duplicated, and reordered", func() {
+		It("should commit the same values at every height", func() {
+			f := func(seed int64) bool {
+				n := 4
+				faults := fault.NewModel().
+					Duplicate(fault.Always(), fault.AllLinks(), 0.2).
+					Delay(fault.Always(), fault.AllLinks(), 0, 200*time.Millisecond)
+				s := sim.New(sim.DefaultOptions().WithSeed(seed).WithFaults(faults), n)
+				Expect(s.RunUntilHeight(10, 24*time.Hour)).To(Succeed())
+				expectAgreement(s, n, 10)
+				return true
+			}
+			Expect(quick.Check(f, &quick.Config{MaxCount: 10})).To(Succeed())
+		})
+	})
+
+	Context("when a link is asymmetric", func() {
+		It("should commit the same values at every height", func() {
+			n := 4
+			seed := time.Now().UnixNano()
+			signatories := sim.GenerateSignatories(seed, n)
+			// the first process can receive messages, but not send them
+			faults := fault.NewModel().Drop(fault.Always(), fault.Directed(signatories[:1], signatories[1:]), 1)
+			s := sim.New(sim.DefaultOptions().WithSeed(seed).WithFaults(faults), n)
+			Expect(s.RunUntilHeight(10, 24*time.Hour)).To(Succeed())
+			expectAgreement(s, n, 10)
+		})
+	})
+
+	Context("when a link has a high latency", func() {
+		It("should commit the same values at every height", func() {
+			n := 4
+			seed := time.Now().UnixNano()
+			signatories := sim.GenerateSignatories(seed, n)
+			links := fault.Between(signatories[:1], signatories[1:])
+			faults := fault.NewModel().Delay(fault.Always(), links, time.Second, 5*time.Second)
+			s := sim.New(sim.DefaultOptions().WithSeed(seed).WithFaults(faults).WithTrace(true), n)
+			Expect(s.RunUntilHeight(10, 24*time.Hour)).To(Succeed())
+			expectAgreement(s, n, 10)
+
+			for _, record := range s.Trace() {
+				if record.Injection != nil {
+					Expect(record.Injection.Kind).To(Equal(fault.KindDelay))
+					Expect(links(record.Injection.From, record.Injection.To)).To(BeTrue())
+					Expect(record.Injection.Delay).To(BeNumerically(">=", time.Second))
+					Expect(record.Injection.Delay).To(BeNumerically("<=", 5*time.Second))
+				}
+			}
+		})
+	})
+
+	Context("when running a simulation with faults and the same seed", func() {
+		It("should reproduce the simulation, and its trace, exactly", func() {
+			f := func(seed int64) bool {
+				faults := fault.NewModel().
+					Drop(fault.Always(), fault.AllLinks(), 0.1).
+					Duplicate(fault.Always(), fault.AllLinks(), 0.1)
+				opts := sim.DefaultOptions().WithSeed(seed).WithFaults(faults).WithTrace(true)
+				s1, s2 := sim.New(opts, 4), sim.New(opts, 4)
+				s1.RunUntil(func() bool { return s1.CurrentHeight(0) > 10 }, time.Hour)
+				s2.RunUntil(func() bool { return s2.CurrentHeight(0) > 10 }, time.Hour)
+				Expect(s1.Trace()).ToNot(BeEmpty())
+				Expect(s1.Trace()).To(Equal(s2.Trace()))
+				Expect(s1.Scenario().Steps).ToNot(BeEmpty())
+				Expect(s1.Scenario()).To(Equal(s2.Scenario()))
+				return true
+			}
+			Expect(quick.Check(f, &quick.Config{MaxCount: 5})).To(Succeed())
+		})
+	})
+})
+
+func heightOf(msg interface{}) process.Height {
+	switch msg := msg.(type) {
+	case process.Propose:
+		return msg.Height
+	case process.Prevote:
+		return msg.Height
+	case process.Precommit:
+		return msg.Height
+	case process.Timeout:
+		return msg.Height
+	}
+	return 0
+}
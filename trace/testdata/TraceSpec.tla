@@ -0,0 +1,106 @@
+----------------------------- MODULE TraceSpec -----------------------------
+(***************************************************************************)
+(* Trace validation of one Process against the local state of the         *)
+(* consensus algorithm (see the package documentation of package trace).  *)
+(* Every Record of the trace is mapped onto the variables h_p, round_p,     *)
+(* step_p, lockedValue_p, lockedRound_p, validValue_p, and validRound_p,    *)
+(* and every pair of consecutive Records must be a transition that the      *)
+(* algorithm allows: either the Process commits and starts the next Height *)
+(* (L49 to L54, and L11 to L21), or it stays at its Height, in which case  *)
+(* it never goes back to a previous round or step, and it only locks and    *)
+(* updates its valid Value in its current round (L36 to L43).              *)
+(*                                                                         *)
+(* The trace is read with the ndJsonDeserialize operator of the Json       *)
+(* module of the TLA+ community modules, from trace.ndjson in the working  *)
+(* directory of TLC. TLC reports a deadlock at the first Record that is    *)
+(* not accepted.                                                           *)
+(***************************************************************************)
+EXTENDS Integers, Sequences, Json
+
+Trace == ndJsonDeserialize("trace.ndjson")
+
+Nil == "nil"
+
+Steps == {"PROPOSE", "PREVOTE", "PRECOMMIT"}
+
+\* The order in which the steps of a round are taken.
+StepOrder == [PROPOSE |-> 0, PREVOTE |-> 1, PRECOMMIT |-> 2]
+
+VARIABLES
+    l,              \* the position of the current Record in the Trace
+    h_p,
+    round_p,
+    step_p,
+    lockedValue_p,
+    lockedRound_p,
+    validValue_p,
+    validRound_p
+
+vars == <<l, h_p, round_p, step_p, lockedValue_p, lockedRound_p, validValue_p, validRound_p>>
+
+TypeOK ==
+    /\ h_p \in Nat \ {0}
+    /\ round_p \in Nat
+    /\ step_p \in Steps
+    /\ lockedRound_p \in -1..round_p
+    /\ validRound_p \in lockedRound_p..round_p
+    /\ (lockedRound_p = -1) <=> (lockedValue_p = Nil)
+    /\ (validRound_p = -1) <=> (validValue_p = Nil)
+
+Init ==
+    /\ l = 1
+    /\ Trace[1].index = 0
+    /\ h_p = Trace[1].state.height
+    /\ round_p = Trace[1].state.round
+    /\ step_p = Trace[1].state.step
+    /\ lockedValue_p = Trace[1].state.lockedValue
+    /\ lockedRound_p = Trace[1].state.lockedRound
+    /\ validValue_p = Trace[1].state.validValue
+    /\ validRound_p = Trace[1].state.validRound
+
+\* The Process commits a Value, and starts round 0 of the next Height without
+\* a locked or valid Value.
+NextHeight ==
+    /\ h_p' = h_p + 1
+    /\ round_p' = 0
+    /\ step_p' = "PROPOSE"
+    /\ lockedValue_p' = Nil
+    /\ lockedRound_p' = -1
+    /\ validValue_p' = Nil
+    /\ validRound_p' = -1
+
+\* The Process stays at its Height. Locks and valid Values are only replaced by
+\* locks and valid Values from later rounds.
+SameHeight ==
+    /\ h_p' = h_p
+    /\ round_p' >= round_p
+    /\ round_p' = round_p => StepOrder[step_p'] >= StepOrder[step_p]
+    /\ lockedRound_p' >= lockedRound_p
+    /\ lockedRound_p' = lockedRound_p => lockedValue_p' = lockedValue_p
+    /\ validRound_p' >= validRound_p
+    /\ validRound_p' = validRound_p => validValue_p' = validValue_p
+
+\* The next Record of the Trace.
+Read ==
+    /\ l < Len(Trace)
+    /\ l' = l + 1
+    /\ Trace[l'].index = l
+    /\ h_p' = Trace[l'].state.height
+    /\ round_p' = Trace[l'].state.round
+    /\ step_p' = Trace[l'].state.step
+    /\ lockedValue_p' = Trace[l'].state.lockedValue
+    /\ lockedRound_p' = Trace[l'].state.lockedRound
+    /\ validValue_p' = Trace[l'].state.validValue
+    /\ validRound_p' = Trace[l'].state.validRound
+    /\ NextHeight \/ SameHeight
+
+\* Every Record of the Trace has been read.
+Done ==
+    /\ l = Len(Trace)
+    /\ UNCHANGED vars
+
+Next == Read \/ Done
+
+Spec == Init /\ [][Next]_vars
+
+=============================================================================
@@ -0,0 +1,366 @@
+// Package trace exports the execution of a Process as a structured trace, in a
+// format that can be mapped onto a TLA+ specification of the consensus
+// algorithm by trace validation tooling.
+//
+// A Recorder is a process.Tracer that writes one Record for every input that
+// is given to a Process. Records are written as newline-delimited JSON (one
+// JSON object per line), which can be read by the ndJsonDeserialize operator
+// of the Json module of the TLA+ community modules. A Record looks like:
+//
+//	{
+//		"index": 3,
+//		"process": "<signatory>",
+//		"event": {
+//			"method": "Prevote",
+//			"height": 0,
+//			"round": 0,
+//			"message": {"height": 1, "round": 0, "value": "<value>", "from": "<signatory>", "signature": "<signature>"}
+//		},
+//		"state": {
+//			"height": 1,
+//			"round": 0,
+//			"step": "PREVOTE",
+//			"lockedValue": "nil",
+//			"lockedRound": -1,
+//			"validValue": "nil",
+//			"validRound": -1
+//		}
+//	}
+//
+// The index is the position of the Record in the trace of the Process,
+// starting at zero, and the process is the signatory of the Process. The event
+// is the input: method is the name of the method of the Process that was
+// called (see process.TraceEvent), height and round are the arguments of
+// OnTimeoutPropose, OnTimeoutPrevote, and OnTimeoutPrecommit (and round is the
+// argument of StartRound), and message is the Propose, Prevote, Precommit,
+// Timeout, or CommitCertificate that was received, in its usual JSON encoding.
+// Events without a message have no message field.
+//
+// The state is the State of the Process after the input has been handled
+// (including inputs that were rejected), and maps onto the variables of the
+// algorithm:
+//
+//	height      h_p
+//	round       round_p
+//	step        step_p, one of "PROPOSE", "PREVOTE", and "PRECOMMIT"
+//	lockedValue lockedValue_p
+//	lockedRound lockedRound_p
+//	validValue  validValue_p
+//	validRound  validRound_p
+//
+// Values are encoded as unpadded base64 strings, except for process.NilValue,
+// which is encoded as "nil" in the state. Messages keep their usual encoding,
+// in which nil votes have a Value of 32 zero bytes.
+//
+// Every Record is one step of the specification: the receipt of a message
+// (including the messages that the Process broadcasts to itself), or the
+// expiry of a timeout, followed by every rule of the algorithm that it
+// enables. In particular, the Record of the input that causes a commit has the
+// State in which the next Height has already started (L49 to L54 of the
+// algorithm, ending in StartRound(0)). This is the case whether or not the
+// Process is wrapped by a process.ActionProcess, because an ActionProcess
+// writes the Record once ActionProcess.Committed has started the next Height.
+// The file testdata/trace.ndjson is a trace of one Process in a committee of 4
+// Processes, and testdata/TraceSpec.tla maps its Records onto the variables of
+// the algorithm, and checks that every Record is a transition that the
+// algorithm allows. The specification is checked by TLC in the tests of this
+// package, when the TLA2TOOLS environment variable is the classpath of
+// tla2tools.jar and the CommunityModules-deps.jar of the TLA+ community
+// modules. Otherwise, the check is skipped.
+//
+// Signatures are written as they are in the messages. The trace in the
+// testdata is recorded by a sim.Simulation, in which Processes do not sign
+// their messages, so its signatures are zero, and they are not meaningful.
+//
+// Validate checks that a trace is consistent with the reference implementation
+// in package process/reference, by replaying the events of the trace against
+// it and comparing the State after every event. This checks the Process
+// against an independent implementation of the algorithm, but it is not a
+// check against the TLA+ specification, which must be done by the trace
+// validation tooling of the specification.
+package trace
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/hyperdrive/process/reference"
+	"github.com/renproject/id"
+)
+
+// NilValue is the encoding of process.NilValue in the State of a Record.
+const NilValue = "nil"
+
+// A Record is the input to a Process, and the resulting State of the Process.
+type Record struct {
+	Index   int          `json:"index"`
+	Process id.Signatory `json:"process"`
+	Event   Event        `json:"event"`
+	State   State        `json:"state"`
+}
+
+// NewRecord returns the Record of an input to a Process, and the resulting
+// State.
+func NewRecord(index int, whoami id.Signatory, event process.TraceEvent, state process.State) Record {
+	return Record{
+		Index:   index,
+		Process: whoami,
+		Event: Event{
+			Method:  event.Method,
+			Height:  event.Height,
+			Round:   event.Round,
+			Message: event.Message,
+		},
+		State: NewState(state),
+	}
+}
+
+// An Event is an input to a Process.
+type Event struct {
+	Method  string         `json:"method"`
+	Height  process.Height `json:"height"`
+	Round   process.Round  `json:"round"`
+	Message interface{}    `json:"message,omitempty"`
+}
+
+// UnmarshalJSON decodes an Event, using its Method to decide the type of its
+// Message.
+func (event *Event) UnmarshalJSON(data []byte) error {
+	raw := struct {
+		Method  string          `json:"method"`
+		Height  process.Height  `json:"height"`
+		Round   process.Round   `json:"round"`
+		Message json.RawMessage `json:"message"`
+	}{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	event.Method = raw.Method
+	event.Height = raw.Height
+	event.Round = raw.Round
+	event.Message = nil
+
+	var err error
+	switch raw.Method {
+	case "Propose":
+		msg := process.Propose{}
+		err = json.Unmarshal(raw.Message, &msg)
+		event.Message = msg
+	case "Prevote":
+		msg := process.Prevote{}
+		err = json.Unmarshal(raw.Message, &msg)
+		event.Message = msg
+	case "Precommit":
+		msg := process.Precommit{}
+		err = json.Unmarshal(raw.Message, &msg)
+		event.Message = msg
+	case "Timeout":
+		msg := process.Timeout{}
+		err = json.Unmarshal(raw.Message, &msg)
+		event.Message = msg
+	case "Sync":
+		msg := process.CommitCertificate{}
+		err = json.Unmarshal(raw.Message, &msg)
+		event.Message = msg
+	}
+	if err != nil {
+		return fmt.Errorf("unmarshaling message of method=%v: %v", raw.Method, err)
+	}
+	return nil
+}
+
+// A State is the part of a process.State that is defined by the algorithm.
+type State struct {
+	Height      process.Height `json:"height"`
+	Round       process.Round  `json:"round"`
+	Step        string         `json:"step"`
+	LockedValue string         `json:"lockedValue"`
+	LockedRound process.Round  `json:"lockedRound"`
+	ValidValue  string         `json:"validValue"`
+	ValidRound  process.Round  `json:"validRound"`
+}
+
+// NewState returns the State of a Record from the State of a Process.
+func NewState(state process.State) State {
+	return State{
+		Height:      state.CurrentHeight,
+		Round:       state.CurrentRound,
+		Step:        stepString(state.CurrentStep),
+		LockedValue: valueString(state.LockedValue),
+		LockedRound: state.LockedRound,
+		ValidValue:  valueString(state.ValidValue),
+		ValidRound:  state.ValidRound,
+	}
+}
+
+func stepString(step process.Step) string {
+	switch step {
+	case process.Proposing:
+		return "PROPOSE"
+	case process.Prevoting:
+		return "PREVOTE"
+	case process.Precommitting:
+		return "PRECOMMIT"
+	default:
+		return fmt.Sprintf("STEP%v", uint8(step))
+	}
+}
+
+func valueString(value process.Value) string {
+	if value == process.NilValue {
+		return NilValue
+	}
+	return value.String()
+}
+
+// A Recorder is a process.Tracer that writes a Record for every input that is
+// given to a Process, as a line of JSON. A Recorder must only be used by one
+// Process. If a Record cannot be written, then the Recorder stops writing
+// Records, and the error is returned by Err.
+type Recorder struct {
+	w      io.Writer
+	whoami id.Signatory
+	index  int
+	err    error
+}
+
+// NewRecorder returns a Recorder that writes the Records of the Process with
+// the given signatory to the writer.
+func NewRecorder(w io.Writer, whoami id.Signatory) *Recorder {
+	return &Recorder{w: w, whoami: whoami}
+}
+
+// Trace implements the process.Tracer interface.
+func (rec *Recorder) Trace(event process.TraceEvent, state process.State) {
+	if rec.err != nil {
+		return
+	}
+	data, err := json.Marshal(NewRecord(rec.index, rec.whoami, event, state))
+	if err != nil {
+		rec.err = fmt.Errorf("marshaling record=%v: %v", rec.index, err)
+		return
+	}
+	if _, err := rec.w.Write(append(data, '\n')); err != nil {
+		rec.err = fmt.Errorf("writing record=%v: %v", rec.index, err)
+		return
+	}
+	rec.index++
+}
+
+// Err returns the error that stopped the Recorder from writing Records, or nil
+// if every Record has been written.
+func (rec *Recorder) Err() error {
+	return rec.err
+}
+
+// Read the Records that have been written by a Recorder. Empty lines are
+// ignored.
+func Read(r io.Reader) ([]Record, error) {
+	records := []Record{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		record := Record{}
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("unmarshaling line=%v: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading records: %v", err)
+	}
+	return records, nil
+}
+
+// Validate the Records of a Process in a committee of n Processes that all
+// have equal voting power, by replaying their events against a
+// reference.Process that uses the same Scheduler and Validator, and checking
+// that the State of every Record is the same as the State of the
+// reference.Process. It returns an error that describes the first Record that
+// does not match.
+//
+// The Records must be a whole trace, starting with the call to Start. Traces
+// of Processes that have been resumed, synced, or restarted with new
+// signatories or VotingPowers cannot be validated, because the algorithm does
+// not define these inputs, and neither can traces of Processes with a bounded
+// round window. The Values that are proposed by the Process are not
+// part of its State, so they are not checked.
+func Validate(records []Record, n int, scheduler process.Scheduler, validator process.Validator) error {
+	if len(records) == 0 {
+		return fmt.Errorf("validating records: empty trace")
+	}
+	whoami := records[0].Process
+	ref := reference.New(whoami, n, scheduler, proposer{}, validator)
+	for i, record := range records {
+		if record.Index != i {
+			return fmt.Errorf("validating record=%v: expected index=%v, got index=%v", i, i, record.Index)
+		}
+		if record.Process != whoami {
+			return fmt.Errorf("validating record=%v: expected process=%v, got process=%v", i, whoami, record.Process)
+		}
+		if i == 0 && record.Event.Method != "Start" {
+			return fmt.Errorf("validating record=%v: expected method=Start, got method=%v", i, record.Event.Method)
+		}
+
+		var actions []process.Action
+		event := record.Event
+		switch event.Method {
+		case "Start":
+			if i != 0 {
+				return fmt.Errorf("validating record=%v: process started twice", i)
+			}
+			actions = ref.Start()
+		case "Propose", "Prevote", "Precommit", "Timeout":
+			var ok bool
+			switch msg := event.Message.(type) {
+			case process.Propose:
+				actions, ok = ref.Propose(msg), event.Method == "Propose"
+			case process.Prevote:
+				actions, ok = ref.Prevote(msg), event.Method == "Prevote"
+			case process.Precommit:
+				actions, ok = ref.Precommit(msg), event.Method == "Precommit"
+			case process.Timeout:
+				actions, ok = ref.Timeout(msg), event.Method == "Timeout"
+			}
+			if !ok {
+				return fmt.Errorf("validating record=%v: unexpected message=%T for method=%v", i, event.Message, event.Method)
+			}
+		case "OnTimeoutPropose":
+			actions = ref.OnTimeoutPropose(event.Height, event.Round)
+		case "OnTimeoutPrevote":
+			actions = ref.OnTimeoutPrevote(event.Height, event.Round)
+		case "OnTimeoutPrecommit":
+			actions = ref.OnTimeoutPrecommit(event.Height, event.Round)
+		default:
+			return fmt.Errorf("validating record=%v: unsupported method=%v", i, event.Method)
+		}
+
+		// The next Height is started as part of the event that caused the
+		// commit.
+		for _, action := range actions {
+			if _, ok := action.(process.Commit); ok {
+				ref.Committed()
+			}
+		}
+
+		if expected := NewState(ref.State()); record.State != expected {
+			return fmt.Errorf("validating record=%v: method=%v: expected state=%+v, got state=%+v", i, event.Method, expected, record.State)
+		}
+	}
+	return nil
+}
+
+// proposer is the Proposer of the reference.Process in Validate. The Values
+// that are proposed do not change the State of a Process, so any Value will
+// do.
+type proposer struct{}
+
+func (proposer) Propose(process.Height, process.Round) process.Value {
+	return process.Value{1}
+}
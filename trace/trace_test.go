@@ -0,0 +1,256 @@
+package trace_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"testing/quick"
+	"time"
+
+	"github.com/renproject/hyperdrive/fault"
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/hyperdrive/process/processutil"
+	"github.com/renproject/hyperdrive/scheduler"
+	"github.com/renproject/hyperdrive/sim"
+	"github.com/renproject/hyperdrive/trace"
+	"github.com/renproject/id"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// failingWriter fails every write
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("failed")
+}
+
+var _ = Describe("Trace", func() {
+	// validator accepts every value except the nil value, in the same way as
+	// the validator of a Simulation
+	validator := processutil.MockValidator{MockValid: func(_ process.Height, _ process.Round, value process.Value) bool {
+		return value != process.NilValue
+	}}
+
+	// record runs a Simulation of n processes with the faults, until every
+	// process has reached the height, and returns the traces of the processes
+	record := func(seed int64, n int, faults fault.Model, height process.Height) ([]*bytes.Buffer, []id.Signatory) {
+		signatories := sim.GenerateSignatories(seed, n)
+		buffers := make([]*bytes.Buffer, n)
+		recorders := make([]*trace.Recorder, n)
+		opts := sim.DefaultOptions().WithSeed(seed).WithFaults(faults)
+		for i := range buffers {
+			buffers[i] = new(bytes.Buffer)
+			recorders[i] = trace.NewRecorder(buffers[i], signatories[i])
+			opts = opts.WithTracer(i, recorders[i])
+		}
+		s := sim.New(opts, n)
+		Expect(s.RunUntilHeight(height, 24*time.Hour)).To(Succeed())
+		for _, recorder := range recorders {
+			Expect(recorder.Err()).ToNot(HaveOccurred())
+		}
+		return buffers, signatories
+	}
+
+	Context("when recording a simulation", func() {
+		It("should write one record per line, with the state variables of the algorithm", func() {
+			buffers, signatories := record(0, 4, fault.NewModel(), 1)
+			lines := strings.Split(strings.TrimSpace(buffers[0].String()), "\n")
+			Expect(len(lines)).To(BeNumerically(">", 1))
+
+			first := map[string]interface{}{}
+			Expect(json.Unmarshal([]byte(lines[0]), &first)).To(Succeed())
+			Expect(first["index"]).To(Equal(0.0))
+			Expect(first["process"]).To(Equal(signatories[0].String()))
+			Expect(first["event"]).To(Equal(map[string]interface{}{"method": "Start", "height": 0.0, "round": 0.0}))
+			Expect(first["state"]).To(Equal(map[string]interface{}{
+				"height":      1.0,
+				"round":       0.0,
+				"step":        "PROPOSE",
+				"lockedValue": trace.NilValue,
+				"lockedRound": -1.0,
+				"validValue":  trace.NilValue,
+				"validRound":  -1.0,
+			}))
+
+			records, err := trace.Read(buffers[0])
+			Expect(err).ToNot(HaveOccurred())
+			Expect(records).To(HaveLen(len(lines)))
+			for i, record := range records {
+				Expect(record.Index).To(Equal(i))
+				Expect(record.Process).To(Equal(signatories[0]))
+			}
+			methods := map[string]bool{}
+			for _, record := range records {
+				methods[record.Event.Method] = true
+				switch record.Event.Method {
+				case "Propose":
+					Expect(record.Event.Message).To(BeAssignableToTypeOf(process.Propose{}))
+				case "Prevote":
+					Expect(record.Event.Message).To(BeAssignableToTypeOf(process.Prevote{}))
+				case "Precommit":
+					Expect(record.Event.Message).To(BeAssignableToTypeOf(process.Precommit{}))
+				case "Timeout":
+					Expect(record.Event.Message).To(BeAssignableToTypeOf(process.Timeout{}))
+				default:
+					Expect(record.Event.Message).To(BeNil())
+				}
+			}
+			Expect(methods).To(HaveKey("Propose"))
+			// the next height is started as part of the input that caused
+			// the commit
+			Expect(methods).ToNot(HaveKey("StartRound"))
+			Expect(records[len(records)-1].State.Height).To(Equal(process.Height(2)))
+		})
+
+		It("should accept the trace of every process", func() {
+			lockedInLaterRound := false
+			f := func(seed int64) bool {
+				n := 4
+				// delays that are longer than the timeouts result in many
+				// rounds, locks, and rejected messages
+				faults := fault.NewModel().
+					Duplicate(fault.Always(), fault.AllLinks(), 0.1).
+					Delay(fault.Always(), fault.AllLinks(), 0, 2*time.Second)
+				buffers, signatories := record(seed, n, faults, 5)
+				for _, buffer := range buffers {
+					records, err := trace.Read(buffer)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(trace.Validate(records, n, scheduler.NewRoundRobin(signatories), validator)).To(Succeed())
+					for _, record := range records {
+						if record.State.LockedRound > 0 {
+							lockedInLaterRound = true
+						}
+					}
+				}
+				return true
+			}
+			Expect(quick.Check(f, &quick.Config{MaxCount: 10})).To(Succeed())
+			Expect(lockedInLaterRound).To(BeTrue())
+		})
+	})
+
+	Context("when recording a process that is not wrapped by an action process", func() {
+		It("should accept the trace", func() {
+			signatories := sim.GenerateSignatories(0, 4)
+			value := process.Value{1}
+			buffer := new(bytes.Buffer)
+			recorder := trace.NewRecorder(buffer, signatories[1])
+			p := process.New(signatories[1], 4, nil, scheduler.NewRoundRobin(signatories), nil, validator, nil, nil, processutil.CommitterCallback{}, nil).
+				WithTracer(recorder)
+			p.Start()
+			p.Propose(process.Propose{Height: 1, Round: 0, ValidRound: process.InvalidRound, Value: value, From: signatories[1]})
+			for _, from := range signatories[:3] {
+				p.Prevote(process.Prevote{Height: 1, Round: 0, Value: value, From: from})
+			}
+			for _, from := range signatories[:3] {
+				p.Precommit(process.Precommit{Height: 1, Round: 0, Value: value, From: from})
+			}
+			p.OnTimeoutPropose(2, 0)
+			Expect(p.CurrentHeight).To(Equal(process.Height(2)))
+			Expect(recorder.Err()).ToNot(HaveOccurred())
+
+			records, err := trace.Read(buffer)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(records).To(HaveLen(9))
+			Expect(trace.Validate(records, 4, scheduler.NewRoundRobin(signatories), validator)).To(Succeed())
+		})
+	})
+
+	Context("when reading the trace in the testdata", func() {
+		It("should accept the trace", func() {
+			// the trace is the first process of a simulation of 4 processes
+			// with a seed of 0, until every process has reached height 3
+			f, err := os.Open("testdata/trace.ndjson")
+			Expect(err).ToNot(HaveOccurred())
+			defer f.Close()
+			records, err := trace.Read(f)
+			Expect(err).ToNot(HaveOccurred())
+			signatories := sim.GenerateSignatories(0, 4)
+			Expect(records[0].Process).To(Equal(signatories[0]))
+			Expect(records[len(records)-1].State.Height).To(Equal(process.Height(3)))
+			Expect(trace.Validate(records, 4, scheduler.NewRoundRobin(signatories), validator)).To(Succeed())
+		})
+
+		It("should be accepted by the trace specification", func() {
+			// TLC is run with the classpath in TLA2TOOLS, which must include
+			// tla2tools.jar and CommunityModules-deps.jar (for the Json
+			// module)
+			classpath := os.Getenv("TLA2TOOLS")
+			java, err := exec.LookPath("java")
+			if classpath == "" || err != nil {
+				Skip("TLC is not installed")
+			}
+			metadir, err := ioutil.TempDir("", "tlc")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(metadir)
+
+			cmd := exec.Command(java, "-cp", classpath, "tlc2.TLC", "-metadir", metadir, "-config", "TraceSpec.cfg", "TraceSpec.tla")
+			cmd.Dir = "testdata"
+			output, err := cmd.CombinedOutput()
+			Expect(err).ToNot(HaveOccurred(), string(output))
+			Expect(string(output)).To(ContainSubstring("No error has been found"))
+		})
+	})
+
+	Context("when a trace has been tampered with", func() {
+		It("should reject the first record that is not accepted", func() {
+			faults := fault.NewModel().Delay(fault.Always(), fault.AllLinks(), 0, 2*time.Second)
+			buffers, signatories := record(1, 4, faults, 3)
+			records, err := trace.Read(buffers[0])
+			Expect(err).ToNot(HaveOccurred())
+			Expect(trace.Validate(records, 4, scheduler.NewRoundRobin(signatories), validator)).To(Succeed())
+
+			tamper := func(i int, f func(record *trace.Record)) []trace.Record {
+				tampered := append([]trace.Record{}, records...)
+				f(&tampered[i])
+				return tampered
+			}
+			i := len(records) / 2
+			for _, tampered := range [][]trace.Record{
+				tamper(i, func(record *trace.Record) { record.State.LockedRound++ }),
+				tamper(i, func(record *trace.Record) { record.State.Step = "DECIDED" }),
+				tamper(i, func(record *trace.Record) { record.State.ValidValue = process.Value{42}.String() }),
+				tamper(i, func(record *trace.Record) { record.Index++ }),
+				tamper(i, func(record *trace.Record) { record.Process = signatories[1] }),
+			} {
+				err := trace.Validate(tampered, 4, scheduler.NewRoundRobin(signatories), validator)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("record=%v", i))
+			}
+
+			// dropping an input changes the states of the records after it
+			Expect(trace.Validate(append(append([]trace.Record{}, records[:i]...), records[i+1:]...), 4, scheduler.NewRoundRobin(signatories), validator)).ToNot(Succeed())
+		})
+	})
+
+	Context("when a trace has inputs that are not defined by the algorithm", func() {
+		It("should reject the trace", func() {
+			signatories := sim.GenerateSignatories(0, 4)
+			buffer := new(bytes.Buffer)
+			recorder := trace.NewRecorder(buffer, signatories[0])
+			p := process.New(signatories[0], 4, nil, scheduler.NewRoundRobin(signatories), nil, validator, nil, nil, processutil.CommitterCallback{}, nil).
+				WithTracer(recorder)
+			p.Start()
+			p.Resume()
+			records, err := trace.Read(buffer)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(trace.Validate(records, 4, scheduler.NewRoundRobin(signatories), validator)).To(MatchError(ContainSubstring("method=Resume")))
+			Expect(trace.Validate(records[1:], 4, scheduler.NewRoundRobin(signatories), validator)).ToNot(Succeed())
+			Expect(trace.Validate(nil, 4, scheduler.NewRoundRobin(signatories), validator)).ToNot(Succeed())
+		})
+	})
+
+	Context("when a record cannot be written", func() {
+		It("should stop recording, and return the error", func() {
+			recorder := trace.NewRecorder(failingWriter{}, id.NewPrivKey().Signatory())
+			recorder.Trace(process.TraceEvent{Method: "Start"}, process.DefaultState())
+			Expect(recorder.Err()).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,104 @@
+package transport
+
+import (
+	"time"
+
+	"github.com/renproject/hyperdrive/fault"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultMinBackoff is the delay before redialing a peer after the first
+	// failed attempt.
+	DefaultMinBackoff = 100 * time.Millisecond
+	// DefaultMaxBackoff is the maximum delay before redialing a peer.
+	DefaultMaxBackoff = 10 * time.Second
+	// DefaultDialTimeout is the timeout for dialing a peer.
+	DefaultDialTimeout = 5 * time.Second
+	// DefaultHandshakeTimeout is the timeout for authenticating a connection.
+	DefaultHandshakeTimeout = 5 * time.Second
+	// DefaultQueueCapacity is the number of messages that can be queued for a
+	// peer before new messages are dropped.
+	DefaultQueueCapacity = 1000
+	// DefaultMaxMessageSize is the maximum number of bytes in a message.
+	DefaultMaxMessageSize = 1024 * 1024
+)
+
+// Options represent the options for a Transport
+type Options struct {
+	Logger           *zap.Logger
+	MinBackoff       time.Duration
+	MaxBackoff       time.Duration
+	DialTimeout      time.Duration
+	HandshakeTimeout time.Duration
+	QueueCapacity    int
+	MaxMessageSize   int
+	Faults           fault.Model
+}
+
+// DefaultOptions returns the default options for a Transport
+func DefaultOptions() Options {
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		panic(err)
+	}
+	return Options{
+		Logger:           logger,
+		MinBackoff:       DefaultMinBackoff,
+		MaxBackoff:       DefaultMaxBackoff,
+		DialTimeout:      DefaultDialTimeout,
+		HandshakeTimeout: DefaultHandshakeTimeout,
+		QueueCapacity:    DefaultQueueCapacity,
+		MaxMessageSize:   DefaultMaxMessageSize,
+		Faults:           fault.NewModel(),
+	}
+}
+
+// WithLogger updates the logger used in the Transport
+func (opts Options) WithLogger(logger *zap.Logger) Options {
+	opts.Logger = logger
+	return opts
+}
+
+// WithBackoff updates the minimum and maximum delay before redialing a peer.
+// The delay doubles after every failed attempt, until it reaches the maximum.
+func (opts Options) WithBackoff(min, max time.Duration) Options {
+	opts.MinBackoff = min
+	opts.MaxBackoff = max
+	return opts
+}
+
+// WithDialTimeout updates the timeout for dialing a peer
+func (opts Options) WithDialTimeout(timeout time.Duration) Options {
+	opts.DialTimeout = timeout
+	return opts
+}
+
+// WithHandshakeTimeout updates the timeout for authenticating a connection.
+// Connections that do not complete the handshake in time are closed.
+func (opts Options) WithHandshakeTimeout(timeout time.Duration) Options {
+	opts.HandshakeTimeout = timeout
+	return opts
+}
+
+// WithQueueCapacity updates the number of messages that can be queued for a
+// peer while it is not connected
+func (opts Options) WithQueueCapacity(capacity int) Options {
+	opts.QueueCapacity = capacity
+	return opts
+}
+
+// WithMaxMessageSize updates the maximum number of bytes in a message.
+// Connections that send larger messages are closed.
+func (opts Options) WithMaxMessageSize(size int) Options {
+	opts.MaxMessageSize = size
+	return opts
+}
+
+// WithFaults updates the Model of the network faults that are injected into
+// messages before they are sent to peers. This is only intended for testing.
+func (opts Options) WithFaults(faults fault.Model) Options {
+	opts.Faults = faults
+	return opts
+}
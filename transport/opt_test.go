@@ -0,0 +1,66 @@
+package transport_test
+
+import (
+	"time"
+
+	"github.com/renproject/hyperdrive/fault"
+	"github.com/renproject/hyperdrive/transport"
+
+	"go.uber.org/zap"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Transport Opts", func() {
+	Context("Transport Opts", func() {
+		Specify("with default opts", func() {
+			opts := transport.DefaultOptions()
+			Expect(opts.MinBackoff).To(Equal(transport.DefaultMinBackoff))
+			Expect(opts.MaxBackoff).To(Equal(transport.DefaultMaxBackoff))
+			Expect(opts.DialTimeout).To(Equal(transport.DefaultDialTimeout))
+			Expect(opts.HandshakeTimeout).To(Equal(transport.DefaultHandshakeTimeout))
+			Expect(opts.QueueCapacity).To(Equal(transport.DefaultQueueCapacity))
+			Expect(opts.MaxMessageSize).To(Equal(transport.DefaultMaxMessageSize))
+			Expect(opts.Faults.Rules()).To(BeEmpty())
+		})
+
+		Specify("with logger", func() {
+			logger := zap.NewExample()
+			opts := transport.DefaultOptions().WithLogger(logger)
+			Expect(opts.Logger).To(Equal(logger))
+		})
+
+		Specify("with backoff", func() {
+			opts := transport.DefaultOptions().WithBackoff(time.Millisecond, time.Second)
+			Expect(opts.MinBackoff).To(Equal(time.Millisecond))
+			Expect(opts.MaxBackoff).To(Equal(time.Second))
+		})
+
+		Specify("with dial timeout", func() {
+			opts := transport.DefaultOptions().WithDialTimeout(time.Second)
+			Expect(opts.DialTimeout).To(Equal(time.Second))
+		})
+
+		Specify("with handshake timeout", func() {
+			opts := transport.DefaultOptions().WithHandshakeTimeout(time.Second)
+			Expect(opts.HandshakeTimeout).To(Equal(time.Second))
+		})
+
+		Specify("with queue capacity", func() {
+			opts := transport.DefaultOptions().WithQueueCapacity(10)
+			Expect(opts.QueueCapacity).To(Equal(10))
+		})
+
+		Specify("with max message size", func() {
+			opts := transport.DefaultOptions().WithMaxMessageSize(10)
+			Expect(opts.MaxMessageSize).To(Equal(10))
+		})
+
+		Specify("with faults", func() {
+			faults := fault.NewModel().Drop(fault.Always(), fault.AllLinks(), 0.5)
+			opts := transport.DefaultOptions().WithFaults(faults)
+			Expect(opts.Faults.Rules()).To(HaveLen(1))
+		})
+	})
+})
@@ -0,0 +1,234 @@
+package transport
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/renproject/id"
+)
+
+// sizeHintPubKey is the number of bytes in a compressed public key.
+const sizeHintPubKey = 33
+
+// Labels that separate the hashes of the handshake, so that a hash that is
+// computed for one purpose cannot be used for another.
+const (
+	labelInitiatorAuth = "hyperdrive/transport/initiator/auth"
+	labelResponderAuth = "hyperdrive/transport/responder/auth"
+	labelInitiatorKey  = "hyperdrive/transport/initiator/key"
+	labelResponderKey  = "hyperdrive/transport/responder/key"
+)
+
+// A Session is an authenticated, and encrypted, connection with a peer. It is
+// established by a Handshake, after which every message is sent as a frame: a
+// 4-byte big-endian length, followed by the message sealed with AES-256-GCM.
+// Each direction of the Session has its own key, and its own nonce counter, so
+// that nonces are never reused.
+type Session struct {
+	conn   net.Conn
+	r      *bufio.Reader
+	remote id.Signatory
+
+	sealer    cipher.AEAD
+	sealNonce uint64
+	opener    cipher.AEAD
+	openNonce uint64
+}
+
+// Handshake establishes a Session over the connection. The side that dialed the
+// connection is the initiator, and the other side is the responder. The
+// handshake has two steps, and in each step the initiator sends first:
+//
+//  1. Both sides send a compressed ephemeral secp256k1 public key, and derive a
+//     shared secret using ECDH. A session key for each direction is derived
+//     from the shared secret and both ephemeral public keys.
+//  2. Both sides sign a hash of both ephemeral public keys with their
+//     id.PrivKey, and send the signature encrypted with their session key. The
+//     signatory of the remote side is recovered from its signature.
+//
+// Signing the ephemeral public keys binds them to the identity of each side,
+// so that the connection cannot be intercepted by a third party. Handshake
+// does not check whether the remote signatory is allowed to connect; this is
+// left to the caller.
+func Handshake(conn net.Conn, privKey *id.PrivKey, initiator bool) (*Session, error) {
+	r := bufio.NewReader(conn)
+
+	// Exchange ephemeral public keys.
+	ephemeral, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, fmt.Errorf("generating ephemeral key: %v", err)
+	}
+	localEphemeral := crypto.CompressPubkey(&ephemeral.PublicKey)
+	remoteEphemeral := make([]byte, sizeHintPubKey)
+	err = exchange(initiator, func() error {
+		if _, err := conn.Write(localEphemeral); err != nil {
+			return fmt.Errorf("writing ephemeral key: %v", err)
+		}
+		return nil
+	}, func() error {
+		if _, err := io.ReadFull(r, remoteEphemeral); err != nil {
+			return fmt.Errorf("reading ephemeral key: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	remotePubKey, err := crypto.DecompressPubkey(remoteEphemeral)
+	if err != nil {
+		return nil, fmt.Errorf("decompressing ephemeral key: %v", err)
+	}
+
+	// Derive the session keys. The transcript orders the ephemeral public keys
+	// by role, so that both sides compute the same transcript.
+	transcript := append(append([]byte{}, localEphemeral...), remoteEphemeral...)
+	if !initiator {
+		transcript = append(append([]byte{}, remoteEphemeral...), localEphemeral...)
+	}
+	secret := sharedSecret(ephemeral, remotePubKey)
+	initiatorAEAD, err := newAEAD(deriveKey(labelInitiatorKey, secret, transcript))
+	if err != nil {
+		return nil, err
+	}
+	responderAEAD, err := newAEAD(deriveKey(labelResponderKey, secret, transcript))
+	if err != nil {
+		return nil, err
+	}
+	session := &Session{
+		conn:   conn,
+		r:      r,
+		sealer: initiatorAEAD,
+		opener: responderAEAD,
+	}
+	localLabel, remoteLabel := labelInitiatorAuth, labelResponderAuth
+	if !initiator {
+		session.sealer, session.opener = responderAEAD, initiatorAEAD
+		localLabel, remoteLabel = labelResponderAuth, labelInitiatorAuth
+	}
+
+	// Exchange signatures over the transcript. Each side signs a different
+	// hash, so that a signature cannot be reflected back to its signer.
+	localHash := id.NewHash(append([]byte(localLabel), transcript...))
+	localSig, err := privKey.Sign(&localHash)
+	if err != nil {
+		return nil, fmt.Errorf("signing handshake: %v", err)
+	}
+	var data []byte
+	err = exchange(initiator, func() error {
+		if err := session.WriteMessage(localSig[:]); err != nil {
+			return fmt.Errorf("writing handshake signature: %v", err)
+		}
+		return nil
+	}, func() (err error) {
+		if data, err = session.ReadMessage(id.SizeHintSignature); err != nil {
+			return fmt.Errorf("reading handshake signature: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != id.SizeHintSignature {
+		return nil, fmt.Errorf("bad handshake signature size: expected=%v, got=%v", id.SizeHintSignature, len(data))
+	}
+	remoteSig := id.Signature{}
+	copy(remoteSig[:], data)
+	remoteHash := id.NewHash(append([]byte(remoteLabel), transcript...))
+	session.remote, err = remoteSig.Signatory(&remoteHash)
+	if err != nil {
+		return nil, fmt.Errorf("recovering handshake signatory: %v", err)
+	}
+	return session, nil
+}
+
+// Remote returns the signatory of the remote side of the Session.
+func (session *Session) Remote() id.Signatory {
+	return session.remote
+}
+
+// WriteMessage seals the data, and writes it to the connection as a frame.
+func (session *Session) WriteMessage(data []byte) error {
+	sealed := session.sealer.Seal(nil, nonce(session.sealer, session.sealNonce), data, nil)
+	session.sealNonce++
+	return writeFrame(session.conn, sealed)
+}
+
+// ReadMessage reads a frame from the connection, and opens it. An error is
+// returned if the opened data would be greater than the maximum size, or if
+// the frame cannot be opened (for example, because it has been tampered with).
+// After an error, the Session must be closed.
+func (session *Session) ReadMessage(maxSize int) ([]byte, error) {
+	sealed, err := readFrame(session.r, maxSize+session.opener.Overhead())
+	if err != nil {
+		return nil, err
+	}
+	data, err := session.opener.Open(nil, nonce(session.opener, session.openNonce), sealed, nil)
+	if err != nil {
+		return nil, fmt.Errorf("opening message: %v", err)
+	}
+	session.openNonce++
+	return data, nil
+}
+
+// Close the connection of the Session.
+func (session *Session) Close() error {
+	return session.conn.Close()
+}
+
+// nonce returns the counter as a nonce for the AEAD.
+func nonce(aead cipher.AEAD, counter uint64) []byte {
+	nonce := make([]byte, aead.NonceSize())
+	binary.BigEndian.PutUint64(nonce[len(nonce)-8:], counter)
+	return nonce
+}
+
+// exchange sends, and then receives, if this side is the initiator. Otherwise,
+// it receives, and then sends. This means that the handshake does not depend on
+// the connection buffering writes.
+func exchange(initiator bool, send, recv func() error) error {
+	first, second := send, recv
+	if !initiator {
+		first, second = recv, send
+	}
+	if err := first(); err != nil {
+		return err
+	}
+	return second()
+}
+
+// sharedSecret returns the x-coordinate of the ECDH shared point.
+func sharedSecret(privKey *ecdsa.PrivateKey, pubKey *ecdsa.PublicKey) []byte {
+	x, _ := crypto.S256().ScalarMult(pubKey.X, pubKey.Y, privKey.D.Bytes())
+	secret := make([]byte, 32)
+	xBytes := x.Bytes()
+	copy(secret[32-len(xBytes):], xBytes)
+	return secret
+}
+
+func deriveKey(label string, secret, transcript []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte(label))
+	h.Write(secret)
+	h.Write(transcript)
+	return h.Sum(nil)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("creating cipher: %v", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("creating cipher: %v", err)
+	}
+	return aead, nil
+}
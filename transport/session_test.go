@@ -0,0 +1,146 @@
+package transport_test
+
+import (
+	"bytes"
+	"math/rand"
+	"net"
+	"testing/quick"
+	"time"
+
+	"github.com/renproject/hyperdrive/transport"
+	"github.com/renproject/id"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Session", func() {
+	// handshake connects two sessions through a pipe, and returns them
+	handshake := func(initiatorKey, responderKey *id.PrivKey) (*transport.Session, *transport.Session) {
+		initiatorConn, responderConn := net.Pipe()
+		errs := make(chan error, 1)
+		var responder *transport.Session
+		go func() {
+			var err error
+			responder, err = transport.Handshake(responderConn, responderKey, false)
+			errs <- err
+		}()
+		initiator, err := transport.Handshake(initiatorConn, initiatorKey, true)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(<-errs).ToNot(HaveOccurred())
+		return initiator, responder
+	}
+
+	Context("when both sides complete the handshake", func() {
+		It("should authenticate both sides", func() {
+			initiatorKey, responderKey := id.NewPrivKey(), id.NewPrivKey()
+			initiator, responder := handshake(initiatorKey, responderKey)
+			defer initiator.Close()
+			defer responder.Close()
+
+			Expect(initiator.Remote()).To(Equal(responderKey.Signatory()))
+			Expect(responder.Remote()).To(Equal(initiatorKey.Signatory()))
+		})
+
+		It("should send messages in both directions", func() {
+			initiator, responder := handshake(id.NewPrivKey(), id.NewPrivKey())
+			defer initiator.Close()
+			defer responder.Close()
+
+			f := func(data []byte, fromInitiator bool) bool {
+				from, to := initiator, responder
+				if !fromInitiator {
+					from, to = responder, initiator
+				}
+				go func() {
+					defer GinkgoRecover()
+					Expect(from.WriteMessage(data)).To(Succeed())
+				}()
+				received, err := to.ReadMessage(len(data))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(bytes.Equal(received, data)).To(BeTrue())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+	})
+
+	Context("when messages are sent over the connection", func() {
+		It("should encrypt them", func() {
+			initiatorConn, responderConn := net.Pipe()
+			defer initiatorConn.Close()
+			defer responderConn.Close()
+
+			// record everything that the initiator writes after the handshake
+			go func() {
+				defer GinkgoRecover()
+				_, err := transport.Handshake(responderConn, id.NewPrivKey(), false)
+				Expect(err).ToNot(HaveOccurred())
+			}()
+			initiator, err := transport.Handshake(initiatorConn, id.NewPrivKey(), true)
+			Expect(err).ToNot(HaveOccurred())
+
+			r := rand.New(rand.NewSource(time.Now().UnixNano()))
+			data := make([]byte, 64)
+			r.Read(data)
+			received := make(chan []byte, 1)
+			go func() {
+				buf := make([]byte, 1024)
+				n, _ := responderConn.Read(buf)
+				received <- buf[:n]
+			}()
+			Expect(initiator.WriteMessage(data)).To(Succeed())
+
+			var raw []byte
+			Eventually(received).Should(Receive(&raw))
+			Expect(bytes.Contains(raw, data)).To(BeFalse())
+		})
+
+		It("should reject messages that have been tampered with", func() {
+			initiatorConn, responderConn := net.Pipe()
+			defer initiatorConn.Close()
+			defer responderConn.Close()
+
+			var responder *transport.Session
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				var err error
+				responder, err = transport.Handshake(responderConn, id.NewPrivKey(), false)
+				Expect(err).ToNot(HaveOccurred())
+			}()
+			_, err := transport.Handshake(initiatorConn, id.NewPrivKey(), true)
+			Expect(err).ToNot(HaveOccurred())
+			<-done
+
+			// a frame with 4 bytes of data, and 16 bytes that should be the
+			// authentication tag
+			go func() {
+				frame := make([]byte, 4+20)
+				frame[3] = 20
+				initiatorConn.Write(frame)
+			}()
+			_, err = responder.ReadMessage(4)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("when the remote side does not complete the handshake", func() {
+		It("should return an error", func() {
+			initiatorConn, responderConn := net.Pipe()
+			defer initiatorConn.Close()
+
+			// the responder sends a public key that is not on the curve, and
+			// then closes the connection
+			go func() {
+				buf := make([]byte, 33)
+				responderConn.Read(buf)
+				responderConn.Write(bytes.Repeat([]byte{0xFF}, 33))
+				responderConn.Close()
+			}()
+			_, err := transport.Handshake(initiatorConn, id.NewPrivKey(), true)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,560 @@
+// Package transport implements a TCP transport for Replicas. Each Transport has
+// a static list of peers, identified by their signatories, and it keeps a
+// connection to each of them, redialing with exponential backoff whenever the
+// connection is lost.
+//
+// Every connection begins with a Handshake that authenticates both sides with
+// their id.PrivKey, and establishes an encrypted Session (see Handshake for
+// details). Connections from signatories that are not allowed, and connections
+// to addresses that do not belong to the expected peer, are closed. Every
+// message is then sent as an encrypted frame that contains the
+// process.MessageType of the message, followed by the message in binary.
+//
+// By default, the peers are the allowed signatories. When the committee of the
+// Replica changes, the allowed signatories are replaced with SetAllowed, and
+// the connections of signatories that are no longer allowed are closed. The
+// Transport implements the replica.Membership interface, so that this can be
+// done by the Replica itself.
+//
+// The Transport implements the process.Broadcaster interface, and it passes the
+// messages that it receives to a Receiver (usually a replica.Replica). The
+// Replica still verifies the signatures of messages, because messages can be
+// relayed by peers other than their sender.
+package transport
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/renproject/hyperdrive/fault"
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/id"
+	"github.com/renproject/surge"
+
+	"go.uber.org/zap"
+)
+
+// A Receiver is given the messages that are received by a Transport. It is
+// implemented by replica.Replica.
+type Receiver interface {
+	Propose(context.Context, process.Propose)
+	Prevote(context.Context, process.Prevote)
+	Precommit(context.Context, process.Precommit)
+	Timeout(context.Context, process.Timeout)
+}
+
+// A Transport sends messages to, and receives messages from, a static list of
+// peers over TCP. Messages that are broadcast are also given to the Receiver
+// of the Transport itself, because the Broadcaster must deliver messages to
+// the Process that broadcast them.
+//
+// Each peer has a queue of messages that is drained while the peer is
+// connected. If the queue is full, then new messages for that peer are
+// dropped. Consensus does not depend on every message being delivered, so
+// dropped messages only slow it down.
+type Transport struct {
+	opts     Options
+	privKey  *id.PrivKey
+	self     id.Signatory
+	peers    map[id.Signatory]string
+	receiver Receiver
+
+	queues   map[id.Signatory]chan []byte
+	loopback chan interface{}
+
+	// allowed is the set of signatories that are allowed to connect, and
+	// conns are the authenticated connections, with their remote signatories,
+	// so that they can be closed when their remote is no longer allowed.
+	allowedMu *sync.Mutex
+	allowed   map[id.Signatory]bool
+	conns     map[net.Conn]id.Signatory
+
+	// The time at which the Transport was created, and the random number
+	// generator, are used to inject faults.
+	start   time.Time
+	faultMu *sync.Mutex
+	faultR  *rand.Rand
+}
+
+// New returns a Transport that authenticates itself with the private key. The
+// peers map the signatories of the other Replicas to their TCP addresses, and
+// only these signatories are allowed to connect until SetAllowed is called. If
+// the signatory of the Transport is one of the peers, then it is ignored.
+func New(opts Options, privKey *id.PrivKey, peers map[id.Signatory]string, receiver Receiver) *Transport {
+	self := privKey.Signatory()
+	t := &Transport{
+		opts:     opts,
+		privKey:  privKey,
+		self:     self,
+		peers:    make(map[id.Signatory]string, len(peers)),
+		receiver: receiver,
+
+		queues:   make(map[id.Signatory]chan []byte, len(peers)),
+		loopback: make(chan interface{}, opts.QueueCapacity),
+
+		allowedMu: new(sync.Mutex),
+		allowed:   make(map[id.Signatory]bool, len(peers)),
+		conns:     map[net.Conn]id.Signatory{},
+
+		start:   time.Now(),
+		faultMu: new(sync.Mutex),
+		faultR:  rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	for peer, addr := range peers {
+		if peer.Equal(&self) {
+			continue
+		}
+		t.peers[peer] = addr
+		t.queues[peer] = make(chan []byte, opts.QueueCapacity)
+		t.allowed[peer] = true
+	}
+	return t
+}
+
+// SetAllowed replaces the signatories that are allowed to connect, and closes
+// the connections of signatories that are no longer allowed. Messages are only
+// sent to peers that are allowed, and only received from signatories that are
+// allowed, but signatories that are not peers are never dialed, because their
+// addresses are not known. It is safe for concurrent use, and it implements
+// the replica.Membership interface.
+func (t *Transport) SetAllowed(signatories []id.Signatory) {
+	t.allowedMu.Lock()
+	defer t.allowedMu.Unlock()
+
+	t.allowed = make(map[id.Signatory]bool, len(signatories))
+	for _, signatory := range signatories {
+		if !signatory.Equal(&t.self) {
+			t.allowed[signatory] = true
+		}
+	}
+	for conn, remote := range t.conns {
+		if !t.allowed[remote] {
+			t.logWarn("closing connection to peer that is no longer allowed", remote)
+			conn.Close()
+			delete(t.conns, conn)
+		}
+	}
+}
+
+// Run the Transport until the context is done. Connections are accepted from
+// the listener, unless it is nil, and the peers are dialed. The listener is
+// closed when the context is done.
+func (t *Transport) Run(ctx context.Context, listener net.Listener) {
+	wg := new(sync.WaitGroup)
+	if listener != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			t.accept(ctx, listener)
+		}()
+	}
+	for peer, addr := range t.peers {
+		peer, addr := peer, addr
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			t.dial(ctx, peer, addr)
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg := <-t.loopback:
+				t.deliver(ctx, msg)
+			}
+		}
+	}()
+
+	<-ctx.Done()
+	if listener != nil {
+		listener.Close()
+	}
+	wg.Wait()
+}
+
+// BroadcastPropose sends the Propose to all peers, and to the Receiver.
+func (t *Transport) BroadcastPropose(propose process.Propose) {
+	t.broadcast(process.MessageTypePropose, propose)
+}
+
+// BroadcastPrevote sends the Prevote to all peers, and to the Receiver.
+func (t *Transport) BroadcastPrevote(prevote process.Prevote) {
+	t.broadcast(process.MessageTypePrevote, prevote)
+}
+
+// BroadcastPrecommit sends the Precommit to all peers, and to the Receiver.
+func (t *Transport) BroadcastPrecommit(precommit process.Precommit) {
+	t.broadcast(process.MessageTypePrecommit, precommit)
+}
+
+// BroadcastTimeout sends the Timeout to all peers, and to the Receiver.
+func (t *Transport) BroadcastTimeout(timeout process.Timeout) {
+	t.broadcast(process.MessageTypeTimeout, timeout)
+}
+
+func (t *Transport) broadcast(ty process.MessageType, msg surge.Marshaler) {
+	data, err := encode(ty, msg)
+	if err != nil {
+		t.logError("encoding message", err)
+		return
+	}
+	select {
+	case t.loopback <- msg:
+	default:
+		t.logWarn("dropping message", t.self)
+	}
+	for peer := range t.queues {
+		if t.isAllowed(peer) {
+			t.send(peer, msg, data)
+		}
+	}
+}
+
+// send the encoded message to the peer by adding it to the queue of the peer.
+// Faults from the fault.Model are injected into the message first.
+func (t *Transport) send(peer id.Signatory, msg interface{}, data []byte) {
+	t.faultMu.Lock()
+	effect := t.opts.Faults.Apply(t.self, peer, msg, time.Since(t.start), t.faultR)
+	t.faultMu.Unlock()
+	for _, injection := range effect.Injections {
+		t.logInjection(injection)
+	}
+	if effect.Drop {
+		return
+	}
+	enqueue := func() {
+		for i := 0; i <= effect.Duplicates; i++ {
+			select {
+			case t.queues[peer] <- data:
+			default:
+				t.logWarn("dropping message", peer)
+			}
+		}
+	}
+	if effect.Delay > 0 {
+		time.AfterFunc(effect.Delay, enqueue)
+		return
+	}
+	enqueue()
+}
+
+// accept connections from the listener until it is closed, and handle each of
+// them in the background.
+func (t *Transport) accept(ctx context.Context, listener net.Listener) {
+	wg := new(sync.WaitGroup)
+	defer wg.Wait()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() == nil {
+				t.logError("accepting connection", err)
+			}
+			return
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			t.handle(ctx, conn)
+		}()
+	}
+}
+
+// handle an inbound connection by authenticating it, and then reading messages
+// from it and giving them to the Receiver, until the connection is closed, a
+// malformed message is received, or the context is done.
+func (t *Transport) handle(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	defer closeOnDone(ctx, conn)()
+
+	session, err := t.handshake(conn, false)
+	if err != nil {
+		if ctx.Err() == nil {
+			t.logError("accepting handshake", err)
+		}
+		return
+	}
+	if !t.register(conn, session.Remote()) {
+		t.logWarn("dropping connection from peer that is not allowed", session.Remote())
+		return
+	}
+	defer t.unregister(conn)
+
+	for {
+		data, err := session.ReadMessage(t.opts.MaxMessageSize)
+		if err != nil {
+			if err != io.EOF && ctx.Err() == nil {
+				t.logError("reading message", err)
+			}
+			return
+		}
+		msg, err := decode(data)
+		if err != nil {
+			t.logError("decoding message", err)
+			return
+		}
+		t.deliver(ctx, msg)
+	}
+}
+
+// dial the peer, and write the messages in its queue to the connection. When
+// the connection is lost, the peer is redialed with exponential backoff, and
+// the message that could not be written is written again.
+func (t *Transport) dial(ctx context.Context, peer id.Signatory, addr string) {
+	queue := t.queues[peer]
+	dialer := net.Dialer{Timeout: t.opts.DialTimeout}
+	backoff := t.opts.MinBackoff
+	var pending []byte
+	for {
+		conn, session, err := t.connect(ctx, dialer, peer, addr)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			t.logError("dialing peer", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			if backoff > t.opts.MaxBackoff {
+				backoff = t.opts.MaxBackoff
+			}
+			continue
+		}
+		backoff = t.opts.MinBackoff
+
+		// Peers never write to connections that they have accepted, after the
+		// handshake, so reading only returns once the connection has been
+		// lost. Closing the connection makes the next write fail, instead of
+		// silently writing into a connection that has been closed by the peer.
+		stop := closeOnDone(ctx, conn)
+		go func() {
+			io.Copy(ioutil.Discard, session.r)
+			conn.Close()
+		}()
+		pending = t.write(ctx, session, queue, pending)
+		t.unregister(conn)
+		conn.Close()
+		stop()
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// connect dials the address, and authenticates the connection. An error is
+// returned if the connection does not belong to the expected peer.
+func (t *Transport) connect(ctx context.Context, dialer net.Dialer, peer id.Signatory, addr string) (net.Conn, *Session, error) {
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	stop := closeOnDone(ctx, conn)
+	defer stop()
+	session, err := t.handshake(conn, true)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	if remote := session.Remote(); !remote.Equal(&peer) {
+		conn.Close()
+		return nil, nil, fmt.Errorf("bad peer: expected=%v, got=%v", peer, remote)
+	}
+	if !t.register(conn, peer) {
+		conn.Close()
+		return nil, nil, fmt.Errorf("bad peer: peer=%v is not allowed", peer)
+	}
+	return conn, session, nil
+}
+
+// isAllowed returns true if the signatory is allowed to connect.
+func (t *Transport) isAllowed(signatory id.Signatory) bool {
+	t.allowedMu.Lock()
+	defer t.allowedMu.Unlock()
+	return t.allowed[signatory]
+}
+
+// register an authenticated connection, so that it is closed if its remote is
+// no longer allowed. It returns false, and does not register the connection,
+// if the remote is not allowed.
+func (t *Transport) register(conn net.Conn, remote id.Signatory) bool {
+	t.allowedMu.Lock()
+	defer t.allowedMu.Unlock()
+	if !t.allowed[remote] {
+		return false
+	}
+	t.conns[conn] = remote
+	return true
+}
+
+// unregister a connection that is no longer used.
+func (t *Transport) unregister(conn net.Conn) {
+	t.allowedMu.Lock()
+	defer t.allowedMu.Unlock()
+	delete(t.conns, conn)
+}
+
+// handshake establishes a Session over the connection. The handshake must be
+// completed before the handshake timeout.
+func (t *Transport) handshake(conn net.Conn, initiator bool) (*Session, error) {
+	if err := conn.SetDeadline(time.Now().Add(t.opts.HandshakeTimeout)); err != nil {
+		return nil, err
+	}
+	session, err := Handshake(conn, t.privKey, initiator)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// write messages from the queue to the Session until writing fails, or the
+// context is done. It returns the message that could not be written.
+func (t *Transport) write(ctx context.Context, session *Session, queue chan []byte, pending []byte) []byte {
+	for {
+		if pending == nil {
+			select {
+			case <-ctx.Done():
+				return nil
+			case pending = <-queue:
+			}
+		}
+		if err := session.WriteMessage(pending); err != nil {
+			t.logError("writing message", err)
+			return pending
+		}
+		pending = nil
+	}
+}
+
+func (t *Transport) deliver(ctx context.Context, msg interface{}) {
+	switch msg := msg.(type) {
+	case process.Propose:
+		t.receiver.Propose(ctx, msg)
+	case process.Prevote:
+		t.receiver.Prevote(ctx, msg)
+	case process.Precommit:
+		t.receiver.Precommit(ctx, msg)
+	case process.Timeout:
+		t.receiver.Timeout(ctx, msg)
+	}
+}
+
+func (t *Transport) logError(msg string, err error) {
+	if t.opts.Logger != nil {
+		t.opts.Logger.Error(msg, zap.Error(err))
+	}
+}
+
+func (t *Transport) logWarn(msg string, peer id.Signatory) {
+	if t.opts.Logger != nil {
+		t.opts.Logger.Warn(msg, zap.String("peer", peer.String()))
+	}
+}
+
+func (t *Transport) logInjection(injection fault.Injection) {
+	if t.opts.Logger != nil {
+		t.opts.Logger.Debug("injecting fault", zap.Stringer("kind", injection.Kind), zap.String("peer", injection.To.String()), zap.Duration("delay", injection.Delay))
+	}
+}
+
+// closeOnDone closes the connection when the context is done, so that reading
+// from, or writing to, the connection returns. The returned function must be
+// called once the connection is no longer used.
+func closeOnDone(ctx context.Context, conn net.Conn) func() {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
+
+// encode the message, prefixed with its type.
+func encode(ty process.MessageType, msg surge.Marshaler) ([]byte, error) {
+	data := make([]byte, 1+msg.SizeHint())
+	data[0] = byte(ty)
+	if _, _, err := msg.Marshal(data[1:], len(data)-1); err != nil {
+		return nil, fmt.Errorf("marshaling type=%v: %v", ty, err)
+	}
+	return data, nil
+}
+
+// decode a message that was encoded with its type.
+func decode(data []byte) (interface{}, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("unmarshaling type: empty message")
+	}
+	ty := process.MessageType(data[0])
+	var msg interface{}
+	var err error
+	switch ty {
+	case process.MessageTypePropose:
+		value := process.Propose{}
+		_, _, err = value.Unmarshal(data[1:], len(data)-1)
+		msg = value
+	case process.MessageTypePrevote:
+		value := process.Prevote{}
+		_, _, err = value.Unmarshal(data[1:], len(data)-1)
+		msg = value
+	case process.MessageTypePrecommit:
+		value := process.Precommit{}
+		_, _, err = value.Unmarshal(data[1:], len(data)-1)
+		msg = value
+	case process.MessageTypeTimeout:
+		value := process.Timeout{}
+		_, _, err = value.Unmarshal(data[1:], len(data)-1)
+		msg = value
+	default:
+		return nil, fmt.Errorf("unmarshaling message: unknown type=%v", ty)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unmarshaling type=%v: %v", ty, err)
+	}
+	return msg, nil
+}
+
+// writeFrame writes the data, prefixed with its length.
+func writeFrame(w io.Writer, data []byte) error {
+	frame := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint32(frame, uint32(len(data)))
+	copy(frame[4:], data)
+	_, err := w.Write(frame)
+	return err
+}
+
+// readFrame reads data that was prefixed with its length. An error is returned
+// if the length is greater than the maximum size.
+func readFrame(r io.Reader, maxSize int) ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(header[:])
+	if uint64(n) > uint64(maxSize) {
+		return nil, fmt.Errorf("bad message size: expected<=%v, got=%v", maxSize, n)
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
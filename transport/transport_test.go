@@ -0,0 +1,429 @@
+package transport_test
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/renproject/hyperdrive/fault"
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/hyperdrive/process/processutil"
+	"github.com/renproject/hyperdrive/replica"
+	"github.com/renproject/hyperdrive/transport"
+	"github.com/renproject/id"
+
+	"go.uber.org/zap"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// Replicas receive the messages from a Transport, and Transports broadcast the
+// messages of Replicas.
+var _ transport.Receiver = &replica.Replica{}
+var _ process.Broadcaster = &transport.Transport{}
+
+// Replicas notify Transports when their allowed signatories change.
+var _ replica.Membership = &transport.Transport{}
+
+// mockReceiver sends all messages that it receives to a channel.
+type mockReceiver struct {
+	msgs chan interface{}
+}
+
+func newMockReceiver() mockReceiver {
+	return mockReceiver{msgs: make(chan interface{}, 100)}
+}
+
+func (r mockReceiver) Propose(ctx context.Context, propose process.Propose) {
+	r.msgs <- propose
+}
+
+func (r mockReceiver) Prevote(ctx context.Context, prevote process.Prevote) {
+	r.msgs <- prevote
+}
+
+func (r mockReceiver) Precommit(ctx context.Context, precommit process.Precommit) {
+	r.msgs <- precommit
+}
+
+func (r mockReceiver) Timeout(ctx context.Context, timeout process.Timeout) {
+	r.msgs <- timeout
+}
+
+var _ = Describe("Transport", func() {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	opts := transport.DefaultOptions().
+		WithLogger(zap.NewNop()).
+		WithBackoff(10*time.Millisecond, 100*time.Millisecond)
+
+	// listen on a random local port
+	listen := func() net.Listener {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		return listener
+	}
+
+	// newPeers returns n private keys, listeners for each of them, and the
+	// peers that map their signatories to the addresses of their listeners
+	newPeers := func(n int) ([]*id.PrivKey, []net.Listener, map[id.Signatory]string) {
+		privKeys := make([]*id.PrivKey, n)
+		listeners := make([]net.Listener, n)
+		peers := make(map[id.Signatory]string, n)
+		for i := range privKeys {
+			privKeys[i] = id.NewPrivKey()
+			listeners[i] = listen()
+			peers[privKeys[i].Signatory()] = listeners[i].Addr().String()
+		}
+		return privKeys, listeners, peers
+	}
+
+	// expectClosed expects the connection to be closed by the remote side
+	expectClosed := func(conn net.Conn) {
+		Expect(conn.SetReadDeadline(time.Now().Add(5 * time.Second))).To(Succeed())
+		_, err := conn.Read(make([]byte, 1))
+		Expect(err).To(HaveOccurred())
+		if netErr, ok := err.(net.Error); ok {
+			Expect(netErr.Timeout()).To(BeFalse())
+		}
+	}
+
+	Context("when broadcasting messages", func() {
+		It("should deliver them to all peers, and to itself", func() {
+			n := 4
+			privKeys, listeners, peers := newPeers(n)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			transports := make([]*transport.Transport, n)
+			receivers := make([]mockReceiver, n)
+			for i := range transports {
+				receivers[i] = newMockReceiver()
+				transports[i] = transport.New(opts, privKeys[i], peers, receivers[i])
+				go transports[i].Run(ctx, listeners[i])
+			}
+
+			propose := processutil.RandomPropose(r)
+			prevote := processutil.RandomPrevote(r)
+			precommit := processutil.RandomPrecommit(r)
+			timeout := processutil.RandomTimeout(r)
+			sender := transports[r.Intn(n)]
+			sender.BroadcastPropose(propose)
+			sender.BroadcastPrevote(prevote)
+			sender.BroadcastPrecommit(precommit)
+			sender.BroadcastTimeout(timeout)
+
+			// messages are delivered in order
+			for _, receiver := range receivers {
+				Eventually(receiver.msgs).Should(Receive(Equal(propose)))
+				Eventually(receiver.msgs).Should(Receive(Equal(prevote)))
+				Eventually(receiver.msgs).Should(Receive(Equal(precommit)))
+				Eventually(receiver.msgs).Should(Receive(Equal(timeout)))
+				Consistently(receiver.msgs, 100*time.Millisecond).ShouldNot(Receive())
+			}
+		})
+	})
+
+	Context("when a peer is not listening", func() {
+		It("should queue messages, and deliver them once the peer is listening", func() {
+			privKeys, listeners, peers := newPeers(2)
+			addr := listeners[1].Addr().String()
+			Expect(listeners[1].Close()).To(Succeed())
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			sender := transport.New(opts, privKeys[0], peers, newMockReceiver())
+			go sender.Run(ctx, listeners[0])
+			prevote := processutil.RandomPrevote(r)
+			sender.BroadcastPrevote(prevote)
+
+			// let the sender fail to dial a few times
+			time.Sleep(100 * time.Millisecond)
+			listener, err := net.Listen("tcp", addr)
+			Expect(err).ToNot(HaveOccurred())
+			receiver := newMockReceiver()
+			go transport.New(opts, privKeys[1], peers, receiver).Run(ctx, listener)
+			Eventually(receiver.msgs, 5*time.Second).Should(Receive(Equal(prevote)))
+		})
+	})
+
+	Context("when a peer restarts", func() {
+		It("should reconnect to the peer", func() {
+			privKeys, listeners, peers := newPeers(2)
+			addr := listeners[1].Addr().String()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			sender := transport.New(opts, privKeys[0], peers, newMockReceiver())
+			go sender.Run(ctx, listeners[0])
+			receiverCtx, receiverCancel := context.WithCancel(ctx)
+			receiver := newMockReceiver()
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				transport.New(opts, privKeys[1], peers, receiver).Run(receiverCtx, listeners[1])
+			}()
+			prevote := processutil.RandomPrevote(r)
+			sender.BroadcastPrevote(prevote)
+			Eventually(receiver.msgs).Should(Receive(Equal(prevote)))
+
+			// restart the peer on the same address
+			receiverCancel()
+			Eventually(done).Should(BeClosed())
+			listener, err := net.Listen("tcp", addr)
+			Expect(err).ToNot(HaveOccurred())
+			receiver = newMockReceiver()
+			go transport.New(opts, privKeys[1], peers, receiver).Run(ctx, listener)
+
+			// the first message after the restart can be written into the
+			// lost connection before the sender notices that it has been
+			// lost, so messages are broadcast until one is received
+			Eventually(func() bool {
+				prevote := processutil.RandomPrevote(r)
+				sender.BroadcastPrevote(prevote)
+				select {
+				case msg := <-receiver.msgs:
+					Expect(msg).To(BeAssignableToTypeOf(prevote))
+					return true
+				case <-time.After(100 * time.Millisecond):
+					return false
+				}
+			}, 5*time.Second).Should(BeTrue())
+		})
+	})
+
+	Context("when receiving malformed messages", func() {
+		It("should close the connection", func() {
+			privKeys, listeners, peers := newPeers(2)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			receiver := newMockReceiver()
+			go transport.New(opts.WithMaxMessageSize(1024), privKeys[0], peers, receiver).Run(ctx, listeners[0])
+			addr := listeners[0].Addr().String()
+
+			// connect to the transport as an allowed peer
+			connect := func() (net.Conn, *transport.Session) {
+				conn, err := net.Dial("tcp", addr)
+				Expect(err).ToNot(HaveOccurred())
+				session, err := transport.Handshake(conn, privKeys[1], true)
+				Expect(err).ToNot(HaveOccurred())
+				return conn, session
+			}
+
+			// too large
+			conn, _ := connect()
+			_, err := conn.Write([]byte{0x00, 0x00, 0x04, 0x20})
+			Expect(err).ToNot(HaveOccurred())
+			expectClosed(conn)
+			conn.Close()
+
+			// not encrypted
+			conn, _ = connect()
+			_, err = conn.Write([]byte{0x00, 0x00, 0x00, 0x01, byte(process.MessageTypePrevote)})
+			Expect(err).ToNot(HaveOccurred())
+			expectClosed(conn)
+			conn.Close()
+
+			// unknown type, and empty
+			for _, data := range [][]byte{{0x7F}, {}} {
+				conn, session := connect()
+				Expect(session.WriteMessage(data)).To(Succeed())
+				expectClosed(conn)
+				conn.Close()
+			}
+
+			// well-formed messages are still received from new connections
+			conn, session := connect()
+			defer conn.Close()
+			prevote := processutil.RandomPrevote(r)
+			data := make([]byte, 1+prevote.SizeHint())
+			data[0] = byte(process.MessageTypePrevote)
+			_, _, err = prevote.Marshal(data[1:], prevote.SizeHint())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(session.WriteMessage(data)).To(Succeed())
+			Eventually(receiver.msgs).Should(Receive(Equal(prevote)))
+			Consistently(receiver.msgs, 100*time.Millisecond).ShouldNot(Receive())
+		})
+	})
+
+	Context("when a signatory that is not a peer connects", func() {
+		It("should close the connection", func() {
+			privKeys, listeners, peers := newPeers(1)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			receiver := newMockReceiver()
+			go transport.New(opts, privKeys[0], peers, receiver).Run(ctx, listeners[0])
+
+			conn, err := net.Dial("tcp", listeners[0].Addr().String())
+			Expect(err).ToNot(HaveOccurred())
+			defer conn.Close()
+			session, err := transport.Handshake(conn, id.NewPrivKey(), true)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(session.Remote()).To(Equal(privKeys[0].Signatory()))
+
+			prevote := processutil.RandomPrevote(r)
+			data := make([]byte, 1+prevote.SizeHint())
+			data[0] = byte(process.MessageTypePrevote)
+			_, _, err = prevote.Marshal(data[1:], prevote.SizeHint())
+			Expect(err).ToNot(HaveOccurred())
+			session.WriteMessage(data)
+			expectClosed(conn)
+			Consistently(receiver.msgs, 100*time.Millisecond).ShouldNot(Receive())
+		})
+	})
+
+	Context("when the committee changes", func() {
+		It("should disconnect the signatories that are no longer allowed, and accept the new ones", func() {
+			privKeys, listeners, peers := newPeers(3)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			receiver := newMockReceiver()
+			t := transport.New(opts, privKeys[0], peers, receiver)
+			go t.Run(ctx, listeners[0])
+			addr := listeners[0].Addr().String()
+
+			// connect to the transport, and send a prevote
+			connect := func(privKey *id.PrivKey) (net.Conn, *transport.Session) {
+				conn, err := net.Dial("tcp", addr)
+				Expect(err).ToNot(HaveOccurred())
+				session, err := transport.Handshake(conn, privKey, true)
+				Expect(err).ToNot(HaveOccurred())
+				return conn, session
+			}
+			send := func(session *transport.Session) process.Prevote {
+				prevote := processutil.RandomPrevote(r)
+				data := make([]byte, 1+prevote.SizeHint())
+				data[0] = byte(process.MessageTypePrevote)
+				_, _, err := prevote.Marshal(data[1:], prevote.SizeHint())
+				Expect(err).ToNot(HaveOccurred())
+				session.WriteMessage(data)
+				return prevote
+			}
+
+			removed, removedSession := connect(privKeys[1])
+			defer removed.Close()
+			kept, keptSession := connect(privKeys[2])
+			defer kept.Close()
+			Eventually(receiver.msgs).Should(Receive(Equal(send(removedSession))))
+			Eventually(receiver.msgs).Should(Receive(Equal(send(keptSession))))
+
+			// rotate the committee, replacing the first peer with a new
+			// signatory
+			added := id.NewPrivKey()
+			t.SetAllowed([]id.Signatory{privKeys[0].Signatory(), privKeys[2].Signatory(), added.Signatory()})
+			expectClosed(removed)
+			Eventually(receiver.msgs).Should(Receive(Equal(send(keptSession))))
+
+			// the removed signatory cannot reconnect
+			conn, session := connect(privKeys[1])
+			defer conn.Close()
+			send(session)
+			expectClosed(conn)
+			Consistently(receiver.msgs, 100*time.Millisecond).ShouldNot(Receive())
+
+			// the new signatory can connect, even though it is not a peer
+			conn, session = connect(added)
+			defer conn.Close()
+			Eventually(receiver.msgs).Should(Receive(Equal(send(session))))
+		})
+
+		It("should stop sending messages to the signatories that are no longer allowed", func() {
+			privKeys, listeners, peers := newPeers(2)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			sender := transport.New(opts, privKeys[0], peers, newMockReceiver())
+			go sender.Run(ctx, listeners[0])
+			receiver := newMockReceiver()
+			go transport.New(opts, privKeys[1], peers, receiver).Run(ctx, listeners[1])
+			prevote := processutil.RandomPrevote(r)
+			sender.BroadcastPrevote(prevote)
+			Eventually(receiver.msgs, 5*time.Second).Should(Receive(Equal(prevote)))
+
+			sender.SetAllowed([]id.Signatory{privKeys[0].Signatory()})
+			sender.BroadcastPrevote(processutil.RandomPrevote(r))
+			Consistently(receiver.msgs, 500*time.Millisecond).ShouldNot(Receive())
+		})
+	})
+
+	Context("when the address of a peer belongs to another signatory", func() {
+		It("should not send messages to it", func() {
+			privKeys, listeners, peers := newPeers(2)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			// the impostor listens on the address of the peer, and allows the
+			// sender to connect
+			impostorPeers := map[id.Signatory]string{privKeys[0].Signatory(): ""}
+			impostor := newMockReceiver()
+			go transport.New(opts, id.NewPrivKey(), impostorPeers, impostor).Run(ctx, listeners[1])
+
+			sender := transport.New(opts, privKeys[0], peers, newMockReceiver())
+			go sender.Run(ctx, listeners[0])
+			sender.BroadcastPrevote(processutil.RandomPrevote(r))
+			Consistently(impostor.msgs, 500*time.Millisecond).ShouldNot(Receive())
+		})
+	})
+
+	Context("when injecting faults", func() {
+		It("should drop, duplicate, and delay messages to peers", func() {
+			privKeys, listeners, peers := newPeers(4)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			self := privKeys[0].Signatory()
+			dropped, duplicated, delayed := privKeys[1].Signatory(), privKeys[2].Signatory(), privKeys[3].Signatory()
+
+			// faults are only injected once every connection has been
+			// established, so that the time it takes to connect is not
+			// mistaken for the delay
+			connected := int32(0)
+			directed := func(to id.Signatory) fault.Links {
+				links := fault.Directed([]id.Signatory{self}, []id.Signatory{to})
+				return func(from, to id.Signatory) bool {
+					return atomic.LoadInt32(&connected) == 1 && links(from, to)
+				}
+			}
+			faults := fault.NewModel().
+				Drop(fault.Always(), directed(dropped), 1).
+				Duplicate(fault.Always(), directed(duplicated), 1).
+				Delay(fault.Always(), directed(delayed), 500*time.Millisecond, 500*time.Millisecond)
+
+			transports := make([]*transport.Transport, 4)
+			receivers := make([]mockReceiver, 4)
+			for i := range transports {
+				receivers[i] = newMockReceiver()
+				transportOpts := opts
+				if i == 0 {
+					transportOpts = opts.WithFaults(faults)
+				}
+				transports[i] = transport.New(transportOpts, privKeys[i], peers, receivers[i])
+				go transports[i].Run(ctx, listeners[i])
+			}
+
+			// the first prevote is delivered to every peer once it has
+			// connected
+			warmup := processutil.RandomPrevote(r)
+			transports[0].BroadcastPrevote(warmup)
+			for i := range receivers {
+				Eventually(receivers[i].msgs, 10*time.Second).Should(Receive(Equal(warmup)))
+			}
+			atomic.StoreInt32(&connected, 1)
+
+			prevote := processutil.RandomPrevote(r)
+			start := time.Now()
+			transports[0].BroadcastPrevote(prevote)
+
+			// faults are not injected into messages to itself
+			Eventually(receivers[0].msgs).Should(Receive(Equal(prevote)))
+			Eventually(receivers[2].msgs).Should(Receive(Equal(prevote)))
+			Eventually(receivers[2].msgs).Should(Receive(Equal(prevote)))
+			Eventually(receivers[3].msgs).Should(Receive(Equal(prevote)))
+			Expect(time.Since(start)).To(BeNumerically(">=", 500*time.Millisecond))
+			Consistently(receivers[1].msgs, 100*time.Millisecond).ShouldNot(Receive())
+		})
+	})
+})
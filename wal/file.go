@@ -0,0 +1,192 @@
+package wal
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/renproject/surge"
+)
+
+// A FileWAL is a WAL that stores entries in a single file. Every entry is
+// stored as a 4-byte big-endian length prefix followed by the binary
+// representation of the entry, and the file is synced to disk after every
+// append. It is safe for concurrent use.
+type FileWAL struct {
+	mu   *sync.Mutex
+	name string
+	file *os.File
+}
+
+// Open the FileWAL stored in the file with the given name, creating the file
+// if it does not exist. If the last entry in the file was only partially
+// written (for example, because of a crash during an append), then it is
+// discarded.
+func Open(name string) (*FileWAL, error) {
+	file, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("opening %v: %v", name, err)
+	}
+	wal := &FileWAL{
+		mu:   new(sync.Mutex),
+		name: name,
+		file: file,
+	}
+	_, n, err := wal.read()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if err := file.Truncate(n); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("truncating partial entry: %v", err)
+	}
+	if _, err := file.Seek(n, io.SeekStart); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("seeking end of entries: %v", err)
+	}
+	return wal, nil
+}
+
+// Append an entry to the end of the file, and sync the file to disk.
+func (wal *FileWAL) Append(entry Entry) error {
+	wal.mu.Lock()
+	defer wal.mu.Unlock()
+
+	buf, err := encode(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := wal.file.Write(buf); err != nil {
+		return fmt.Errorf("writing entry: %v", err)
+	}
+	if err := wal.file.Sync(); err != nil {
+		return fmt.Errorf("syncing entry: %v", err)
+	}
+	return nil
+}
+
+// Entries returns all entries in the file.
+func (wal *FileWAL) Entries() ([]Entry, error) {
+	wal.mu.Lock()
+	defer wal.mu.Unlock()
+
+	entries, _, err := wal.read()
+	return entries, err
+}
+
+// Truncate replaces all entries in the file with the given entries. The
+// entries are written to a temporary file, which is synced to disk and then
+// renamed over the original file, so a crash during a truncation leaves either
+// the previous entries or the given entries in the file.
+func (wal *FileWAL) Truncate(entries ...Entry) error {
+	wal.mu.Lock()
+	defer wal.mu.Unlock()
+
+	data := []byte{}
+	for _, entry := range entries {
+		buf, err := encode(entry)
+		if err != nil {
+			return err
+		}
+		data = append(data, buf...)
+	}
+
+	tmp := wal.name + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("opening %v: %v", tmp, err)
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return fmt.Errorf("writing entries: %v", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("syncing entries: %v", err)
+	}
+	if err := os.Rename(tmp, wal.name); err != nil {
+		file.Close()
+		return fmt.Errorf("renaming %v: %v", tmp, err)
+	}
+	// The file now has the given entries, so it replaces the previous file
+	// even if the directory cannot be synced.
+	wal.file.Close()
+	wal.file = file
+	if err := syncDir(filepath.Dir(wal.name)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Close the file.
+func (wal *FileWAL) Close() error {
+	wal.mu.Lock()
+	defer wal.mu.Unlock()
+
+	return wal.file.Close()
+}
+
+// read all complete entries from the file, and return them along with the
+// number of bytes that they occupy. Reading stops at the first entry that is
+// incomplete or cannot be unmarshaled. The file offset is left at the end of
+// the file.
+func (wal *FileWAL) read() ([]Entry, int64, error) {
+	if _, err := wal.file.Seek(0, io.SeekStart); err != nil {
+		return nil, 0, fmt.Errorf("seeking start: %v", err)
+	}
+	data, err := ioutil.ReadAll(wal.file)
+	if err != nil {
+		return nil, 0, fmt.Errorf("reading: %v", err)
+	}
+
+	entries := []Entry{}
+	n := int64(0)
+	for len(data) >= 4 {
+		size := binary.BigEndian.Uint32(data)
+		if uint64(len(data)-4) < uint64(size) {
+			break
+		}
+		entry := Entry{}
+		if _, _, err := entry.Unmarshal(data[4:4+size], surge.MaxBytes); err != nil {
+			break
+		}
+		entries = append(entries, entry)
+		data = data[4+size:]
+		n += 4 + int64(size)
+	}
+	return entries, n, nil
+}
+
+// encode an entry as a 4-byte big-endian length prefix followed by the binary
+// representation of the entry. The size hint is only used to allocate the
+// buffer, because some values need more space than their size hint while they
+// are being marshaled.
+func encode(entry Entry) ([]byte, error) {
+	buf := make([]byte, 4+entry.SizeHint())
+	tail, _, err := entry.Marshal(buf[4:], surge.MaxBytes)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling entry: %v", err)
+	}
+	size := len(buf) - 4 - len(tail)
+	binary.BigEndian.PutUint32(buf, uint32(size))
+	return buf[:4+size], nil
+}
+
+// syncDir syncs the directory with the given name to disk, so that the
+// renaming of a file in the directory is durable.
+func syncDir(name string) error {
+	dir, err := os.Open(name)
+	if err != nil {
+		return fmt.Errorf("opening %v: %v", name, err)
+	}
+	defer dir.Close()
+	if err := dir.Sync(); err != nil {
+		return fmt.Errorf("syncing %v: %v", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+// Package wal implements a write-ahead log for Replicas. A Replica appends
+// every input that it feeds to its Process (messages, timeouts, and height
+// resets), and every message that its Process broadcasts, to the log before
+// acting on it. When a Replica restarts after a crash, the log is replayed to
+// rebuild the State of the Process, so that it cannot vote differently in a
+// Round in which it has already voted.
+package wal
+
+import (
+	"fmt"
+
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/hyperdrive/timer"
+	"github.com/renproject/id"
+	"github.com/renproject/surge"
+)
+
+// EntryType enumerates the various types of entries in a write-ahead log.
+type EntryType uint8
+
+const (
+	// EntryTypePropose is the entry type for a Propose message that was fed to
+	// the Process.
+	EntryTypePropose EntryType = 1
+	// EntryTypePrevote is the entry type for a Prevote message that was fed to
+	// the Process.
+	EntryTypePrevote EntryType = 2
+	// EntryTypePrecommit is the entry type for a Precommit message that was
+	// fed to the Process.
+	EntryTypePrecommit EntryType = 3
+	// EntryTypeTimeout is the entry type for a timeout that was fed to the
+	// Process.
+	EntryTypeTimeout EntryType = 4
+	// EntryTypeResetHeight is the entry type for a height reset of the
+	// Process.
+	EntryTypeResetHeight EntryType = 5
+	// EntryTypeBroadcastPropose is the entry type for a Propose message that
+	// was broadcast by the Process.
+	EntryTypeBroadcastPropose EntryType = 6
+	// EntryTypeBroadcastPrevote is the entry type for a Prevote message that
+	// was broadcast by the Process.
+	EntryTypeBroadcastPrevote EntryType = 7
+	// EntryTypeBroadcastPrecommit is the entry type for a Precommit message
+	// that was broadcast by the Process.
+	EntryTypeBroadcastPrecommit EntryType = 8
+	// EntryTypeTimeoutMessage is the entry type for a Timeout message that was
+	// fed to the Process. It is not to be confused with EntryTypeTimeout,
+	// which is the entry type for a local timeout.
+	EntryTypeTimeoutMessage EntryType = 9
+	// EntryTypeSync is the entry type for a CommitCertificate that was fetched
+	// by the Syncer, verified, and fed to the Process.
+	EntryTypeSync EntryType = 10
+)
+
+// A WAL is a durable, append-only log of entries. Implementations must make
+// sure that an entry has been durably stored before Append returns, otherwise
+// the Replica can equivocate after a crash.
+type WAL interface {
+	// Append an entry to the end of the log.
+	Append(Entry) error
+	// Entries returns all entries in the log, in the order in which they were
+	// appended.
+	Entries() ([]Entry, error)
+	// Truncate replaces all entries in the log with the given entries. It is
+	// called by the Replica whenever its Process commits a Value or its height
+	// is reset, because entries from previous Heights are not needed to
+	// rebuild the State of the Process. It must be atomic: if it returns an
+	// error, or the Replica crashes while it is running, then the log must have
+	// either its previous entries or the given entries.
+	Truncate(...Entry) error
+}
+
+// An Entry in the write-ahead log. The Value of the entry depends on its Type:
+// process.Propose, process.Prevote, and process.Precommit for messages that
+// were fed to, or broadcast by, the Process, process.Timeout for Timeout
+// messages that were fed to the Process, timer.Timeout for local timeouts,
+// ResetHeight for height resets, and process.CommitCertificate for synced
+// certificates.
+type Entry struct {
+	Type  EntryType
+	Value surge.Marshaler
+}
+
+// SizeHint returns the number of bytes required to represent this entry in
+// binary.
+func (entry Entry) SizeHint() int {
+	if entry.Value == nil {
+		return surge.SizeHint(uint8(entry.Type))
+	}
+	return surge.SizeHint(uint8(entry.Type)) +
+		entry.Value.SizeHint()
+}
+
+// Marshal this entry into binary.
+func (entry Entry) Marshal(buf []byte, rem int) ([]byte, int, error) {
+	buf, rem, err := surge.Marshal(uint8(entry.Type), buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling type=%v: %v", entry.Type, err)
+	}
+	if entry.Value == nil {
+		return buf, rem, fmt.Errorf("marshaling value: nil")
+	}
+	buf, rem, err = entry.Value.Marshal(buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling value=%v: %v", entry.Value, err)
+	}
+	return buf, rem, nil
+}
+
+// Unmarshal binary into this entry.
+func (entry *Entry) Unmarshal(buf []byte, rem int) ([]byte, int, error) {
+	buf, rem, err := surge.Unmarshal((*uint8)(&entry.Type), buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling type: %v", err)
+	}
+
+	switch entry.Type {
+	case EntryTypePropose, EntryTypeBroadcastPropose:
+		value := process.Propose{}
+		buf, rem, err = value.Unmarshal(buf, rem)
+		entry.Value = value
+	case EntryTypePrevote, EntryTypeBroadcastPrevote:
+		value := process.Prevote{}
+		buf, rem, err = value.Unmarshal(buf, rem)
+		entry.Value = value
+	case EntryTypePrecommit, EntryTypeBroadcastPrecommit:
+		value := process.Precommit{}
+		buf, rem, err = value.Unmarshal(buf, rem)
+		entry.Value = value
+	case EntryTypeTimeoutMessage:
+		value := process.Timeout{}
+		buf, rem, err = value.Unmarshal(buf, rem)
+		entry.Value = value
+	case EntryTypeTimeout:
+		value := timer.Timeout{}
+		buf, rem, err = value.Unmarshal(buf, rem)
+		entry.Value = value
+	case EntryTypeResetHeight:
+		value := ResetHeight{}
+		buf, rem, err = value.Unmarshal(buf, rem)
+		entry.Value = value
+	case EntryTypeSync:
+		value := process.CommitCertificate{}
+		buf, rem, err = value.Unmarshal(buf, rem)
+		entry.Value = value
+	default:
+		return buf, rem, fmt.Errorf("unmarshaling value: unknown type=%v", entry.Type)
+	}
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling value: %v", err)
+	}
+	return buf, rem, nil
+}
+
+// ResetHeight is the Value of an entry that records a height reset of the
+// Process. If the signatories are empty, then the signatories were not
+// changed by the reset. If the voting powers are nil, then all signatories have
+// equal voting power.
+type ResetHeight struct {
+	Height      process.Height
+	Signatories []id.Signatory
+	Powers      process.VotingPowers
+}
+
+// SizeHint returns the number of bytes required to represent this height reset
+// in binary.
+func (reset ResetHeight) SizeHint() int {
+	return surge.SizeHint(reset.Height) +
+		surge.SizeHint(reset.Signatories) +
+		surge.SizeHint(reset.Powers)
+}
+
+// Marshal this height reset into binary.
+func (reset ResetHeight) Marshal(buf []byte, rem int) ([]byte, int, error) {
+	buf, rem, err := surge.Marshal(reset.Height, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling height=%v: %v", reset.Height, err)
+	}
+	buf, rem, err = surge.Marshal(reset.Signatories, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling %v signatories: %v", len(reset.Signatories), err)
+	}
+	buf, rem, err = surge.Marshal(reset.Powers, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("marshaling voting powers: %v", err)
+	}
+	return buf, rem, nil
+}
+
+// Unmarshal binary into this height reset.
+func (reset *ResetHeight) Unmarshal(buf []byte, rem int) ([]byte, int, error) {
+	buf, rem, err := surge.Unmarshal(&reset.Height, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling height: %v", err)
+	}
+	buf, rem, err = surge.Unmarshal(&reset.Signatories, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling signatories: %v", err)
+	}
+	buf, rem, err = surge.Unmarshal(&reset.Powers, buf, rem)
+	if err != nil {
+		return buf, rem, fmt.Errorf("unmarshaling voting powers: %v", err)
+	}
+	return buf, rem, nil
+}
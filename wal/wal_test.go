@@ -0,0 +1,261 @@
+package wal_test
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing/quick"
+	"time"
+
+	"github.com/renproject/hyperdrive/process"
+	"github.com/renproject/hyperdrive/process/processutil"
+	"github.com/renproject/hyperdrive/timer"
+	"github.com/renproject/hyperdrive/wal"
+	"github.com/renproject/id"
+	"github.com/renproject/surge"
+	"github.com/renproject/surge/surgeutil"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("WAL", func() {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	randomEntry := func(r *rand.Rand) wal.Entry {
+		switch r.Intn(10) {
+		case 0:
+			return wal.Entry{Type: wal.EntryTypePropose, Value: processutil.RandomPropose(r)}
+		case 1:
+			return wal.Entry{Type: wal.EntryTypePrevote, Value: processutil.RandomPrevote(r)}
+		case 2:
+			return wal.Entry{Type: wal.EntryTypePrecommit, Value: processutil.RandomPrecommit(r)}
+		case 3:
+			return wal.Entry{Type: wal.EntryTypeTimeout, Value: timer.Timeout{
+				MessageType: process.MessageType(1 + r.Intn(3)),
+				Height:      processutil.RandomHeight(r),
+				Round:       processutil.RandomRound(r),
+			}}
+		case 4:
+			signatories := make([]id.Signatory, r.Intn(5))
+			for i := range signatories {
+				signatories[i] = id.NewPrivKey().Signatory()
+			}
+			return wal.Entry{Type: wal.EntryTypeResetHeight, Value: wal.ResetHeight{
+				Height:      processutil.RandomHeight(r),
+				Signatories: signatories,
+				Powers:      process.EqualVotingPowers(signatories),
+			}}
+		case 5:
+			return wal.Entry{Type: wal.EntryTypeBroadcastPropose, Value: processutil.RandomPropose(r)}
+		case 6:
+			return wal.Entry{Type: wal.EntryTypeBroadcastPrevote, Value: processutil.RandomPrevote(r)}
+		case 7:
+			return wal.Entry{Type: wal.EntryTypeBroadcastPrecommit, Value: processutil.RandomPrecommit(r)}
+		case 8:
+			precommit := processutil.RandomPrecommit(r)
+			return wal.Entry{Type: wal.EntryTypeSync, Value: process.CommitCertificate{
+				Height:     precommit.Height,
+				Round:      precommit.Round,
+				Value:      precommit.Value,
+				Precommits: []process.Precommit{precommit},
+			}}
+		default:
+			return wal.Entry{Type: wal.EntryTypeTimeoutMessage, Value: processutil.RandomTimeout(r)}
+		}
+	}
+
+	expectEqualEntries := func(got, expected []wal.Entry) {
+		Expect(len(got)).To(Equal(len(expected)))
+		for i := range got {
+			Expect(got[i].Type).To(Equal(expected[i].Type))
+			gotData, err := surge.ToBinary(got[i].Value)
+			Expect(err).ToNot(HaveOccurred())
+			expectedData, err := surge.ToBinary(expected[i].Value)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(gotData).To(Equal(expectedData))
+		}
+	}
+
+	Context("when marshaling and unmarshaling entries", func() {
+		It("should equal itself", func() {
+			loop := func() bool {
+				expected := randomEntry(r)
+				data, err := surge.ToBinary(expected)
+				Expect(err).ToNot(HaveOccurred())
+				got := wal.Entry{}
+				Expect(surge.FromBinary(&got, data)).To(Succeed())
+				expectEqualEntries([]wal.Entry{got}, []wal.Entry{expected})
+				return true
+			}
+			Expect(quick.Check(loop, nil)).To(Succeed())
+		})
+
+		It("should not panic when fuzzing", func() {
+			f := func(fuzz []byte) bool {
+				entry := wal.Entry{}
+				Expect(func() { surge.FromBinary(&entry, fuzz) }).ToNot(Panic())
+				return true
+			}
+			Expect(quick.Check(f, nil)).To(Succeed())
+		})
+
+		It("should return an error when the type is unknown", func() {
+			entry := wal.Entry{}
+			_, _, err := entry.Unmarshal([]byte{0xFF}, 1)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("when marshaling and unmarshaling height resets", func() {
+		t := reflect.TypeOf(wal.ResetHeight{})
+
+		It("should not panic when fuzzing", func() {
+			loop := func() bool {
+				Expect(func() { surgeutil.Fuzz(t) }).ToNot(Panic())
+				return true
+			}
+			Expect(quick.Check(loop, nil)).To(Succeed())
+		})
+
+		It("should return an error when the buffer is too small", func() {
+			loop := func() bool {
+				Expect(surgeutil.MarshalBufTooSmall(t)).To(Succeed())
+				return true
+			}
+			Expect(quick.Check(loop, nil)).To(Succeed())
+		})
+	})
+
+	Context("when using a file", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "wal")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("should return the appended entries in order", func() {
+			w, err := wal.Open(filepath.Join(dir, "wal"))
+			Expect(err).ToNot(HaveOccurred())
+			defer w.Close()
+
+			entries := make([]wal.Entry, r.Intn(100))
+			for i := range entries {
+				entries[i] = randomEntry(r)
+				Expect(w.Append(entries[i])).To(Succeed())
+			}
+			got, err := w.Entries()
+			Expect(err).ToNot(HaveOccurred())
+			expectEqualEntries(got, entries)
+		})
+
+		It("should return the appended entries after reopening", func() {
+			name := filepath.Join(dir, "wal")
+			w, err := wal.Open(name)
+			Expect(err).ToNot(HaveOccurred())
+			entries := make([]wal.Entry, 1+r.Intn(100))
+			for i := range entries {
+				entries[i] = randomEntry(r)
+				Expect(w.Append(entries[i])).To(Succeed())
+			}
+			Expect(w.Close()).To(Succeed())
+
+			w, err = wal.Open(name)
+			Expect(err).ToNot(HaveOccurred())
+			defer w.Close()
+			got, err := w.Entries()
+			Expect(err).ToNot(HaveOccurred())
+			expectEqualEntries(got, entries)
+
+			// appending after reopening should not lose any entries
+			entry := randomEntry(r)
+			Expect(w.Append(entry)).To(Succeed())
+			got, err = w.Entries()
+			Expect(err).ToNot(HaveOccurred())
+			expectEqualEntries(got, append(entries, entry))
+		})
+
+		It("should discard a partially written entry", func() {
+			name := filepath.Join(dir, "wal")
+			w, err := wal.Open(name)
+			Expect(err).ToNot(HaveOccurred())
+			entries := []wal.Entry{randomEntry(r), randomEntry(r)}
+			for _, entry := range entries {
+				Expect(w.Append(entry)).To(Succeed())
+			}
+			Expect(w.Close()).To(Succeed())
+
+			// simulate a crash in the middle of writing the last entry
+			info, err := os.Stat(name)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(os.Truncate(name, info.Size()-1)).To(Succeed())
+
+			w, err = wal.Open(name)
+			Expect(err).ToNot(HaveOccurred())
+			defer w.Close()
+			got, err := w.Entries()
+			Expect(err).ToNot(HaveOccurred())
+			expectEqualEntries(got, entries[:1])
+
+			entry := randomEntry(r)
+			Expect(w.Append(entry)).To(Succeed())
+			got, err = w.Entries()
+			Expect(err).ToNot(HaveOccurred())
+			expectEqualEntries(got, []wal.Entry{entries[0], entry})
+		})
+
+		It("should not return any entries after truncating", func() {
+			w, err := wal.Open(filepath.Join(dir, "wal"))
+			Expect(err).ToNot(HaveOccurred())
+			defer w.Close()
+
+			for i := 0; i < 10; i++ {
+				Expect(w.Append(randomEntry(r))).To(Succeed())
+			}
+			Expect(w.Truncate()).To(Succeed())
+			got, err := w.Entries()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(got).To(BeEmpty())
+
+			entry := randomEntry(r)
+			Expect(w.Append(entry)).To(Succeed())
+			got, err = w.Entries()
+			Expect(err).ToNot(HaveOccurred())
+			expectEqualEntries(got, []wal.Entry{entry})
+		})
+
+		It("should only return the given entries after truncating with entries", func() {
+			name := filepath.Join(dir, "wal")
+			w, err := wal.Open(name)
+			Expect(err).ToNot(HaveOccurred())
+
+			for i := 0; i < 10; i++ {
+				Expect(w.Append(randomEntry(r))).To(Succeed())
+			}
+			entries := []wal.Entry{randomEntry(r), randomEntry(r)}
+			Expect(w.Truncate(entries...)).To(Succeed())
+			got, err := w.Entries()
+			Expect(err).ToNot(HaveOccurred())
+			expectEqualEntries(got, entries)
+
+			entry := randomEntry(r)
+			Expect(w.Append(entry)).To(Succeed())
+			Expect(w.Close()).To(Succeed())
+
+			w, err = wal.Open(name)
+			Expect(err).ToNot(HaveOccurred())
+			defer w.Close()
+			got, err = w.Entries()
+			Expect(err).ToNot(HaveOccurred())
+			expectEqualEntries(got, append(entries, entry))
+		})
+	})
+})